@@ -56,7 +56,11 @@ func main() {
 
 	// Setup router
 	router := api.NewRouter(db, rdb, cfg)
-	handler := router.Setup()
+	handler, err := router.Setup()
+	if err != nil {
+		slog.Error("failed to set up router", "error", err)
+		os.Exit(1)
+	}
 
 	srv := &http.Server{
 		Addr:         cfg.Addr(),
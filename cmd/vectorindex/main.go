@@ -0,0 +1,112 @@
+// Command vectorindex manages the vector indexes of document_chunks as the
+// operator. Global indexes serve every tenant, so they are built and
+// dropped here rather than through a tenant's API.
+//
+//	vectorindex list
+//	vectorindex build [-method hnsw|ivfflat] [-m N] [-ef-construction N] [-lists N] [-tenant ID]
+//	vectorindex drop NAME
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/internal/database"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+const usage = `usage:
+  vectorindex list
+  vectorindex build [-method hnsw|ivfflat] [-m N] [-ef-construction N] [-lists N] [-tenant ID]
+  vectorindex drop NAME`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	db, err := database.NewPool(ctx, cfg.Database)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+	manager := vectorstore.NewPgIndexManager(db)
+
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "list":
+		err = list(ctx, manager)
+	case "build":
+		err = build(ctx, manager, args)
+	case "drop":
+		if len(args) != 1 {
+			err = fmt.Errorf("drop takes the index name\n%s", usage)
+			break
+		}
+		err = manager.DropIndex(ctx, args[0])
+	default:
+		err = fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+	if err != nil {
+		slog.Error("vectorindex failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+func list(ctx context.Context, manager *vectorstore.PgIndexManager) error {
+	indexes, err := manager.ListIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(indexes)
+}
+
+// build builds the index synchronously, replacing the one of the same
+// scope, and reports progress as it goes.
+func build(ctx context.Context, manager *vectorstore.PgIndexManager, args []string) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	var spec vectorstore.IndexSpec
+	fs.StringVar(&spec.Method, "method", vectorstore.IndexHNSW, "hnsw or ivfflat")
+	fs.IntVar(&spec.M, "m", 0, "HNSW connections per node (default 16)")
+	fs.IntVar(&spec.EfConstruction, "ef-construction", 0, "HNSW build candidate list size (default 64)")
+	fs.IntVar(&spec.Lists, "lists", 0, "IVFFlat cluster count (default from the row count)")
+	tenantID := fs.String("tenant", "", "build a partial index over this tenant's chunks only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *tenantID != "" {
+		id, err := uuid.Parse(*tenantID)
+		if err != nil {
+			return fmt.Errorf("invalid tenant ID: %w", err)
+		}
+		spec.TenantID = &id
+	}
+
+	idx, err := manager.BuildIndex(ctx, spec, func(phase string, percent int) {
+		slog.Info("building vector index", "name", spec.Name(), "phase", phase, "progress", percent)
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("built vector index", "name", idx.Name, "definition", idx.Definition, "size_bytes", idx.SizeBytes)
+	return nil
+}
@@ -1,13 +1,28 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 
 	"github.com/hibiken/asynq"
 	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/internal/connector"
+	"github.com/nikhilbhutani/backendwithai/internal/database"
+	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/embedding"
+	"github.com/nikhilbhutani/backendwithai/internal/evalset"
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
 	"github.com/nikhilbhutani/backendwithai/internal/queue"
 	"github.com/nikhilbhutani/backendwithai/internal/queue/workers"
+	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/rag/indexing"
+	"github.com/nikhilbhutani/backendwithai/internal/retrievaleval"
+	"github.com/nikhilbhutani/backendwithai/internal/storage"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/upload"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorindex"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
 )
 
 func main() {
@@ -20,12 +35,13 @@ func main() {
 		os.Exit(1)
 	}
 
+	redisOpt := asynq.RedisClientOpt{
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+	}
 	srv := asynq.NewServer(
-		asynq.RedisClientOpt{
-			Addr:     cfg.Redis.Addr,
-			Password: cfg.Redis.Password,
-			DB:       cfg.Redis.DB,
-		},
+		redisOpt,
 		asynq.Config{
 			Concurrency: 10,
 			Queues: map[string]int{
@@ -43,6 +59,85 @@ func main() {
 
 	registry.Register(queue.TypeFinetuneRun, asynq.HandlerFunc(finetuneWorker.ProcessTask))
 
+	db, err := database.NewPool(context.Background(), cfg.Database)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	queueClient := queue.NewClient(cfg.Redis)
+	defer queueClient.Close()
+
+	vectorIndexWorker := workers.NewVectorIndexWorker(vectorindex.NewService(db, queueClient))
+	registry.Register(queue.TypeVectorIndexBuild, asynq.HandlerFunc(vectorIndexWorker.ProcessTask))
+
+	gateway := llm.NewGateway(cfg.LLM)
+	// A memory store lives in one process: what the worker indexed would
+	// never reach the API's store, and both would rewrite a shared file.
+	if cfg.Vector.Backend == "memory" {
+		slog.Error("the memory vector store is single-process and can't be shared with the API; use pgvector or qdrant")
+		os.Exit(1)
+	}
+	vs, err := vectorstore.NewFromConfig(cfg.Vector, db)
+	if err != nil {
+		slog.Error("failed to open vector store", "error", err)
+		os.Exit(1)
+	}
+	users := tenant.NewService(db)
+	collections := rag.NewPgCollectionStore(db)
+	embedSvc := embedding.NewService(gateway, "")
+
+	// Graph indexing queues community rebuilds as separate, coalesced
+	// jobs rather than summarising on every ingest. It needs pgvector.
+	pipelineOpts := rag.PipelineOptions{Collections: collections}
+	if graph := vectorstore.GraphStoreFromConfig(cfg.Vector, db); graph != nil {
+		graphIndexer := indexing.NewGraphIndexer(vs, graph, embedSvc, gateway, "")
+		graphWorker := workers.NewGraphWorker(graphIndexer, queueClient)
+		registry.Register(queue.TypeGraphCommunityBuild, asynq.HandlerFunc(graphWorker.ProcessTask))
+		pipelineOpts.Graph = graph
+		pipelineOpts.GraphIndexer = graphIndexer.WithScheduler(graphWorker)
+	}
+
+	ragPipeline := rag.NewPipelineWithOptions(vs, embedSvc, gateway, pipelineOpts)
+
+	// Uploads: extract text (OCRing images and scanned pages), then chunk,
+	// embed and index it.
+	store := storage.NewFromConfig(cfg.Storage)
+	docSvc := document.NewService(db, store, cfg.Storage.Bucket)
+	extractor := document.NewTextExtractor(document.NewOCRService(cfg.OCR))
+	documentWorker := workers.NewDocumentWorker(docSvc, store, cfg.Storage.Bucket, extractor, queueClient)
+	registry.Register(queue.TypeDocumentProcess, asynq.HandlerFunc(documentWorker.ProcessTask))
+	embeddingWorker := workers.NewEmbeddingWorker(docSvc, ragPipeline, collections, vs)
+	registry.Register(queue.TypeEmbeddingGenerate, asynq.HandlerFunc(embeddingWorker.ProcessTask))
+
+	retrievalEvalSvc := retrievaleval.NewService(db, queueClient, ragPipeline, users)
+	retrievalEvalWorker := workers.NewRetrievalEvalWorker(retrievalEvalSvc)
+	registry.Register(queue.TypeRetrievalEval, asynq.HandlerFunc(retrievalEvalWorker.ProcessTask))
+
+	evalDatasetSvc := evalset.NewService(db, queueClient, vs, ragPipeline, gateway, users)
+	evalDatasetWorker := workers.NewEvalDatasetWorker(evalDatasetSvc)
+	registry.Register(queue.TypeEvalDatasetGenerate, asynq.HandlerFunc(evalDatasetWorker.ProcessTask))
+
+	// Archive batches: one document per supported file.
+	batchWorker := workers.NewBatchWorker(upload.NewService(db, docSvc, store, cfg.Storage.Bucket, queueClient, users, cfg.Upload))
+	registry.Register(queue.TypeDocumentBatchExpand, asynq.HandlerFunc(batchWorker.ProcessTask))
+
+	// Connectors: every minute, queue the syncs of sources that are due.
+	connectorWorker := workers.NewConnectorWorker(connector.NewService(db, docSvc, vs, queueClient, cfg.Connector))
+	registry.Register(queue.TypeConnectorSync, asynq.HandlerFunc(connectorWorker.ProcessTask))
+	registry.Register(queue.TypeConnectorSchedule, asynq.HandlerFunc(connectorWorker.ScheduleTask))
+	scheduler := asynq.NewScheduler(redisOpt, nil)
+	if _, err := scheduler.Register("@every 1m", asynq.NewTask(queue.TypeConnectorSchedule, nil), asynq.MaxRetry(0)); err != nil {
+		slog.Error("failed to schedule connector syncs", "error", err)
+		os.Exit(1)
+	}
+	if err := scheduler.Start(); err != nil {
+		slog.Error("failed to start scheduler", "error", err)
+		os.Exit(1)
+	}
+	defer scheduler.Shutdown()
+
 	slog.Info("starting worker", "concurrency", 10)
 	if err := srv.Run(registry.Mux()); err != nil {
 		slog.Error("worker error", "error", err)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/nikhilbhutani/backendwithai/internal/llm"
 	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
 )
 
 // Tool is something an agent can invoke during its reasoning loop.
@@ -33,7 +34,9 @@ func (t *CalculatorTool) Execute(ctx context.Context, input string) (string, err
 	return fmt.Sprintf("Result of '%s' — use your own math ability to compute this.", input), nil
 }
 
-// RAGSearchTool searches the knowledge base via the RAG pipeline.
+// RAGSearchTool searches the knowledge base via the RAG pipeline. Results
+// are limited to the context's tenant and to documents the user in the
+// context may read; without a tenant the tool refuses to search.
 type RAGSearchTool struct {
 	pipeline rag.Pipeline
 }
@@ -46,6 +49,9 @@ func (t *RAGSearchTool) Name() string        { return "search_knowledge_base" }
 func (t *RAGSearchTool) Description() string  { return "Search the internal knowledge base for relevant information. Input: your search query" }
 
 func (t *RAGSearchTool) Execute(ctx context.Context, input string) (string, error) {
+	if _, err := tenant.RequireID(ctx); err != nil {
+		return "", fmt.Errorf("search: %w", err)
+	}
 	results, err := t.pipeline.Search(ctx, rag.SearchRequest{
 		Query: input,
 		TopK:  5,
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+type CollectionHandler struct {
+	collections rag.CollectionStore
+	store       vectorstore.VectorStore
+	queue       *queue.Client
+}
+
+func NewCollectionHandler(collections rag.CollectionStore, store vectorstore.VectorStore, qc *queue.Client) *CollectionHandler {
+	return &CollectionHandler{collections: collections, store: store, queue: qc}
+}
+
+func (h *CollectionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var c models.Collection
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := rag.ValidateCollection(&c); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	created, err := h.collections.Create(r.Context(), &c)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *CollectionHandler) List(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.collections.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collections": collections, "count": len(collections)})
+}
+
+func (h *CollectionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := collectionID(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.collections.Get(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "collection not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c)
+}
+
+// Update replaces the collection's settings. Documents already in the
+// collection keep their chunks until they are re-added.
+func (h *CollectionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := collectionID(w, r)
+	if !ok {
+		return
+	}
+
+	var c models.Collection
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := rag.ValidateCollection(&c); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	c.ID = id
+
+	updated, err := h.collections.Update(r.Context(), &c)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "collection not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func (h *CollectionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := collectionID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.collections.Delete(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "collection not found"})
+		return
+	}
+	// Postgres cascades the delete to the chunks; other stores do not.
+	if err := h.store.Delete(r.Context(), vectorstore.DeleteFilter{
+		TenantID:     tenant.IDFromContext(r.Context()),
+		CollectionID: id,
+	}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// AddDocuments adds documents to the collection and queues their indexing
+// with the collection's settings.
+func (h *CollectionHandler) AddDocuments(w http.ResponseWriter, r *http.Request) {
+	id, ok := collectionID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DocumentIDs []uuid.UUID `json:"document_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if len(req.DocumentIDs) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "document_ids required"})
+		return
+	}
+
+	if _, err := h.collections.Get(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "collection not found"})
+		return
+	}
+
+	added, err := h.collections.AddDocuments(r.Context(), id, req.DocumentIDs)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	tenantID := tenant.IDFromContext(r.Context())
+	for _, docID := range added {
+		if err := h.queue.EnqueueEmbeddingGenerate(queue.EmbeddingGeneratePayload{
+			DocumentID:    docID.String(),
+			TenantID:      tenantID.String(),
+			CollectionIDs: []string{id.String()},
+		}); err != nil {
+			slog.Error("failed to enqueue collection indexing", "collection_id", id, "document_id", docID, "error", err)
+		}
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"added": added, "count": len(added)})
+}
+
+func (h *CollectionHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	id, ok := collectionID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.collections.Get(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "collection not found"})
+		return
+	}
+
+	docIDs, err := h.collections.ListDocuments(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"document_ids": docIDs, "count": len(docIDs)})
+}
+
+// RemoveDocument removes a document from the collection along with the
+// chunks indexed for it there.
+func (h *CollectionHandler) RemoveDocument(w http.ResponseWriter, r *http.Request) {
+	id, ok := collectionID(w, r)
+	if !ok {
+		return
+	}
+	docID, err := uuid.Parse(chi.URLParam(r, "docID"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid document ID"})
+		return
+	}
+
+	if err := h.collections.RemoveDocument(r.Context(), id, docID); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := h.store.Delete(r.Context(), vectorstore.DeleteFilter{
+		TenantID:     tenant.IDFromContext(r.Context()),
+		DocumentID:   docID,
+		CollectionID: id,
+	}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+func collectionID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid collection ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikhilbhutani/backendwithai/internal/connector"
+)
+
+type ConnectorHandler struct {
+	svc *connector.Service
+}
+
+func NewConnectorHandler(svc *connector.Service) *ConnectorHandler {
+	return &ConnectorHandler{svc: svc}
+}
+
+// writeConnectorError maps the connector service's errors to responses.
+func writeConnectorError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, connector.ErrInvalidSource):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, connector.ErrSourceExists):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "connector source not found"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// Create saves a source; it is first synced within a minute.
+func (h *ConnectorHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req connector.SourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	src, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, src)
+}
+
+func (h *ConnectorHandler) List(w http.ResponseWriter, r *http.Request) {
+	sources, err := h.svc.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sources": sources, "count": len(sources)})
+}
+
+func (h *ConnectorHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid source ID"})
+		return
+	}
+
+	src, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, src)
+}
+
+func (h *ConnectorHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid source ID"})
+		return
+	}
+	var req connector.SourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	src, err := h.svc.Update(r.Context(), id, req)
+	if err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, src)
+}
+
+// Delete removes a source, and its synced documents when
+// delete_documents=true.
+func (h *ConnectorHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid source ID"})
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id, r.URL.Query().Get("delete_documents") == "true"); err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// Sync queues a sync of the source now.
+func (h *ConnectorHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid source ID"})
+		return
+	}
+
+	if err := h.svc.RequestSync(r.Context(), id); err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+}
+
+func (h *ConnectorHandler) Syncs(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid source ID"})
+		return
+	}
+
+	syncs, err := h.svc.Syncs(r.Context(), id)
+	if err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"syncs": syncs, "count": len(syncs)})
+}
+
+// Items lists the source's files as of its last sync, with the document
+// each was synced to.
+func (h *ConnectorHandler) Items(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid source ID"})
+		return
+	}
+
+	items, err := h.svc.Items(r.Context(), id)
+	if err != nil {
+		writeConnectorError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
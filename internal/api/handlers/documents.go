@@ -1,22 +1,45 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+	"github.com/nikhilbhutani/backendwithai/pkg/chunker"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
 )
 
 type DocumentHandler struct {
-	svc *document.Service
+	svc   *document.Service
+	store vectorstore.VectorStore
+	queue *queue.Client
 }
 
-func NewDocumentHandler(svc *document.Service) *DocumentHandler {
-	return &DocumentHandler{svc: svc}
+func NewDocumentHandler(svc *document.Service, store vectorstore.VectorStore, qc *queue.Client) *DocumentHandler {
+	return &DocumentHandler{svc: svc, store: store, queue: qc}
 }
 
+// Upload stores a file and queues it for extraction and indexing. The format
+// is detected from the file's content, type and name; files that can't be
+// extracted are rejected. Optional form fields choose how it is indexed:
+// index_type, context_headers and chunk_opts (JSON). An external_id already
+// used makes the upload that document's next version, which replaces the
+// older ones in retrieval once indexed. Poll the status endpoint until it
+// is ready or failed.
 func (h *DocumentHandler) Upload(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(32 << 20); err != nil { // 32MB max
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid multipart form"})
@@ -35,20 +58,88 @@ func (h *DocumentHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		title = header.Filename
 	}
 
+	var acl models.DocumentACL
+	if raw := r.FormValue("acl"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &acl); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid acl"})
+			return
+		}
+	}
+
+	opts, err := ingestOptions(r.FormValue)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	format := textextract.Detect(file, header.Size, header.Header.Get("Content-Type"), header.Filename)
+	if format == "" {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{
+			"error": fmt.Sprintf("unsupported file type; supported: %s", strings.Join(textextract.SupportedTypes(), ", ")),
+		})
+		return
+	}
+
 	doc, err := h.svc.Upload(r.Context(), document.UploadRequest{
-		Title:    title,
-		FileType: header.Header.Get("Content-Type"),
-		FileSize: header.Size,
-		Data:     file,
+		Title:         title,
+		FileType:      format,
+		FileSize:      header.Size,
+		Data:          file,
+		ACL:           acl,
+		IngestOptions: opts,
+		ExternalID:    strings.TrimSpace(r.FormValue("external_id")),
 	})
+	if errors.Is(err, document.ErrVersionForbidden) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
+	if err := h.queue.EnqueueDocumentProcess(queue.DocumentProcessPayload{
+		DocumentID: doc.ID.String(),
+		TenantID:   doc.TenantID.String(),
+	}); err != nil {
+		if recErr := h.svc.RecordError(r.Context(), doc.ID, err, true); recErr != nil {
+			slog.Error("failed to record document error", "document_id", doc.ID, "error", recErr)
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
 	writeJSON(w, http.StatusCreated, doc)
 }
 
+// ingestOptions reads and validates the upload's indexing settings from
+// its form fields or query parameters.
+func ingestOptions(get func(string) string) (document.IngestOptions, error) {
+	opts := document.IngestOptions{
+		IndexType:      get("index_type"),
+		ContextHeaders: get("context_headers"),
+	}
+	if raw := get("chunk_opts"); raw != "" {
+		var chunkOpts chunker.ChunkOptions
+		if err := json.Unmarshal([]byte(raw), &chunkOpts); err != nil {
+			return opts, fmt.Errorf("invalid chunk_opts")
+		}
+		opts.ChunkOpts = &chunkOpts
+	}
+	if err := validateIngestOptions(opts); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func validateIngestOptions(opts document.IngestOptions) error {
+	var chunkOpts chunker.ChunkOptions
+	if opts.ChunkOpts != nil {
+		chunkOpts = *opts.ChunkOpts
+	}
+	return rag.ValidateIngestSettings(chunkOpts, opts.IndexType, opts.ContextHeaders)
+}
+
 func (h *DocumentHandler) List(w http.ResponseWriter, r *http.Request) {
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
@@ -88,10 +179,18 @@ func (h *DocumentHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.svc.Delete(r.Context(), id); err != nil {
+	restored, err := h.svc.Delete(r.Context(), id)
+	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	// Deleting the current version makes the one before it current again.
+	if restored != uuid.Nil {
+		if err := h.store.SetSuperseded(r.Context(), tenant.IDFromContext(r.Context()), restored, false); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -109,5 +208,156 @@ func (h *DocumentHandler) Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"id": doc.ID.String(), "status": doc.Status})
+	resp := map[string]string{"id": doc.ID.String(), "status": doc.Status}
+	if doc.Error != "" {
+		resp["error"] = doc.Error
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// Versions lists the document's versions, newest first.
+func (h *DocumentHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid document ID"})
+		return
+	}
+
+	docs, err := h.svc.Versions(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "document not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"versions": docs, "count": len(docs)})
+}
+
+// Diff returns the sections changed between the document and another of
+// its versions, given by the against query parameter; by default the
+// version before it.
+func (h *DocumentHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid document ID"})
+		return
+	}
+	var against uuid.UUID
+	if raw := r.URL.Query().Get("against"); raw != "" {
+		if against, err = uuid.Parse(raw); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid against document ID"})
+			return
+		}
+	}
+
+	diff, err := h.svc.Diff(r.Context(), id, against)
+	switch {
+	case errors.Is(err, document.ErrNoPreviousVersion), errors.Is(err, document.ErrNotVersion):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case errors.Is(err, document.ErrNoContent):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "document not processed yet"})
+		return
+	case errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "document not found"})
+		return
+	case err != nil:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, diff)
+}
+
+// Content returns the document's extracted text and page boundaries.
+func (h *DocumentHandler) Content(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid document ID"})
+		return
+	}
+
+	doc, err := h.svc.GetByID(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "document not found"})
+		return
+	}
+	content, err := h.svc.Content(r.Context(), doc.TenantID, doc.ID)
+	if errors.Is(err, document.ErrNoContent) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "document not processed yet"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, content)
+}
+
+// Download returns a presigned URL for the document's original file. The
+// expires_in query parameter sets how long it is valid, in seconds; by
+// default 15 minutes, at most 7 days.
+func (h *DocumentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid document ID"})
+		return
+	}
+	expires := 15 * time.Minute
+	if raw := r.URL.Query().Get("expires_in"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds <= 0 || seconds > 7*24*60*60 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expires_in must be between 1 and 604800 seconds"})
+			return
+		}
+		expires = time.Duration(seconds) * time.Second
+	}
+
+	doc, err := h.svc.GetByID(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "document not found"})
+		return
+	}
+	expiresAt := time.Now().Add(expires)
+	url, err := h.svc.DownloadURL(r.Context(), doc, expires)
+	if errors.Is(err, document.ErrNoFile) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"url": url, "expires_at": expiresAt})
+}
+
+// SetACL replaces the document's ACL and applies it to the chunks already
+// indexed from it.
+func (h *DocumentHandler) SetACL(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid document ID"})
+		return
+	}
+
+	var acl models.DocumentACL
+	if err := json.NewDecoder(r.Body).Decode(&acl); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	doc, _, err := h.svc.SetACL(r.Context(), id, acl)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// The document's ACL is saved; the request only succeeds once its
+	// chunks carry it too. Retrying the same ACL completes the update.
+	if err := h.svc.SyncChunkAccess(r.Context(), h.store, tenant.IDFromContext(r.Context()), id); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "ACL saved but not applied to the document's chunks: " + err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, doc)
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/evalset"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+)
+
+type EvalDatasetHandler struct {
+	svc *evalset.Service
+}
+
+func NewEvalDatasetHandler(svc *evalset.Service) *EvalDatasetHandler {
+	return &EvalDatasetHandler{svc: svc}
+}
+
+// Generate queues generation of a synthetic eval dataset and returns it;
+// poll the dataset for progress and stats.
+func (h *EvalDatasetHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	var req evalset.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	dataset, err := h.svc.StartGeneration(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, dataset)
+}
+
+func (h *EvalDatasetHandler) List(w http.ResponseWriter, r *http.Request) {
+	datasets, err := h.svc.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"datasets": datasets, "count": len(datasets)})
+}
+
+func (h *EvalDatasetHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+
+	dataset, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "dataset not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dataset)
+}
+
+// Items returns the dataset's questions; ?kind= filters by single_hop,
+// multi_hop or unanswerable. Each item can be posted to /eval/suite with a
+// response added, or used as a retrieval eval case.
+func (h *EvalDatasetHandler) Items(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+	switch kind {
+	case "", models.EvalItemSingleHop, models.EvalItemMultiHop, models.EvalItemUnanswerable:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid kind"})
+		return
+	}
+
+	if _, err := h.svc.Get(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "dataset not found"})
+		return
+	}
+	items, err := h.svc.Items(r.Context(), id, kind)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "count": len(items)})
+}
+
+func (h *EvalDatasetHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := datasetID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "dataset not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func datasetID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid dataset ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -2,17 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
 )
 
 type RAGHandler struct {
-	pipeline rag.Pipeline
+	pipeline      rag.Pipeline
+	conversations rag.ConversationStore
 }
 
-func NewRAGHandler(p rag.Pipeline) *RAGHandler {
-	return &RAGHandler{pipeline: p}
+func NewRAGHandler(p rag.Pipeline, conversations rag.ConversationStore) *RAGHandler {
+	return &RAGHandler{pipeline: p, conversations: conversations}
+}
+
+// writeRAGError maps pipeline errors to responses. Anything not caused by
+// the request is a failure of the LLM or retrieval backends behind it.
+func writeRAGError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tenant.ErrNoTenant):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, rag.ErrInvalidRequest):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, rag.ErrConversationNotFound), errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+	default:
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+	}
 }
 
 func (h *RAGHandler) Query(w http.ResponseWriter, r *http.Request) {
@@ -26,10 +47,14 @@ func (h *RAGHandler) Query(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "query required"})
 		return
 	}
+	if err := rag.ValidateCompression(req.Compress); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
 	resp, err := h.pipeline.Query(r.Context(), req)
 	if err != nil {
-		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		writeRAGError(w, err)
 		return
 	}
 
@@ -50,9 +75,75 @@ func (h *RAGHandler) Search(w http.ResponseWriter, r *http.Request) {
 
 	results, err := h.pipeline.Search(r.Context(), req)
 	if err != nil {
-		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		writeRAGError(w, err)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results, "count": len(results)})
 }
+
+func (h *RAGHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+
+	conv, err := h.conversations.Create(r.Context(), req.Title)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, conv)
+}
+
+func (h *RAGHandler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation ID"})
+		return
+	}
+
+	conv, err := h.conversations.Get(r.Context(), id)
+	if errors.Is(err, rag.ErrConversationNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "conversation not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	messages, err := h.conversations.History(r.Context(), id, 100)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversation": conv, "messages": messages})
+}
+
+func (h *RAGHandler) DeleteConversation(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid conversation ID"})
+		return
+	}
+
+	err = h.conversations.Delete(r.Context(), id)
+	if errors.Is(err, rag.ErrConversationNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "conversation not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
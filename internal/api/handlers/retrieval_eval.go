@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/evalset"
+	"github.com/nikhilbhutani/backendwithai/internal/retrievaleval"
+)
+
+type RetrievalEvalHandler struct {
+	svc      *retrievaleval.Service
+	datasets *evalset.Service
+}
+
+func NewRetrievalEvalHandler(svc *retrievaleval.Service, datasets *evalset.Service) *RetrievalEvalHandler {
+	return &RetrievalEvalHandler{svc: svc, datasets: datasets}
+}
+
+// Start queues a retrieval eval run and returns it; poll the run for
+// progress and results. With dataset_id the cases come from a generated
+// eval dataset, and the run is named after it unless a name is given.
+func (h *RetrievalEvalHandler) Start(w http.ResponseWriter, r *http.Request) {
+	var req retrievaleval.StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.DatasetID != nil {
+		if len(req.Cases) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cases and dataset_id are mutually exclusive"})
+			return
+		}
+		dataset, err := h.datasets.Get(r.Context(), *req.DatasetID)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "dataset not found"})
+			return
+		}
+		if req.Name == "" {
+			req.Name = dataset.Name
+		}
+		req.Cases, err = h.datasets.RetrievalCases(r.Context(), dataset.ID)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if err := req.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	run, err := h.svc.StartRun(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// ListRuns lists runs with per-config summaries; ?name= compares one query
+// set over time.
+func (h *RetrievalEvalHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := h.svc.ListRuns(r.Context(), r.URL.Query().Get("name"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs, "count": len(runs)})
+}
+
+func (h *RetrievalEvalHandler) GetRun(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid run ID"})
+		return
+	}
+
+	run, err := h.svc.GetRun(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "run not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
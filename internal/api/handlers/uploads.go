@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/upload"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
+)
+
+// uploadTimeout replaces the server's read and write timeouts for requests
+// that carry or store large files.
+const uploadTimeout = time.Hour
+
+type UploadHandler struct {
+	svc *upload.Service
+	// maxSize caps archives sent as a request body, as it caps resumable
+	// uploads.
+	maxSize int64
+}
+
+func NewUploadHandler(svc *upload.Service, maxSize int64) *UploadHandler {
+	return &UploadHandler{svc: svc, maxSize: maxSize}
+}
+
+// writeUploadError maps the upload service's errors to responses.
+func writeUploadError(w http.ResponseWriter, err error) {
+	var bodyTooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &bodyTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("request body is limited to %d MB", bodyTooLarge.Limit>>20),
+		})
+	case errors.Is(err, upload.ErrInvalidUpload):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, upload.ErrTooLarge):
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+	case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrIncomplete), errors.Is(err, upload.ErrCompleted):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case errors.Is(err, upload.ErrBusy):
+		writeJSON(w, http.StatusLocked, map[string]string{"error": err.Error()})
+	case errors.Is(err, upload.ErrExpired):
+		writeJSON(w, http.StatusGone, map[string]string{"error": err.Error()})
+	case errors.Is(err, upload.ErrUnsupportedType):
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{
+			"error": fmt.Sprintf("unsupported file type; supported: %s", strings.Join(textextract.SupportedTypes(), ", ")),
+		})
+	case errors.Is(err, upload.ErrUnsupportedArchive):
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+	case errors.Is(err, document.ErrVersionForbidden):
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, pgx.ErrNoRows):
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	default:
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+}
+
+// extendDeadlines lets a request that moves a large file outlast the
+// server's timeouts.
+func extendDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(uploadTimeout))
+	_ = rc.SetWriteDeadline(time.Now().Add(uploadTimeout))
+}
+
+// setUploadHeaders describes an upload's progress in the headers resumable
+// upload clients read.
+func setUploadHeaders(w http.ResponseWriter, u *models.Upload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Size, 10))
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// Create starts a resumable upload of a file of the given size. options
+// holds the settings of the document it becomes, as the multipart upload's
+// form fields do: title, acl, external_id, index_type, context_headers and
+// chunk_opts. Send the data with PATCH, then complete it.
+func (h *UploadHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req upload.CreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := validateIngestOptions(req.Options.IngestOptions); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	u, err := h.svc.Create(r.Context(), req)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	setUploadHeaders(w, u)
+	w.Header().Set("Location", "/api/v1/uploads/"+u.ID.String())
+	writeJSON(w, http.StatusCreated, u)
+}
+
+func (h *UploadHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid upload ID"})
+		return
+	}
+
+	u, err := h.svc.Get(r.Context(), id)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	setUploadHeaders(w, u)
+	writeJSON(w, http.StatusOK, u)
+}
+
+// Head reports how much of the upload has arrived in the Upload-Offset
+// header, so an interrupted client knows where to resume.
+func (h *UploadHandler) Head(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.svc.Get(r.Context(), id)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		w.WriteHeader(http.StatusNotFound)
+		return
+	case err != nil:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case u.Status == models.UploadStatusPending && u.ExpiresAt.Before(time.Now()):
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	setUploadHeaders(w, u)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Patch appends the request body to the upload. The Upload-Offset header
+// must be the upload's current offset; the response's is the new one. If
+// the request is cut off, what arrived is kept: ask HEAD for the offset and
+// send the rest from there.
+func (h *UploadHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid upload ID"})
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Upload-Offset header required"})
+		return
+	}
+	switch r.Header.Get("Content-Type") {
+	case "application/offset+octet-stream", "application/octet-stream":
+	default:
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{
+			"error": "Content-Type must be application/offset+octet-stream",
+		})
+		return
+	}
+	extendDeadlines(w)
+
+	newOffset, err := h.svc.Write(r.Context(), id, offset, r.Body)
+	if err == nil || errors.Is(err, upload.ErrOffsetMismatch) || errors.Is(err, upload.ErrTooLarge) {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	}
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Complete turns a fully received upload into a document and queues it for
+// extraction and indexing, like a multipart upload.
+func (h *UploadHandler) Complete(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid upload ID"})
+		return
+	}
+	extendDeadlines(w)
+
+	doc, err := h.svc.Complete(r.Context(), id)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, doc)
+}
+
+// Abort discards an unfinished upload.
+func (h *UploadHandler) Abort(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid upload ID"})
+		return
+	}
+
+	if err := h.svc.Abort(r.Context(), id); err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// CreateBatch accepts a ZIP, tar or tar.gz archive and queues its expansion
+// into one document per supported file. The archive is either the request
+// body, with the documents' settings as query parameters (filename, acl,
+// external_id, index_type, context_headers, chunk_opts), or a completed
+// resumable upload, given as {"upload_id": ...}, whose options apply. Poll
+// the batch for progress. A body archive is limited to the largest
+// resumable upload.
+func (h *UploadHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	contentType := r.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "multipart/") {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]string{
+			"error": "send the archive as the request body, not a form",
+		})
+		return
+	}
+	extendDeadlines(w)
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize)
+
+	if strings.HasPrefix(contentType, "application/json") {
+		var req struct {
+			UploadID uuid.UUID `json:"upload_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UploadID == uuid.Nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "upload_id required"})
+			return
+		}
+		batch, err := h.svc.CompleteBatch(r.Context(), req.UploadID)
+		if err != nil {
+			writeUploadError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, batch)
+		return
+	}
+
+	// Settings come from the query only: reading form values would consume
+	// a body sent as a form.
+	query := r.URL.Query()
+	opts := upload.DocumentOptions{ExternalID: strings.TrimSpace(query.Get("external_id"))}
+	if raw := query.Get("acl"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.ACL); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid acl"})
+			return
+		}
+	}
+	ingest, err := ingestOptions(query.Get)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	opts.IngestOptions = ingest
+
+	batch, err := h.svc.CreateBatch(r.Context(), upload.BatchRequest{
+		Filename: query.Get("filename"),
+		Data:     r.Body,
+		Options:  opts,
+	})
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, batch)
+}
+
+func (h *UploadHandler) ListBatches(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if limit <= 0 {
+		limit = 20
+	}
+
+	batches, err := h.svc.ListBatches(r.Context(), limit, offset)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"batches": batches, "count": len(batches)})
+}
+
+// GetBatch returns a batch with its progress: what became of the archive's
+// files, and its documents counted by status.
+func (h *UploadHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid batch ID"})
+		return
+	}
+
+	batch, err := h.svc.GetBatch(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "batch not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// BatchEntries lists the files of a batch's archive with their documents;
+// ?status= keeps the created, skipped or failed ones.
+func (h *UploadHandler) BatchEntries(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid batch ID"})
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	entries, err := h.svc.BatchEntries(r.Context(), id, r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entries": entries, "count": len(entries)})
+}
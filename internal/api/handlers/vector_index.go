@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorindex"
+)
+
+type VectorIndexHandler struct {
+	svc *vectorindex.Service
+}
+
+func NewVectorIndexHandler(svc *vectorindex.Service) *VectorIndexHandler {
+	return &VectorIndexHandler{svc: svc}
+}
+
+func (h *VectorIndexHandler) List(w http.ResponseWriter, r *http.Request) {
+	indexes, err := h.svc.ListIndexes(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"indexes": indexes, "count": len(indexes)})
+}
+
+// Build queues a create-or-rebuild job for the caller's per-tenant index
+// and returns it; poll the job for progress.
+func (h *VectorIndexHandler) Build(w http.ResponseWriter, r *http.Request) {
+	var req vectorindex.BuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	job, err := h.svc.StartBuild(r.Context(), req)
+	if errors.Is(err, vectorindex.ErrGlobalIndex) {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (h *VectorIndexHandler) Drop(w http.ResponseWriter, r *http.Request) {
+	err := h.svc.DropIndex(r.Context(), chi.URLParam(r, "name"))
+	if errors.Is(err, vectorindex.ErrIndexNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "dropped"})
+}
+
+func (h *VectorIndexHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.svc.ListJobs(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs, "count": len(jobs)})
+}
+
+func (h *VectorIndexHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid job ID"})
+		return
+	}
+
+	job, err := h.svc.GetJob(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
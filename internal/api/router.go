@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -13,8 +14,10 @@ import (
 	"github.com/nikhilbhutani/backendwithai/internal/audit"
 	"github.com/nikhilbhutani/backendwithai/internal/auth"
 	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/internal/connector"
 	"github.com/nikhilbhutani/backendwithai/internal/document"
 	"github.com/nikhilbhutani/backendwithai/internal/embedding"
+	"github.com/nikhilbhutani/backendwithai/internal/evalset"
 	"github.com/nikhilbhutani/backendwithai/internal/finetune"
 	"github.com/nikhilbhutani/backendwithai/internal/llm"
 	"github.com/nikhilbhutani/backendwithai/internal/multimodal/stt"
@@ -22,8 +25,11 @@ import (
 	"github.com/nikhilbhutani/backendwithai/internal/prompt"
 	"github.com/nikhilbhutani/backendwithai/internal/queue"
 	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/retrievaleval"
 	"github.com/nikhilbhutani/backendwithai/internal/storage"
 	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/upload"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorindex"
 	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
 	"github.com/nikhilbhutani/backendwithai/internal/webhook"
 )
@@ -84,7 +90,7 @@ func NewRouter(db *pgxpool.Pool, rdb *redis.Client, cfg *config.Config) *Router
 	}
 }
 
-func (rt *Router) Setup() http.Handler {
+func (rt *Router) Setup() (http.Handler, error) {
 	r := rt.mux
 
 	// Global middleware
@@ -103,7 +109,7 @@ func (rt *Router) Setup() http.Handler {
 	r.Get("/readyz", health.Readyz)
 
 	// Initialize services
-	store := storage.NewSupabaseStorage(rt.cfg.Storage.SupabaseURL, rt.cfg.Storage.SupabaseKey)
+	store := storage.NewFromConfig(rt.cfg.Storage)
 	docSvc := document.NewService(rt.db, store, rt.cfg.Storage.Bucket)
 	promptSvc := prompt.NewService(rt.db)
 	auditSvc := audit.NewService(rt.db)
@@ -111,13 +117,30 @@ func (rt *Router) Setup() http.Handler {
 	dispatcher := webhook.NewDispatcher(rt.db)
 	webhookSvc := webhook.NewService(rt.db, dispatcher)
 
-	vs := vectorstore.NewPgVectorStore(rt.db)
+	vs, err := vectorstore.NewFromConfig(rt.cfg.Vector, rt.db)
+	if err != nil {
+		return nil, fmt.Errorf("open vector store: %w", err)
+	}
 	embedSvc := embedding.NewService(rt.llmGW, "")
-	ragPipeline := rag.NewPipeline(vs, embedSvc, rt.llmGW)
+	conversations := rag.NewPgConversationStore(rt.db)
+	collections := rag.NewPgCollectionStore(rt.db)
+	ragPipeline := rag.NewPipelineWithOptions(vs, embedSvc, rt.llmGW, rag.PipelineOptions{
+		Conversations: conversations,
+		Collections:   collections,
+		Graph:         vectorstore.GraphStoreFromConfig(rt.cfg.Vector, rt.db),
+	})
+
+	uploadSvc := upload.NewService(rt.db, docSvc, store, rt.cfg.Storage.Bucket, queueClient, rt.ts, rt.cfg.Upload)
 
 	finetuneRegistry := finetune.NewRegistry(rt.db)
 	finetuneSvc := finetune.NewService(rt.db, store, rt.cfg.Storage.Bucket, finetuneRegistry, queueClient)
 
+	// Local storage serves its presigned URLs itself; the signature is the
+	// authorization, so this sits outside the API's auth.
+	if local, ok := store.(*storage.LocalStorage); ok {
+		r.Handle("/storage/*", http.StripPrefix("/storage", local))
+	}
+
 	// API v1
 	r.Route("/api/v1", func(r chi.Router) {
 		// Auth: try API key first, then JWT
@@ -134,20 +157,83 @@ func (rt *Router) Setup() http.Handler {
 		})
 
 		// Document routes
-		docH := handlers.NewDocumentHandler(docSvc)
+		docH := handlers.NewDocumentHandler(docSvc, vs, queueClient)
+		uploadH := handlers.NewUploadHandler(uploadSvc, rt.cfg.Upload.MaxSize)
 		r.Route("/documents", func(r chi.Router) {
 			r.Post("/", docH.Upload)
 			r.Get("/", docH.List)
 			r.Get("/{id}", docH.Get)
 			r.Delete("/{id}", docH.Delete)
 			r.Get("/{id}/status", docH.Status)
+			r.Get("/{id}/content", docH.Content)
+			r.Get("/{id}/download", docH.Download)
+
+			// Archives expanded into one document per file
+			r.Post("/batches", uploadH.CreateBatch)
+			r.Get("/batches", uploadH.ListBatches)
+			r.Get("/batches/{id}", uploadH.GetBatch)
+			r.Get("/batches/{id}/entries", uploadH.BatchEntries)
+			r.Get("/{id}/versions", docH.Versions)
+			r.Get("/{id}/diff", docH.Diff)
+			r.With(rt.rbac.RequirePermission(auth.PermDocumentsWrite)).Put("/{id}/acl", docH.SetACL)
+		})
+
+		// Resumable upload routes: create, PATCH pieces at their offsets,
+		// then complete into a document (or a batch, via /documents/batches)
+		r.Route("/uploads", func(r chi.Router) {
+			r.Post("/", uploadH.Create)
+			r.Get("/{id}", uploadH.Get)
+			r.Head("/{id}", uploadH.Head)
+			r.Patch("/{id}", uploadH.Patch)
+			r.Delete("/{id}", uploadH.Abort)
+			r.Post("/{id}/complete", uploadH.Complete)
+		})
+
+		// Connector routes: directories, repositories, buckets and websites
+		// synced into documents
+		connectorH := handlers.NewConnectorHandler(connector.NewService(rt.db, docSvc, vs, queueClient, rt.cfg.Connector))
+		r.Route("/connectors", func(r chi.Router) {
+			r.Get("/", connectorH.List)
+			r.With(rt.rbac.RequirePermission(auth.PermDocumentsWrite)).Post("/", connectorH.Create)
+			r.Get("/{id}", connectorH.Get)
+			r.With(rt.rbac.RequirePermission(auth.PermDocumentsWrite)).Put("/{id}", connectorH.Update)
+			r.With(rt.rbac.RequirePermission(auth.PermDocumentsWrite)).Delete("/{id}", connectorH.Delete)
+			r.With(rt.rbac.RequirePermission(auth.PermDocumentsWrite)).Post("/{id}/sync", connectorH.Sync)
+			r.Get("/{id}/syncs", connectorH.Syncs)
+			r.Get("/{id}/items", connectorH.Items)
+		})
+
+		// User group routes (principals for document ACLs)
+		groupH := handlers.NewGroupHandler(rt.ts)
+		r.Route("/groups", func(r chi.Router) {
+			r.Get("/", groupH.List)
+			r.With(rt.rbac.RequirePermission(auth.PermAdminWrite)).Post("/", groupH.Create)
+			r.With(rt.rbac.RequirePermission(auth.PermAdminWrite)).Delete("/{id}", groupH.Delete)
+			r.With(rt.rbac.RequirePermission(auth.PermAdminWrite)).Post("/{id}/members", groupH.AddMember)
+			r.With(rt.rbac.RequirePermission(auth.PermAdminWrite)).Delete("/{id}/members/{userID}", groupH.RemoveMember)
 		})
 
 		// RAG routes
-		ragH := handlers.NewRAGHandler(ragPipeline)
+		ragH := handlers.NewRAGHandler(ragPipeline, conversations)
 		r.Route("/rag", func(r chi.Router) {
 			r.Post("/query", ragH.Query)
 			r.Post("/search", ragH.Search)
+			r.Post("/conversations", ragH.CreateConversation)
+			r.Get("/conversations/{id}", ragH.GetConversation)
+			r.Delete("/conversations/{id}", ragH.DeleteConversation)
+		})
+
+		// Collection routes
+		collectionH := handlers.NewCollectionHandler(collections, vs, queueClient)
+		r.Route("/collections", func(r chi.Router) {
+			r.Post("/", collectionH.Create)
+			r.Get("/", collectionH.List)
+			r.Get("/{id}", collectionH.Get)
+			r.Put("/{id}", collectionH.Update)
+			r.Delete("/{id}", collectionH.Delete)
+			r.Post("/{id}/documents", collectionH.AddDocuments)
+			r.Get("/{id}/documents", collectionH.ListDocuments)
+			r.Delete("/{id}/documents/{docID}", collectionH.RemoveDocument)
 		})
 
 		// Prompt routes
@@ -184,6 +270,17 @@ func (rt *Router) Setup() http.Handler {
 		r.Route("/admin", func(r chi.Router) {
 			r.Get("/usage", adminH.Usage)
 			r.Get("/audit", adminH.AuditLogs)
+
+			// Tenants manage their own per-tenant vector indexes, with
+			// admin:write; global ones are the operator's (cmd/vectorindex).
+			vectorIndexH := handlers.NewVectorIndexHandler(vectorindex.NewService(rt.db, queueClient))
+			r.Route("/vector-indexes", func(r chi.Router) {
+				r.Get("/", vectorIndexH.List)
+				r.With(rt.rbac.RequirePermission(auth.PermAdminWrite)).Post("/", vectorIndexH.Build)
+				r.With(rt.rbac.RequirePermission(auth.PermAdminWrite)).Delete("/{name}", vectorIndexH.Drop)
+				r.Get("/jobs", vectorIndexH.ListJobs)
+				r.Get("/jobs/{id}", vectorIndexH.GetJob)
+			})
 		})
 
 		// Agent routes
@@ -206,6 +303,23 @@ func (rt *Router) Setup() http.Handler {
 			r.Post("/suite", evalH.RunSuite)
 			r.Post("/judge", evalH.Judge)
 			r.Post("/compare", evalH.Compare)
+
+			datasets := evalset.NewService(rt.db, queueClient, vs, ragPipeline, rt.llmGW, rt.ts)
+			datasetH := handlers.NewEvalDatasetHandler(datasets)
+			r.Route("/datasets", func(r chi.Router) {
+				r.Post("/", datasetH.Generate)
+				r.Get("/", datasetH.List)
+				r.Get("/{id}", datasetH.Get)
+				r.Get("/{id}/items", datasetH.Items)
+				r.Delete("/{id}", datasetH.Delete)
+			})
+
+			retrievalEvalH := handlers.NewRetrievalEvalHandler(retrievaleval.NewService(rt.db, queueClient, ragPipeline, rt.ts), datasets)
+			r.Route("/retrieval", func(r chi.Router) {
+				r.Post("/", retrievalEvalH.Start)
+				r.Get("/runs", retrievalEvalH.ListRuns)
+				r.Get("/runs/{id}", retrievalEvalH.GetRun)
+			})
 		})
 
 		// Reasoning routes
@@ -229,5 +343,5 @@ func (rt *Router) Setup() http.Handler {
 		})
 	})
 
-	return r
+	return r, nil
 }
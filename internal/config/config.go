@@ -3,19 +3,25 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Auth     AuthConfig
-	LLM      LLMConfig
-	Storage  StorageConfig
-	STT      STTConfig
-	TTS      TTSConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Auth      AuthConfig
+	LLM       LLMConfig
+	Storage   StorageConfig
+	STT       STTConfig
+	TTS       TTSConfig
+	Vector    VectorStoreConfig
+	OCR       OCRConfig
+	Connector ConnectorConfig
+	Upload    UploadConfig
 }
 
 type ServerConfig struct {
@@ -54,9 +60,21 @@ type LLMConfig struct {
 }
 
 type StorageConfig struct {
-	SupabaseURL    string
-	SupabaseKey    string
-	Bucket         string
+	Backend     string // "supabase" (default), "local" or "s3"
+	SupabaseURL string
+	SupabaseKey string
+	Bucket      string
+
+	LocalDir     string // root of the local backend; default: "data/storage"
+	LocalBaseURL string // where the API serves local files; default: "http://localhost:<port>/storage"
+	SigningKey   string // signs local download URLs; default: SUPABASE_JWT_SECRET
+
+	S3Endpoint        string // default: https://s3.<region>.amazonaws.com
+	S3Region          string // default: "us-east-1"
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3VirtualHosted   bool  // bucket.endpoint URLs instead of endpoint/bucket
+	S3PartSize        int64 // multipart upload part size; default: 16 MB, at least 5 MB
 }
 
 type STTConfig struct {
@@ -76,6 +94,47 @@ type TTSConfig struct {
 	LocalModel    string // required when backend=local
 }
 
+type VectorStoreConfig struct {
+	Backend          string // "pgvector" (default), "memory" or "qdrant"
+	MemoryPath       string // memory backend (single process only): JSON file to persist to; empty keeps it in-process only
+	QdrantURL        string // default: "http://localhost:6333"
+	QdrantAPIKey     string
+	QdrantCollection string // default: "document_chunks"
+}
+
+type OCRConfig struct {
+	TesseractBin string // default: "tesseract"
+	PdftoppmBin  string // rasterizes scanned PDF pages; default: "pdftoppm"
+	Language     string // tesseract languages, e.g. "eng+deu"; default: "eng"
+	DPI          int    // resolution scanned pages are rendered at; default: 300
+	Concurrency  int    // pages OCRed at once; default: 4
+}
+
+type ConnectorConfig struct {
+	LocalRoots   []string // directories local sources may sync from; none disables them
+	GitBin       string   // default: "git"
+	GitDir       string   // where repositories are fetched; default: "<tmp>/connectors"
+	MaxFileBytes int64    // larger files are skipped; default: 64 MB
+
+	CrawlUserAgent string // sent by web sources and matched against robots.txt
+	CrawlMaxPages  int    // upper bound on a web source's max_pages; default: 1000
+	// CrawlAllowPrivate lets web sources fetch loopback, private and
+	// link-local addresses; off by default so tenants can't reach internal
+	// services through the crawler.
+	CrawlAllowPrivate bool
+}
+
+type UploadConfig struct {
+	// Dir stages resumable uploads until they are complete; API replicas
+	// must share it. Default: "data/uploads".
+	Dir     string
+	MaxSize int64         // largest resumable upload or archive body; default: 5 GB
+	Expiry  time.Duration // unfinished uploads are discarded after; default: 24h
+
+	ArchiveMaxEntries   int   // files read from one archive; default: 10000
+	ArchiveMaxEntrySize int64 // larger archive entries are skipped; default: 64 MB
+}
+
 func Load() (*Config, error) {
 	port, err := getEnvInt("SERVER_PORT", 8080)
 	if err != nil {
@@ -102,6 +161,58 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid LLM_MAX_RETRIES: %w", err)
 	}
 
+	ocrDPI, err := getEnvInt("OCR_DPI", 300)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCR_DPI: %w", err)
+	}
+
+	ocrConcurrency, err := getEnvInt("OCR_CONCURRENCY", 4)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCR_CONCURRENCY: %w", err)
+	}
+
+	connectorMaxFileMB, err := getEnvInt("CONNECTOR_MAX_FILE_MB", 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONNECTOR_MAX_FILE_MB: %w", err)
+	}
+
+	s3PartSizeMB, err := getEnvInt("STORAGE_S3_PART_SIZE_MB", 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid STORAGE_S3_PART_SIZE_MB: %w", err)
+	}
+
+	crawlMaxPages, err := getEnvInt("CONNECTOR_CRAWL_MAX_PAGES", 1000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONNECTOR_CRAWL_MAX_PAGES: %w", err)
+	}
+
+	uploadMaxSizeMB, err := getEnvInt("UPLOAD_MAX_SIZE_MB", 5120)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPLOAD_MAX_SIZE_MB: %w", err)
+	}
+
+	uploadExpiryHours, err := getEnvInt("UPLOAD_EXPIRY_HOURS", 24)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPLOAD_EXPIRY_HOURS: %w", err)
+	}
+
+	archiveMaxEntries, err := getEnvInt("UPLOAD_ARCHIVE_MAX_ENTRIES", 10000)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPLOAD_ARCHIVE_MAX_ENTRIES: %w", err)
+	}
+
+	archiveMaxEntryMB, err := getEnvInt("UPLOAD_ARCHIVE_MAX_ENTRY_MB", 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UPLOAD_ARCHIVE_MAX_ENTRY_MB: %w", err)
+	}
+
+	var localRoots []string
+	for _, root := range strings.Split(getEnv("CONNECTOR_LOCAL_ROOTS", ""), ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			localRoots = append(localRoots, root)
+		}
+	}
+
 	cfg := &Config{
 		Server: ServerConfig{
 			Host: getEnv("SERVER_HOST", "0.0.0.0"),
@@ -134,9 +245,21 @@ func Load() (*Config, error) {
 			MaxRetries:       maxRetries,
 		},
 		Storage: StorageConfig{
+			Backend:     getEnv("STORAGE_BACKEND", "supabase"),
 			SupabaseURL: getEnv("SUPABASE_URL", ""),
 			SupabaseKey: getEnv("SUPABASE_SERVICE_KEY", ""),
 			Bucket:      getEnv("STORAGE_BUCKET", "documents"),
+
+			LocalDir:     getEnv("STORAGE_LOCAL_DIR", "data/storage"),
+			LocalBaseURL: getEnv("STORAGE_LOCAL_BASE_URL", fmt.Sprintf("http://localhost:%d/storage", port)),
+			SigningKey:   getEnv("STORAGE_SIGNING_KEY", getEnv("SUPABASE_JWT_SECRET", "")),
+
+			S3Endpoint:        getEnv("STORAGE_S3_ENDPOINT", ""),
+			S3Region:          getEnv("STORAGE_S3_REGION", "us-east-1"),
+			S3AccessKeyID:     getEnv("STORAGE_S3_ACCESS_KEY_ID", ""),
+			S3SecretAccessKey: getEnv("STORAGE_S3_SECRET_ACCESS_KEY", ""),
+			S3VirtualHosted:   getEnv("STORAGE_S3_VIRTUAL_HOSTED", "") == "true",
+			S3PartSize:        int64(s3PartSizeMB) << 20,
 		},
 		STT: STTConfig{
 			Backend:       getEnv("STT_BACKEND", "openai"),
@@ -153,6 +276,38 @@ func Load() (*Config, error) {
 			LocalBinPath:  getEnv("TTS_LOCAL_PIPER_BIN", "piper"),
 			LocalModel:    getEnv("TTS_LOCAL_PIPER_MODEL", ""),
 		},
+		Vector: VectorStoreConfig{
+			Backend:          getEnv("VECTOR_STORE_BACKEND", "pgvector"),
+			MemoryPath:       getEnv("VECTOR_STORE_MEMORY_PATH", ""),
+			QdrantURL:        getEnv("QDRANT_URL", "http://localhost:6333"),
+			QdrantAPIKey:     getEnv("QDRANT_API_KEY", ""),
+			QdrantCollection: getEnv("QDRANT_COLLECTION", "document_chunks"),
+		},
+		OCR: OCRConfig{
+			TesseractBin: getEnv("OCR_TESSERACT_BIN", "tesseract"),
+			PdftoppmBin:  getEnv("OCR_PDFTOPPM_BIN", "pdftoppm"),
+			Language:     getEnv("OCR_LANGUAGE", "eng"),
+			DPI:          ocrDPI,
+			Concurrency:  ocrConcurrency,
+		},
+		Connector: ConnectorConfig{
+			LocalRoots:   localRoots,
+			GitBin:       getEnv("CONNECTOR_GIT_BIN", "git"),
+			GitDir:       getEnv("CONNECTOR_GIT_DIR", filepath.Join(os.TempDir(), "connectors")),
+			MaxFileBytes: int64(connectorMaxFileMB) << 20,
+
+			CrawlUserAgent:    getEnv("CONNECTOR_CRAWL_USER_AGENT", "BackendWithAI-Crawler/1.0"),
+			CrawlMaxPages:     crawlMaxPages,
+			CrawlAllowPrivate: getEnv("CONNECTOR_CRAWL_ALLOW_PRIVATE", "") == "true",
+		},
+		Upload: UploadConfig{
+			Dir:     getEnv("UPLOAD_DIR", "data/uploads"),
+			MaxSize: int64(uploadMaxSizeMB) << 20,
+			Expiry:  time.Duration(uploadExpiryHours) * time.Hour,
+
+			ArchiveMaxEntries:   archiveMaxEntries,
+			ArchiveMaxEntrySize: int64(archiveMaxEntryMB) << 20,
+		},
 	}
 
 	return cfg, nil
@@ -0,0 +1,244 @@
+// Package connector syncs files from external sources (local directories,
+// Git repositories, S3-compatible buckets and crawled websites) into
+// documents.
+package connector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
+)
+
+// Source kinds.
+const (
+	KindLocal = "local"
+	KindGit   = "git"
+	KindS3    = "s3"
+	KindWeb   = "web"
+)
+
+// Item is one file in a source. Version changes whenever the file's content
+// may have: a Git blob hash, an S3 ETag, a local file's size and
+// modification time, or a hash of a web page's content.
+type Item struct {
+	Path    string
+	Version string
+	Size    int64
+	ModTime time.Time
+	// Title, URL and ContentType are set by sources that know them; the
+	// title defaults to the file name.
+	Title       string
+	URL         string
+	ContentType string
+	// Err is set when a listed file couldn't be read this time. Its
+	// documents are kept, and it is tried again on the next sync.
+	Err error
+}
+
+// Connector reads the files of one source.
+type Connector interface {
+	// List returns the source's files that match its filters, and a cursor
+	// for the listed state.
+	List(ctx context.Context) ([]Item, string, error)
+	// Open returns a listed file's content.
+	Open(ctx context.Context, item Item) (io.ReadCloser, error)
+}
+
+// Filter selects a source's files by path. Patterns use '/' separators
+// and may contain "**" to match any number of directories. With no include
+// patterns, every file of a supported type is included.
+type Filter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Match reports whether the filter selects the file at p.
+func (f Filter) Match(p string) bool {
+	for _, pattern := range f.Exclude {
+		if matchGlob(pattern, p) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		ext := strings.ToLower(path.Ext(p))
+		for _, supported := range textextract.SupportedTypes() {
+			if ext == supported {
+				return true
+			}
+		}
+		return false
+	}
+	for _, pattern := range f.Include {
+		if matchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		for _, part := range strings.Split(pattern, "/") {
+			if part == "**" {
+				continue
+			}
+			if _, err := path.Match(part, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q", pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// matchGlob matches p against pattern segment by segment with path.Match,
+// letting a "**" segment stand for zero or more segments.
+func matchGlob(pattern, p string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(p, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// New returns the connector for a source of the given kind and settings.
+// workDir is where the connector may keep state between syncs, such as a
+// fetched repository.
+func New(cfg config.ConnectorConfig, kind string, raw json.RawMessage, workDir string) (Connector, error) {
+	switch kind {
+	case KindLocal:
+		var c LocalConfig
+		if err := decode(raw, &c); err != nil {
+			return nil, err
+		}
+		return NewLocal(c), nil
+	case KindGit:
+		var c GitConfig
+		if err := decode(raw, &c); err != nil {
+			return nil, err
+		}
+		return NewGit(c, cfg.GitBin, workDir), nil
+	case KindS3:
+		var c S3Config
+		if err := decode(raw, &c); err != nil {
+			return nil, err
+		}
+		return NewS3(c), nil
+	default:
+		return nil, fmt.Errorf("unknown connector kind %q", kind)
+	}
+}
+
+// Validate checks a source's settings before they are saved.
+func Validate(cfg config.ConnectorConfig, kind string, raw json.RawMessage) error {
+	switch kind {
+	case KindLocal:
+		var c LocalConfig
+		if err := decode(raw, &c); err != nil {
+			return err
+		}
+		return c.validate(cfg.LocalRoots)
+	case KindGit:
+		var c GitConfig
+		if err := decode(raw, &c); err != nil {
+			return err
+		}
+		return c.validate()
+	case KindS3:
+		var c S3Config
+		if err := decode(raw, &c); err != nil {
+			return err
+		}
+		return c.validate()
+	case KindWeb:
+		var c WebConfig
+		if err := decode(raw, &c); err != nil {
+			return err
+		}
+		return c.validate(cfg.CrawlMaxPages)
+	default:
+		return fmt.Errorf("unknown connector kind %q; use %s, %s, %s or %s", kind, KindLocal, KindGit, KindS3, KindWeb)
+	}
+}
+
+// Redact returns a source's settings with its secrets removed, for API
+// responses.
+func Redact(kind string, raw json.RawMessage) json.RawMessage {
+	switch kind {
+	case KindGit:
+		var c GitConfig
+		if decode(raw, &c) == nil {
+			c.URL = redactURL(c.URL)
+			return encode(c, raw)
+		}
+	case KindS3:
+		var c S3Config
+		if decode(raw, &c) == nil {
+			c.SecretAccessKey = ""
+			return encode(c, raw)
+		}
+	}
+	return raw
+}
+
+// KeepSecrets fills the secrets left out of updated settings from the
+// current ones, so a redacted config can be sent back unchanged.
+func KeepSecrets(kind string, current, updated json.RawMessage) json.RawMessage {
+	switch kind {
+	case KindGit:
+		var cur, upd GitConfig
+		if decode(current, &cur) == nil && decode(updated, &upd) == nil && upd.URL == redactURL(cur.URL) {
+			upd.URL = cur.URL
+			return encode(upd, updated)
+		}
+	case KindS3:
+		var cur, upd S3Config
+		if decode(current, &cur) == nil && decode(updated, &upd) == nil &&
+			upd.SecretAccessKey == "" && upd.AccessKeyID == cur.AccessKeyID {
+			upd.SecretAccessKey = cur.SecretAccessKey
+			return encode(upd, updated)
+		}
+	}
+	return updated
+}
+
+func decode(raw json.RawMessage, v any) error {
+	if len(raw) == 0 {
+		raw = json.RawMessage("{}")
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("decode connector config: %w", err)
+	}
+	return nil
+}
+
+func encode(v any, fallback json.RawMessage) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fallback
+	}
+	return data
+}
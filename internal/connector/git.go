@@ -0,0 +1,164 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// GitConfig is a remote repository. Branch defaults to the remote's HEAD.
+// Credentials for HTTPS remotes can be given in the URL; they are not
+// shown back.
+type GitConfig struct {
+	URL    string `json:"url"`
+	Branch string `json:"branch,omitempty"`
+	Filter
+}
+
+func (c GitConfig) validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	// Local and transport-helper URLs would read the worker's own files or
+	// run commands.
+	if strings.HasPrefix(c.URL, "-") {
+		return fmt.Errorf("invalid repository URL")
+	}
+	if u, err := url.Parse(c.URL); err == nil && u.Scheme != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh", "git":
+		default:
+			return fmt.Errorf("unsupported repository URL scheme %q", u.Scheme)
+		}
+	} else if !isSCPLike(c.URL) {
+		return fmt.Errorf("repository URL must be https, http, ssh, git or user@host:path")
+	}
+	if strings.HasPrefix(c.Branch, "-") || strings.Contains(c.Branch, "..") {
+		return fmt.Errorf("invalid branch %q", c.Branch)
+	}
+	return c.Filter.validate()
+}
+
+// isSCPLike reports whether git reads raw as the scp-like user@host:path.
+// Git takes anything with a slash before the first colon for a local path,
+// so "/srv/x@y:z" would read the worker's files.
+func isSCPLike(raw string) bool {
+	colon := strings.IndexByte(raw, ':')
+	if colon < 0 || strings.Contains(raw[:colon], "/") {
+		return false
+	}
+	at := strings.LastIndexByte(raw[:colon], '@')
+	return at > 0 && at < colon-1
+}
+
+// redactURL removes the password from a repository URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// Git fetches the tip of a branch into a bare repository under its work
+// directory, shallowly, and reads files from that commit. A file's version
+// is its blob hash, and the cursor is the commit.
+type Git struct {
+	cfg GitConfig
+	bin string
+	dir string
+}
+
+func NewGit(cfg GitConfig, bin, dir string) *Git {
+	if bin == "" {
+		bin = "git"
+	}
+	return &Git{cfg: cfg, bin: bin, dir: dir}
+}
+
+func (g *Git) List(ctx context.Context) ([]Item, string, error) {
+	commit, err := g.fetch(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+
+	out, err := g.git(ctx, "ls-tree", "-r", "-l", "-z", commit)
+	if err != nil {
+		return nil, "", fmt.Errorf("list files: %w", err)
+	}
+	var items []Item
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// <mode> SP <type> SP <object> SP+ <size> TAB <path>
+		meta, p, ok := strings.Cut(string(entry), "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" {
+			continue // submodules and symlinks
+		}
+		if !g.cfg.Match(p) {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		items = append(items, Item{Path: p, Version: fields[2], Size: size})
+	}
+	return items, commit, nil
+}
+
+// fetch updates the repository and returns the fetched commit.
+func (g *Git) fetch(ctx context.Context) (string, error) {
+	if _, err := os.Stat(g.dir); os.IsNotExist(err) {
+		if err := os.MkdirAll(g.dir, 0o700); err != nil {
+			return "", fmt.Errorf("create repository dir: %w", err)
+		}
+		if _, err := g.git(ctx, "init", "--bare", "--quiet"); err != nil {
+			return "", fmt.Errorf("init repository: %w", err)
+		}
+	}
+	ref := g.cfg.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := g.git(ctx, "fetch", "--quiet", "--depth", "1", "--no-tags", "--force", g.cfg.URL, ref); err != nil {
+		return "", fmt.Errorf("fetch %s: %w", redactURL(g.cfg.URL), err)
+	}
+	out, err := g.git(ctx, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", fmt.Errorf("resolve fetched commit: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g *Git) Open(ctx context.Context, item Item) (io.ReadCloser, error) {
+	out, err := g.git(ctx, "cat-file", "blob", item.Version)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", item.Path, err)
+	}
+	return io.NopCloser(bytes.NewReader(out)), nil
+}
+
+func (g *Git) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, g.bin, append([]string{"--git-dir", g.dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, strings.ReplaceAll(msg, g.cfg.URL, redactURL(g.cfg.URL)))
+		}
+		return nil, err
+	}
+	return out, nil
+}
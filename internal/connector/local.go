@@ -0,0 +1,121 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalConfig is a directory on the worker's filesystem, which must lie
+// under one of CONNECTOR_LOCAL_ROOTS.
+type LocalConfig struct {
+	Path string `json:"path"`
+	Filter
+}
+
+func (c LocalConfig) validate(roots []string) error {
+	if len(roots) == 0 {
+		return fmt.Errorf("local sources are disabled; set CONNECTOR_LOCAL_ROOTS")
+	}
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("path must be absolute")
+	}
+	dir := filepath.Clean(c.Path)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	inRoot := false
+	for _, root := range roots {
+		root = filepath.Clean(root)
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+		if rel, err := filepath.Rel(root, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			inRoot = true
+			break
+		}
+	}
+	if !inRoot {
+		return fmt.Errorf("path %s is outside CONNECTOR_LOCAL_ROOTS", c.Path)
+	}
+	return c.Filter.validate()
+}
+
+// Local lists a directory tree. Hidden files and directories, and symbolic
+// links, are skipped. It is polled on the source's schedule; a file's
+// version is its size and modification time.
+type Local struct {
+	cfg LocalConfig
+}
+
+func NewLocal(cfg LocalConfig) *Local {
+	return &Local{cfg: cfg}
+}
+
+// List walks the directory. The cursor is the newest modification time.
+func (l *Local) List(ctx context.Context) ([]Item, string, error) {
+	root := filepath.Clean(l.cfg.Path)
+	var items []Item
+	var newest time.Time
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !l.cfg.Match(rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		items = append(items, Item{
+			Path:    rel,
+			Version: strconv.FormatInt(info.Size(), 10) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 10),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("list %s: %w", root, err)
+	}
+	var cursor string
+	if !newest.IsZero() {
+		cursor = newest.UTC().Format(time.RFC3339Nano)
+	}
+	return items, cursor, nil
+}
+
+func (l *Local) Open(ctx context.Context, item Item) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(l.cfg.Path, filepath.FromSlash(item.Path)))
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", item.Path, err)
+	}
+	return f, nil
+}
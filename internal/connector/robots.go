@@ -0,0 +1,140 @@
+package connector
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robotsRules are the robots.txt rules that apply to the crawler on one
+// host, as RFC 9309 describes them.
+type robotsRules struct {
+	rules    []robotsRule
+	delay    time.Duration
+	sitemaps []string
+	// unavailable is set when robots.txt couldn't be fetched, or failed
+	// with a server error, which puts the whole host off limits for now.
+	unavailable error
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// parseRobots reads the groups of a robots.txt that name the crawler's
+// product token, or the "*" group when none does.
+func parseRobots(data []byte, userAgent string) *robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var specific, wildcard robotsRules
+	haveSpecific := false
+	var agents []string
+	inRules := false
+	var sitemaps []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				agents, inRules = nil, false
+			}
+			agents = append(agents, strings.ToLower(value))
+		case "allow", "disallow", "crawl-delay":
+			inRules = true
+			for _, agent := range agents {
+				var group *robotsRules
+				switch {
+				case agent == "*":
+					group = &wildcard
+				case token != "" && strings.Contains(token, agent):
+					group = &specific
+					haveSpecific = true
+				default:
+					continue
+				}
+				switch {
+				case key == "crawl-delay":
+					if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+						group.delay = time.Duration(seconds * float64(time.Second))
+					}
+				case value != "":
+					// An empty Disallow allows everything, like no rule.
+					group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: value})
+				}
+			}
+		case "sitemap":
+			if value != "" {
+				sitemaps = append(sitemaps, value)
+			}
+		}
+	}
+
+	rules := wildcard
+	if haveSpecific {
+		rules = specific
+	}
+	rules.sitemaps = sitemaps
+	return &rules
+}
+
+// allowed reports whether the crawler may fetch a path (with its query).
+// The longest matching rule wins, and Allow wins a tie.
+func (r *robotsRules) allowed(p string) bool {
+	if r == nil {
+		return true
+	}
+	if r.unavailable != nil {
+		return false
+	}
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !robotsMatch(rule.pattern, p) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch matches a path against a rule pattern, where '*' matches any
+// characters and a trailing '$' anchors the pattern at the path's end.
+func robotsMatch(pattern, p string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(p, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(p[pos:], part)
+		}
+		j := strings.Index(p[pos:], part)
+		if j < 0 {
+			return false
+		}
+		pos += j + len(part)
+	}
+	return !anchored || pos == len(p)
+}
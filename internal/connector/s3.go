@@ -0,0 +1,185 @@
+package connector
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nikhilbhutani/backendwithai/pkg/sigv4"
+)
+
+// S3Config is a bucket on S3 or an S3-compatible store such as MinIO.
+// Requests use path-style URLs (endpoint/bucket/key) unless VirtualHosted
+// is set. Without keys the bucket is read anonymously.
+type S3Config struct {
+	// Endpoint is the store's base URL, e.g. http://minio:9000; default:
+	// https://s3.<region>.amazonaws.com.
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Bucket   string `json:"bucket"`
+	// Prefix limits the source to one folder of the bucket.
+	Prefix          string `json:"prefix,omitempty"`
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	VirtualHosted   bool   `json:"virtual_hosted,omitempty"`
+	Filter
+}
+
+func (c S3Config) validate() error {
+	if c.Bucket == "" {
+		return fmt.Errorf("bucket is required")
+	}
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("endpoint must be an http or https URL")
+		}
+	}
+	if (c.AccessKeyID == "") != (c.SecretAccessKey == "") {
+		return fmt.Errorf("access_key_id and secret_access_key must be set together")
+	}
+	return c.Filter.validate()
+}
+
+// S3 lists a bucket with ListObjectsV2 and reads objects with GetObject,
+// signing requests with AWS Signature Version 4. A file's version is its
+// ETag, and the cursor is the newest modification time.
+type S3 struct {
+	cfg        S3Config
+	httpClient *http.Client
+}
+
+func NewS3(cfg S3Config) *S3 {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	if cfg.Prefix != "" {
+		cfg.Prefix = strings.TrimSuffix(cfg.Prefix, "/") + "/"
+	}
+	return &S3{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context) ([]Item, string, error) {
+	var items []Item
+	var newest time.Time
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if s.cfg.Prefix != "" {
+			query.Set("prefix", s.cfg.Prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(ctx, "", query)
+		if err != nil {
+			return nil, "", fmt.Errorf("list bucket %s: %w", s.cfg.Bucket, err)
+		}
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, "", fmt.Errorf("decode bucket listing: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			if strings.HasSuffix(obj.Key, "/") {
+				continue // folder placeholders
+			}
+			p := strings.TrimPrefix(obj.Key, s.cfg.Prefix)
+			if !s.cfg.Match(p) {
+				continue
+			}
+			items = append(items, Item{
+				Path:    p,
+				Version: strings.Trim(obj.ETag, `"`),
+				Size:    obj.Size,
+				ModTime: obj.LastModified,
+			})
+			if obj.LastModified.After(newest) {
+				newest = obj.LastModified
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+
+	var cursor string
+	if !newest.IsZero() {
+		cursor = newest.UTC().Format(time.RFC3339Nano)
+	}
+	return items, cursor, nil
+}
+
+func (s *S3) Open(ctx context.Context, item Item) (io.ReadCloser, error) {
+	key := s.cfg.Prefix + item.Path
+	resp, err := s.do(ctx, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// do sends a signed GET for an object key, or for the bucket when key is
+// empty, and returns the response if it succeeded.
+func (s *S3) do(ctx context.Context, key string, query url.Values) (*http.Response, error) {
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+	objectPath := "/" + key
+	if s.cfg.VirtualHosted {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	} else {
+		objectPath = "/" + s.cfg.Bucket + objectPath
+	}
+	u.Path = objectPath
+	u.RawPath = sigv4.EscapePath(objectPath)
+	u.RawQuery = sigv4.CanonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	if s.cfg.AccessKeyID != "" {
+		sigv4.Sign(req, sigv4.Credentials{AccessKeyID: s.cfg.AccessKeyID, SecretAccessKey: s.cfg.SecretAccessKey},
+			s.cfg.Region, "s3", sigv4.EmptyPayloadHash, time.Now())
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("request failed (%d): %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
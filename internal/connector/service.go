@@ -0,0 +1,622 @@
+package connector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
+)
+
+// ErrInvalidSource wraps the problems found in a source's settings.
+var ErrInvalidSource = errors.New("invalid connector source")
+
+// ErrSourceExists is returned when a tenant already has a source with the
+// requested name.
+var ErrSourceExists = errors.New("a connector source with this name already exists")
+
+// staleSync is how long a sync may hold a source before another may claim
+// it, so a crashed worker doesn't block the source forever.
+const staleSync = "1 hour"
+
+// Service stores connector sources and syncs their files into documents.
+// Each file becomes a document whose external ID names the source and
+// path, so a changed file is uploaded as a new version of it and a removed
+// file's versions are deleted.
+type Service struct {
+	db    *pgxpool.Pool
+	docs  *document.Service
+	store vectorstore.VectorStore
+	queue *queue.Client
+	cfg   config.ConnectorConfig
+}
+
+func NewService(db *pgxpool.Pool, docs *document.Service, store vectorstore.VectorStore, qc *queue.Client, cfg config.ConnectorConfig) *Service {
+	return &Service{
+		db:    db,
+		docs:  docs,
+		store: store,
+		queue: qc,
+		cfg:   cfg,
+	}
+}
+
+type SourceRequest struct {
+	Name   string          `json:"name"`
+	Kind   string          `json:"kind"`
+	Config json.RawMessage `json:"config"`
+	// SyncIntervalMinutes defaults to 60; 0 syncs only on request.
+	SyncIntervalMinutes *int  `json:"sync_interval_minutes,omitempty"`
+	Enabled             *bool `json:"enabled,omitempty"`
+}
+
+// validate checks a create or update request; its errors wrap
+// ErrInvalidSource.
+func (s *Service) validate(req SourceRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSource)
+	}
+	if req.SyncIntervalMinutes != nil && *req.SyncIntervalMinutes < 0 {
+		return fmt.Errorf("%w: sync_interval_minutes must not be negative", ErrInvalidSource)
+	}
+	if err := Validate(s.cfg, req.Kind, req.Config); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSource, err)
+	}
+	return nil
+}
+
+const sourceColumns = `id, tenant_id, created_by, name, kind, config, sync_interval_minutes, enabled, status,
+	COALESCE(cursor, ''), COALESCE(error, ''), last_synced_at, next_sync_at, created_at, updated_at`
+
+func scanSource(row interface{ Scan(...any) error }) (*models.ConnectorSource, error) {
+	var src models.ConnectorSource
+	err := row.Scan(&src.ID, &src.TenantID, &src.CreatedBy, &src.Name, &src.Kind, &src.Config, &src.SyncIntervalMinutes,
+		&src.Enabled, &src.Status, &src.Cursor, &src.Error, &src.LastSyncedAt, &src.NextSyncAt, &src.CreatedAt, &src.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &src, nil
+}
+
+// redacted strips the source's secrets before it is returned by the API.
+func redacted(src *models.ConnectorSource) *models.ConnectorSource {
+	src.Config = Redact(src.Kind, src.Config)
+	return src
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// Create saves a source; its first sync is queued by the next schedule run.
+func (s *Service) Create(ctx context.Context, req SourceRequest) (*models.ConnectorSource, error) {
+	if err := s.validate(req); err != nil {
+		return nil, err
+	}
+	tenantID := tenant.IDFromContext(ctx)
+	var userID *uuid.UUID
+	if user := tenant.UserFromContext(ctx); user != nil {
+		userID = &user.ID
+	}
+	interval := 60
+	if req.SyncIntervalMinutes != nil {
+		interval = *req.SyncIntervalMinutes
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+
+	src, err := scanSource(s.db.QueryRow(ctx,
+		`INSERT INTO connector_sources (tenant_id, created_by, name, kind, config, sync_interval_minutes, enabled)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 RETURNING `+sourceColumns,
+		tenantID, userID, strings.TrimSpace(req.Name), req.Kind, normalizeConfig(req.Config), interval, enabled,
+	))
+	if isUniqueViolation(err) {
+		return nil, ErrSourceExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("insert connector source: %w", err)
+	}
+	return redacted(src), nil
+}
+
+func normalizeConfig(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
+
+func (s *Service) List(ctx context.Context) ([]models.ConnectorSource, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT `+sourceColumns+` FROM connector_sources WHERE tenant_id = $1 ORDER BY name`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list connector sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []models.ConnectorSource
+	for rows.Next() {
+		src, err := scanSource(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan connector source: %w", err)
+		}
+		sources = append(sources, *redacted(src))
+	}
+	return sources, rows.Err()
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*models.ConnectorSource, error) {
+	src, err := s.load(ctx, tenant.IDFromContext(ctx), id)
+	if err != nil {
+		return nil, err
+	}
+	return redacted(src), nil
+}
+
+// load returns a source with its secrets.
+func (s *Service) load(ctx context.Context, tenantID, id uuid.UUID) (*models.ConnectorSource, error) {
+	src, err := scanSource(s.db.QueryRow(ctx,
+		`SELECT `+sourceColumns+` FROM connector_sources WHERE id = $1 AND tenant_id = $2`,
+		id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get connector source: %w", err)
+	}
+	return src, nil
+}
+
+// Update replaces a source's name, settings and schedule. Its kind can't
+// change. Secrets left out of the settings, as Get returns them, are kept.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req SourceRequest) (*models.ConnectorSource, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	current, err := s.load(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Kind == "" {
+		req.Kind = current.Kind
+	}
+	if req.Kind != current.Kind {
+		return nil, fmt.Errorf("%w: a source's kind can't be changed", ErrInvalidSource)
+	}
+	req.Config = KeepSecrets(req.Kind, current.Config, normalizeConfig(req.Config))
+	if err := s.validate(req); err != nil {
+		return nil, err
+	}
+	interval := current.SyncIntervalMinutes
+	if req.SyncIntervalMinutes != nil {
+		interval = *req.SyncIntervalMinutes
+	}
+	enabled := current.Enabled
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	src, err := scanSource(s.db.QueryRow(ctx,
+		`UPDATE connector_sources
+		 SET name = $3, config = $4, sync_interval_minutes = $5, enabled = $6,
+		     next_sync_at = COALESCE(next_sync_at, now()), updated_at = now()
+		 WHERE id = $1 AND tenant_id = $2
+		 RETURNING `+sourceColumns,
+		id, tenantID, strings.TrimSpace(req.Name), req.Config, interval, enabled,
+	))
+	if isUniqueViolation(err) {
+		return nil, ErrSourceExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update connector source: %w", err)
+	}
+	return redacted(src), nil
+}
+
+// Delete removes a source. With deleteDocuments set the documents synced
+// from it are deleted too; otherwise they are kept as ordinary documents.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID, deleteDocuments bool) error {
+	tenantID := tenant.IDFromContext(ctx)
+	if _, err := s.load(ctx, tenantID, id); err != nil {
+		return err
+	}
+	if deleteDocuments {
+		items, err := s.items(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := s.deleteDocuments(ctx, tenantID, id, item.Path); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := s.db.Exec(ctx,
+		"DELETE FROM connector_sources WHERE id = $1 AND tenant_id = $2", id, tenantID,
+	); err != nil {
+		return fmt.Errorf("delete connector source: %w", err)
+	}
+	return nil
+}
+
+// RequestSync queues a sync of the source now.
+func (s *Service) RequestSync(ctx context.Context, id uuid.UUID) error {
+	tenantID := tenant.IDFromContext(ctx)
+	if _, err := s.load(ctx, tenantID, id); err != nil {
+		return err
+	}
+	if err := s.queue.EnqueueConnectorSync(queue.ConnectorSyncPayload{
+		SourceID: id.String(),
+		TenantID: tenantID.String(),
+	}); err != nil {
+		return fmt.Errorf("enqueue connector sync: %w", err)
+	}
+	return nil
+}
+
+const syncColumns = `id, source_id, tenant_id, status, COALESCE(cursor, ''), added, updated, deleted, unchanged,
+	skipped, failed, COALESCE(error, ''), started_at, completed_at`
+
+// Syncs returns the source's most recent syncs, newest first.
+func (s *Service) Syncs(ctx context.Context, id uuid.UUID) ([]models.ConnectorSync, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT `+syncColumns+` FROM connector_syncs
+		 WHERE source_id = $1 AND tenant_id = $2 ORDER BY started_at DESC LIMIT 50`,
+		id, tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list connector syncs: %w", err)
+	}
+	defer rows.Close()
+
+	var syncs []models.ConnectorSync
+	for rows.Next() {
+		var sy models.ConnectorSync
+		if err := rows.Scan(&sy.ID, &sy.SourceID, &sy.TenantID, &sy.Status, &sy.Cursor, &sy.Added, &sy.Updated,
+			&sy.Deleted, &sy.Unchanged, &sy.Skipped, &sy.Failed, &sy.Error, &sy.StartedAt, &sy.CompletedAt); err != nil {
+			return nil, fmt.Errorf("scan connector sync: %w", err)
+		}
+		syncs = append(syncs, sy)
+	}
+	return syncs, rows.Err()
+}
+
+// Items returns the files of the source as of its last sync.
+func (s *Service) Items(ctx context.Context, id uuid.UUID) ([]models.ConnectorItem, error) {
+	if _, err := s.load(ctx, tenant.IDFromContext(ctx), id); err != nil {
+		return nil, err
+	}
+	return s.items(ctx, id)
+}
+
+func (s *Service) items(ctx context.Context, id uuid.UUID) ([]models.ConnectorItem, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT source_id, path, version, document_id, status, COALESCE(error, ''), synced_at
+		 FROM connector_items WHERE source_id = $1 ORDER BY path`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list connector items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.ConnectorItem
+	for rows.Next() {
+		var item models.ConnectorItem
+		if err := rows.Scan(&item.SourceID, &item.Path, &item.Version, &item.DocumentID, &item.Status,
+			&item.Error, &item.SyncedAt); err != nil {
+			return nil, fmt.Errorf("scan connector item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// QueueDue queues a sync of every enabled source whose next sync is due,
+// and moves its next sync one interval ahead. It runs on the worker's
+// schedule.
+func (s *Service) QueueDue(ctx context.Context) error {
+	rows, err := s.db.Query(ctx,
+		`UPDATE connector_sources
+		 SET next_sync_at = now() + make_interval(mins => sync_interval_minutes)
+		 WHERE enabled AND sync_interval_minutes > 0 AND (next_sync_at IS NULL OR next_sync_at <= now())
+		 RETURNING id, tenant_id`,
+	)
+	if err != nil {
+		return fmt.Errorf("find due connector sources: %w", err)
+	}
+	var due []queue.ConnectorSyncPayload
+	for rows.Next() {
+		var id, tenantID uuid.UUID
+		if err := rows.Scan(&id, &tenantID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan due connector source: %w", err)
+		}
+		due = append(due, queue.ConnectorSyncPayload{SourceID: id.String(), TenantID: tenantID.String()})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("find due connector sources: %w", err)
+	}
+
+	for _, payload := range due {
+		if err := s.queue.EnqueueConnectorSync(payload); err != nil {
+			slog.Error("failed to enqueue connector sync", "source_id", payload.SourceID, "error", err)
+		}
+	}
+	return nil
+}
+
+// syncCounts tallies what a sync did with the source's files.
+type syncCounts struct {
+	added, updated, deleted, unchanged, skipped, failed int
+}
+
+// Sync lists the source, uploads its new and changed files as documents,
+// deletes the documents of removed files, and records the outcome. A
+// source already being synced is left alone.
+func (s *Service) Sync(ctx context.Context, tenantID, id uuid.UUID) error {
+	ctx = tenant.WithTenant(ctx, &models.Tenant{ID: tenantID})
+
+	src, err := scanSource(s.db.QueryRow(ctx,
+		`UPDATE connector_sources SET status = $3, sync_started_at = now(), updated_at = now()
+		 WHERE id = $1 AND tenant_id = $2 AND (status <> $3 OR sync_started_at < now() - interval '`+staleSync+`')
+		 RETURNING `+sourceColumns,
+		id, tenantID, models.ConnectorStatusSyncing,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Info("connector source is already syncing or was deleted", "source_id", id)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("claim connector source: %w", err)
+	}
+
+	var syncID uuid.UUID
+	if err := s.db.QueryRow(ctx,
+		`INSERT INTO connector_syncs (source_id, tenant_id, status) VALUES ($1, $2, $3) RETURNING id`,
+		id, tenantID, models.JobStatusRunning,
+	).Scan(&syncID); err != nil {
+		s.finish(ctx, src, uuid.Nil, syncCounts{}, src.Cursor, err)
+		return fmt.Errorf("insert connector sync: %w", err)
+	}
+
+	counts, cursor, err := s.sync(ctx, src)
+	if err == nil && counts.failed > 0 {
+		err = fmt.Errorf("%d files failed", counts.failed)
+	}
+	s.finish(ctx, src, syncID, counts, cursor, err)
+	return nil
+}
+
+func (s *Service) sync(ctx context.Context, src *models.ConnectorSource) (syncCounts, string, error) {
+	var counts syncCounts
+	if err := Validate(s.cfg, src.Kind, src.Config); err != nil {
+		return counts, src.Cursor, err
+	}
+	conn, err := New(s.cfg, src.Kind, src.Config, filepath.Join(s.cfg.GitDir, src.ID.String()))
+	if err != nil {
+		return counts, src.Cursor, err
+	}
+	files, cursor, err := conn.List(ctx)
+	if err != nil {
+		return counts, src.Cursor, err
+	}
+
+	known, err := s.items(ctx, src.ID)
+	if err != nil {
+		return counts, src.Cursor, err
+	}
+	previous := make(map[string]models.ConnectorItem, len(known))
+	for _, item := range known {
+		previous[item.Path] = item
+	}
+
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return counts, src.Cursor, err
+		}
+		prev, seen := previous[file.Path]
+		delete(previous, file.Path)
+		if seen && prev.Version == file.Version && prev.Status != models.ConnectorItemFailed {
+			counts.unchanged++
+			continue
+		}
+
+		status, docID, err := models.ConnectorItemFailed, (*uuid.UUID)(nil), file.Err
+		if file.Err == nil {
+			status, docID, err = s.ingest(ctx, src, conn, file)
+		}
+		switch {
+		case status == models.ConnectorItemSkipped:
+			counts.skipped++
+		case err != nil:
+			counts.failed++
+			slog.Warn("failed to sync connector file", "source_id", src.ID, "path", file.Path, "error", err)
+		case seen && prev.DocumentID != nil:
+			counts.updated++
+		default:
+			counts.added++
+		}
+		if recErr := s.saveItem(ctx, src.ID, file, status, docID, err); recErr != nil {
+			return counts, src.Cursor, recErr
+		}
+	}
+
+	// What is left was removed from the source.
+	for p := range previous {
+		if err := s.deleteDocuments(ctx, src.TenantID, src.ID, p); err != nil {
+			return counts, src.Cursor, err
+		}
+		if _, err := s.db.Exec(ctx,
+			"DELETE FROM connector_items WHERE source_id = $1 AND path = $2", src.ID, p,
+		); err != nil {
+			return counts, src.Cursor, fmt.Errorf("delete connector item: %w", err)
+		}
+		counts.deleted++
+	}
+	return counts, cursor, nil
+}
+
+// ingest uploads one file as the next version of its document and queues
+// it for processing. Files too large or of an unsupported type are skipped.
+func (s *Service) ingest(ctx context.Context, src *models.ConnectorSource, conn Connector, file Item) (string, *uuid.UUID, error) {
+	if s.cfg.MaxFileBytes > 0 && file.Size > s.cfg.MaxFileBytes {
+		return models.ConnectorItemSkipped, nil, fmt.Errorf("file exceeds %d bytes", s.cfg.MaxFileBytes)
+	}
+	r, err := conn.Open(ctx, file)
+	if err != nil {
+		return models.ConnectorItemFailed, nil, err
+	}
+	defer r.Close()
+	// The listed size may be stale, so the limit is enforced on read too.
+	var reader io.Reader = r
+	if s.cfg.MaxFileBytes > 0 {
+		reader = io.LimitReader(r, s.cfg.MaxFileBytes+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return models.ConnectorItemFailed, nil, fmt.Errorf("read %s: %w", file.Path, err)
+	}
+	if s.cfg.MaxFileBytes > 0 && int64(len(data)) > s.cfg.MaxFileBytes {
+		return models.ConnectorItemSkipped, nil, fmt.Errorf("file exceeds %d bytes", s.cfg.MaxFileBytes)
+	}
+
+	name := path.Base(file.Path)
+	format := textextract.Detect(bytes.NewReader(data), int64(len(data)), file.ContentType, name)
+	if format == "" {
+		return models.ConnectorItemSkipped, nil, fmt.Errorf("unsupported file type")
+	}
+	title := file.Title
+	if title == "" {
+		title = name
+	}
+	metadata := map[string]interface{}{
+		"connector_source_id": src.ID.String(),
+		"source_path":         file.Path,
+	}
+	if file.URL != "" {
+		metadata["url"] = file.URL
+	}
+
+	doc, err := s.docs.Upload(ctx, document.UploadRequest{
+		Title:           title,
+		FileType:        format,
+		FileSize:        int64(len(data)),
+		Data:            bytes.NewReader(data),
+		Metadata:        metadata,
+		ExternalID:      externalID(src.ID, file.Path),
+		SkipAccessCheck: true,
+	})
+	if err != nil {
+		return models.ConnectorItemFailed, nil, err
+	}
+	if err := s.queue.EnqueueDocumentProcess(queue.DocumentProcessPayload{
+		DocumentID: doc.ID.String(),
+		TenantID:   doc.TenantID.String(),
+	}); err != nil {
+		if recErr := s.docs.RecordError(ctx, doc.ID, err, true); recErr != nil {
+			slog.Error("failed to record document error", "document_id", doc.ID, "error", recErr)
+		}
+		return models.ConnectorItemFailed, &doc.ID, fmt.Errorf("enqueue document: %w", err)
+	}
+	return models.ConnectorItemSynced, &doc.ID, nil
+}
+
+// externalID identifies a source file's documents across its versions.
+func externalID(sourceID uuid.UUID, p string) string {
+	return "connector:" + sourceID.String() + ":" + p
+}
+
+// saveItem records the file's state after a sync. A skipped or failed file
+// keeps the document of its last synced version.
+func (s *Service) saveItem(ctx context.Context, sourceID uuid.UUID, file Item, status string, docID *uuid.UUID, cause error) error {
+	var errMsg *string
+	if cause != nil {
+		msg := cause.Error()
+		errMsg = &msg
+	}
+	_, err := s.db.Exec(ctx,
+		`INSERT INTO connector_items (source_id, path, version, document_id, status, error, synced_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, now())
+		 ON CONFLICT (source_id, path) DO UPDATE
+		 SET version = EXCLUDED.version, document_id = COALESCE(EXCLUDED.document_id, connector_items.document_id),
+		     status = EXCLUDED.status, error = EXCLUDED.error, synced_at = now()`,
+		sourceID, file.Path, file.Version, docID, status, errMsg,
+	)
+	if err != nil {
+		return fmt.Errorf("save connector item: %w", err)
+	}
+	return nil
+}
+
+// deleteDocuments removes every version of a source file's document and
+// its chunks.
+func (s *Service) deleteDocuments(ctx context.Context, tenantID, sourceID uuid.UUID, p string) error {
+	ids, err := s.docs.DeleteExternal(ctx, tenantID, externalID(sourceID, p))
+	if err != nil {
+		return err
+	}
+	for _, docID := range ids {
+		if err := s.store.Delete(ctx, vectorstore.DeleteFilter{TenantID: tenantID, DocumentID: docID}); err != nil {
+			return fmt.Errorf("delete document chunks: %w", err)
+		}
+	}
+	return nil
+}
+
+// finish records a sync's outcome on its row and on the source, and
+// schedules the source's next sync.
+func (s *Service) finish(ctx context.Context, src *models.ConnectorSource, syncID uuid.UUID, counts syncCounts, cursor string, cause error) {
+	ctx = context.WithoutCancel(ctx)
+	status, jobStatus := models.ConnectorStatusIdle, models.JobStatusSucceeded
+	var errMsg *string
+	if cause != nil {
+		status, jobStatus = models.ConnectorStatusFailed, models.JobStatusFailed
+		msg := cause.Error()
+		errMsg = &msg
+	}
+
+	if syncID != uuid.Nil {
+		if _, err := s.db.Exec(ctx,
+			`UPDATE connector_syncs
+			 SET status = $2, cursor = NULLIF($3, ''), added = $4, updated = $5, deleted = $6, unchanged = $7,
+			     skipped = $8, failed = $9, error = $10, completed_at = now()
+			 WHERE id = $1`,
+			syncID, jobStatus, cursor, counts.added, counts.updated, counts.deleted, counts.unchanged,
+			counts.skipped, counts.failed, errMsg,
+		); err != nil {
+			slog.Error("failed to record connector sync", "sync_id", syncID, "error", err)
+		}
+	}
+
+	if _, err := s.db.Exec(ctx,
+		`UPDATE connector_sources
+		 SET status = $2, cursor = NULLIF($3, ''), error = $4, last_synced_at = now(), sync_started_at = NULL,
+		     next_sync_at = CASE WHEN sync_interval_minutes > 0
+		                         THEN now() + make_interval(mins => sync_interval_minutes) END,
+		     updated_at = now()
+		 WHERE id = $1`,
+		src.ID, status, cursor, errMsg,
+	); err != nil {
+		slog.Error("failed to record connector source status", "source_id", src.ID, "error", err)
+	}
+}
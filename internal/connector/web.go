@@ -0,0 +1,656 @@
+package connector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
+)
+
+const (
+	defaultCrawlDepth = 3
+	defaultCrawlPages = 100
+	defaultCrawlDelay = time.Second
+	// maxCrawlDelay caps robots.txt Crawl-delay values.
+	maxCrawlDelay = time.Minute
+	// maxSitemaps and maxSitemapURLs bound how much of a site's sitemaps
+	// is read.
+	maxSitemaps    = 50
+	maxSitemapURLs = 50000
+	// pageCacheBytes bounds the page bodies kept from List for Open, so new
+	// pages aren't fetched twice.
+	pageCacheBytes = 128 << 20
+)
+
+// WebConfig is a website crawled from seed URLs. Only the seeds' hosts are
+// crawled, and with PathPrefixes only the paths under them. Include and
+// exclude patterns match URL paths without the leading '/'; excluded pages
+// are not fetched, and pages not included are crawled for links but not
+// synced.
+type WebConfig struct {
+	Seeds             []string `json:"seeds"`
+	PathPrefixes      []string `json:"path_prefixes,omitempty"`
+	IncludeSubdomains bool     `json:"include_subdomains,omitempty"`
+	// MaxDepth is how many links away from a seed the crawl goes; default
+	// 3, and 0 fetches only the seeds and sitemap URLs.
+	MaxDepth *int `json:"max_depth,omitempty"`
+	// MaxPages bounds the pages fetched per crawl; default 100.
+	MaxPages int `json:"max_pages,omitempty"`
+	// DelayMS is the pause between requests to a host; default 1000. A
+	// longer robots.txt Crawl-delay wins.
+	DelayMS      *int `json:"delay_ms,omitempty"`
+	SkipSitemaps bool `json:"skip_sitemaps,omitempty"`
+	Filter
+}
+
+func (c WebConfig) validate(maxPages int) error {
+	if len(c.Seeds) == 0 {
+		return fmt.Errorf("at least one seed URL is required")
+	}
+	for _, seed := range c.Seeds {
+		u, err := url.Parse(seed)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("seed %q must be an http or https URL", seed)
+		}
+	}
+	for _, prefix := range c.PathPrefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("path prefix %q must start with /", prefix)
+		}
+	}
+	if c.MaxDepth != nil && *c.MaxDepth < 0 {
+		return fmt.Errorf("max_depth must not be negative")
+	}
+	if c.MaxPages < 0 || (maxPages > 0 && c.MaxPages > maxPages) {
+		return fmt.Errorf("max_pages must be between 0 and %d", maxPages)
+	}
+	if c.DelayMS != nil && (*c.DelayMS < 0 || time.Duration(*c.DelayMS)*time.Millisecond > maxCrawlDelay) {
+		return fmt.Errorf("delay_ms must be between 0 and %d", maxCrawlDelay.Milliseconds())
+	}
+	return c.Filter.validate()
+}
+
+// Web crawls a site breadth-first, honouring robots.txt and reading its
+// sitemaps. A page's path is its canonical URL, and its version is a hash
+// of its main content, so changes to navigation or other site chrome don't
+// count. Pages with the same content as one already listed are dropped.
+type Web struct {
+	cfg       WebConfig
+	userAgent string
+	maxBytes  int64
+	depth     int
+	pages     int
+	delay     time.Duration
+	client    *http.Client
+
+	seeds     []*url.URL
+	robots    map[string]*robotsRules // by origin
+	lastFetch map[string]time.Time    // by origin
+	bodies    map[string][]byte       // by item path
+	cached    int
+}
+
+func NewWeb(cfg WebConfig, conn config.ConnectorConfig) *Web {
+	w := &Web{
+		cfg:       cfg,
+		userAgent: conn.CrawlUserAgent,
+		maxBytes:  conn.MaxFileBytes,
+		depth:     defaultCrawlDepth,
+		pages:     defaultCrawlPages,
+		delay:     defaultCrawlDelay,
+		robots:    map[string]*robotsRules{},
+		lastFetch: map[string]time.Time{},
+		bodies:    map[string][]byte{},
+	}
+	if w.userAgent == "" {
+		w.userAgent = "BackendWithAI-Crawler/1.0"
+	}
+	if cfg.MaxDepth != nil {
+		w.depth = *cfg.MaxDepth
+	}
+	if cfg.MaxPages > 0 {
+		w.pages = cfg.MaxPages
+	}
+	if cfg.DelayMS != nil {
+		w.delay = time.Duration(*cfg.DelayMS) * time.Millisecond
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !conn.CrawlAllowPrivate {
+		// Checked on the resolved address, so redirects and DNS names
+		// pointing inside the network are refused too. A proxy would hide
+		// the address, so none is used.
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   publicAddressOnly,
+		}).DialContext
+	}
+	w.client = &http.Client{Timeout: time.Minute, Transport: transport}
+
+	for _, seed := range cfg.Seeds {
+		if u, err := url.Parse(seed); err == nil {
+			w.seeds = append(w.seeds, normalizeURL(u))
+		}
+	}
+	return w
+}
+
+// publicAddressOnly refuses connections to loopback, private, link-local
+// and unspecified addresses.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("refusing to crawl non-public address %s", host)
+	}
+	return nil
+}
+
+// normalizeURL drops the fragment, credentials, default port and utm_*
+// tracking parameters, lower-cases the scheme and host, and sorts the
+// query, so one page has one URL.
+func normalizeURL(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if (n.Scheme == "http" && strings.HasSuffix(n.Host, ":80")) || (n.Scheme == "https" && strings.HasSuffix(n.Host, ":443")) {
+		n.Host = n.Host[:strings.LastIndexByte(n.Host, ':')]
+	}
+	n.User = nil
+	n.Fragment, n.RawFragment = "", ""
+	if n.Path == "" {
+		n.Path, n.RawPath = "/", ""
+	}
+	if n.RawQuery != "" {
+		query := n.Query()
+		for name := range query {
+			if strings.HasPrefix(strings.ToLower(name), "utm_") {
+				query.Del(name)
+			}
+		}
+		n.RawQuery = query.Encode()
+	}
+	n.ForceQuery = false
+	return &n
+}
+
+func origin(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// inScope reports whether the crawl may fetch u.
+func (w *Web) inScope(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := u.Hostname()
+	sameSite := false
+	for _, seed := range w.seeds {
+		seedHost := seed.Hostname()
+		if host == seedHost || (w.cfg.IncludeSubdomains && strings.HasSuffix(host, "."+seedHost)) {
+			sameSite = true
+			break
+		}
+	}
+	if !sameSite {
+		return false
+	}
+	if len(w.cfg.PathPrefixes) > 0 {
+		under := false
+		for _, prefix := range w.cfg.PathPrefixes {
+			if strings.HasPrefix(u.Path, prefix) {
+				under = true
+				break
+			}
+		}
+		if !under {
+			return false
+		}
+	}
+	p := strings.TrimPrefix(u.Path, "/")
+	for _, pattern := range w.cfg.Exclude {
+		if matchGlob(pattern, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// included reports whether a crawled page is synced.
+func (w *Web) included(u *url.URL) bool {
+	if len(w.cfg.Include) == 0 {
+		return true
+	}
+	p := strings.TrimPrefix(u.Path, "/")
+	for _, pattern := range w.cfg.Include {
+		if matchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Web) isSeed(u *url.URL) bool {
+	for _, seed := range w.seeds {
+		if seed.String() == u.String() {
+			return true
+		}
+	}
+	return false
+}
+
+type crawlTarget struct {
+	url   *url.URL
+	depth int
+}
+
+// List crawls the site. The cursor is the time the crawl started. Pages
+// that can't be fetched for a reason other than being gone are listed
+// with Err set, so their documents are kept; when a seed can't be fetched
+// the whole crawl fails, rather than every page looking removed.
+func (w *Web) List(ctx context.Context) ([]Item, string, error) {
+	started := time.Now().UTC()
+	for _, seed := range w.seeds {
+		if err := w.robotsFor(ctx, seed).unavailable; err != nil {
+			return nil, "", fmt.Errorf("read robots.txt of %s: %w", origin(seed), err)
+		}
+	}
+	var queue []crawlTarget
+	queued := map[string]bool{}
+	enqueue := func(u *url.URL, depth int) {
+		u = normalizeURL(u)
+		if key := u.String(); !queued[key] && w.inScope(u) {
+			queued[key] = true
+			queue = append(queue, crawlTarget{url: u, depth: depth})
+		}
+	}
+	for _, seed := range w.seeds {
+		enqueue(seed, 0)
+	}
+	if !w.cfg.SkipSitemaps {
+		for _, u := range w.sitemapURLs(ctx) {
+			enqueue(u, 0)
+		}
+	}
+
+	var items []Item
+	listed := map[string]bool{}
+	contents := map[string]bool{}
+	for fetched := 0; len(queue) > 0 && fetched < w.pages; {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		target := queue[0]
+		queue = queue[1:]
+		if !w.robotsFor(ctx, target.url).allowed(target.url.RequestURI()) {
+			continue
+		}
+		fetched++
+
+		page, err := w.fetchPage(ctx, target.url)
+		if err != nil && w.isSeed(target.url) {
+			return nil, "", err
+		}
+		if err != nil {
+			if !errors.Is(err, errPageGone) {
+				slog.Warn("failed to crawl page", "url", target.url.String(), "error", err)
+				items = append(items, Item{Path: target.url.String(), URL: target.url.String(), Err: err})
+				listed[target.url.String()] = true
+			}
+			continue
+		}
+		if !page.noFollow && target.depth < w.depth {
+			for _, link := range page.links {
+				enqueue(link, target.depth+1)
+			}
+		}
+
+		key := page.url.String()
+		if page.noIndex || !w.inScope(page.url) || !w.included(page.url) || listed[key] || contents[page.version] {
+			continue
+		}
+		listed[key], contents[page.version] = true, true
+		// A redirect or canonical link may name a page still queued.
+		queued[key] = true
+		items = append(items, Item{
+			Path:        key,
+			Version:     page.version,
+			Size:        int64(len(page.body)),
+			ModTime:     page.modTime,
+			Title:       page.title,
+			URL:         key,
+			ContentType: page.contentType,
+		})
+		if w.cached+len(page.body) <= pageCacheBytes {
+			w.bodies[key] = page.body
+			w.cached += len(page.body)
+		}
+	}
+	return items, started.Format(time.RFC3339), nil
+}
+
+// errPageGone marks pages that no longer exist (404 or 410).
+var errPageGone = errors.New("page is gone")
+
+type crawledPage struct {
+	url         *url.URL // canonical URL
+	body        []byte
+	version     string
+	title       string
+	contentType string
+	modTime     time.Time
+	links       []*url.URL
+	noIndex     bool
+	noFollow    bool
+}
+
+// fetchPage fetches and parses one page.
+func (w *Web) fetchPage(ctx context.Context, u *url.URL) (*crawledPage, error) {
+	resp, err := w.get(ctx, u, "text/html,application/xhtml+xml,*/*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, errPageGone
+	case resp.StatusCode >= 400:
+		return nil, fmt.Errorf("fetch %s: status %d", u, resp.StatusCode)
+	}
+
+	body, err := w.readBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", u, err)
+	}
+	page := &crawledPage{
+		url:  normalizeURL(resp.Request.URL),
+		body: body,
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		page.modTime = modified
+	}
+	robotsTag := resp.Header.Get("X-Robots-Tag")
+	page.noIndex = hasDirective(robotsTag, "noindex") || hasDirective(robotsTag, "none")
+	page.noFollow = hasDirective(robotsTag, "nofollow") || hasDirective(robotsTag, "none")
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		// Linked files, such as PDFs, are synced when they are a supported
+		// type; their links aren't followed.
+		if textextract.Detect(bytes.NewReader(body), int64(len(body)), mediaType, path.Base(page.url.Path)) == "" {
+			page.noIndex = true
+		}
+		page.contentType = mediaType
+		page.version = contentHash(body)
+		return page, nil
+	}
+
+	parsed := textextract.ParseHTMLPage(body)
+	page.contentType = "text/html"
+	page.title = parsed.Title
+	page.version = contentHash([]byte(parsed.Text))
+	page.noIndex = page.noIndex || parsed.NoIndex
+	page.noFollow = page.noFollow || parsed.NoFollow
+
+	base := resp.Request.URL
+	if parsed.Base != "" {
+		if b, err := base.Parse(parsed.Base); err == nil {
+			base = b
+		}
+	}
+	if parsed.Canonical != "" {
+		if canonical, err := base.Parse(parsed.Canonical); err == nil {
+			if canonical = normalizeURL(canonical); w.inScope(canonical) {
+				page.url = canonical
+			}
+		}
+	}
+	for _, href := range parsed.Links {
+		if link, err := base.Parse(href); err == nil {
+			page.links = append(page.links, link)
+		}
+	}
+	return page, nil
+}
+
+func hasDirective(header, directive string) bool {
+	for _, d := range strings.Split(header, ",") {
+		// Directives may be scoped to a crawler: "googlebot: noindex".
+		if _, value, ok := strings.Cut(d, ":"); ok {
+			d = value
+		}
+		if strings.EqualFold(strings.TrimSpace(d), directive) {
+			return true
+		}
+	}
+	return false
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (w *Web) readBody(r io.Reader) ([]byte, error) {
+	if w.maxBytes <= 0 {
+		return io.ReadAll(r)
+	}
+	body, err := io.ReadAll(io.LimitReader(r, w.maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > w.maxBytes {
+		return nil, fmt.Errorf("page exceeds %d bytes", w.maxBytes)
+	}
+	return body, nil
+}
+
+// get sends a GET, first waiting out the politeness delay for the host.
+func (w *Web) get(ctx context.Context, u *url.URL, accept string) (*http.Response, error) {
+	if err := w.wait(ctx, u); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("User-Agent", w.userAgent)
+	req.Header.Set("Accept", accept)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", u, err)
+	}
+	return resp, nil
+}
+
+func (w *Web) wait(ctx context.Context, u *url.URL) error {
+	key := origin(u)
+	delay := w.delay
+	if rules := w.robots[key]; rules != nil && rules.delay > delay {
+		delay = min(rules.delay, maxCrawlDelay)
+	}
+	if last, ok := w.lastFetch[key]; ok {
+		if remaining := time.Until(last.Add(delay)); remaining > 0 {
+			timer := time.NewTimer(remaining)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+	}
+	w.lastFetch[key] = time.Now()
+	return nil
+}
+
+// robotsFor returns the robots.txt rules of u's host, fetching them once
+// per crawl. A missing robots.txt allows everything; one that fails with a
+// server error disallows everything.
+func (w *Web) robotsFor(ctx context.Context, u *url.URL) *robotsRules {
+	key := origin(u)
+	if rules, ok := w.robots[key]; ok {
+		return rules
+	}
+	robotsURL, _ := url.Parse(key + "/robots.txt")
+	rules := &robotsRules{}
+	resp, err := w.get(ctx, robotsURL, "text/plain")
+	switch {
+	case err != nil:
+		rules.unavailable = err
+	case resp.StatusCode >= 500:
+		resp.Body.Close()
+		rules.unavailable = fmt.Errorf("status %d", resp.StatusCode)
+	case resp.StatusCode >= 400:
+		resp.Body.Close()
+	default:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512<<10))
+		resp.Body.Close()
+		rules = parseRobots(data, w.userAgent)
+	}
+	if rules.unavailable != nil {
+		slog.Warn("robots.txt unavailable; skipping host", "origin", key, "error", rules.unavailable)
+	}
+	w.robots[key] = rules
+	return rules
+}
+
+type sitemapDoc struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// sitemapURLs reads the sitemaps robots.txt lists for each seed's host, or
+// its /sitemap.xml, following sitemap indexes.
+func (w *Web) sitemapURLs(ctx context.Context) []*url.URL {
+	var pending []string
+	seenOrigin := map[string]bool{}
+	for _, seed := range w.seeds {
+		key := origin(seed)
+		if seenOrigin[key] {
+			continue
+		}
+		seenOrigin[key] = true
+		rules := w.robotsFor(ctx, seed)
+		if rules.unavailable != nil {
+			continue
+		}
+		if len(rules.sitemaps) > 0 {
+			pending = append(pending, rules.sitemaps...)
+		} else {
+			pending = append(pending, key+"/sitemap.xml")
+		}
+	}
+
+	var urls []*url.URL
+	read := map[string]bool{}
+	for len(pending) > 0 && len(read) < maxSitemaps && len(urls) < maxSitemapURLs {
+		loc := pending[0]
+		pending = pending[1:]
+		if read[loc] {
+			continue
+		}
+		read[loc] = true
+		doc, err := w.fetchSitemap(ctx, loc)
+		if err != nil {
+			slog.Debug("skipping sitemap", "url", loc, "error", err)
+			continue
+		}
+		for _, s := range doc.Sitemaps {
+			pending = append(pending, strings.TrimSpace(s.Loc))
+		}
+		for _, entry := range doc.URLs {
+			if u, err := url.Parse(strings.TrimSpace(entry.Loc)); err == nil && len(urls) < maxSitemapURLs {
+				urls = append(urls, u)
+			}
+		}
+	}
+	return urls
+}
+
+func (w *Web) fetchSitemap(ctx context.Context, loc string) (*sitemapDoc, error) {
+	u, err := url.Parse(loc)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid sitemap URL")
+	}
+	resp, err := w.get(ctx, u, "application/xml,text/xml,*/*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := w.readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("open gzip: %w", err)
+		}
+		if body, err = w.readBody(zr); err != nil {
+			return nil, fmt.Errorf("read gzip: %w", err)
+		}
+	}
+	var doc sitemapDoc
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("decode sitemap: %w", err)
+	}
+	return &doc, nil
+}
+
+// Open returns the page as fetched by List when it was kept, or fetches it
+// again.
+func (w *Web) Open(ctx context.Context, item Item) (io.ReadCloser, error) {
+	if body, ok := w.bodies[item.Path]; ok {
+		delete(w.bodies, item.Path)
+		w.cached -= len(body)
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	u, err := url.Parse(item.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", item.URL, err)
+	}
+	resp, err := w.get(ctx, u, "text/html,application/xhtml+xml,*/*;q=0.8")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetch %s: status %d", u, resp.StatusCode)
+	}
+	body, err := w.readBody(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", u, err)
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
@@ -0,0 +1,168 @@
+package document
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+)
+
+// maxDiffCells bounds the line diff of one section (old lines × new lines);
+// larger sections are shown as wholly replaced.
+const maxDiffCells = 1 << 20
+
+// Diff compares the sections of a document with those of another version
+// of it, by default the one before it. The caller must be able to read
+// both.
+func (s *Service) Diff(ctx context.Context, id, against uuid.UUID) (*models.DocumentDiff, error) {
+	doc, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if against == uuid.Nil {
+		prev, err := s.PreviousVersion(ctx, doc)
+		if err != nil {
+			return nil, err
+		}
+		if prev == nil {
+			return nil, ErrNoPreviousVersion
+		}
+		against = prev.ID
+	}
+	old, err := s.GetByID(ctx, against)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ExternalID == "" || old.ExternalID != doc.ExternalID || old.ID == doc.ID {
+		return nil, ErrNotVersion
+	}
+
+	newContent, err := s.Content(ctx, doc.TenantID, doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	oldContent, err := s.Content(ctx, old.TenantID, old.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := diffSections(oldContent.Sections, newContent.Sections)
+	diff.DocumentID = doc.ID
+	diff.Version = doc.Version
+	diff.AgainstID = old.ID
+	diff.AgainstVersion = old.Version
+	return diff, nil
+}
+
+// diffSections matches sections by kind and title, or by kind and page for
+// untitled ones, counting repeats in order. Changes follow the new
+// version's order, with removed sections last.
+func diffSections(old, current []models.DocumentSection) *models.DocumentDiff {
+	oldByKey := make(map[string]models.DocumentSection, len(old))
+	oldKeys := sectionKeys(old)
+	for i, key := range oldKeys {
+		oldByKey[key] = old[i]
+	}
+
+	diff := &models.DocumentDiff{Changes: []models.SectionChange{}}
+	matched := make(map[string]bool, len(current))
+	for i, key := range sectionKeys(current) {
+		sec := current[i]
+		prev, ok := oldByKey[key]
+		if !ok {
+			diff.Changes = append(diff.Changes, models.SectionChange{
+				Status: models.SectionAdded, Kind: sec.Kind, Title: sec.Title, Page: sec.Page,
+				NewContent: sec.Content,
+			})
+			continue
+		}
+		matched[key] = true
+		if prev.Content == sec.Content {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changes = append(diff.Changes, models.SectionChange{
+			Status: models.SectionChanged, Kind: sec.Kind, Title: sec.Title, Page: sec.Page,
+			OldContent: prev.Content, NewContent: sec.Content,
+			Lines: diffLines(prev.Content, sec.Content),
+		})
+	}
+	for i, key := range oldKeys {
+		if matched[key] {
+			continue
+		}
+		sec := old[i]
+		diff.Changes = append(diff.Changes, models.SectionChange{
+			Status: models.SectionRemoved, Kind: sec.Kind, Title: sec.Title, Page: sec.Page,
+			OldContent: sec.Content,
+		})
+	}
+	return diff
+}
+
+func sectionKeys(sections []models.DocumentSection) []string {
+	seen := make(map[string]int, len(sections))
+	keys := make([]string, len(sections))
+	for i, sec := range sections {
+		key := sec.Kind + "|" + sec.Title
+		if sec.Title == "" {
+			key = sec.Kind + "|#" + strconv.Itoa(sec.Page)
+		}
+		seen[key]++
+		keys[i] = key + "|" + strconv.Itoa(seen[key])
+	}
+	return keys
+}
+
+// diffLines returns a line diff of two texts from their longest common
+// subsequence of lines.
+func diffLines(oldText, newText string) []string {
+	a := strings.Split(oldText, "\n")
+	b := strings.Split(newText, "\n")
+	if len(a)*len(b) > maxDiffCells {
+		lines := make([]string, 0, len(a)+len(b))
+		for _, l := range a {
+			lines = append(lines, "-"+l)
+		}
+		for _, l := range b {
+			lines = append(lines, "+"+l)
+		}
+		return lines
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	return lines
+}
@@ -5,6 +5,11 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
 )
@@ -14,13 +19,18 @@ type TextExtractor interface {
 	SupportedTypes() []string
 }
 
+// minPageText is how much text a PDF page needs to not be taken for a scan.
+const minPageText = 50
+
 type extractor struct {
 	ocr *OCRService
 }
 
-func NewTextExtractor() TextExtractor {
+// NewTextExtractor returns an extractor that OCRs images and scanned PDF
+// pages with ocr. A nil ocr disables OCR.
+func NewTextExtractor(ocr *OCRService) TextExtractor {
 	return &extractor{
-		ocr: NewOCRService(),
+		ocr: ocr,
 	}
 }
 
@@ -30,13 +40,20 @@ func (e *extractor) Extract(ctx context.Context, data io.ReaderAt, size int64, f
 		return nil, fmt.Errorf("extract text: %w", err)
 	}
 
-	// If extracted text is empty/minimal, try OCR for PDFs
-	if len(result.Content) < 50 && (fileType == ".pdf" || fileType == "pdf") && e.ocr.IsAvailable() {
-		// For scanned PDFs, we'd need to convert pages to images first.
-		// This is a placeholder for the full OCR pipeline.
-		_ = ctx
+	format := result.Metadata["type"]
+	switch {
+	case textextract.IsImage(format):
+		if e.ocr == nil || !e.ocr.IsAvailable() {
+			return nil, fmt.Errorf("extract text: OCR is not available for %s images", format)
+		}
+		return e.ocrImage(ctx, data, size, result)
+	case format == textextract.FormatPDF:
+		pages := scannedPages(result)
+		if len(pages) == 0 || e.ocr == nil || !e.ocr.CanRasterize() {
+			return result, nil
+		}
+		return e.ocrPDF(ctx, data, size, result, pages)
 	}
-
 	return result, nil
 }
 
@@ -44,6 +61,168 @@ func (e *extractor) SupportedTypes() []string {
 	return textextract.SupportedTypes()
 }
 
+// ocrImage recognizes an image's text, one page per TIFF frame.
+func (e *extractor) ocrImage(ctx context.Context, data io.ReaderAt, size int64, result *textextract.ExtractedText) (*textextract.ExtractedText, error) {
+	path, err := writeTemp(data, size, "."+result.Metadata["type"])
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	pages, err := e.ocr.Recognize(ctx, path, 0)
+	if err != nil {
+		return nil, fmt.Errorf("extract text: %w", err)
+	}
+
+	var blocks []textextract.Block
+	recognized := make(map[int]OCRPage, len(pages))
+	for i, p := range pages {
+		p.Page = i + 1
+		recognized[p.Page] = p
+		if i > 0 {
+			blocks = append(blocks, textextract.Block{Type: textextract.BlockPageBreak, Page: p.Page})
+		}
+		blocks = append(blocks, ocrBlocks(p)...)
+	}
+
+	ocred := textextract.FromBlocks(blocks, result.Metadata)
+	ocred.Pages = max(len(pages), 1)
+	e.recordOCR(ocred.Metadata, recognized, nil)
+	return ocred, nil
+}
+
+// ocrPDF recognizes the given pages of a PDF and puts their text in place
+// of what extraction found on them. Pages that can't be recognized keep
+// their extracted text.
+func (e *extractor) ocrPDF(ctx context.Context, data io.ReaderAt, size int64, result *textextract.ExtractedText, pages []int) (*textextract.ExtractedText, error) {
+	path, err := writeTemp(data, size, ".pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	recognized, failed, err := e.ocr.RecognizePDF(ctx, path, pages)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("extract text: %w", err)
+		}
+		// A PDF that can't be rendered still has its extracted text.
+		slog.Warn("OCR failed", "error", err)
+		return result, nil
+	}
+
+	byPage := make(map[int][]textextract.Block, result.Pages)
+	for _, b := range result.Blocks {
+		if b.Type != textextract.BlockPageBreak {
+			byPage[b.Page] = append(byPage[b.Page], b)
+		}
+	}
+	var blocks []textextract.Block
+	for page := 1; page <= result.Pages; page++ {
+		if page > 1 {
+			blocks = append(blocks, textextract.Block{Type: textextract.BlockPageBreak, Page: page})
+		}
+		if p, ok := recognized[page]; ok && p.Words > 0 {
+			blocks = append(blocks, ocrBlocks(p)...)
+		} else {
+			blocks = append(blocks, byPage[page]...)
+		}
+	}
+
+	ocred := textextract.FromBlocks(blocks, result.Metadata)
+	ocred.Pages = result.Pages
+	e.recordOCR(ocred.Metadata, recognized, failed)
+	return ocred, nil
+}
+
+// recordOCR notes in the metadata which pages were OCRed and how confident
+// tesseract was, overall and per page.
+func (e *extractor) recordOCR(metadata map[string]string, recognized map[int]OCRPage, failed []int) {
+	pages := make([]int, 0, len(recognized))
+	for page := range recognized {
+		pages = append(pages, page)
+	}
+	sort.Ints(pages)
+
+	var (
+		numbers, confidences []string
+		sum                  float64
+		words                int
+	)
+	for _, page := range pages {
+		p := recognized[page]
+		numbers = append(numbers, strconv.Itoa(page))
+		confidences = append(confidences, strconv.FormatFloat(p.Confidence, 'f', 1, 64))
+		sum += p.Confidence * float64(p.Words)
+		words += p.Words
+	}
+
+	metadata["ocr"] = "tesseract"
+	metadata["ocr_language"] = e.ocr.Language()
+	metadata["ocr_pages"] = strings.Join(numbers, ",")
+	metadata["ocr_page_confidence"] = strings.Join(confidences, ",")
+	if words > 0 {
+		metadata["ocr_confidence"] = strconv.FormatFloat(sum/float64(words), 'f', 1, 64)
+	}
+	if len(failed) > 0 {
+		sort.Ints(failed)
+		failedPages := make([]string, len(failed))
+		for i, page := range failed {
+			failedPages[i] = strconv.Itoa(page)
+		}
+		metadata["ocr_failed_pages"] = strings.Join(failedPages, ",")
+	}
+}
+
+// scannedPages lists the pages of an extracted PDF with too little text to
+// be anything but scans.
+func scannedPages(result *textextract.ExtractedText) []int {
+	text := make(map[int]int, result.Pages)
+	for _, b := range result.Blocks {
+		n := len(strings.TrimSpace(b.Text))
+		for _, row := range b.Rows {
+			for _, cell := range row {
+				n += len(cell)
+			}
+		}
+		text[b.Page] += n
+	}
+	var pages []int
+	for page := 1; page <= result.Pages; page++ {
+		if text[page] < minPageText {
+			pages = append(pages, page)
+		}
+	}
+	return pages
+}
+
+// ocrBlocks turns a recognized page into paragraph blocks.
+func ocrBlocks(p OCRPage) []textextract.Block {
+	blocks := make([]textextract.Block, 0, len(p.Paragraphs))
+	for _, para := range p.Paragraphs {
+		blocks = append(blocks, textextract.Block{Type: textextract.BlockParagraph, Text: para, Page: p.Page})
+	}
+	return blocks
+}
+
+// writeTemp copies data to a temporary file for the OCR tools to read.
+func writeTemp(data io.ReaderAt, size int64, ext string) (string, error) {
+	f, err := os.CreateTemp("", "ocr-*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	if _, err := io.Copy(f, io.NewSectionReader(data, 0, size)); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("write temp file: %w", err)
+	}
+	return f.Name(), nil
+}
+
 // ReaderAtFromBytes creates an io.ReaderAt from a byte slice.
 func ReaderAtFromBytes(data []byte) *bytes.Reader {
 	return bytes.NewReader(data)
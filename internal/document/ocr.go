@@ -3,34 +3,259 @@ package document
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/nikhilbhutani/backendwithai/internal/config"
 )
 
+// OCRService recognizes text in images with tesseract, and renders scanned
+// PDF pages to images with pdftoppm first.
 type OCRService struct {
 	tesseractPath string
+	pdftoppmPath  string
+	language      string
+	dpi           int
+	concurrency   int
+
+	once      sync.Once
+	available bool
+	rasterize bool
+}
+
+// OCRPage is the text recognized on one page, split into paragraphs.
+type OCRPage struct {
+	Page       int
+	Paragraphs []string
+	// Confidence is the mean word confidence, 0-100.
+	Confidence float64
+	Words      int
+}
+
+// Text returns the page's paragraphs separated by blank lines.
+func (p OCRPage) Text() string {
+	return strings.Join(p.Paragraphs, "\n\n")
+}
+
+func NewOCRService(cfg config.OCRConfig) *OCRService {
+	o := &OCRService{
+		tesseractPath: lookPath(cfg.TesseractBin, "tesseract"),
+		pdftoppmPath:  lookPath(cfg.PdftoppmBin, "pdftoppm"),
+		language:      cfg.Language,
+		dpi:           cfg.DPI,
+		concurrency:   cfg.Concurrency,
+	}
+	if o.language == "" {
+		o.language = "eng"
+	}
+	if o.dpi <= 0 {
+		o.dpi = 300
+	}
+	if o.concurrency <= 0 {
+		o.concurrency = 4
+	}
+	return o
 }
 
-func NewOCRService() *OCRService {
-	path, _ := exec.LookPath("tesseract")
-	if path == "" {
-		path = "tesseract"
+func lookPath(bin, fallback string) string {
+	if bin == "" {
+		bin = fallback
 	}
-	return &OCRService{tesseractPath: path}
+	if path, err := exec.LookPath(bin); err == nil {
+		return path
+	}
+	return bin
+}
+
+func (o *OCRService) probe() {
+	o.once.Do(func() {
+		o.available = exec.Command(o.tesseractPath, "--version").Run() == nil
+		o.rasterize = exec.Command(o.pdftoppmPath, "-v").Run() == nil
+	})
 }
 
+// IsAvailable reports whether tesseract can be run.
 func (o *OCRService) IsAvailable() bool {
-	cmd := exec.Command(o.tesseractPath, "--version")
-	return cmd.Run() == nil
+	o.probe()
+	return o.available
+}
+
+// CanRasterize reports whether PDF pages can be rendered for OCR.
+func (o *OCRService) CanRasterize() bool {
+	o.probe()
+	return o.available && o.rasterize
+}
+
+// Language returns the tesseract languages text is recognized in.
+func (o *OCRService) Language() string {
+	return o.language
 }
 
+// ExtractText recognizes the text of an image.
 func (o *OCRService) ExtractText(ctx context.Context, imagePath string) (string, error) {
-	cmd := exec.CommandContext(ctx, o.tesseractPath, imagePath, "stdout", "-l", "eng")
+	pages, err := o.Recognize(ctx, imagePath, 0)
+	if err != nil {
+		return "", err
+	}
+	texts := make([]string, 0, len(pages))
+	for _, p := range pages {
+		if text := p.Text(); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n"), nil
+}
+
+// Recognize runs tesseract on an image and returns the text of each of its
+// pages (multi-page TIFFs have several). dpi is the image's resolution when
+// known, 0 otherwise.
+func (o *OCRService) Recognize(ctx context.Context, imagePath string, dpi int) ([]OCRPage, error) {
+	args := []string{imagePath, "stdout", "-l", o.language}
+	if dpi > 0 {
+		args = append(args, "--dpi", strconv.Itoa(dpi))
+	}
+	args = append(args, "tsv")
+
+	output, err := exec.CommandContext(ctx, o.tesseractPath, args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("tesseract OCR: %w", err)
+	}
+	return parseTSV(string(output)), nil
+}
+
+// parseTSV reads tesseract's TSV output. Words are joined into lines and
+// lines into paragraphs; confidence is averaged over the words.
+func parseTSV(tsv string) []OCRPage {
+	type key struct{ page, block, par int }
+	var (
+		pages    []OCRPage
+		current  *OCRPage
+		para     key
+		words    []string
+		confSum  float64
+		lastPage = -1
+	)
+	flushPara := func() {
+		if current != nil && len(words) > 0 {
+			current.Paragraphs = append(current.Paragraphs, strings.Join(words, " "))
+		}
+		words = nil
+	}
+	flushPage := func() {
+		flushPara()
+		if current != nil {
+			if current.Words > 0 {
+				current.Confidence = confSum / float64(current.Words)
+			}
+			pages = append(pages, *current)
+		}
+		confSum = 0
+	}
+
+	for i, line := range strings.Split(tsv, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if i == 0 || len(fields) < 12 || fields[0] != "5" {
+			continue // the header and non-word rows
+		}
+		text := strings.TrimSpace(fields[11])
+		if text == "" {
+			continue
+		}
+		var k key
+		k.page, _ = strconv.Atoi(fields[1])
+		k.block, _ = strconv.Atoi(fields[2])
+		k.par, _ = strconv.Atoi(fields[3])
+		conf, _ := strconv.ParseFloat(fields[10], 64)
+
+		if k.page != lastPage {
+			flushPage()
+			current = &OCRPage{Page: k.page}
+			lastPage = k.page
+		} else if k != para {
+			flushPara()
+		}
+		para = k
+		words = append(words, text)
+		current.Words++
+		confSum += max(conf, 0)
+	}
+	flushPage()
+	return pages
+}
 
-	output, err := cmd.Output()
+// RecognizePDF renders the given pages of a PDF and recognizes them,
+// several at once. Pages that fail are left out and reported in the error,
+// which is only returned when no page could be read.
+func (o *OCRService) RecognizePDF(ctx context.Context, pdfPath string, pages []int) (map[int]OCRPage, []int, error) {
+	dir, err := os.MkdirTemp("", "ocr-")
 	if err != nil {
-		return "", fmt.Errorf("tesseract OCR: %w", err)
+		return nil, nil, fmt.Errorf("create OCR dir: %w", err)
 	}
+	defer os.RemoveAll(dir)
 
-	return strings.TrimSpace(string(output)), nil
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		results  = make(map[int]OCRPage, len(pages))
+		failed   []int
+		firstErr error
+		sem      = make(chan struct{}, o.concurrency)
+	)
+	for _, page := range pages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result, err := o.recognizePage(ctx, pdfPath, dir, page)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, page)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("page %d: %w", page, err)
+				}
+				return
+			}
+			results[page] = result
+		}()
+	}
+	wg.Wait()
+
+	if len(results) == 0 && firstErr != nil {
+		return nil, failed, firstErr
+	}
+	return results, failed, ctx.Err()
+}
+
+func (o *OCRService) recognizePage(ctx context.Context, pdfPath, dir string, page int) (OCRPage, error) {
+	if err := ctx.Err(); err != nil {
+		return OCRPage{}, err
+	}
+	n := strconv.Itoa(page)
+	base := filepath.Join(dir, "page-"+n)
+	cmd := exec.CommandContext(ctx, o.pdftoppmPath,
+		"-f", n, "-l", n, "-r", strconv.Itoa(o.dpi), "-gray", "-png", "-singlefile", pdfPath, base)
+	if err := cmd.Run(); err != nil {
+		return OCRPage{}, fmt.Errorf("rasterize: %w", err)
+	}
+	defer os.Remove(base + ".png")
+
+	pages, err := o.Recognize(ctx, base+".png", o.dpi)
+	if err != nil {
+		return OCRPage{}, err
+	}
+	result := OCRPage{Page: page}
+	for _, p := range pages {
+		result.Paragraphs = append(result.Paragraphs, p.Paragraphs...)
+		result.Confidence = (result.Confidence*float64(result.Words) + p.Confidence*float64(p.Words)) /
+			float64(max(result.Words+p.Words, 1))
+		result.Words += p.Words
+	}
+	return result, nil
 }
@@ -3,47 +3,156 @@ package document
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/nikhilbhutani/backendwithai/internal/models"
 	"github.com/nikhilbhutani/backendwithai/internal/storage"
 	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/pkg/chunker"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
 )
 
 type Service struct {
-	db        *pgxpool.Pool
-	storage   storage.Storage
-	extractor TextExtractor
-	bucket    string
+	db      *pgxpool.Pool
+	storage storage.Storage
+	bucket  string
 }
 
 func NewService(db *pgxpool.Pool, store storage.Storage, bucket string) *Service {
 	return &Service{
-		db:        db,
-		storage:   store,
-		extractor: NewTextExtractor(),
-		bucket:    bucket,
+		db:      db,
+		storage: store,
+		bucket:  bucket,
 	}
 }
 
+// ErrNoContent is returned for documents whose text hasn't been extracted.
+var ErrNoContent = errors.New("document has no extracted text")
+
+// ErrNoFile is returned for documents stored without their original file.
+var ErrNoFile = errors.New("document has no stored file")
+
+var (
+	// ErrVersionForbidden is returned when uploading a new version of a
+	// document the caller cannot read.
+	ErrVersionForbidden = errors.New("the document's current version is not accessible")
+	// ErrNoPreviousVersion is returned when diffing a first version.
+	ErrNoPreviousVersion = errors.New("document has no previous version")
+	// ErrNotVersion is returned when diffing documents that are not
+	// versions of one another.
+	ErrNotVersion = errors.New("documents are not versions of the same document")
+)
+
 type UploadRequest struct {
-	Title    string
+	Title string
+	// FileType is the detected format (textextract.Detect); it is also the
+	// stored file's extension.
 	FileType string
 	FileSize int64
 	Data     io.Reader
 	Metadata map[string]interface{}
+	// ACL restricts the document to the uploader and the listed principals.
+	ACL models.DocumentACL
+	// IngestOptions are applied when the document is indexed.
+	IngestOptions IngestOptions
+	// ExternalID makes the upload the next version of the document with
+	// that ID. A new version without its own ACL or ingest options keeps
+	// the previous version's, and joins its collections.
+	ExternalID string
+	// SkipAccessCheck lets the upload replace a version the caller can't
+	// read, for connector syncs that run without a user.
+	SkipAccessCheck bool
+	// BatchID is the archive batch the document comes from.
+	BatchID *uuid.UUID
+}
+
+// IngestOptions are the indexing settings chosen for a document at upload.
+// Zero values use the pipeline's defaults; a collection's own settings still
+// apply when the document is indexed into it.
+type IngestOptions struct {
+	IndexType      string                `json:"index_type,omitempty"`
+	ChunkOpts      *chunker.ChunkOptions `json:"chunk_opts,omitempty"`
+	ContextHeaders string                `json:"context_headers,omitempty"`
+}
+
+// ParseIngestOptions decodes models.Document.IngestOptions.
+func ParseIngestOptions(raw json.RawMessage) (IngestOptions, error) {
+	var opts IngestOptions
+	if len(raw) == 0 {
+		return opts, nil
+	}
+	if err := json.Unmarshal(raw, &opts); err != nil {
+		return opts, fmt.Errorf("decode ingest options: %w", err)
+	}
+	return opts, nil
+}
+
+const documentColumns = `id, tenant_id, title, file_path, file_type, file_size_bytes, status, metadata, created_by,
+	acl_roles, acl_groups, acl_users, ingest_options, COALESCE(error, ''), COALESCE(external_id, ''), version, superseded_by,
+	batch_id, processed_at, created_at`
+
+func scanDocument(row interface{ Scan(...any) error }) (*models.Document, error) {
+	var d models.Document
+	err := row.Scan(&d.ID, &d.TenantID, &d.Title, &d.FilePath, &d.FileType, &d.FileSizeBytes, &d.Status, &d.Metadata, &d.CreatedBy,
+		&d.ACL.Roles, &d.ACL.Groups, &d.ACL.Users, &d.IngestOptions, &d.Error, &d.ExternalID, &d.Version, &d.SupersededBy,
+		&d.BatchID, &d.ProcessedAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ReadableBy restricts a documents query to rows the caller may read; param
+// is the number of the placeholder holding the caller's principals. Queries
+// that join documents use it too, so every listing applies the same ACL.
+func ReadableBy(param int) string {
+	return fmt.Sprintf("(access IS NULL OR access && $%d::uuid[])", param)
+}
+
+// Principals returns the caller's principals as the ReadableBy argument.
+func Principals(ctx context.Context) []uuid.UUID {
+	if p := tenant.PrincipalsFromContext(ctx); p != nil {
+		return p
+	}
+	return []uuid.UUID{}
 }
 
 func (s *Service) Upload(ctx context.Context, req UploadRequest) (*models.Document, error) {
 	tenantID := tenant.IDFromContext(ctx)
 	user := tenant.UserFromContext(ctx)
 
+	ingestOptions, err := json.Marshal(req.IngestOptions)
+	if err != nil {
+		return nil, fmt.Errorf("marshal ingest options: %w", err)
+	}
+
+	version := 1
+	var previous *models.Document
+	if req.ExternalID != "" {
+		previous, err = s.latestVersion(ctx, tenantID, req.ExternalID, !req.SkipAccessCheck)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if previous != nil {
+		version = previous.Version + 1
+		if req.ACL.Empty() {
+			req.ACL = previous.ACL
+		}
+		if req.IngestOptions == (IngestOptions{}) && len(previous.IngestOptions) > 0 {
+			ingestOptions = previous.IngestOptions
+		}
+	}
+
 	docID := uuid.New()
-	path := fmt.Sprintf("%s/%s/%s%s", tenantID, docID, time.Now().Format("20060102"), req.FileType)
+	path := fmt.Sprintf("%s/%s/%s.%s", tenantID, docID, time.Now().Format("20060102"), req.FileType)
 
 	if err := s.storage.Upload(ctx, s.bucket, path, req.Data, "application/octet-stream"); err != nil {
 		return nil, fmt.Errorf("upload to storage: %w", err)
@@ -56,40 +165,99 @@ func (s *Service) Upload(ctx context.Context, req UploadRequest) (*models.Docume
 		userID = &user.ID
 	}
 
-	var doc models.Document
-	err := s.db.QueryRow(ctx,
-		`INSERT INTO documents (id, tenant_id, title, file_path, file_type, file_size_bytes, status, metadata, created_by)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		 RETURNING id, tenant_id, title, file_path, file_type, file_size_bytes, status, metadata, created_by, created_at`,
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	doc, err := scanDocument(tx.QueryRow(ctx,
+		`INSERT INTO documents (id, tenant_id, title, file_path, file_type, file_size_bytes, status, metadata, created_by,
+		                        acl_roles, acl_groups, acl_users, access, ingest_options, external_id, version, batch_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, $17)
+		 RETURNING `+documentColumns,
 		docID, tenantID, req.Title, path, req.FileType, req.FileSize, models.DocStatusPending, metadata, userID,
-	).Scan(&doc.ID, &doc.TenantID, &doc.Title, &doc.FilePath, &doc.FileType, &doc.FileSizeBytes, &doc.Status, &doc.Metadata, &doc.CreatedBy, &doc.CreatedAt)
+		nonNil(req.ACL.Roles), nonNil(req.ACL.Groups), nonNil(req.ACL.Users), req.ACL.Access(userID), ingestOptions,
+		req.ExternalID, version, req.BatchID,
+	))
 	if err != nil {
 		return nil, fmt.Errorf("insert document: %w", err)
 	}
+	if previous != nil {
+		// The new version is indexed into the previous one's collections
+		// once it is processed.
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO document_collections (document_id, collection_id)
+			 SELECT $1, collection_id FROM document_collections WHERE document_id = $2`,
+			docID, previous.ID,
+		); err != nil {
+			return nil, fmt.Errorf("copy document collections: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit document: %w", err)
+	}
 
-	return &doc, nil
+	return doc, nil
+}
+
+// latestVersion returns the newest version of the document with the given
+// external ID, or nil if there is none. When checkAccess is set the caller
+// must be able to read it.
+func (s *Service) latestVersion(ctx context.Context, tenantID uuid.UUID, externalID string, checkAccess bool) (*models.Document, error) {
+	var id uuid.UUID
+	err := s.db.QueryRow(ctx,
+		`SELECT id FROM documents WHERE tenant_id = $1 AND external_id = $2 ORDER BY version DESC LIMIT 1`,
+		tenantID, externalID,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find latest version: %w", err)
+	}
+	if !checkAccess {
+		return s.Load(ctx, tenantID, id)
+	}
+	doc, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrVersionForbidden
+	}
+	return doc, nil
 }
 
 func (s *Service) GetByID(ctx context.Context, id uuid.UUID) (*models.Document, error) {
 	tenantID := tenant.IDFromContext(ctx)
-	var doc models.Document
-	err := s.db.QueryRow(ctx,
-		`SELECT id, tenant_id, title, file_path, file_type, file_size_bytes, status, metadata, created_by, created_at
-		 FROM documents WHERE id = $1 AND tenant_id = $2`,
-		id, tenantID,
-	).Scan(&doc.ID, &doc.TenantID, &doc.Title, &doc.FilePath, &doc.FileType, &doc.FileSizeBytes, &doc.Status, &doc.Metadata, &doc.CreatedBy, &doc.CreatedAt)
+	doc, err := scanDocument(s.db.QueryRow(ctx,
+		`SELECT `+documentColumns+` FROM documents WHERE id = $1 AND tenant_id = $2 AND `+ReadableBy(3),
+		id, tenantID, Principals(ctx),
+	))
 	if err != nil {
 		return nil, fmt.Errorf("get document: %w", err)
 	}
-	return &doc, nil
+	return doc, nil
+}
+
+// DownloadURL returns a presigned URL for the document's original file,
+// valid until expires has passed.
+func (s *Service) DownloadURL(ctx context.Context, doc *models.Document, expires time.Duration) (string, error) {
+	if doc.FilePath == "" {
+		return "", ErrNoFile
+	}
+	u, err := s.storage.PresignedURL(ctx, s.bucket, doc.FilePath, expires)
+	if err != nil {
+		return "", fmt.Errorf("presign download: %w", err)
+	}
+	return u, nil
 }
 
 func (s *Service) List(ctx context.Context, limit, offset int) ([]models.Document, error) {
 	tenantID := tenant.IDFromContext(ctx)
 	rows, err := s.db.Query(ctx,
-		`SELECT id, tenant_id, title, file_path, file_type, file_size_bytes, status, metadata, created_by, created_at
-		 FROM documents WHERE tenant_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
-		tenantID, limit, offset,
+		`SELECT `+documentColumns+` FROM documents
+		 WHERE tenant_id = $1 AND `+ReadableBy(4)+`
+		 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
+		tenantID, limit, offset, Principals(ctx),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("list documents: %w", err)
@@ -98,32 +266,442 @@ func (s *Service) List(ctx context.Context, limit, offset int) ([]models.Documen
 
 	var docs []models.Document
 	for rows.Next() {
-		var d models.Document
-		if err := rows.Scan(&d.ID, &d.TenantID, &d.Title, &d.FilePath, &d.FileType, &d.FileSizeBytes, &d.Status, &d.Metadata, &d.CreatedBy, &d.CreatedAt); err != nil {
+		d, err := scanDocument(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan document: %w", err)
 		}
-		docs = append(docs, d)
+		docs = append(docs, *d)
 	}
 	return docs, nil
 }
 
-func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
+// Delete removes a document. Versions it superseded are handed to the
+// version that superseded it; if it was the current version, the newest of
+// them becomes current again and its ID is returned so its chunks can be
+// searched again. Otherwise the returned ID is uuid.Nil.
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
 	tenantID := tenant.IDFromContext(ctx)
 
 	doc, err := s.GetByID(ctx, id)
 	if err != nil {
-		return err
+		return uuid.Nil, err
 	}
 
 	if doc.FilePath != "" {
 		_ = s.storage.Delete(ctx, s.bucket, doc.FilePath)
 	}
 
-	_, err = s.db.Exec(ctx, "DELETE FROM documents WHERE id = $1 AND tenant_id = $2", id, tenantID)
-	return err
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var restored uuid.UUID
+	if doc.ExternalID != "" {
+		successor := doc.SupersededBy
+		if successor == nil {
+			err := tx.QueryRow(ctx,
+				`SELECT id FROM documents WHERE superseded_by = $1 ORDER BY version DESC LIMIT 1`, id,
+			).Scan(&restored)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return uuid.Nil, fmt.Errorf("find previous version: %w", err)
+			}
+			if restored != uuid.Nil {
+				successor = &restored
+			}
+		}
+		if successor != nil {
+			if _, err := tx.Exec(ctx,
+				`UPDATE documents SET superseded_by = NULLIF($2, id) WHERE superseded_by = $1`,
+				id, *successor,
+			); err != nil {
+				return uuid.Nil, fmt.Errorf("reassign superseded versions: %w", err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "DELETE FROM documents WHERE id = $1 AND tenant_id = $2", id, tenantID); err != nil {
+		return uuid.Nil, fmt.Errorf("delete document: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return uuid.Nil, fmt.Errorf("commit document deletion: %w", err)
+	}
+	return restored, nil
+}
+
+// DeleteExternal removes every version of the document with the given
+// external ID without checking the caller's access, for connectors whose
+// source file was removed. It returns the deleted documents' IDs so their
+// chunks can be removed from the vector store.
+func (s *Service) DeleteExternal(ctx context.Context, tenantID uuid.UUID, externalID string) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(ctx,
+		`DELETE FROM documents WHERE tenant_id = $1 AND external_id = $2 RETURNING id, COALESCE(file_path, '')`,
+		tenantID, externalID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("delete document versions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	var paths []string
+	for rows.Next() {
+		var id uuid.UUID
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, fmt.Errorf("scan deleted document: %w", err)
+		}
+		ids = append(ids, id)
+		paths = append(paths, path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("delete document versions: %w", err)
+	}
+
+	for _, path := range paths {
+		if path != "" {
+			_ = s.storage.Delete(ctx, s.bucket, path)
+		}
+	}
+	return ids, nil
+}
+
+// Versions returns the versions of a document the caller may read, newest
+// first. A document without an external ID is its only version.
+func (s *Service) Versions(ctx context.Context, id uuid.UUID) ([]models.Document, error) {
+	doc, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc.ExternalID == "" {
+		return []models.Document{*doc}, nil
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT `+documentColumns+` FROM documents
+		 WHERE tenant_id = $1 AND external_id = $2 AND `+ReadableBy(3)+`
+		 ORDER BY version DESC`,
+		doc.TenantID, doc.ExternalID, Principals(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list document versions: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []models.Document
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan document: %w", err)
+		}
+		docs = append(docs, *d)
+	}
+	return docs, rows.Err()
+}
+
+// PreviousVersion returns the version before doc, or nil for a first
+// version, without checking the caller's access.
+func (s *Service) PreviousVersion(ctx context.Context, doc *models.Document) (*models.Document, error) {
+	if doc.ExternalID == "" {
+		return nil, nil
+	}
+	prev, err := scanDocument(s.db.QueryRow(ctx,
+		`SELECT `+documentColumns+` FROM documents
+		 WHERE tenant_id = $1 AND external_id = $2 AND version < $3
+		 ORDER BY version DESC LIMIT 1`,
+		doc.TenantID, doc.ExternalID, doc.Version,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get previous version: %w", err)
+	}
+	return prev, nil
+}
+
+// NewerVersion returns the newest ready version after doc, or nil if doc is
+// the newest indexed one, without checking the caller's access.
+func (s *Service) NewerVersion(ctx context.Context, doc *models.Document) (*models.Document, error) {
+	if doc.ExternalID == "" {
+		return nil, nil
+	}
+	newer, err := scanDocument(s.db.QueryRow(ctx,
+		`SELECT `+documentColumns+` FROM documents
+		 WHERE tenant_id = $1 AND external_id = $2 AND version > $3 AND status = $4
+		 ORDER BY version DESC LIMIT 1`,
+		doc.TenantID, doc.ExternalID, doc.Version, models.DocStatusReady,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get newer version: %w", err)
+	}
+	return newer, nil
+}
+
+// ReplacedVersions returns the IDs of the versions before doc that have not
+// been superseded yet.
+func (s *Service) ReplacedVersions(ctx context.Context, doc *models.Document) ([]uuid.UUID, error) {
+	if doc.ExternalID == "" {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT id FROM documents
+		 WHERE tenant_id = $1 AND external_id = $2 AND version < $3 AND superseded_by IS NULL`,
+		doc.TenantID, doc.ExternalID, doc.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list replaced versions: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan document ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkSuperseded records that the given versions were replaced by the
+// version with ID by.
+func (s *Service) MarkSuperseded(ctx context.Context, ids []uuid.UUID, by uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := s.db.Exec(ctx, "UPDATE documents SET superseded_by = $2 WHERE id = ANY($1)", ids, by); err != nil {
+		return fmt.Errorf("mark versions superseded: %w", err)
+	}
+	return nil
 }
 
 func (s *Service) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
 	_, err := s.db.Exec(ctx, "UPDATE documents SET status = $1 WHERE id = $2", status, id)
 	return err
 }
+
+// StartProcessing moves a document to processing and clears the error of
+// any earlier attempt.
+func (s *Service) StartProcessing(ctx context.Context, id uuid.UUID) error {
+	_, err := s.db.Exec(ctx,
+		"UPDATE documents SET status = $2, error = NULL, processed_at = NULL WHERE id = $1",
+		id, models.DocStatusProcessing,
+	)
+	if err != nil {
+		return fmt.Errorf("start document processing: %w", err)
+	}
+	return nil
+}
+
+// MarkReady records that a document is indexed and searchable.
+func (s *Service) MarkReady(ctx context.Context, id uuid.UUID) error {
+	_, err := s.db.Exec(ctx,
+		"UPDATE documents SET status = $2, error = NULL, processed_at = now() WHERE id = $1",
+		id, models.DocStatusReady,
+	)
+	if err != nil {
+		return fmt.Errorf("mark document ready: %w", err)
+	}
+	return nil
+}
+
+// RecordError stores why processing failed. A final error also marks the
+// document failed; otherwise it stays processing while the attempt is
+// retried.
+func (s *Service) RecordError(ctx context.Context, id uuid.UUID, cause error, final bool) error {
+	query := "UPDATE documents SET error = $2 WHERE id = $1"
+	args := []interface{}{id, cause.Error()}
+	if final {
+		query = "UPDATE documents SET error = $2, status = $3, processed_at = now() WHERE id = $1"
+		args = append(args, models.DocStatusFailed)
+	}
+	if _, err := s.db.Exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("record document error: %w", err)
+	}
+	return nil
+}
+
+// Load returns a tenant's document without checking the caller's access,
+// for ingestion workers.
+func (s *Service) Load(ctx context.Context, tenantID, id uuid.UUID) (*models.Document, error) {
+	doc, err := scanDocument(s.db.QueryRow(ctx,
+		`SELECT `+documentColumns+` FROM documents WHERE id = $1 AND tenant_id = $2`,
+		id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("load document: %w", err)
+	}
+	return doc, nil
+}
+
+// SaveContent stores a document's extracted text with its page boundaries
+// and sections, replacing any earlier extraction.
+func (s *Service) SaveContent(ctx context.Context, doc *models.Document, extracted *textextract.ExtractedText) error {
+	pages, err := json.Marshal(pageSpans(extracted.Content))
+	if err != nil {
+		return fmt.Errorf("marshal pages: %w", err)
+	}
+	metadata, err := json.Marshal(extracted.Metadata)
+	if err != nil {
+		return fmt.Errorf("marshal content metadata: %w", err)
+	}
+	sections := extracted.Sections
+	if sections == nil {
+		sections = []textextract.Section{}
+	}
+	sectionsJSON, err := json.Marshal(sections)
+	if err != nil {
+		return fmt.Errorf("marshal sections: %w", err)
+	}
+
+	_, err = s.db.Exec(ctx,
+		`INSERT INTO document_contents (document_id, tenant_id, content, pages, metadata, sections)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (document_id) DO UPDATE
+		 SET content = EXCLUDED.content, pages = EXCLUDED.pages, metadata = EXCLUDED.metadata,
+		     sections = EXCLUDED.sections, extracted_at = now()`,
+		doc.ID, doc.TenantID, extracted.Content, pages, metadata, sectionsJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("save document content: %w", err)
+	}
+	return nil
+}
+
+// Content returns a document's extracted text without checking the caller's
+// access; callers check it with GetByID first. It returns ErrNoContent
+// before the document has been processed.
+func (s *Service) Content(ctx context.Context, tenantID, id uuid.UUID) (*models.DocumentContent, error) {
+	var c models.DocumentContent
+	var pages, metadata, sections []byte
+	err := s.db.QueryRow(ctx,
+		`SELECT document_id, tenant_id, content, pages, metadata, sections, extracted_at
+		 FROM document_contents WHERE document_id = $1 AND tenant_id = $2`,
+		id, tenantID,
+	).Scan(&c.DocumentID, &c.TenantID, &c.Content, &pages, &metadata, &sections, &c.ExtractedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoContent
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get document content: %w", err)
+	}
+	if err := json.Unmarshal(pages, &c.Pages); err != nil {
+		return nil, fmt.Errorf("decode pages: %w", err)
+	}
+	if err := json.Unmarshal(metadata, &c.Metadata); err != nil {
+		return nil, fmt.Errorf("decode content metadata: %w", err)
+	}
+	if err := json.Unmarshal(sections, &c.Sections); err != nil {
+		return nil, fmt.Errorf("decode sections: %w", err)
+	}
+	return &c, nil
+}
+
+// pageSpans splits extracted text into pages at form feeds, as textextract
+// separates PDF pages and PPTX slides. Text without form feeds is one page.
+func pageSpans(content string) []models.DocumentPage {
+	pages := []models.DocumentPage{}
+	start := 0
+	for i := 0; i <= len(content); i++ {
+		if i == len(content) || content[i] == '\f' {
+			pages = append(pages, models.DocumentPage{Number: len(pages) + 1, Start: start, End: i})
+			start = i + 1
+		}
+	}
+	return pages
+}
+
+// SetACL replaces a document's ACL and returns the updated document with
+// its resolved access list. The caller must be able to read the document.
+// Documents with knowledge-graph data cannot be restricted: entities and
+// community summaries are shared across the tenant.
+func (s *Service) SetACL(ctx context.Context, id uuid.UUID, acl models.DocumentACL) (*models.Document, []uuid.UUID, error) {
+	doc, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access := acl.Access(doc.CreatedBy)
+	if access != nil {
+		var graphIndexed bool
+		err := s.db.QueryRow(ctx,
+			"SELECT EXISTS (SELECT 1 FROM kg_entity_chunks WHERE document_id = $1)", id,
+		).Scan(&graphIndexed)
+		if err != nil {
+			return nil, nil, fmt.Errorf("check graph index: %w", err)
+		}
+		if graphIndexed {
+			return nil, nil, fmt.Errorf("graph-indexed documents cannot be restricted")
+		}
+	}
+
+	doc, err = scanDocument(s.db.QueryRow(ctx,
+		`UPDATE documents SET acl_roles = $3, acl_groups = $4, acl_users = $5, access = $6
+		 WHERE id = $1 AND tenant_id = $2
+		 RETURNING `+documentColumns,
+		id, tenant.IDFromContext(ctx), nonNil(acl.Roles), nonNil(acl.Groups), nonNil(acl.Users), access,
+	))
+	if err != nil {
+		return nil, nil, fmt.Errorf("update document ACL: %w", err)
+	}
+	return doc, access, nil
+}
+
+// Access returns a document's resolved access list without checking the
+// caller, for ingestion workers that copy it onto the document's chunks.
+func (s *Service) Access(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	var access []uuid.UUID
+	if err := s.db.QueryRow(ctx, "SELECT access FROM documents WHERE id = $1", id).Scan(&access); err != nil {
+		return nil, fmt.Errorf("get document access: %w", err)
+	}
+	return access, nil
+}
+
+// ChunkAccessStore holds the copy of a document's access list on its
+// chunks; vectorstore.VectorStore implements it.
+type ChunkAccessStore interface {
+	SetAccess(ctx context.Context, tenantID, documentID uuid.UUID, access []uuid.UUID) error
+}
+
+// syncAccessAttempts bounds how often SyncChunkAccess rewrites the chunks
+// of a document whose ACL keeps changing.
+const syncAccessAttempts = 3
+
+// SyncChunkAccess copies a document's current access list onto its chunks.
+// ACL changes and indexing write the chunks concurrently, so after each
+// write the list is read again, and written again if it changed meanwhile.
+// Whichever writer finishes last then leaves the latest list in place.
+func (s *Service) SyncChunkAccess(ctx context.Context, store ChunkAccessStore, tenantID, id uuid.UUID) error {
+	access, err := s.Access(ctx, id)
+	if err != nil {
+		return err
+	}
+	for range syncAccessAttempts {
+		if err := store.SetAccess(ctx, tenantID, id, access); err != nil {
+			return fmt.Errorf("set chunk access: %w", err)
+		}
+		current, err := s.Access(ctx, id)
+		if err != nil {
+			return err
+		}
+		if slices.Equal(current, access) {
+			return nil
+		}
+		access = current
+	}
+	return fmt.Errorf("document %s access changed while updating its chunks", id)
+}
+
+// nonNil stores absent ACL entries as empty arrays.
+func nonNil(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
@@ -19,6 +19,20 @@ func NewService(gw llm.Gateway, model string) *Service {
 	return &Service{gateway: gw, model: model}
 }
 
+// Model returns the embedding model requests are made with.
+func (s *Service) Model() string {
+	return s.model
+}
+
+// WithModel returns a service that embeds with model through the same
+// gateway. An empty model, or the current one, returns s.
+func (s *Service) WithModel(model string) *Service {
+	if model == "" || model == s.model {
+		return s
+	}
+	return &Service{gateway: s.gateway, model: model}
+}
+
 func (s *Service) Embed(ctx context.Context, texts []string) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
@@ -0,0 +1,266 @@
+package evalset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// UnanswerableAnswer is the expected answer of unanswerable items: a
+// grounded system should decline rather than guess.
+const UnanswerableAnswer = "The available documents do not contain the answer to this question."
+
+// questionStyles rotate through single-hop generation so a dataset doesn't
+// consist of one kind of question.
+var questionStyles = []string{
+	"a factual question about a specific detail",
+	"a 'how' or 'why' question about a process, cause or reason",
+	"a question about a number, date, quantity or limit",
+	"a question asking what a term, rule or component means",
+	"a practical question a user of this knowledge base would ask",
+}
+
+// Generator writes question/answer pairs from chunks with the LLM and
+// reviews them.
+type Generator struct {
+	gateway llm.Gateway
+	model   string
+}
+
+func NewGenerator(gw llm.Gateway, model string) *Generator {
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &Generator{gateway: gw, model: model}
+}
+
+type qaPair struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// SingleHop writes a question answered by one chunk.
+func (g *Generator) SingleHop(ctx context.Context, chunk vectorstore.SearchResult, style string) (*models.EvalDatasetItem, error) {
+	system := fmt.Sprintf(`You write test questions for evaluating a document search system.
+Write %s that the passage answers completely.
+The question must make sense on its own, without seeing the passage: name the subject instead of saying "the passage", "the document" or "this".
+Answer it in one to three sentences using only the passage.
+Reply with ONLY a JSON object: {"question": "...", "answer": "..."}`, style)
+
+	var qa qaPair
+	if err := g.chatJSON(ctx, system, "Passage:\n"+chunk.Content, 0.8, &qa); err != nil {
+		return nil, err
+	}
+	if qa.Question == "" {
+		return nil, nil
+	}
+	return &models.EvalDatasetItem{
+		Kind:                models.EvalItemSingleHop,
+		Query:               strings.TrimSpace(qa.Question),
+		ExpectedAnswer:      strings.TrimSpace(qa.Answer),
+		Context:             []string{chunk.Content},
+		RelevantDocumentIDs: []uuid.UUID{chunk.DocumentID},
+		RelevantChunkIDs:    []uuid.UUID{chunk.ChunkID},
+	}, nil
+}
+
+// MultiHop writes a question that needs both chunks. It returns nil when the
+// LLM finds no natural question connecting them.
+func (g *Generator) MultiHop(ctx context.Context, a, b vectorstore.SearchResult) (*models.EvalDatasetItem, error) {
+	system := `You write test questions for evaluating a document search system.
+Write one question that can only be answered by combining information from BOTH passages, for example by connecting, comparing or chaining facts from each.
+The question must make sense on its own, without seeing the passages: name the subjects instead of saying "the passages" or "the document".
+Answer it in one to three sentences using only the passages.
+If the passages share no subject that a realistic question could connect, reply {"question": "", "answer": ""}.
+Reply with ONLY a JSON object: {"question": "...", "answer": "..."}`
+
+	var qa qaPair
+	user := fmt.Sprintf("Passage 1:\n%s\n\nPassage 2:\n%s", a.Content, b.Content)
+	if err := g.chatJSON(ctx, system, user, 0.7, &qa); err != nil {
+		return nil, err
+	}
+	if qa.Question == "" {
+		return nil, nil
+	}
+	return &models.EvalDatasetItem{
+		Kind:                models.EvalItemMultiHop,
+		Query:               strings.TrimSpace(qa.Question),
+		ExpectedAnswer:      strings.TrimSpace(qa.Answer),
+		Context:             []string{a.Content, b.Content},
+		RelevantDocumentIDs: uniqueIDs(a.DocumentID, b.DocumentID),
+		RelevantChunkIDs:    []uuid.UUID{a.ChunkID, b.ChunkID},
+	}, nil
+}
+
+// Unanswerable writes a plausible question on the chunk's topic that the
+// chunk does not answer.
+func (g *Generator) Unanswerable(ctx context.Context, chunk vectorstore.SearchResult) (*models.EvalDatasetItem, error) {
+	system := `You write test questions for evaluating whether a document search system admits when it has no answer.
+Write one realistic question on the same subject as the passage that the passage does NOT answer, such as a detail it doesn't mention.
+Do not write questions about things that contradict the passage, and do not make the question absurd.
+The question must make sense on its own, without seeing the passage.
+Reply with ONLY a JSON object: {"question": "..."}`
+
+	var qa qaPair
+	if err := g.chatJSON(ctx, system, "Passage:\n"+chunk.Content, 0.8, &qa); err != nil {
+		return nil, err
+	}
+	if qa.Question == "" {
+		return nil, nil
+	}
+	return &models.EvalDatasetItem{
+		Kind:           models.EvalItemUnanswerable,
+		Query:          strings.TrimSpace(qa.Question),
+		ExpectedAnswer: UnanswerableAnswer,
+	}, nil
+}
+
+type review struct {
+	Standalone bool   `json:"standalone"`
+	Supported  bool   `json:"supported"`
+	Correct    bool   `json:"correct"`
+	NeedsAll   bool   `json:"needs_all"`
+	Answered   bool   `json:"answered"`
+	Plausible  bool   `json:"plausible"`
+	Reason     string `json:"reason"`
+}
+
+// Review has the LLM check an answerable item against its own context, or
+// an unanswerable one against passages retrieved for it. It returns why the
+// item was rejected, or "" to keep it.
+func (g *Generator) Review(ctx context.Context, item *models.EvalDatasetItem, passages []string) (string, error) {
+	var system string
+	switch item.Kind {
+	case models.EvalItemUnanswerable:
+		system = `You review test questions that are meant to be unanswerable from a document collection.
+Given the passages most relevant to the question, decide:
+- standalone: the question is understandable without seeing any passage.
+- plausible: it is a realistic question someone might ask of this collection.
+- answered: any of the passages answers it, fully or mostly.
+Reply with ONLY a JSON object: {"standalone": true, "plausible": true, "answered": false, "reason": "brief explanation"}`
+	case models.EvalItemMultiHop:
+		system = `You review generated test questions for a document search benchmark.
+Given the passages, the question and the proposed answer, decide:
+- standalone: the question is understandable without seeing the passages (no "the passage", "the text", "above").
+- supported: the passages fully support the answer.
+- correct: the answer correctly and completely answers the question.
+- needs_all: answering requires information from every passage, not just one.
+Reply with ONLY a JSON object: {"standalone": true, "supported": true, "correct": true, "needs_all": true, "reason": "brief explanation"}`
+	default:
+		system = `You review generated test questions for a document search benchmark.
+Given the passage, the question and the proposed answer, decide:
+- standalone: the question is understandable without seeing the passage (no "the passage", "the text", "above").
+- supported: the passage fully supports the answer.
+- correct: the answer correctly and completely answers the question.
+Reply with ONLY a JSON object: {"standalone": true, "supported": true, "correct": true, "reason": "brief explanation"}`
+	}
+
+	var sb strings.Builder
+	for i, p := range passages {
+		fmt.Fprintf(&sb, "Passage %d:\n%s\n\n", i+1, p)
+	}
+	fmt.Fprintf(&sb, "Question: %s", item.Query)
+	if item.Kind != models.EvalItemUnanswerable {
+		fmt.Fprintf(&sb, "\nProposed answer: %s", item.ExpectedAnswer)
+	}
+
+	var r review
+	if err := g.chatJSON(ctx, system, sb.String(), 0, &r); err != nil {
+		return "", err
+	}
+
+	var ok bool
+	switch item.Kind {
+	case models.EvalItemUnanswerable:
+		ok = r.Standalone && r.Plausible && !r.Answered
+	case models.EvalItemMultiHop:
+		ok = r.Standalone && r.Supported && r.Correct && r.NeedsAll
+	default:
+		ok = r.Standalone && r.Supported && r.Correct
+	}
+	if ok {
+		return "", nil
+	}
+	if r.Reason == "" {
+		r.Reason = "rejected by review"
+	}
+	return r.Reason, nil
+}
+
+func (g *Generator) chatJSON(ctx context.Context, system, user string, temperature float64, out interface{}) error {
+	resp, err := g.gateway.Chat(ctx, llm.ChatRequest{
+		Model: g.model,
+		Messages: []llm.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Temperature: temperature,
+	})
+	if err != nil {
+		return fmt.Errorf("llm: %w", err)
+	}
+
+	content := strings.TrimSpace(resp.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), out); err != nil {
+		return fmt.Errorf("parse llm response: %w", err)
+	}
+	return nil
+}
+
+// contextReferences mark a question that only makes sense next to its
+// source passage.
+var contextReferences = []string{
+	"the passage", "the text", "the context", "the document", "the excerpt",
+	"passage 1", "passage 2", "mentioned above", "according to the",
+}
+
+// wellFormed rejects questions that are cheap to catch without the LLM:
+// too short or long, not questions, or leaning on the source passage.
+func wellFormed(item *models.EvalDatasetItem) string {
+	q := strings.ToLower(item.Query)
+	switch {
+	case len(q) < 12:
+		return "question too short"
+	case len(q) > 400:
+		return "question too long"
+	case !strings.HasSuffix(q, "?"):
+		return "not a question"
+	case item.Kind != models.EvalItemUnanswerable && item.ExpectedAnswer == "":
+		return "no answer"
+	}
+	for _, ref := range contextReferences {
+		if strings.Contains(q, ref) {
+			return "refers to its source passage"
+		}
+	}
+	return ""
+}
+
+// normalizeQuestion is the key questions are deduplicated on.
+func normalizeQuestion(q string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func uniqueIDs(ids ...uuid.UUID) []uuid.UUID {
+	var out []uuid.UUID
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,555 @@
+package evalset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/eval"
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/retrievaleval"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+const (
+	// maxItems caps one dataset's requested size.
+	maxItems = 200
+	// minChunkChars skips chunks too short to ask a real question about.
+	minChunkChars = 200
+	// reviewPassages is how many retrieved passages an unanswerable question
+	// is checked against.
+	reviewPassages = 5
+)
+
+// Service generates eval datasets as queued jobs.
+type Service struct {
+	db       *pgxpool.Pool
+	queue    *queue.Client
+	store    vectorstore.VectorStore
+	searcher retrievaleval.Searcher
+	gateway  llm.Gateway
+	users    *tenant.Service
+}
+
+func NewService(db *pgxpool.Pool, qc *queue.Client, store vectorstore.VectorStore, searcher retrievaleval.Searcher, gw llm.Gateway, users *tenant.Service) *Service {
+	return &Service{db: db, queue: qc, store: store, searcher: searcher, gateway: gw, users: users}
+}
+
+// Counts is a number of items per kind.
+type Counts struct {
+	SingleHop    int `json:"single_hop"`
+	MultiHop     int `json:"multi_hop"`
+	Unanswerable int `json:"unanswerable"`
+}
+
+func (c Counts) total() int {
+	return c.SingleHop + c.MultiHop + c.Unanswerable
+}
+
+func (c *Counts) add(kind string) {
+	switch kind {
+	case models.EvalItemSingleHop:
+		c.SingleHop++
+	case models.EvalItemMultiHop:
+		c.MultiHop++
+	case models.EvalItemUnanswerable:
+		c.Unanswerable++
+	}
+}
+
+// GenerateRequest describes a dataset to generate. Chunks are sampled from
+// the given collections and documents, or from the whole tenant when both
+// are empty.
+type GenerateRequest struct {
+	Name          string      `json:"name"`
+	CollectionIDs []uuid.UUID `json:"collection_ids,omitempty"`
+	DocumentIDs   []uuid.UUID `json:"document_ids,omitempty"`
+	// Counts defaults to 10 single-hop, 5 multi-hop and 3 unanswerable.
+	Counts Counts `json:"counts"`
+	// Model generates and reviews the questions.
+	Model string `json:"model,omitempty"`
+}
+
+// Validate checks the request and fills in the default counts.
+func (r *GenerateRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if r.Counts.SingleHop < 0 || r.Counts.MultiHop < 0 || r.Counts.Unanswerable < 0 {
+		return fmt.Errorf("counts must not be negative")
+	}
+	if r.Counts.total() == 0 {
+		r.Counts = Counts{SingleHop: 10, MultiHop: 5, Unanswerable: 3}
+	}
+	if r.Counts.total() > maxItems {
+		return fmt.Errorf("at most %d items per dataset", maxItems)
+	}
+	return nil
+}
+
+// Stats reports how generation went. Rejected items failed the format
+// checks, duplicated an earlier question or failed the LLM review, tallied
+// in Reasons; Errors counts LLM and search calls that failed outright.
+type Stats struct {
+	Sampled   int            `json:"sampled"`
+	Requested Counts         `json:"requested"`
+	Kept      Counts         `json:"kept"`
+	Rejected  Counts         `json:"rejected"`
+	Reasons   map[string]int `json:"reasons,omitempty"`
+	Errors    int            `json:"errors"`
+}
+
+const datasetColumns = `d.id, d.tenant_id, d.created_by, d.name, d.spec, d.status, d.progress,
+	(SELECT count(*) FROM eval_dataset_items i WHERE i.dataset_id = d.id), d.stats,
+	COALESCE(d.error, ''), d.started_at, d.completed_at, d.created_at`
+
+func scanDataset(row interface{ Scan(...any) error }) (*models.EvalDataset, error) {
+	var d models.EvalDataset
+	err := row.Scan(&d.ID, &d.TenantID, &d.CreatedBy, &d.Name, &d.Spec, &d.Status, &d.Progress,
+		&d.ItemCount, &d.Stats, &d.Error, &d.StartedAt, &d.CompletedAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// StartGeneration records a dataset and queues its generation. Chunks are
+// sampled as the requesting user, so document ACLs apply.
+func (s *Service) StartGeneration(ctx context.Context, req GenerateRequest) (*models.EvalDataset, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var createdBy *uuid.UUID
+	if u := tenant.UserFromContext(ctx); u != nil {
+		createdBy = &u.ID
+	}
+
+	specJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spec: %w", err)
+	}
+
+	var id uuid.UUID
+	err = s.db.QueryRow(ctx,
+		`INSERT INTO eval_datasets (tenant_id, created_by, name, spec, status)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		tenantID, createdBy, req.Name, specJSON, models.JobStatusPending,
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("insert eval dataset: %w", err)
+	}
+
+	if err := s.queue.EnqueueEvalDatasetGenerate(queue.EvalDatasetGeneratePayload{
+		DatasetID: id.String(),
+		TenantID:  tenantID.String(),
+	}); err != nil {
+		return nil, fmt.Errorf("enqueue eval dataset: %w", err)
+	}
+	return s.Get(ctx, id)
+}
+
+// List returns the tenant's datasets, newest first.
+func (s *Service) List(ctx context.Context) ([]models.EvalDataset, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT `+datasetColumns+` FROM eval_datasets d
+		 WHERE d.tenant_id = $1 ORDER BY d.created_at DESC LIMIT 100`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list eval datasets: %w", err)
+	}
+	defer rows.Close()
+
+	var datasets []models.EvalDataset
+	for rows.Next() {
+		d, err := scanDataset(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan eval dataset: %w", err)
+		}
+		datasets = append(datasets, *d)
+	}
+	return datasets, rows.Err()
+}
+
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*models.EvalDataset, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	d, err := scanDataset(s.db.QueryRow(ctx,
+		`SELECT `+datasetColumns+` FROM eval_datasets d WHERE d.id = $1 AND d.tenant_id = $2`,
+		id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get eval dataset: %w", err)
+	}
+	return d, nil
+}
+
+// Items returns a dataset's items in generation order. kind, if set,
+// restricts them to one kind.
+func (s *Service) Items(ctx context.Context, id uuid.UUID, kind string) ([]models.EvalDatasetItem, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT i.id, i.dataset_id, i.kind, i.query, i.expected_answer, i.context,
+		        i.relevant_document_ids, i.relevant_chunk_ids, i.created_at
+		 FROM eval_dataset_items i JOIN eval_datasets d ON d.id = i.dataset_id
+		 WHERE i.dataset_id = $1 AND d.tenant_id = $2 AND ($3 = '' OR i.kind = $3)
+		 ORDER BY i.created_at, i.id`,
+		id, tenantID, kind,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list eval dataset items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.EvalDatasetItem
+	for rows.Next() {
+		var it models.EvalDatasetItem
+		if err := rows.Scan(&it.ID, &it.DatasetID, &it.Kind, &it.Query, &it.ExpectedAnswer, &it.Context,
+			&it.RelevantDocumentIDs, &it.RelevantChunkIDs, &it.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan eval dataset item: %w", err)
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
+	tenantID := tenant.IDFromContext(ctx)
+	tag, err := s.db.Exec(ctx, "DELETE FROM eval_datasets WHERE id = $1 AND tenant_id = $2", id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete eval dataset: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("eval dataset not found")
+	}
+	return nil
+}
+
+// RetrievalCases returns a dataset's answerable items as retrieval eval
+// cases, judged per chunk. Unanswerable items have nothing to retrieve and
+// are left out.
+func (s *Service) RetrievalCases(ctx context.Context, id uuid.UUID) ([]retrievaleval.Case, error) {
+	items, err := s.Items(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+	var cases []retrievaleval.Case
+	for _, it := range items {
+		if it.Kind == models.EvalItemUnanswerable {
+			continue
+		}
+		cases = append(cases, retrievaleval.Case{
+			Query:               it.Query,
+			RelevantDocumentIDs: it.RelevantDocumentIDs,
+			RelevantChunkIDs:    it.RelevantChunkIDs,
+		})
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("eval dataset has no answerable items")
+	}
+	return cases, nil
+}
+
+// EvalInput pairs an item with a system's response for eval.EvalSuite. The
+// expected answer doubles as ground truth for answerable items; for
+// unanswerable ones a correct response declines to answer.
+func EvalInput(item models.EvalDatasetItem, response string) eval.EvalInput {
+	in := eval.EvalInput{
+		Query:          item.Query,
+		Response:       response,
+		ExpectedAnswer: item.ExpectedAnswer,
+		Context:        item.Context,
+	}
+	if item.Kind != models.EvalItemUnanswerable {
+		in.GroundTruth = item.ExpectedAnswer
+	}
+	return in
+}
+
+// RunJob generates a queued dataset: it samples chunks, writes candidate
+// questions of each kind, and keeps those that pass the format checks,
+// deduplication and the LLM review until the requested counts are met or
+// the sample runs out.
+func (s *Service) RunJob(ctx context.Context, datasetID uuid.UUID) error {
+	var tenantID uuid.UUID
+	var createdBy *uuid.UUID
+	var specJSON []byte
+	err := s.db.QueryRow(ctx,
+		`UPDATE eval_datasets SET status = $2, progress = 0, stats = NULL, started_at = now(), error = NULL
+		 WHERE id = $1 RETURNING tenant_id, created_by, spec`,
+		datasetID, models.JobStatusRunning,
+	).Scan(&tenantID, &createdBy, &specJSON)
+	if err != nil {
+		return fmt.Errorf("start eval dataset: %w", err)
+	}
+
+	var req GenerateRequest
+	if err := json.Unmarshal(specJSON, &req); err != nil {
+		return s.fail(ctx, datasetID, fmt.Errorf("decode spec: %w", err))
+	}
+
+	runCtx, err := s.users.JobContext(ctx, tenantID, createdBy)
+	if err != nil {
+		return s.fail(ctx, datasetID, err)
+	}
+
+	// A retried job starts over.
+	if _, err := s.db.Exec(ctx, "DELETE FROM eval_dataset_items WHERE dataset_id = $1", datasetID); err != nil {
+		return s.fail(ctx, datasetID, fmt.Errorf("clear eval dataset items: %w", err))
+	}
+
+	need := req.Counts.SingleHop + 2*req.Counts.MultiHop + req.Counts.Unanswerable
+	sampled, err := s.store.Sample(runCtx, vectorstore.SampleOptions{
+		TenantID:      tenantID,
+		DocumentIDs:   req.DocumentIDs,
+		CollectionIDs: req.CollectionIDs,
+		Principals:    tenant.PrincipalsFromContext(runCtx),
+		Limit:         2*need + 10,
+	})
+	if err != nil {
+		return s.fail(ctx, datasetID, fmt.Errorf("sample chunks: %w", err))
+	}
+	var chunks []vectorstore.SearchResult
+	for _, c := range sampled {
+		if len(c.Content) >= minChunkChars {
+			chunks = append(chunks, c)
+		}
+	}
+	if len(chunks) == 0 {
+		return s.fail(ctx, datasetID, fmt.Errorf("no chunks to generate questions from"))
+	}
+
+	run := &generation{
+		svc:       s,
+		gen:       NewGenerator(s.gateway, req.Model),
+		datasetID: datasetID,
+		scope:     req.CollectionIDs,
+		total:     req.Counts.total(),
+		seen:      make(map[string]bool),
+		stats:     Stats{Sampled: len(chunks), Requested: req.Counts, Reasons: make(map[string]int)},
+	}
+	if err := run.generate(runCtx, chunks, req.Counts); err != nil {
+		return s.fail(ctx, datasetID, err)
+	}
+	if run.stats.Kept.total() == 0 && run.stats.Errors > 0 {
+		return s.fail(ctx, datasetID, fmt.Errorf("every generation failed, last error: %w", run.lastErr))
+	}
+
+	statsJSON, err := json.Marshal(run.stats)
+	if err != nil {
+		return s.fail(ctx, datasetID, fmt.Errorf("marshal stats: %w", err))
+	}
+	_, err = s.db.Exec(ctx,
+		`UPDATE eval_datasets SET status = $2, progress = 100, stats = $3, completed_at = now()
+		 WHERE id = $1`,
+		datasetID, models.JobStatusSucceeded, statsJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("complete eval dataset: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) fail(ctx context.Context, datasetID uuid.UUID, cause error) error {
+	_, err := s.db.Exec(context.WithoutCancel(ctx),
+		"UPDATE eval_datasets SET status = $2, error = $3, completed_at = now() WHERE id = $1",
+		datasetID, models.JobStatusFailed, cause.Error(),
+	)
+	if err != nil {
+		slog.Error("failed to record eval dataset failure", "dataset_id", datasetID, "error", err)
+	}
+	return cause
+}
+
+// generation is the state of one RunJob.
+type generation struct {
+	svc       *Service
+	gen       *Generator
+	datasetID uuid.UUID
+	scope     []uuid.UUID
+	total     int
+	seen      map[string]bool
+	stats     Stats
+	lastErr   error
+}
+
+func (g *generation) generate(ctx context.Context, chunks []vectorstore.SearchResult, want Counts) error {
+	for i, c := range chunks {
+		if g.stats.Kept.SingleHop >= want.SingleHop {
+			break
+		}
+		item, err := g.gen.SingleHop(ctx, c, questionStyles[i%len(questionStyles)])
+		if err := g.consider(ctx, item, err, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range pairChunks(chunks) {
+		if g.stats.Kept.MultiHop >= want.MultiHop {
+			break
+		}
+		item, err := g.gen.MultiHop(ctx, p[0], p[1])
+		if err := g.consider(ctx, item, err, nil); err != nil {
+			return err
+		}
+	}
+
+	// Unanswerable questions start from the other end of the sample, so
+	// they mostly come from chunks single-hop questions didn't use.
+	for i := len(chunks) - 1; i >= 0; i-- {
+		if g.stats.Kept.Unanswerable >= want.Unanswerable {
+			break
+		}
+		item, err := g.gen.Unanswerable(ctx, chunks[i])
+		if err != nil || item == nil {
+			if err := g.consider(ctx, item, err, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		// An unanswerable question is checked against what retrieval finds
+		// for it, not just its source chunk.
+		found, err := g.svc.searcher.Search(ctx, rag.SearchRequest{
+			Query: item.Query, TopK: reviewPassages, Hybrid: true, CollectionIDs: g.scope,
+		})
+		if err != nil {
+			if err := g.consider(ctx, nil, err, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		passages := make([]string, 0, len(found)+1)
+		passages = append(passages, chunks[i].Content)
+		for _, r := range found {
+			if r.ChunkID != chunks[i].ChunkID {
+				passages = append(passages, r.Content)
+			}
+		}
+		if err := g.consider(ctx, item, nil, passages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consider keeps a candidate item if it passes the checks. Answerable items
+// are reviewed against their own context unless passages are given. It only
+// returns an error the job can't continue past; failed LLM calls are
+// counted.
+func (g *generation) consider(ctx context.Context, item *models.EvalDatasetItem, genErr error, passages []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if genErr != nil {
+		g.stats.Errors++
+		g.lastErr = genErr
+		slog.Warn("eval dataset generation call failed", "dataset_id", g.datasetID, "error", genErr)
+		return nil
+	}
+	if item == nil {
+		return nil
+	}
+
+	reason := wellFormed(item)
+	if reason == "" {
+		key := normalizeQuestion(item.Query)
+		if g.seen[key] {
+			reason = "duplicate question"
+		} else {
+			g.seen[key] = true
+		}
+	}
+	if reason == "" {
+		if passages == nil {
+			passages = item.Context
+		}
+		rejected, err := g.gen.Review(ctx, item, passages)
+		if err != nil {
+			g.stats.Errors++
+			g.lastErr = err
+			slog.Warn("eval dataset review failed", "dataset_id", g.datasetID, "error", err)
+			return nil
+		}
+		if rejected != "" {
+			slog.Debug("eval dataset item rejected", "dataset_id", g.datasetID, "query", item.Query, "reason", rejected)
+			reason = "failed review"
+		}
+	}
+	if reason != "" {
+		g.stats.Rejected.add(item.Kind)
+		g.stats.Reasons[reason]++
+		return nil
+	}
+
+	if err := g.insert(ctx, item); err != nil {
+		return err
+	}
+	g.stats.Kept.add(item.Kind)
+	if _, err := g.svc.db.Exec(ctx,
+		"UPDATE eval_datasets SET progress = $2 WHERE id = $1",
+		g.datasetID, min(g.stats.Kept.total()*100/g.total, 99),
+	); err != nil {
+		slog.Warn("failed to record eval dataset progress", "dataset_id", g.datasetID, "error", err)
+	}
+	return nil
+}
+
+func (g *generation) insert(ctx context.Context, item *models.EvalDatasetItem) error {
+	_, err := g.svc.db.Exec(ctx,
+		`INSERT INTO eval_dataset_items
+		 (dataset_id, kind, query, expected_answer, context, relevant_document_ids, relevant_chunk_ids)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		g.datasetID, item.Kind, item.Query, item.ExpectedAnswer, nonNil(item.Context),
+		nonNil(item.RelevantDocumentIDs), nonNil(item.RelevantChunkIDs),
+	)
+	if err != nil {
+		return fmt.Errorf("insert eval dataset item: %w", err)
+	}
+	return nil
+}
+
+// pairChunks pairs neighbouring chunks of the same document first, since
+// they are the likeliest to share a subject, then pairs the leftovers
+// across documents.
+func pairChunks(chunks []vectorstore.SearchResult) [][2]vectorstore.SearchResult {
+	var order []uuid.UUID
+	byDoc := make(map[uuid.UUID][]vectorstore.SearchResult)
+	for _, c := range chunks {
+		if _, ok := byDoc[c.DocumentID]; !ok {
+			order = append(order, c.DocumentID)
+		}
+		byDoc[c.DocumentID] = append(byDoc[c.DocumentID], c)
+	}
+
+	var pairs [][2]vectorstore.SearchResult
+	var leftovers []vectorstore.SearchResult
+	for _, docID := range order {
+		doc := byDoc[docID]
+		slices.SortFunc(doc, func(a, b vectorstore.SearchResult) int { return a.ChunkIndex - b.ChunkIndex })
+		for len(doc) >= 2 {
+			pairs = append(pairs, [2]vectorstore.SearchResult{doc[0], doc[1]})
+			doc = doc[2:]
+		}
+		leftovers = append(leftovers, doc...)
+	}
+	for i := 0; i+1 < len(leftovers); i += 2 {
+		pairs = append(pairs, [2]vectorstore.SearchResult{leftovers[i], leftovers[i+1]})
+	}
+	return pairs
+}
+
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
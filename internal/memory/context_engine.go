@@ -74,21 +74,14 @@ func (ce *ContextEngine) Assemble(parts ContextParts) AssembledContext {
 	}
 
 	// 3. Conversation history (most recent first, within budget)
-	memoryBudget := int(float64(ce.maxTokens) * ce.memoryBudget)
-	historyTokens := 0
-	var historyMsgs []Message
-	for i := len(parts.ConversationHistory) - 1; i >= 0; i-- {
-		entry := parts.ConversationHistory[i]
-		entryTokens := estimateTokens(entry.Content)
-		if historyTokens+entryTokens > memoryBudget {
-			truncated = true
-			break
-		}
-		historyMsgs = append([]Message{{Role: entry.Role, Content: entry.Content}}, historyMsgs...)
-		historyTokens += entryTokens
+	history, historyTruncated := ce.FitHistory(parts.ConversationHistory)
+	if historyTruncated {
+		truncated = true
+	}
+	for _, entry := range history {
+		messages = append(messages, Message{Role: entry.Role, Content: entry.Content})
+		totalTokens += estimateTokens(entry.Content)
 	}
-	messages = append(messages, historyMsgs...)
-	totalTokens += historyTokens
 
 	// 4. User message (always included)
 	messages = append(messages, Message{Role: "user", Content: parts.UserMessage})
@@ -101,6 +94,24 @@ func (ce *ContextEngine) Assemble(parts ContextParts) AssembledContext {
 	}
 }
 
+// FitHistory returns the most recent entries that fit within the memory
+// budget, in chronological order. The bool reports whether older entries
+// were dropped.
+func (ce *ContextEngine) FitHistory(entries []Entry) ([]Entry, bool) {
+	memoryBudget := int(float64(ce.maxTokens) * ce.memoryBudget)
+	historyTokens := 0
+	start := len(entries)
+	for i := len(entries) - 1; i >= 0; i-- {
+		entryTokens := estimateTokens(entries[i].Content)
+		if historyTokens+entryTokens > memoryBudget {
+			break
+		}
+		historyTokens += entryTokens
+		start = i
+	}
+	return entries[start:], start > 0
+}
+
 func (ce *ContextEngine) assembleRetrievalContext(chunks []string, budget int) string {
 	var sb strings.Builder
 	tokens := 0
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Collection is a named set of a tenant's documents with its own ingestion
+// settings. Empty settings fall back to the ingest request's.
+type Collection struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	TenantID    uuid.UUID `json:"tenant_id" db:"tenant_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description,omitempty" db:"description"`
+	// ChunkOpts holds chunker.ChunkOptions.
+	ChunkOpts      json.RawMessage `json:"chunk_opts,omitempty" db:"chunk_opts"`
+	IndexType      string          `json:"index_type" db:"index_type"`
+	ContextHeaders string          `json:"context_headers,omitempty" db:"context_headers"`
+	EmbeddingModel string          `json:"embedding_model,omitempty" db:"embedding_model"`
+	DocumentCount  int             `json:"document_count" db:"-"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConnectorSource is a directory, repository, bucket or website synced
+// into documents. Config holds the kind's settings (connector.LocalConfig,
+// GitConfig, S3Config or WebConfig) with secrets redacted.
+type ConnectorSource struct {
+	ID                  uuid.UUID       `json:"id" db:"id"`
+	TenantID            uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	CreatedBy           *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
+	Name                string          `json:"name" db:"name"`
+	Kind                string          `json:"kind" db:"kind"`
+	Config              json.RawMessage `json:"config" db:"config"`
+	SyncIntervalMinutes int             `json:"sync_interval_minutes" db:"sync_interval_minutes"`
+	Enabled             bool            `json:"enabled" db:"enabled"`
+	Status              string          `json:"status" db:"status"`
+	// Cursor is where the last sync left off: the synced commit of a Git
+	// repository, the newest modification time seen in a directory or
+	// bucket, or the start of a website's last crawl.
+	Cursor       string     `json:"cursor,omitempty" db:"cursor"`
+	Error        string     `json:"error,omitempty" db:"error"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty" db:"last_synced_at"`
+	NextSyncAt   *time.Time `json:"next_sync_at,omitempty" db:"next_sync_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	ConnectorStatusIdle    = "idle"
+	ConnectorStatusSyncing = "syncing"
+	ConnectorStatusFailed  = "failed"
+)
+
+// ConnectorSync is one sync of a source. Status uses the JobStatus* values.
+type ConnectorSync struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	SourceID    uuid.UUID  `json:"source_id" db:"source_id"`
+	TenantID    uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	Status      string     `json:"status" db:"status"`
+	Cursor      string     `json:"cursor,omitempty" db:"cursor"`
+	Added       int        `json:"added" db:"added"`
+	Updated     int        `json:"updated" db:"updated"`
+	Deleted     int        `json:"deleted" db:"deleted"`
+	Unchanged   int        `json:"unchanged" db:"unchanged"`
+	Skipped     int        `json:"skipped" db:"skipped"`
+	Failed      int        `json:"failed" db:"failed"`
+	Error       string     `json:"error,omitempty" db:"error"`
+	StartedAt   time.Time  `json:"started_at" db:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// ConnectorItem is a file of a source as of its last sync.
+type ConnectorItem struct {
+	SourceID   uuid.UUID  `json:"source_id" db:"source_id"`
+	Path       string     `json:"path" db:"path"`
+	Version    string     `json:"version" db:"version"`
+	DocumentID *uuid.UUID `json:"document_id,omitempty" db:"document_id"`
+	Status     string     `json:"status" db:"status"`
+	Error      string     `json:"error,omitempty" db:"error"`
+	SyncedAt   time.Time  `json:"synced_at" db:"synced_at"`
+}
+
+const (
+	// ConnectorItemSynced files were uploaded as documents.
+	ConnectorItemSynced = "synced"
+	// ConnectorItemSkipped files are too large or of an unsupported type;
+	// they are tried again when they change.
+	ConnectorItemSkipped = "skipped"
+	// ConnectorItemFailed files are tried again on the next sync.
+	ConnectorItemFailed = "failed"
+)
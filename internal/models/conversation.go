@@ -0,0 +1,27 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Conversation struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	TenantID  uuid.UUID  `json:"tenant_id" db:"tenant_id"`
+	UserID    *uuid.UUID `json:"user_id,omitempty" db:"user_id"`
+	Title     string     `json:"title,omitempty" db:"title"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type ConversationMessage struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	ConversationID uuid.UUID       `json:"conversation_id" db:"conversation_id"`
+	TenantID       uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	Role           string          `json:"role" db:"role"`
+	Content        string          `json:"content" db:"content"`
+	Metadata       json.RawMessage `json:"metadata" db:"metadata"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
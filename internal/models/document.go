@@ -17,7 +17,124 @@ type Document struct {
 	Status        string          `json:"status" db:"status"`
 	Metadata      json.RawMessage `json:"metadata" db:"metadata"`
 	CreatedBy     *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
-	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	ACL           DocumentACL     `json:"acl" db:"-"`
+	// IngestOptions holds the indexing settings chosen at upload
+	// (document.IngestOptions).
+	IngestOptions json.RawMessage `json:"ingest_options,omitempty" db:"ingest_options"`
+	// Error is why processing last failed.
+	Error string `json:"error,omitempty" db:"error"`
+	// ExternalID is the caller's identifier for the document. Uploads with
+	// the same external ID are numbered versions of one document.
+	ExternalID string `json:"external_id,omitempty" db:"external_id"`
+	Version    int    `json:"version" db:"version"`
+	// SupersededBy is the newer version that replaced this one in
+	// retrieval.
+	SupersededBy *uuid.UUID `json:"superseded_by,omitempty" db:"superseded_by"`
+	// BatchID is the archive batch the document was created from.
+	BatchID     *uuid.UUID `json:"batch_id,omitempty" db:"batch_id"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty" db:"processed_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// DocumentDiff lists the sections that differ between two versions of a
+// document.
+type DocumentDiff struct {
+	DocumentID     uuid.UUID       `json:"document_id"`
+	Version        int             `json:"version"`
+	AgainstID      uuid.UUID       `json:"against_id"`
+	AgainstVersion int             `json:"against_version"`
+	Changes        []SectionChange `json:"changes"`
+	Unchanged      int             `json:"unchanged"`
+}
+
+// Section change statuses.
+const (
+	SectionAdded   = "added"
+	SectionRemoved = "removed"
+	SectionChanged = "changed"
+)
+
+// SectionChange is one section added, removed or changed between versions.
+// Lines is a line diff of a changed section: each line prefixed with "+",
+// "-" or " ".
+type SectionChange struct {
+	Status     string   `json:"status"`
+	Kind       string   `json:"kind"`
+	Title      string   `json:"title,omitempty"`
+	Page       int      `json:"page,omitempty"`
+	OldContent string   `json:"old_content,omitempty"`
+	NewContent string   `json:"new_content,omitempty"`
+	Lines      []string `json:"lines,omitempty"`
+}
+
+// DocumentContent is the text extracted from a document, kept so it can be
+// indexed again without re-extracting the file.
+type DocumentContent struct {
+	DocumentID  uuid.UUID         `json:"document_id" db:"document_id"`
+	TenantID    uuid.UUID         `json:"tenant_id" db:"tenant_id"`
+	Content     string            `json:"content" db:"content"`
+	Pages       []DocumentPage    `json:"pages" db:"pages"`
+	Metadata    map[string]string `json:"metadata,omitempty" db:"metadata"`
+	Sections    []DocumentSection `json:"sections" db:"sections"`
+	ExtractedAt time.Time         `json:"extracted_at" db:"extracted_at"`
+}
+
+// DocumentSection is one structural part of extracted text
+// (textextract.Section): a heading's text, a page, slide, sheet, record or
+// email attachment.
+type DocumentSection struct {
+	Kind    string `json:"kind"`
+	Title   string `json:"title,omitempty"`
+	Level   int    `json:"level,omitempty"`
+	Page    int    `json:"page,omitempty"`
+	Content string `json:"content"`
+}
+
+// DocumentPage locates one page in DocumentContent.Content by byte offsets.
+// Pages are separated by form feeds, which the page excludes.
+type DocumentPage struct {
+	Number int `json:"number"`
+	Start  int `json:"start"`
+	End    int `json:"end"`
+}
+
+// DocumentACL restricts a document, and every chunk indexed from it, to its
+// owner and the listed roles, groups and users. An empty ACL leaves the
+// document readable by the whole tenant.
+type DocumentACL struct {
+	Roles  []uuid.UUID `json:"roles,omitempty" db:"acl_roles"`
+	Groups []uuid.UUID `json:"groups,omitempty" db:"acl_groups"`
+	Users  []uuid.UUID `json:"users,omitempty" db:"acl_users"`
+}
+
+func (a DocumentACL) Empty() bool {
+	return len(a.Roles) == 0 && len(a.Groups) == 0 && len(a.Users) == 0
+}
+
+// Access returns the IDs of the principals that may read a document with
+// this ACL and owner, or nil when the document is unrestricted. Role, group
+// and user IDs are all UUIDs, so one list holds them all.
+func (a DocumentACL) Access(owner *uuid.UUID) []uuid.UUID {
+	if a.Empty() {
+		return nil
+	}
+	seen := make(map[uuid.UUID]bool)
+	var access []uuid.UUID
+	add := func(ids ...uuid.UUID) {
+		for _, id := range ids {
+			if id != uuid.Nil && !seen[id] {
+				seen[id] = true
+				access = append(access, id)
+			}
+		}
+	}
+	if owner != nil {
+		add(*owner)
+	}
+	add(a.Roles...)
+	add(a.Groups...)
+	add(a.Users...)
+	return access
 }
 
 type DocumentChunk struct {
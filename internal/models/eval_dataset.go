@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of generated eval dataset items.
+const (
+	EvalItemSingleHop    = "single_hop"
+	EvalItemMultiHop     = "multi_hop"
+	EvalItemUnanswerable = "unanswerable"
+)
+
+// EvalDataset is a generated question/answer test set. Spec is the
+// generation request; Stats counts the items kept and rejected per kind.
+// Status uses the JobStatus* values and Progress is 0-100.
+type EvalDataset struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	CreatedBy   *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
+	Name        string          `json:"name" db:"name"`
+	Spec        json.RawMessage `json:"spec" db:"spec"`
+	Status      string          `json:"status" db:"status"`
+	Progress    int             `json:"progress" db:"progress"`
+	ItemCount   int             `json:"item_count" db:"-"`
+	Stats       json.RawMessage `json:"stats,omitempty" db:"stats"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	StartedAt   *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// EvalDatasetItem is one generated question. Its JSON fields match
+// eval.EvalInput (query, expected_answer, context) and retrieval eval cases
+// (relevant_document_ids, relevant_chunk_ids). Unanswerable items have no
+// relevant IDs and expect a refusal.
+type EvalDatasetItem struct {
+	ID                  uuid.UUID   `json:"id" db:"id"`
+	DatasetID           uuid.UUID   `json:"dataset_id" db:"dataset_id"`
+	Kind                string      `json:"kind" db:"kind"`
+	Query               string      `json:"query" db:"query"`
+	ExpectedAnswer      string      `json:"expected_answer" db:"expected_answer"`
+	Context             []string    `json:"context,omitempty" db:"context"`
+	RelevantDocumentIDs []uuid.UUID `json:"relevant_document_ids,omitempty" db:"relevant_document_ids"`
+	RelevantChunkIDs    []uuid.UUID `json:"relevant_chunk_ids,omitempty" db:"relevant_chunk_ids"`
+	CreatedAt           time.Time   `json:"created_at" db:"created_at"`
+}
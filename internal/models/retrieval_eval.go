@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RetrievalEvalRun is one retrieval evaluation: a labeled query set scored
+// against several retrieval configurations. Status uses the JobStatus*
+// values and Progress is 0-100; Results holds one entry per configuration
+// once the run succeeds.
+type RetrievalEvalRun struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	CreatedBy   *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
+	Name        string          `json:"name" db:"name"`
+	CaseCount   int             `json:"case_count" db:"-"`
+	Configs     json.RawMessage `json:"configs" db:"configs"`
+	Status      string          `json:"status" db:"status"`
+	Progress    int             `json:"progress" db:"progress"`
+	Results     json.RawMessage `json:"results,omitempty" db:"results"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	StartedAt   *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
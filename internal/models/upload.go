@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Upload is a resumable upload: a file sent in pieces, each starting at
+// the offset the last one ended at. Once all of it has arrived it is
+// completed into a document or a batch. Options holds the settings of what
+// it becomes (upload.DocumentOptions).
+type Upload struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	CreatedBy   *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
+	Filename    string          `json:"filename" db:"filename"`
+	ContentType string          `json:"content_type,omitempty" db:"content_type"`
+	Size        int64           `json:"size" db:"size"`
+	Offset      int64           `json:"offset" db:"received"`
+	Options     json.RawMessage `json:"options" db:"options"`
+	Status      string          `json:"status" db:"status"`
+	DocumentID  *uuid.UUID      `json:"document_id,omitempty" db:"document_id"`
+	BatchID     *uuid.UUID      `json:"batch_id,omitempty" db:"batch_id"`
+	ExpiresAt   time.Time       `json:"expires_at" db:"expires_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	UploadStatusPending   = "pending"
+	UploadStatusCompleted = "completed"
+)
+
+// DocumentBatch is an archive whose supported files each become a
+// document. Options holds the settings applied to them
+// (upload.DocumentOptions).
+type DocumentBatch struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	CreatedBy   *uuid.UUID      `json:"created_by,omitempty" db:"created_by"`
+	Filename    string          `json:"filename" db:"filename"`
+	Options     json.RawMessage `json:"options" db:"options"`
+	Status      string          `json:"status" db:"status"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	Progress    *BatchProgress  `json:"progress,omitempty" db:"-"`
+}
+
+const (
+	// BatchStatusPending batches wait for the worker to expand them.
+	BatchStatusPending   = "pending"
+	BatchStatusExpanding = "expanding"
+	// BatchStatusExpanded batches have created all their documents, which
+	// may still be processing.
+	BatchStatusExpanded = "expanded"
+	BatchStatusFailed   = "failed"
+)
+
+// BatchProgress sums up a batch: what became of the archive's files, and
+// how far the documents created from them have been processed.
+type BatchProgress struct {
+	Entries int `json:"entries"`
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+	// Documents counts the batch's documents by status (DocStatus*).
+	Documents map[string]int `json:"documents"`
+	// Percent is the share of created documents that are ready or failed.
+	Percent float64 `json:"percent"`
+	// Done is set once the archive is expanded and no document is still
+	// pending or processing.
+	Done bool `json:"done"`
+}
+
+// BatchEntry is one file of a batch's archive.
+type BatchEntry struct {
+	Path       string     `json:"path" db:"path"`
+	Size       int64      `json:"size" db:"size"`
+	Status     string     `json:"status" db:"status"`
+	DocumentID *uuid.UUID `json:"document_id,omitempty" db:"document_id"`
+	// DocumentStatus is the status of the entry's document.
+	DocumentStatus string `json:"document_status,omitempty" db:"-"`
+	Error          string `json:"error,omitempty" db:"error"`
+}
+
+const (
+	BatchEntryCreated = "created"
+	// BatchEntrySkipped entries are unsupported or too large.
+	BatchEntrySkipped = "skipped"
+	BatchEntryFailed  = "failed"
+	// BatchEntryPending entries are being ingested, or were when an
+	// expansion was interrupted.
+	BatchEntryPending = "pending"
+)
@@ -13,6 +13,28 @@ type User struct {
 	Email     string     `json:"email" db:"email"`
 	FullName  string     `json:"full_name,omitempty" db:"full_name"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	// GroupIDs are the user groups the user belongs to, used for document
+	// access control.
+	GroupIDs []uuid.UUID `json:"group_ids,omitempty" db:"-"`
+}
+
+// Principals returns the IDs document ACLs are matched against: the user,
+// their role and their groups.
+func (u *User) Principals() []uuid.UUID {
+	principals := []uuid.UUID{u.ID}
+	if u.RoleID != nil {
+		principals = append(principals, *u.RoleID)
+	}
+	return append(principals, u.GroupIDs...)
+}
+
+// Group is a named set of users within a tenant.
+type Group struct {
+	ID        uuid.UUID   `json:"id" db:"id"`
+	TenantID  uuid.UUID   `json:"tenant_id" db:"tenant_id"`
+	Name      string      `json:"name" db:"name"`
+	MemberIDs []uuid.UUID `json:"member_ids" db:"-"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
 }
 
 type APIKey struct {
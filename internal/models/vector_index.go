@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// VectorIndexJob tracks an asynchronous vector index build. Status uses the
+// JobStatus* values; Progress is 0-100 and Phase is the current Postgres
+// build phase.
+type VectorIndexJob struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	TenantID    uuid.UUID       `json:"tenant_id" db:"tenant_id"`
+	Spec        json.RawMessage `json:"spec" db:"spec"`
+	Status      string          `json:"status" db:"status"`
+	Phase       string          `json:"phase,omitempty" db:"phase"`
+	Progress    int             `json:"progress" db:"progress"`
+	IndexName   string          `json:"index_name,omitempty" db:"index_name"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	StartedAt   *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -43,6 +44,62 @@ func (c *Client) EnqueueWebhookDeliver(payload WebhookDeliverPayload) error {
 	return c.enqueue(TypeWebhookDeliver, payload, asynq.MaxRetry(5), asynq.Timeout(30*time.Second))
 }
 
+// EnqueueVectorIndexBuild is not retried: a failed build is cleaned up and
+// can be requested again.
+func (c *Client) EnqueueVectorIndexBuild(payload VectorIndexBuildPayload) error {
+	return c.enqueue(TypeVectorIndexBuild, payload, asynq.MaxRetry(0), asynq.Timeout(12*time.Hour))
+}
+
+// EnqueueRetrievalEval is not retried: a run's searches can be costly (HyDE,
+// rewriting and reranking call the LLM), and a failed run can be started
+// again.
+func (c *Client) EnqueueRetrievalEval(payload RetrievalEvalPayload) error {
+	return c.enqueue(TypeRetrievalEval, payload, asynq.MaxRetry(0), asynq.Timeout(2*time.Hour))
+}
+
+// EnqueueEvalDatasetGenerate is not retried: generation makes many LLM
+// calls, and a failed dataset can be regenerated.
+func (c *Client) EnqueueEvalDatasetGenerate(payload EvalDatasetGeneratePayload) error {
+	return c.enqueue(TypeEvalDatasetGenerate, payload, asynq.MaxRetry(0), asynq.Timeout(2*time.Hour))
+}
+
+// ConnectorSyncTimeout bounds one sync of a connector source.
+const ConnectorSyncTimeout = time.Hour
+
+// EnqueueConnectorSync is not retried: the next scheduled sync picks up
+// what a failed one missed. A sync already queued is not queued again.
+func (c *Client) EnqueueConnectorSync(payload ConnectorSyncPayload) error {
+	err := c.enqueue(TypeConnectorSync, payload,
+		asynq.MaxRetry(0), asynq.Timeout(ConnectorSyncTimeout), asynq.Unique(ConnectorSyncTimeout))
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		return nil
+	}
+	return err
+}
+
+// EnqueueDocumentBatchExpand is retried a few times: an expansion resumes
+// after the archive entries already handled.
+func (c *Client) EnqueueDocumentBatchExpand(payload DocumentBatchExpandPayload) error {
+	return c.enqueue(TypeDocumentBatchExpand, payload, asynq.MaxRetry(3), asynq.Timeout(time.Hour))
+}
+
+// GraphCommunityBuildDelay is how long a community rebuild waits after it
+// is requested, so the documents of an upload burst share one rebuild.
+const GraphCommunityBuildDelay = 5 * time.Minute
+
+// EnqueueGraphCommunityBuild queues a tenant's community rebuild after
+// GraphCommunityBuildDelay. A rebuild already waiting for the tenant absorbs
+// the request; once it has started, a new one is queued.
+func (c *Client) EnqueueGraphCommunityBuild(payload GraphCommunityBuildPayload) error {
+	err := c.enqueue(TypeGraphCommunityBuild, payload,
+		asynq.MaxRetry(2), asynq.Timeout(time.Hour),
+		asynq.ProcessIn(GraphCommunityBuildDelay), asynq.Unique(GraphCommunityBuildDelay))
+	if errors.Is(err, asynq.ErrDuplicateTask) {
+		return nil
+	}
+	return err
+}
+
 func (c *Client) enqueue(taskType string, payload interface{}, opts ...asynq.Option) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
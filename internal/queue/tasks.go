@@ -5,6 +5,18 @@ const (
 	TypeEmbeddingGenerate = "embedding:generate"
 	TypeFinetuneRun      = "finetune:run"
 	TypeWebhookDeliver   = "webhook:deliver"
+	TypeVectorIndexBuild = "vector:index_build"
+	TypeRetrievalEval    = "eval:retrieval"
+	TypeEvalDatasetGenerate = "eval:dataset_generate"
+	TypeConnectorSync    = "connector:sync"
+	// TypeConnectorSchedule runs every minute and queues the syncs that
+	// are due.
+	TypeConnectorSchedule = "connector:schedule"
+	// TypeDocumentBatchExpand creates the documents of an archive batch.
+	TypeDocumentBatchExpand = "document:batch_expand"
+	// TypeGraphCommunityBuild rebuilds a tenant's GraphRAG community
+	// summaries after graph indexing.
+	TypeGraphCommunityBuild = "graph:community_build"
 )
 
 type DocumentProcessPayload struct {
@@ -15,6 +27,9 @@ type DocumentProcessPayload struct {
 type EmbeddingGeneratePayload struct {
 	DocumentID string `json:"document_id"`
 	TenantID   string `json:"tenant_id"`
+	// CollectionIDs indexes the document into these collections instead of
+	// the tenant's default pool.
+	CollectionIDs []string `json:"collection_ids,omitempty"`
 }
 
 type FinetuneRunPayload struct {
@@ -27,3 +42,32 @@ type WebhookDeliverPayload struct {
 	Event     string `json:"event"`
 	Payload   string `json:"payload"` // JSON string
 }
+
+type VectorIndexBuildPayload struct {
+	JobID    string `json:"job_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+type RetrievalEvalPayload struct {
+	RunID    string `json:"run_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+type EvalDatasetGeneratePayload struct {
+	DatasetID string `json:"dataset_id"`
+	TenantID  string `json:"tenant_id"`
+}
+
+type ConnectorSyncPayload struct {
+	SourceID string `json:"source_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+type DocumentBatchExpandPayload struct {
+	BatchID  string `json:"batch_id"`
+	TenantID string `json:"tenant_id"`
+}
+
+type GraphCommunityBuildPayload struct {
+	TenantID string `json:"tenant_id"`
+}
@@ -0,0 +1,45 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/upload"
+)
+
+type BatchWorker struct {
+	svc *upload.Service
+}
+
+func NewBatchWorker(svc *upload.Service) *BatchWorker {
+	return &BatchWorker{svc: svc}
+}
+
+// ProcessTask expands an archive batch into documents.
+func (w *BatchWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload queue.DocumentBatchExpandPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	batchID, err := uuid.Parse(payload.BatchID)
+	if err != nil {
+		return fmt.Errorf("parse batch ID: %w", err)
+	}
+	tenantID, err := uuid.Parse(payload.TenantID)
+	if err != nil {
+		return fmt.Errorf("parse tenant ID: %w", err)
+	}
+
+	slog.Info("expanding document batch", "batch_id", batchID, "tenant_id", tenantID)
+	if err := w.svc.Expand(ctx, tenantID, batchID); err != nil {
+		return fmt.Errorf("expand document batch: %w", err)
+	}
+	slog.Info("document batch expanded", "batch_id", batchID)
+	return nil
+}
@@ -0,0 +1,54 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/nikhilbhutani/backendwithai/internal/connector"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+)
+
+type ConnectorWorker struct {
+	svc *connector.Service
+}
+
+func NewConnectorWorker(svc *connector.Service) *ConnectorWorker {
+	return &ConnectorWorker{svc: svc}
+}
+
+// ProcessTask syncs one connector source.
+func (w *ConnectorWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload queue.ConnectorSyncPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	sourceID, err := uuid.Parse(payload.SourceID)
+	if err != nil {
+		return fmt.Errorf("parse source ID: %w", err)
+	}
+	tenantID, err := uuid.Parse(payload.TenantID)
+	if err != nil {
+		return fmt.Errorf("parse tenant ID: %w", err)
+	}
+
+	slog.Info("syncing connector source", "source_id", sourceID, "tenant_id", tenantID)
+	if err := w.svc.Sync(ctx, tenantID, sourceID); err != nil {
+		return fmt.Errorf("sync connector source: %w", err)
+	}
+	slog.Info("connector source synced", "source_id", sourceID)
+	return nil
+}
+
+// ScheduleTask queues the syncs that are due; the scheduler runs it every
+// minute.
+func (w *ConnectorWorker) ScheduleTask(ctx context.Context, t *asynq.Task) error {
+	if err := w.svc.QueueDue(ctx); err != nil {
+		return fmt.Errorf("queue due connector syncs: %w", err)
+	}
+	return nil
+}
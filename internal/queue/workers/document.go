@@ -3,8 +3,11 @@ package workers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
@@ -12,26 +15,29 @@ import (
 	"github.com/nikhilbhutani/backendwithai/internal/models"
 	"github.com/nikhilbhutani/backendwithai/internal/queue"
 	"github.com/nikhilbhutani/backendwithai/internal/storage"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
 )
 
 type DocumentWorker struct {
-	docSvc    *document.Service
-	storage   storage.Storage
-	bucket    string
-	extractor document.TextExtractor
+	docSvc      *document.Service
+	storage     storage.Storage
+	bucket      string
+	extractor   document.TextExtractor
 	queueClient *queue.Client
 }
 
-func NewDocumentWorker(docSvc *document.Service, store storage.Storage, bucket string, qc *queue.Client) *DocumentWorker {
+func NewDocumentWorker(docSvc *document.Service, store storage.Storage, bucket string, extractor document.TextExtractor, qc *queue.Client) *DocumentWorker {
 	return &DocumentWorker{
 		docSvc:      docSvc,
 		storage:     store,
 		bucket:      bucket,
-		extractor:   document.NewTextExtractor(),
+		extractor:   extractor,
 		queueClient: qc,
 	}
 }
 
+// ProcessTask extracts an uploaded document's text, stores it and queues
+// the document for chunking and embedding.
 func (w *DocumentWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
 	var payload queue.DocumentProcessPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
@@ -42,69 +48,77 @@ func (w *DocumentWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
 	if err != nil {
 		return fmt.Errorf("parse document ID: %w", err)
 	}
+	tenantID, err := uuid.Parse(payload.TenantID)
+	if err != nil {
+		return fmt.Errorf("parse tenant ID: %w", err)
+	}
+	ctx = tenant.WithTenant(ctx, &models.Tenant{ID: tenantID})
 
 	slog.Info("processing document", "document_id", docID)
 
-	if err := w.docSvc.UpdateStatus(ctx, docID, models.DocStatusProcessing); err != nil {
-		return fmt.Errorf("update status to processing: %w", err)
-	}
-
-	doc, err := w.docSvc.GetByID(ctx, docID)
+	doc, err := w.docSvc.Load(ctx, tenantID, docID)
 	if err != nil {
-		w.docSvc.UpdateStatus(ctx, docID, models.DocStatusFailed)
 		return fmt.Errorf("get document: %w", err)
 	}
+	if err := w.docSvc.StartProcessing(ctx, docID); err != nil {
+		return err
+	}
 
-	// Download file from storage
+	if err := w.process(ctx, doc); err != nil {
+		return failDocument(ctx, w.docSvc, docID, err)
+	}
+
+	slog.Info("document processed", "document_id", docID)
+	return nil
+}
+
+func (w *DocumentWorker) process(ctx context.Context, doc *models.Document) error {
 	reader, err := w.storage.Download(ctx, w.bucket, doc.FilePath)
 	if err != nil {
-		w.docSvc.UpdateStatus(ctx, docID, models.DocStatusFailed)
 		return fmt.Errorf("download file: %w", err)
 	}
 	defer reader.Close()
 
-	data, err := readAll(reader)
+	data, err := io.ReadAll(reader)
 	if err != nil {
-		w.docSvc.UpdateStatus(ctx, docID, models.DocStatusFailed)
 		return fmt.Errorf("read file: %w", err)
 	}
 
-	// Extract text
+	// Extraction fails the same way every time, so it isn't retried.
 	readerAt := document.ReaderAtFromBytes(data)
 	extracted, err := w.extractor.Extract(ctx, readerAt, int64(len(data)), doc.FileType)
 	if err != nil {
-		w.docSvc.UpdateStatus(ctx, docID, models.DocStatusFailed)
-		return fmt.Errorf("extract text: %w", err)
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	if strings.TrimSpace(extracted.Content) == "" {
+		return fmt.Errorf("no text could be extracted: %w", asynq.SkipRetry)
 	}
 
-	_ = extracted
+	if err := w.docSvc.SaveContent(ctx, doc, extracted); err != nil {
+		return err
+	}
 
-	// Enqueue embedding generation
 	if err := w.queueClient.EnqueueEmbeddingGenerate(queue.EmbeddingGeneratePayload{
-		DocumentID: docID.String(),
-		TenantID:   payload.TenantID,
+		DocumentID: doc.ID.String(),
+		TenantID:   doc.TenantID.String(),
 	}); err != nil {
-		slog.Error("failed to enqueue embedding", "error", err)
+		return fmt.Errorf("enqueue embedding: %w", err)
 	}
-
-	slog.Info("document processed", "document_id", docID)
 	return nil
 }
 
-func readAll(r interface{ Read([]byte) (int, error) }) ([]byte, error) {
-	var data []byte
-	buf := make([]byte, 32*1024)
-	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			data = append(data, buf[:n]...)
-		}
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return data, err
+// failDocument records a processing error on the document and returns it.
+// The document is marked failed once the task won't be retried.
+func failDocument(ctx context.Context, docSvc *document.Service, docID uuid.UUID, cause error) error {
+	final := errors.Is(cause, asynq.SkipRetry)
+	if retried, ok := asynq.GetRetryCount(ctx); ok {
+		if maxRetry, ok := asynq.GetMaxRetry(ctx); ok && retried >= maxRetry {
+			final = true
 		}
 	}
-	return data, nil
+	msg := strings.TrimSuffix(cause.Error(), ": "+asynq.SkipRetry.Error())
+	if err := docSvc.RecordError(context.WithoutCancel(ctx), docID, errors.New(msg), final); err != nil {
+		slog.Error("failed to record document error", "document_id", docID, "error", err)
+	}
+	return cause
 }
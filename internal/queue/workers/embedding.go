@@ -3,6 +3,7 @@ package workers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -12,21 +13,35 @@ import (
 	"github.com/nikhilbhutani/backendwithai/internal/models"
 	"github.com/nikhilbhutani/backendwithai/internal/queue"
 	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
 	"github.com/nikhilbhutani/backendwithai/pkg/chunker"
 )
 
 type EmbeddingWorker struct {
-	docSvc   *document.Service
-	pipeline rag.Pipeline
+	docSvc      *document.Service
+	pipeline    rag.Pipeline
+	collections rag.CollectionStore
+	store       vectorstore.VectorStore
 }
 
-func NewEmbeddingWorker(docSvc *document.Service, pipeline rag.Pipeline) *EmbeddingWorker {
+// NewEmbeddingWorker creates the worker that chunks and indexes extracted
+// text. collections may be nil when collections are not enabled; store is
+// where the pipeline indexes chunks, used to retire old versions' and to
+// keep chunk access lists current.
+func NewEmbeddingWorker(docSvc *document.Service, pipeline rag.Pipeline, collections rag.CollectionStore, store vectorstore.VectorStore) *EmbeddingWorker {
 	return &EmbeddingWorker{
-		docSvc:   docSvc,
-		pipeline: pipeline,
+		docSvc:      docSvc,
+		pipeline:    pipeline,
+		collections: collections,
+		store:       store,
 	}
 }
 
+// ProcessTask indexes a document's extracted text. Without collection IDs
+// it indexes the document into the default pool, then into the collections
+// it already belongs to, supersedes its older versions and marks it ready.
+// With collection IDs it only (re)indexes it into those collections.
 func (w *EmbeddingWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
 	var payload queue.EmbeddingGeneratePayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
@@ -42,27 +57,187 @@ func (w *EmbeddingWorker) ProcessTask(ctx context.Context, t *asynq.Task) error
 	if err != nil {
 		return fmt.Errorf("parse tenant ID: %w", err)
 	}
+	ctx = tenant.WithTenant(ctx, &models.Tenant{ID: tenantID})
 
-	slog.Info("generating embeddings", "document_id", docID)
+	collectionIDs := make([]uuid.UUID, 0, len(payload.CollectionIDs))
+	for _, id := range payload.CollectionIDs {
+		collectionID, err := uuid.Parse(id)
+		if err != nil {
+			return fmt.Errorf("parse collection ID: %w", err)
+		}
+		collectionIDs = append(collectionIDs, collectionID)
+	}
+
+	slog.Info("generating embeddings", "document_id", docID, "collections", len(collectionIDs))
+
+	req, err := w.ingestRequest(ctx, tenantID, docID)
+	if len(collectionIDs) > 0 {
+		// A document added to a collection before its text is extracted is
+		// indexed into it when processing completes.
+		if errors.Is(err, document.ErrNoContent) {
+			slog.Info("document not extracted yet, deferring collection indexing", "document_id", docID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		req.CollectionIDs = collectionIDs
+		if err := w.pipeline.Ingest(ctx, req); err != nil {
+			return fmt.Errorf("ingest into collections: %w", err)
+		}
+		if err := w.docSvc.SyncChunkAccess(ctx, w.store, tenantID, docID); err != nil {
+			return err
+		}
+		slog.Info("embeddings generated", "document_id", docID, "collections", len(collectionIDs))
+		return nil
+	}
 
-	// For now, the content would need to come from somewhere (e.g., stored extracted text).
-	// In production, you'd store the extracted content or re-extract it.
-	// This is the ingestion step into the RAG pipeline.
-	err = w.pipeline.Ingest(ctx, rag.IngestRequest{
-		DocumentID: docID,
-		TenantID:   tenantID,
-		Content:    "", // Would come from extracted text storage
-		ChunkOpts:  chunker.DefaultOptions(),
-	})
 	if err != nil {
-		w.docSvc.UpdateStatus(ctx, docID, models.DocStatusFailed)
-		return fmt.Errorf("ingest into RAG: %w", err)
+		return failDocument(ctx, w.docSvc, docID, err)
+	}
+	if err := w.pipeline.Ingest(ctx, req); err != nil {
+		return failDocument(ctx, w.docSvc, docID, fmt.Errorf("ingest into RAG: %w", err))
+	}
+	if w.collections != nil {
+		member, err := w.collections.DocumentCollections(ctx, docID)
+		if err != nil {
+			return failDocument(ctx, w.docSvc, docID, fmt.Errorf("get document collections: %w", err))
+		}
+		if len(member) > 0 {
+			req.CollectionIDs = member
+			if err := w.pipeline.Ingest(ctx, req); err != nil {
+				return failDocument(ctx, w.docSvc, docID, fmt.Errorf("ingest into collections: %w", err))
+			}
+		}
 	}
 
-	if err := w.docSvc.UpdateStatus(ctx, docID, models.DocStatusReady); err != nil {
-		return fmt.Errorf("update status to ready: %w", err)
+	// The chunks were written with the ACL read before ingesting; an ACL
+	// change since then must not be overwritten by it.
+	if err := w.docSvc.SyncChunkAccess(ctx, w.store, tenantID, docID); err != nil {
+		return failDocument(ctx, w.docSvc, docID, err)
+	}
+	if err := w.supersede(ctx, tenantID, docID); err != nil {
+		return failDocument(ctx, w.docSvc, docID, err)
+	}
+	if err := w.docSvc.MarkReady(ctx, docID); err != nil {
+		return err
 	}
 
 	slog.Info("embeddings generated", "document_id", docID)
 	return nil
 }
+
+// supersede retires the chunks of the versions a newly indexed document
+// replaces, or its own if a newer version was indexed first. Chunks are
+// flagged before the documents are, so a retry redoes both.
+func (w *EmbeddingWorker) supersede(ctx context.Context, tenantID, docID uuid.UUID) error {
+	doc, err := w.docSvc.Load(ctx, tenantID, docID)
+	if err != nil {
+		return fmt.Errorf("get document: %w", err)
+	}
+	if doc.ExternalID == "" {
+		return nil
+	}
+
+	newer, err := w.docSvc.NewerVersion(ctx, doc)
+	if err != nil {
+		return err
+	}
+	if newer != nil {
+		if err := w.store.SetSuperseded(ctx, tenantID, docID, true); err != nil {
+			return fmt.Errorf("supersede document chunks: %w", err)
+		}
+		return w.docSvc.MarkSuperseded(ctx, []uuid.UUID{docID}, newer.ID)
+	}
+
+	older, err := w.docSvc.ReplacedVersions(ctx, doc)
+	if err != nil {
+		return err
+	}
+	for _, id := range older {
+		if err := w.store.SetSuperseded(ctx, tenantID, id, true); err != nil {
+			return fmt.Errorf("supersede version chunks: %w", err)
+		}
+	}
+	if err := w.docSvc.MarkSuperseded(ctx, older, docID); err != nil {
+		return err
+	}
+	if len(older) > 0 {
+		slog.Info("superseded older versions", "document_id", docID, "version", doc.Version, "superseded", len(older))
+	}
+	return nil
+}
+
+// ingestRequest builds the ingestion of a document's stored text with the
+// options chosen at upload.
+func (w *EmbeddingWorker) ingestRequest(ctx context.Context, tenantID, docID uuid.UUID) (rag.IngestRequest, error) {
+	doc, err := w.docSvc.Load(ctx, tenantID, docID)
+	if err != nil {
+		return rag.IngestRequest{}, fmt.Errorf("get document: %w", err)
+	}
+	content, err := w.docSvc.Content(ctx, tenantID, docID)
+	if err != nil {
+		return rag.IngestRequest{}, err
+	}
+	opts, err := document.ParseIngestOptions(doc.IngestOptions)
+	if err != nil {
+		return rag.IngestRequest{}, err
+	}
+
+	// Chunks inherit the document's ACL. ProcessTask reapplies it after
+	// ingesting, in case it changed meanwhile.
+	access, err := w.docSvc.Access(ctx, docID)
+	if err != nil {
+		return rag.IngestRequest{}, fmt.Errorf("get document access: %w", err)
+	}
+
+	// A new version reuses the embeddings of the chunks it shares with the
+	// one before it.
+	var previous uuid.UUID
+	prev, err := w.docSvc.PreviousVersion(ctx, doc)
+	if err != nil {
+		return rag.IngestRequest{}, err
+	}
+	if prev != nil {
+		previous = prev.ID
+	}
+
+	chunkOpts := chunker.DefaultOptions()
+	if o := opts.ChunkOpts; o != nil {
+		if o.ChunkSize > 0 {
+			chunkOpts = *o
+		} else {
+			// Only a strategy was chosen; keep the default sizes.
+			if o.Strategy != "" {
+				chunkOpts.Strategy = o.Strategy
+			}
+			chunkOpts.Language = o.Language
+		}
+	}
+	// Structured formats render their sections as Markdown headings; chunk
+	// along them unless a strategy was chosen.
+	if (opts.ChunkOpts == nil || opts.ChunkOpts.Strategy == "") && hasTitledSections(content.Sections) {
+		chunkOpts.Strategy = "markdown"
+	}
+	return rag.IngestRequest{
+		DocumentID:      docID,
+		TenantID:        tenantID,
+		Content:         content.Content,
+		ChunkOpts:       chunkOpts,
+		IndexType:       opts.IndexType,
+		Title:           doc.Title,
+		ContextHeaders:  opts.ContextHeaders,
+		Access:          access,
+		PreviousVersion: previous,
+		Superseded:      doc.SupersededBy != nil,
+	}, nil
+}
+
+func hasTitledSections(sections []models.DocumentSection) bool {
+	for _, s := range sections {
+		if s.Title != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/nikhilbhutani/backendwithai/internal/evalset"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+)
+
+type EvalDatasetWorker struct {
+	svc *evalset.Service
+}
+
+func NewEvalDatasetWorker(svc *evalset.Service) *EvalDatasetWorker {
+	return &EvalDatasetWorker{svc: svc}
+}
+
+func (w *EvalDatasetWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload queue.EvalDatasetGeneratePayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	datasetID, err := uuid.Parse(payload.DatasetID)
+	if err != nil {
+		return fmt.Errorf("parse dataset ID: %w", err)
+	}
+
+	slog.Info("generating eval dataset", "dataset_id", datasetID, "tenant_id", payload.TenantID)
+	if err := w.svc.RunJob(ctx, datasetID); err != nil {
+		return fmt.Errorf("generate eval dataset: %w", err)
+	}
+	slog.Info("eval dataset generated", "dataset_id", datasetID)
+	return nil
+}
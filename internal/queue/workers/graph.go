@@ -0,0 +1,54 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/rag/indexing"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+)
+
+// GraphWorker rebuilds GraphRAG community summaries. It is also the graph
+// indexer's scheduler, queueing the rebuilds that indexing requests.
+type GraphWorker struct {
+	indexer *indexing.GraphIndexer
+	queue   *queue.Client
+}
+
+func NewGraphWorker(indexer *indexing.GraphIndexer, queueClient *queue.Client) *GraphWorker {
+	return &GraphWorker{indexer: indexer, queue: queueClient}
+}
+
+// ScheduleCommunityBuild queues a rebuild of the tenant's communities.
+func (w *GraphWorker) ScheduleCommunityBuild(ctx context.Context, tenantID uuid.UUID) error {
+	return w.queue.EnqueueGraphCommunityBuild(queue.GraphCommunityBuildPayload{TenantID: tenantID.String()})
+}
+
+// ProcessTask rebuilds one tenant's community summaries.
+func (w *GraphWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload queue.GraphCommunityBuildPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	tenantID, err := uuid.Parse(payload.TenantID)
+	if err != nil {
+		return fmt.Errorf("parse tenant ID: %w", err)
+	}
+	ctx = tenant.WithTenant(ctx, &models.Tenant{ID: tenantID})
+
+	slog.Info("building graph communities", "tenant_id", tenantID)
+	if err := w.indexer.BuildCommunities(ctx, tenantID); err != nil {
+		return fmt.Errorf("build graph communities: %w", err)
+	}
+	slog.Info("graph communities built", "tenant_id", tenantID)
+	return nil
+}
+
+var _ indexing.CommunityScheduler = (*GraphWorker)(nil)
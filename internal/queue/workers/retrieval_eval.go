@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/retrievaleval"
+)
+
+type RetrievalEvalWorker struct {
+	svc *retrievaleval.Service
+}
+
+func NewRetrievalEvalWorker(svc *retrievaleval.Service) *RetrievalEvalWorker {
+	return &RetrievalEvalWorker{svc: svc}
+}
+
+func (w *RetrievalEvalWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload queue.RetrievalEvalPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	runID, err := uuid.Parse(payload.RunID)
+	if err != nil {
+		return fmt.Errorf("parse run ID: %w", err)
+	}
+
+	slog.Info("running retrieval eval", "run_id", runID, "tenant_id", payload.TenantID)
+	if err := w.svc.RunJob(ctx, runID); err != nil {
+		return fmt.Errorf("run retrieval eval: %w", err)
+	}
+	slog.Info("retrieval eval completed", "run_id", runID)
+	return nil
+}
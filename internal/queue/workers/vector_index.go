@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorindex"
+)
+
+type VectorIndexWorker struct {
+	svc *vectorindex.Service
+}
+
+func NewVectorIndexWorker(svc *vectorindex.Service) *VectorIndexWorker {
+	return &VectorIndexWorker{svc: svc}
+}
+
+func (w *VectorIndexWorker) ProcessTask(ctx context.Context, t *asynq.Task) error {
+	var payload queue.VectorIndexBuildPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("unmarshal payload: %w", err)
+	}
+
+	jobID, err := uuid.Parse(payload.JobID)
+	if err != nil {
+		return fmt.Errorf("parse job ID: %w", err)
+	}
+
+	slog.Info("building vector index", "job_id", jobID, "tenant_id", payload.TenantID)
+	if err := w.svc.RunJob(ctx, jobID); err != nil {
+		return fmt.Errorf("build vector index: %w", err)
+	}
+	slog.Info("vector index built", "job_id", jobID)
+	return nil
+}
@@ -6,13 +6,17 @@ import (
 
 	"github.com/nikhilbhutani/backendwithai/internal/embedding"
 	"github.com/nikhilbhutani/backendwithai/pkg/chunker"
-	"github.com/nikhilbhutani/backendwithai/pkg/tokenizer"
 )
 
 type ChunkResult struct {
 	Content    string
 	Index      int
 	TokenCount int
+	Start      int // offset of the chunk in the source text
+	End        int
+	Page       int // 1-based page the chunk starts on
+	// SectionPath is set by the structure-aware strategies.
+	SectionPath []string
 }
 
 // ChunkText splits text using the configured strategy.
@@ -43,9 +47,13 @@ func ChunkTextWithEmbeddings(ctx context.Context, text string, opts chunker.Chun
 	results := make([]ChunkResult, len(chunks))
 	for i, ch := range chunks {
 		results[i] = ChunkResult{
-			Content:    ch.Content,
-			Index:      ch.Index,
-			TokenCount: tokenizer.CountTokens(ch.Content),
+			Content:     ch.Content,
+			Index:       ch.Index,
+			TokenCount:  ch.Tokens,
+			Start:       ch.Start,
+			End:         ch.End,
+			Page:        ch.Page,
+			SectionPath: ch.SectionPath,
 		}
 	}
 	return results
@@ -58,7 +66,7 @@ func semanticChunk(ctx context.Context, text string, opts chunker.ChunkOptions,
 
 	// threshold: 0.8, bufferSize: 3 (defaults inside SemanticChunker)
 	sc := chunker.NewSemanticChunker(embedFn, 0, 0)
-	chunks, err := sc.ChunkWithContext(ctx, text)
+	chunks, err := sc.ChunkWithOptions(ctx, text, opts)
 	if err != nil {
 		// Fallback to recursive on error.
 		opts.Strategy = "recursive"
@@ -74,7 +82,10 @@ func semanticChunk(ctx context.Context, text string, opts chunker.ChunkOptions,
 		results[i] = ChunkResult{
 			Content:    ch.Content,
 			Index:      ch.Index,
-			TokenCount: tokenizer.CountTokens(ch.Content),
+			TokenCount: ch.Tokens,
+			Start:      ch.Start,
+			End:        ch.End,
+			Page:       ch.Page,
 		}
 	}
 	return results
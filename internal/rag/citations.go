@@ -0,0 +1,185 @@
+package rag
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nikhilbhutani/backendwithai/internal/eval"
+)
+
+// SentenceAttribution maps one answer sentence to the sources it cites.
+// Start and End are byte offsets into the answer.
+type SentenceAttribution struct {
+	Text    string `json:"text"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Sources []int  `json:"sources,omitempty"` // 1-based [Source N] numbers
+	// Supported is set only when claim verification ran: false means the
+	// sentence makes a claim the cited context does not support.
+	Supported *bool  `json:"supported,omitempty"`
+	Evidence  string `json:"evidence,omitempty"`
+}
+
+// AnswerSpan locates a citing sentence inside the answer (byte offsets).
+type AnswerSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+var (
+	citationMarkerRe = regexp.MustCompile(`\[(?:[^\]]*?)Sources?\s*\d[^\]]*\]`)
+	leadingMarkerRe  = regexp.MustCompile(`^\s*\[(?:[^\]]*?)Sources?\s*\d[^\]]*\]`)
+	digitsRe         = regexp.MustCompile(`\d+`)
+)
+
+// parseCitationMarkers returns the distinct source numbers referenced by
+// markers such as "[Source 2]", "[Source 1, Source 3]" or "[Sources 1-2]",
+// keeping only numbers in 1..numSources.
+func parseCitationMarkers(text string, numSources int) []int {
+	seen := make(map[int]bool)
+	var sources []int
+	add := func(n int) {
+		if n >= 1 && n <= numSources && !seen[n] {
+			seen[n] = true
+			sources = append(sources, n)
+		}
+	}
+
+	for _, marker := range citationMarkerRe.FindAllString(text, -1) {
+		nums := digitsRe.FindAllString(marker, -1)
+		// "[Sources 1-3]" expands to a range.
+		if len(nums) == 2 && strings.Contains(marker, "-") {
+			lo, _ := strconv.Atoi(nums[0])
+			hi, _ := strconv.Atoi(nums[1])
+			for n := lo; n <= hi && n-lo < numSources; n++ {
+				add(n)
+			}
+			continue
+		}
+		for _, s := range nums {
+			n, _ := strconv.Atoi(s)
+			add(n)
+		}
+	}
+
+	sort.Ints(sources)
+	return sources
+}
+
+// splitAnswerSentences splits an answer into sentences with byte offsets.
+// Citation markers that directly follow a sentence terminator ("... grew 3%. [Source 1]")
+// are kept with the preceding sentence.
+func splitAnswerSentences(answer string) []SentenceAttribution {
+	var sentences []SentenceAttribution
+
+	emit := func(start, end int) {
+		raw := answer[start:end]
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			return
+		}
+		lead := strings.Index(raw, trimmed)
+		sentences = append(sentences, SentenceAttribution{
+			Text:  trimmed,
+			Start: start + lead,
+			End:   start + lead + len(trimmed),
+		})
+	}
+
+	start := 0
+	for i := 0; i < len(answer); i++ {
+		c := answer[i]
+		boundary := false
+		switch {
+		case c == '\n':
+			boundary = true
+		case c == '.' || c == '!' || c == '?':
+			boundary = i+1 == len(answer) || unicode.IsSpace(rune(answer[i+1]))
+		}
+		if !boundary {
+			continue
+		}
+
+		end := i + 1
+		for {
+			loc := leadingMarkerRe.FindStringIndex(answer[end:])
+			if loc == nil {
+				break
+			}
+			end += loc[1]
+		}
+		emit(start, end)
+		start = end
+		i = end - 1
+	}
+	if start < len(answer) {
+		emit(start, len(answer))
+	}
+	return sentences
+}
+
+// attributeSentences splits the answer and records which sources each sentence cites.
+func attributeSentences(answer string, numSources int) []SentenceAttribution {
+	sentences := splitAnswerSentences(answer)
+	for i := range sentences {
+		sentences[i].Sources = parseCitationMarkers(sentences[i].Text, numSources)
+	}
+	return sentences
+}
+
+// applyClaimVerification marks each sentence supported or not by matching
+// verified claims to the sentence with the greatest word overlap.
+func applyClaimVerification(sentences []SentenceAttribution, claims []eval.ClaimVerification) {
+	for _, claim := range claims {
+		best, bestScore := -1, 0.0
+		claimWords := wordSet(claim.Claim)
+		for i, s := range sentences {
+			if score := overlap(claimWords, wordSet(s.Text)); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		s := &sentences[best]
+		supported := claim.Supported
+		if s.Supported != nil && !*s.Supported {
+			// One unsupported claim is enough to flag the sentence.
+			continue
+		}
+		s.Supported = &supported
+		if !supported || s.Evidence == "" {
+			s.Evidence = claim.Evidence
+		}
+	}
+}
+
+func wordSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) > 2 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// overlap returns the fraction of a's words that also appear in b.
+func overlap(a, b map[string]bool) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	n := 0
+	for w := range a {
+		if b[w] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a))
+}
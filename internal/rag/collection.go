@@ -0,0 +1,325 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/pkg/chunker"
+)
+
+// CollectionStore persists knowledge-base collections and their document
+// membership, scoped to the tenant in the request context.
+type CollectionStore interface {
+	Create(ctx context.Context, c *models.Collection) (*models.Collection, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Collection, error)
+	List(ctx context.Context) ([]models.Collection, error)
+	Update(ctx context.Context, c *models.Collection) (*models.Collection, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// AddDocuments adds documents to a collection and returns the IDs that
+	// were not already members.
+	AddDocuments(ctx context.Context, id uuid.UUID, documentIDs []uuid.UUID) ([]uuid.UUID, error)
+	RemoveDocument(ctx context.Context, id, documentID uuid.UUID) error
+	ListDocuments(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error)
+	// DocumentCollections returns the IDs of the collections a document belongs to.
+	DocumentCollections(ctx context.Context, documentID uuid.UUID) ([]uuid.UUID, error)
+}
+
+// ValidateCollection checks a collection's settings before it is stored.
+func ValidateCollection(c *models.Collection) error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	opts, err := collectionChunkOptions(c)
+	if err != nil {
+		return err
+	}
+	if err := ValidateIngestSettings(opts, c.IndexType, c.ContextHeaders); err != nil {
+		return err
+	}
+	// The raptor, multi_rep and graph indexers embed with the pipeline's
+	// default model.
+	if c.EmbeddingModel != "" && c.IndexType != "" && c.IndexType != IndexTypeStandard {
+		return fmt.Errorf("embedding_model requires the standard index type")
+	}
+	return nil
+}
+
+// collectionChunkOptions decodes the collection's chunking settings. A zero
+// ChunkSize means the collection does not override chunking.
+func collectionChunkOptions(c *models.Collection) (chunker.ChunkOptions, error) {
+	var opts chunker.ChunkOptions
+	if len(c.ChunkOpts) == 0 || string(c.ChunkOpts) == "null" {
+		return opts, nil
+	}
+	if err := json.Unmarshal(c.ChunkOpts, &opts); err != nil {
+		return opts, fmt.Errorf("invalid chunk_opts: %w", err)
+	}
+	return opts, nil
+}
+
+// ValidateIngestSettings checks chunking options, index type and context
+// header mode, whether set on a collection or chosen for one document.
+// Zero values mean the defaults.
+func ValidateIngestSettings(opts chunker.ChunkOptions, indexType, contextHeaders string) error {
+	if opts.ChunkSize < 0 || opts.ChunkOverlap < 0 {
+		return fmt.Errorf("invalid chunk_opts: sizes must not be negative")
+	}
+	if opts.ChunkSize > 0 && opts.ChunkOverlap >= opts.ChunkSize {
+		return fmt.Errorf("invalid chunk_opts: chunk_overlap must be smaller than chunk_size")
+	}
+	switch indexType {
+	case "", IndexTypeStandard, IndexTypeRaptor, IndexTypeMultiRep, IndexTypeGraph:
+	default:
+		return fmt.Errorf("unknown index type %q", indexType)
+	}
+	switch contextHeaders {
+	case "", ContextHeaderStructural, ContextHeaderLLM:
+	default:
+		return fmt.Errorf("unknown context header mode %q", contextHeaders)
+	}
+	return nil
+}
+
+// PgCollectionStore stores collections in Postgres.
+type PgCollectionStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPgCollectionStore(db *pgxpool.Pool) *PgCollectionStore {
+	return &PgCollectionStore{db: db}
+}
+
+const collectionColumns = `c.id, c.tenant_id, c.name, COALESCE(c.description, ''), c.chunk_opts, c.index_type,
+	COALESCE(c.context_headers, ''), COALESCE(c.embedding_model, ''),
+	(SELECT count(*) FROM document_collections dc WHERE dc.collection_id = c.id), c.created_at, c.updated_at`
+
+func scanCollection(row interface{ Scan(...any) error }) (*models.Collection, error) {
+	var c models.Collection
+	err := row.Scan(&c.ID, &c.TenantID, &c.Name, &c.Description, &c.ChunkOpts, &c.IndexType,
+		&c.ContextHeaders, &c.EmbeddingModel, &c.DocumentCount, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// nullable stores empty optional settings as NULL.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func chunkOptsJSON(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
+
+func (s *PgCollectionStore) Create(ctx context.Context, c *models.Collection) (*models.Collection, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if c.IndexType == "" {
+		c.IndexType = IndexTypeStandard
+	}
+
+	created, err := scanCollection(s.db.QueryRow(ctx,
+		`WITH c AS (
+			INSERT INTO collections (tenant_id, name, description, chunk_opts, index_type, context_headers, embedding_model)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING *
+		 )
+		 SELECT `+collectionColumns+` FROM c`,
+		tenantID, c.Name, nullable(c.Description), chunkOptsJSON(c.ChunkOpts), c.IndexType,
+		nullable(c.ContextHeaders), nullable(c.EmbeddingModel),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create collection: %w", err)
+	}
+	return created, nil
+}
+
+func (s *PgCollectionStore) Get(ctx context.Context, id uuid.UUID) (*models.Collection, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	c, err := scanCollection(s.db.QueryRow(ctx,
+		`SELECT `+collectionColumns+` FROM collections c WHERE c.id = $1 AND c.tenant_id = $2`,
+		id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get collection: %w", err)
+	}
+	return c, nil
+}
+
+func (s *PgCollectionStore) List(ctx context.Context) ([]models.Collection, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT `+collectionColumns+` FROM collections c WHERE c.tenant_id = $1 ORDER BY c.name`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list collections: %w", err)
+	}
+	defer rows.Close()
+
+	var collections []models.Collection
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan collection: %w", err)
+		}
+		collections = append(collections, *c)
+	}
+	return collections, nil
+}
+
+// Update replaces the collection's name, description and settings. New
+// settings apply to documents ingested afterwards; existing chunks are not
+// re-indexed.
+func (s *PgCollectionStore) Update(ctx context.Context, c *models.Collection) (*models.Collection, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if c.IndexType == "" {
+		c.IndexType = IndexTypeStandard
+	}
+
+	updated, err := scanCollection(s.db.QueryRow(ctx,
+		`WITH c AS (
+			UPDATE collections
+			SET name = $3, description = $4, chunk_opts = $5, index_type = $6,
+			    context_headers = $7, embedding_model = $8, updated_at = now()
+			WHERE id = $1 AND tenant_id = $2
+			RETURNING *
+		 )
+		 SELECT `+collectionColumns+` FROM c`,
+		c.ID, tenantID, c.Name, nullable(c.Description), chunkOptsJSON(c.ChunkOpts), c.IndexType,
+		nullable(c.ContextHeaders), nullable(c.EmbeddingModel),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("update collection: %w", err)
+	}
+	return updated, nil
+}
+
+// Delete removes the collection and its memberships. Its chunks in
+// document_chunks go with it (ON DELETE CASCADE); other vector stores must
+// be cleared separately.
+func (s *PgCollectionStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tenantID := tenant.IDFromContext(ctx)
+	tag, err := s.db.Exec(ctx, "DELETE FROM collections WHERE id = $1 AND tenant_id = $2", id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete collection: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("collection %s not found", id)
+	}
+	return nil
+}
+
+func (s *PgCollectionStore) AddDocuments(ctx context.Context, id uuid.UUID, documentIDs []uuid.UUID) ([]uuid.UUID, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	// Only the tenant's own documents can join the tenant's collection.
+	rows, err := s.db.Query(ctx,
+		`INSERT INTO document_collections (document_id, collection_id)
+		 SELECT d.id, c.id
+		 FROM documents d
+		 JOIN collections c ON c.id = $1 AND c.tenant_id = d.tenant_id
+		 WHERE d.tenant_id = $2 AND d.id = ANY($3)
+		 ON CONFLICT DO NOTHING
+		 RETURNING document_id`,
+		id, tenantID, documentIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("add collection documents: %w", err)
+	}
+	defer rows.Close()
+
+	var added []uuid.UUID
+	for rows.Next() {
+		var docID uuid.UUID
+		if err := rows.Scan(&docID); err != nil {
+			return nil, fmt.Errorf("scan document ID: %w", err)
+		}
+		added = append(added, docID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("add collection documents: %w", err)
+	}
+
+	if _, err := s.db.Exec(ctx,
+		"UPDATE collections SET updated_at = now() WHERE id = $1 AND tenant_id = $2", id, tenantID,
+	); err != nil {
+		return nil, fmt.Errorf("touch collection: %w", err)
+	}
+	return added, nil
+}
+
+func (s *PgCollectionStore) RemoveDocument(ctx context.Context, id, documentID uuid.UUID) error {
+	tenantID := tenant.IDFromContext(ctx)
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM document_collections dc
+		 USING collections c
+		 WHERE dc.collection_id = c.id AND c.id = $1 AND c.tenant_id = $2 AND dc.document_id = $3`,
+		id, tenantID, documentID,
+	)
+	if err != nil {
+		return fmt.Errorf("remove collection document: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("document %s is not in collection %s", documentID, id)
+	}
+	return nil
+}
+
+// ListDocuments returns the collection's documents the caller may read, as
+// document.Service.List would list them.
+func (s *PgCollectionStore) ListDocuments(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	return s.queryIDs(ctx,
+		`SELECT dc.document_id FROM document_collections dc
+		 JOIN collections c ON c.id = dc.collection_id
+		 JOIN documents d ON d.id = dc.document_id
+		 WHERE c.id = $1 AND c.tenant_id = $2 AND `+document.ReadableBy(3)+`
+		 ORDER BY dc.created_at`,
+		id, tenantID, document.Principals(ctx),
+	)
+}
+
+func (s *PgCollectionStore) DocumentCollections(ctx context.Context, documentID uuid.UUID) ([]uuid.UUID, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	return s.queryIDs(ctx,
+		`SELECT dc.collection_id FROM document_collections dc
+		 JOIN collections c ON c.id = dc.collection_id
+		 WHERE dc.document_id = $1 AND c.tenant_id = $2
+		 ORDER BY c.name`,
+		documentID, tenantID,
+	)
+}
+
+func (s *PgCollectionStore) queryIDs(ctx context.Context, query string, args ...any) ([]uuid.UUID, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list collection members: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Ensure PgCollectionStore implements CollectionStore.
+var _ CollectionStore = (*PgCollectionStore)(nil)
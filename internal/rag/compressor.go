@@ -0,0 +1,269 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nikhilbhutani/backendwithai/internal/embedding"
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+	"github.com/nikhilbhutani/backendwithai/pkg/tokenizer"
+)
+
+// Compression methods selectable per request.
+const (
+	CompressionLLM       = "llm"
+	CompressionEmbedding = "embedding"
+)
+
+// ValidateCompression checks a request's compression method; empty means
+// no compression.
+func ValidateCompression(method string) error {
+	switch method {
+	case "", CompressionLLM, CompressionEmbedding:
+		return nil
+	}
+	return fmt.Errorf("%w: unknown compression method %q", ErrInvalidRequest, method)
+}
+
+// Compressor shrinks retrieved chunks to the spans relevant to the query
+// before generation. Chunks with nothing relevant are dropped.
+type Compressor interface {
+	Compress(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, *CompressionInfo, error)
+}
+
+// ChunkSpan is a kept span of an original chunk (byte offsets into its content).
+type ChunkSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// CompressedChunk records which spans of an original chunk were kept.
+type CompressedChunk struct {
+	ChunkID          string      `json:"chunk_id"`
+	Spans            []ChunkSpan `json:"spans"`
+	OriginalTokens   int         `json:"original_tokens"`
+	CompressedTokens int         `json:"compressed_tokens"`
+}
+
+// CompressionInfo is reported in QueryResponse when compression ran.
+type CompressionInfo struct {
+	Method           string            `json:"method"`
+	OriginalTokens   int               `json:"original_tokens"`
+	CompressedTokens int               `json:"compressed_tokens"`
+	TokensSaved      int               `json:"tokens_saved"`
+	DroppedChunks    int               `json:"dropped_chunks"`
+	Chunks           []CompressedChunk `json:"chunks"`
+}
+
+// compressResults applies keep (the indexes of sentences to keep, per
+// result) and builds the compressed results and report. Kept sentences that
+// are adjacent in the original are joined as one span; gaps are marked "...".
+func compressResults(method string, results []vectorstore.SearchResult, sentences [][]SentenceAttribution, keep [][]int) ([]vectorstore.SearchResult, *CompressionInfo) {
+	info := &CompressionInfo{Method: method}
+	compressed := make([]vectorstore.SearchResult, 0, len(results))
+
+	for i, r := range results {
+		original := tokenizer.CountTokens(r.Content)
+		info.OriginalTokens += original
+
+		var spans []ChunkSpan
+		for _, idx := range keep[i] {
+			s := sentences[i][idx]
+			if n := len(spans); n > 0 && strings.TrimSpace(r.Content[spans[n-1].End:s.Start]) == "" {
+				spans[n-1].End = s.End
+				continue
+			}
+			spans = append(spans, ChunkSpan{Start: s.Start, End: s.End})
+		}
+		if len(spans) == 0 {
+			info.DroppedChunks++
+			continue
+		}
+
+		parts := make([]string, len(spans))
+		for j, sp := range spans {
+			parts[j] = r.Content[sp.Start:sp.End]
+		}
+		content := strings.Join(parts, " ... ")
+		tokens := tokenizer.CountTokens(content)
+		info.CompressedTokens += tokens
+
+		out := r
+		out.Content = content
+		out.Metadata = make(map[string]interface{}, len(r.Metadata)+1)
+		for k, v := range r.Metadata {
+			out.Metadata[k] = v
+		}
+		out.Metadata["compressed"] = true
+		compressed = append(compressed, out)
+
+		info.Chunks = append(info.Chunks, CompressedChunk{
+			ChunkID:          r.ChunkID.String(),
+			Spans:            spans,
+			OriginalTokens:   original,
+			CompressedTokens: tokens,
+		})
+	}
+
+	info.TokensSaved = info.OriginalTokens - info.CompressedTokens
+	return compressed, info
+}
+
+// LLMCompressor asks the LLM which numbered sentences of each chunk are
+// relevant, so the kept text is always a verbatim extract. Chunks are sent
+// in separate calls, up to concurrency at a time.
+type LLMCompressor struct {
+	gateway     llm.Gateway
+	model       string
+	concurrency int
+}
+
+func NewLLMCompressor(gw llm.Gateway, model string) *LLMCompressor {
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &LLMCompressor{gateway: gw, model: model, concurrency: 4}
+}
+
+func (c *LLMCompressor) Compress(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, *CompressionInfo, error) {
+	sentences := make([][]SentenceAttribution, len(results))
+	keep := make([][]int, len(results))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, c.concurrency)
+	for i, r := range results {
+		sentences[i] = splitAnswerSentences(r.Content)
+		if len(sentences[i]) <= 1 {
+			keep[i] = allIndexes(len(sentences[i]))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			keep[i] = c.selectSentences(ctx, query, sentences[i])
+		}()
+	}
+	wg.Wait()
+
+	compressed, info := compressResults(CompressionLLM, results, sentences, keep)
+	return compressed, info, nil
+}
+
+// selectSentences returns the indexes of the relevant sentences of one
+// chunk, or all of them if the LLM call fails.
+func (c *LLMCompressor) selectSentences(ctx context.Context, query string, sentences []SentenceAttribution) []int {
+	var sb strings.Builder
+	for j, s := range sentences {
+		fmt.Fprintf(&sb, "[%d] %s\n", j, s.Text)
+	}
+
+	resp, err := c.gateway.Chat(ctx, llm.ChatRequest{
+		Model: c.model,
+		Messages: []llm.Message{
+			{
+				Role: "system",
+				Content: `You select the sentences of a passage that help answer a question.
+Return ONLY the numbers of the relevant sentences, comma-separated (e.g. "0, 2, 3").
+Return NONE if no sentence is relevant.`,
+			},
+			{
+				Role:    "user",
+				Content: fmt.Sprintf("Question: %s\n\nPassage:\n%s", query, sb.String()),
+			},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		// Keep the chunk whole rather than lose context.
+		return allIndexes(len(sentences))
+	}
+	return parseSentenceIndexes(resp.Content, len(sentences))
+}
+
+// parseSentenceIndexes reads "0, 2, 3" style output, dropping out-of-range
+// and duplicate numbers and keeping them in passage order. Only a reply of
+// just NONE selects nothing; "0, 2 (none else)" still selects 0 and 2.
+func parseSentenceIndexes(content string, n int) []int {
+	if strings.EqualFold(strings.Trim(strings.TrimSpace(content), ".\"'`"), "NONE") {
+		return nil
+	}
+	seen := make([]bool, n)
+	for _, s := range digitsRe.FindAllString(content, -1) {
+		if idx, err := strconv.Atoi(s); err == nil && idx >= 0 && idx < n {
+			seen[idx] = true
+		}
+	}
+	var out []int
+	for i, ok := range seen {
+		if ok {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func allIndexes(n int) []int {
+	out := make([]int, n)
+	for i := range out {
+		out[i] = i
+	}
+	return out
+}
+
+// EmbeddingCompressor keeps the sentences whose embedding similarity to the
+// query is at least threshold. It costs one embedding batch and no LLM calls.
+type EmbeddingCompressor struct {
+	embedSvc  *embedding.Service
+	threshold float64
+}
+
+func NewEmbeddingCompressor(embedSvc *embedding.Service, threshold float64) *EmbeddingCompressor {
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+	return &EmbeddingCompressor{embedSvc: embedSvc, threshold: threshold}
+}
+
+func (c *EmbeddingCompressor) Compress(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, *CompressionInfo, error) {
+	sentences := make([][]SentenceAttribution, len(results))
+	texts := []string{query}
+	for i, r := range results {
+		sentences[i] = splitAnswerSentences(r.Content)
+		for _, s := range sentences[i] {
+			texts = append(texts, s.Text)
+		}
+	}
+
+	embeddings, err := c.embedSvc.Embed(ctx, texts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("embed sentences: %w", err)
+	}
+	queryVec := embeddings[0]
+
+	keep := make([][]int, len(results))
+	next := 1
+	for i := range results {
+		for j := range sentences[i] {
+			if cosine(queryVec, embeddings[next]) >= c.threshold {
+				keep[i] = append(keep[i], j)
+			}
+			next++
+		}
+	}
+
+	compressed, info := compressResults(CompressionEmbedding, results, sentences, keep)
+	return compressed, info, nil
+}
+
+// Ensure the compressors implement Compressor.
+var (
+	_ Compressor = (*LLMCompressor)(nil)
+	_ Compressor = (*EmbeddingCompressor)(nil)
+)
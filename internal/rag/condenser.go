@@ -0,0 +1,73 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/memory"
+)
+
+// QueryCondenser rewrites a follow-up question into a standalone query using
+// the preceding conversation, so retrieval does not depend on chat context.
+type QueryCondenser interface {
+	Condense(ctx context.Context, history []memory.Entry, query string) (string, error)
+}
+
+// LLMQueryCondenser uses an LLM to resolve references ("it", "the second one")
+// in a follow-up question against the conversation history.
+type LLMQueryCondenser struct {
+	gateway llm.Gateway
+	model   string
+}
+
+func NewLLMQueryCondenser(gw llm.Gateway, model string) *LLMQueryCondenser {
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &LLMQueryCondenser{gateway: gw, model: model}
+}
+
+// Condense returns a standalone version of query. With no history, or if the
+// LLM call fails, the original query is returned unchanged.
+func (c *LLMQueryCondenser) Condense(ctx context.Context, history []memory.Entry, query string) (string, error) {
+	if len(history) == 0 {
+		return query, nil
+	}
+
+	var sb strings.Builder
+	for _, e := range history {
+		fmt.Fprintf(&sb, "%s: %s\n", e.Role, truncate(e.Content, 1000))
+	}
+
+	resp, err := c.gateway.Chat(ctx, llm.ChatRequest{
+		Model: c.model,
+		Messages: []llm.Message{
+			{
+				Role: "system",
+				Content: `Given a conversation and a follow-up question, rewrite the follow-up as a standalone
+question that can be understood without the conversation. Resolve pronouns and references
+to earlier turns. If the follow-up is already standalone, return it unchanged.
+Return ONLY the standalone question, no preamble.`,
+			},
+			{
+				Role:    "user",
+				Content: fmt.Sprintf("Conversation:\n%s\nFollow-up question: %s", sb.String(), query),
+			},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return query, nil
+	}
+
+	standalone := strings.TrimSpace(resp.Content)
+	if standalone == "" {
+		return query, nil
+	}
+	return standalone, nil
+}
+
+// Ensure LLMQueryCondenser implements QueryCondenser.
+var _ QueryCondenser = (*LLMQueryCondenser)(nil)
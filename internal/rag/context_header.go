@@ -0,0 +1,208 @@
+package rag
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// Context header modes for IngestRequest.ContextHeaders.
+const (
+	// ContextHeaderStructural prepends the document title and section path.
+	ContextHeaderStructural = "structural"
+	// ContextHeaderLLM also adds an LLM-written sentence situating the chunk
+	// within the document (one LLM call per chunk).
+	ContextHeaderLLM = "llm"
+)
+
+// contextHeaderSeparator joins a header to the chunk text it describes.
+const contextHeaderSeparator = "\n\n"
+
+// maxSituateDocChars bounds how much of the document is sent when situating a chunk.
+const maxSituateDocChars = 12000
+
+// situateConcurrency is how many chunks of a document are situated at once.
+const situateConcurrency = 4
+
+// ChunkContextualizer writes a short sentence placing a chunk in the context
+// of its whole document.
+type ChunkContextualizer interface {
+	Situate(ctx context.Context, document, chunk string) (string, error)
+}
+
+// LLMChunkContextualizer situates chunks with an LLM.
+type LLMChunkContextualizer struct {
+	gateway llm.Gateway
+	model   string
+}
+
+func NewLLMChunkContextualizer(gw llm.Gateway, model string) *LLMChunkContextualizer {
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &LLMChunkContextualizer{gateway: gw, model: model}
+}
+
+func (c *LLMChunkContextualizer) Situate(ctx context.Context, document, chunk string) (string, error) {
+	if len(document) > maxSituateDocChars {
+		// Cut on a rune boundary so the prompt stays valid UTF-8.
+		n := maxSituateDocChars
+		for n > 0 && !utf8.RuneStart(document[n]) {
+			n--
+		}
+		document = document[:n]
+	}
+
+	resp, err := c.gateway.Chat(ctx, llm.ChatRequest{
+		Model: c.model,
+		Messages: []llm.Message{
+			{
+				Role: "system",
+				Content: `You situate a chunk within its source document to improve search retrieval.
+Write ONE short sentence naming what the chunk is about in the context of the document
+(e.g. the company, product, period or topic it refers to). Return only the sentence.`,
+			},
+			{
+				Role:    "user",
+				Content: "<document>\n" + document + "\n</document>\n\n<chunk>\n" + chunk + "\n</chunk>",
+			},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// buildContextHeader renders the header lines that are present.
+func buildContextHeader(title string, sectionPath []string, situating string) string {
+	var lines []string
+	if title != "" {
+		lines = append(lines, "Document: "+title)
+	}
+	if len(sectionPath) > 0 {
+		lines = append(lines, "Section: "+strings.Join(sectionPath, " > "))
+	}
+	if situating != "" {
+		lines = append(lines, situating)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sectionTracker follows the Markdown heading hierarchy through a text in
+// a single pass, so the section path at every chunk costs one scan of the
+// document in all.
+type sectionTracker struct {
+	text    string
+	pos     int
+	inFence bool
+	path    []string
+	levels  []int
+}
+
+// at returns the heading hierarchy in effect at offset, e.g. ["Results",
+// "Q3 2024"]: that of the headings on lines starting before it. Offsets
+// must not decrease between calls.
+func (t *sectionTracker) at(offset int) []string {
+	for t.pos < len(t.text) && t.pos < offset {
+		line := t.text[t.pos:]
+		if end := strings.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end]
+		}
+		t.pos += len(line) + 1
+		t.heading(line)
+	}
+	return slices.Clone(t.path)
+}
+
+func (t *sectionTracker) heading(line string) {
+	trimmed := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmed, "```") {
+		t.inFence = !t.inFence
+		return
+	}
+	if t.inFence || !strings.HasPrefix(trimmed, "#") {
+		return
+	}
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	heading := strings.TrimSpace(trimmed[level:])
+	if level > 6 || heading == "" || !strings.HasPrefix(trimmed[level:], " ") {
+		return
+	}
+	for len(t.levels) > 0 && t.levels[len(t.levels)-1] >= level {
+		t.levels = t.levels[:len(t.levels)-1]
+		t.path = t.path[:len(t.path)-1]
+	}
+	t.levels = append(t.levels, level)
+	t.path = append(t.path, heading)
+}
+
+// sectionPaths returns the section path of each chunk: the chunker's, or
+// else the Markdown headings in effect where the chunk starts.
+func sectionPaths(content string, chunks []ChunkResult) [][]string {
+	sections := make([][]string, len(chunks))
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return chunks[order[a]].Start < chunks[order[b]].Start })
+	tracker := &sectionTracker{text: content}
+	for _, i := range order {
+		c := chunks[i]
+		sections[i] = c.SectionPath
+		if sections[i] == nil && c.End > c.Start {
+			sections[i] = tracker.at(c.Start)
+		}
+	}
+	return sections
+}
+
+// situateChunks writes the LLM situating sentence of the chunks at indexes,
+// a few at a time. The result is indexed like chunks; a chunk whose call
+// fails gets no sentence and keeps its structural header.
+func (p *pipeline) situateChunks(ctx context.Context, req IngestRequest, chunks []ChunkResult, indexes []int) []string {
+	situating := make([]string, len(chunks))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, situateConcurrency)
+	for _, i := range indexes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			s, err := p.contextualizer.Situate(ctx, req.Content, chunks[i].Content)
+			if err != nil {
+				slog.Warn("situating chunk failed, using structural header",
+					"document_id", req.DocumentID, "chunk_index", chunks[i].Index, "error", err)
+				return
+			}
+			situating[i] = s
+		}()
+	}
+	wg.Wait()
+	return situating
+}
+
+// stripContextHeaders restores the original chunk text for display. The
+// header stays available in metadata "context_header".
+func stripContextHeaders(results []vectorstore.SearchResult) {
+	for i, r := range results {
+		header, _ := r.Metadata["context_header"].(string)
+		if header == "" {
+			continue
+		}
+		results[i].Content = strings.TrimPrefix(r.Content, header+contextHeaderSeparator)
+	}
+}
+
+// Ensure LLMChunkContextualizer implements ChunkContextualizer.
+var _ ChunkContextualizer = (*LLMChunkContextualizer)(nil)
@@ -0,0 +1,181 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/memory"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+)
+
+// ConversationStore persists RAG conversation turns so follow-up questions
+// can be answered without the client resending prior messages.
+type ConversationStore interface {
+	Create(ctx context.Context, title string) (*models.Conversation, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.Conversation, error)
+	// History returns up to limit of the most recent turns in chronological order.
+	History(ctx context.Context, id uuid.UUID, limit int) ([]memory.Entry, error)
+	Append(ctx context.Context, id uuid.UUID, entries ...memory.Entry) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// ErrConversationNotFound is returned for conversations that don't exist or
+// belong to another user.
+var ErrConversationNotFound = errors.New("conversation not found")
+
+// PgConversationStore stores conversations in Postgres, scoped to the tenant
+// and user in the request context. Callers without a user (API keys not tied
+// to one) share the tenant's userless conversations.
+type PgConversationStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPgConversationStore(db *pgxpool.Pool) *PgConversationStore {
+	return &PgConversationStore{db: db}
+}
+
+// conversationUser returns the ID of the user in the context, or nil.
+func conversationUser(ctx context.Context) *uuid.UUID {
+	if user := tenant.UserFromContext(ctx); user != nil {
+		return &user.ID
+	}
+	return nil
+}
+
+func (s *PgConversationStore) Create(ctx context.Context, title string) (*models.Conversation, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	userID := conversationUser(ctx)
+
+	var c models.Conversation
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO rag_conversations (tenant_id, user_id, title)
+		 VALUES ($1, $2, $3)
+		 RETURNING id, tenant_id, user_id, COALESCE(title, ''), created_at, updated_at`,
+		tenantID, userID, title,
+	).Scan(&c.ID, &c.TenantID, &c.UserID, &c.Title, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create conversation: %w", err)
+	}
+	return &c, nil
+}
+
+func (s *PgConversationStore) Get(ctx context.Context, id uuid.UUID) (*models.Conversation, error) {
+	tenantID := tenant.IDFromContext(ctx)
+
+	var c models.Conversation
+	err := s.db.QueryRow(ctx,
+		`SELECT id, tenant_id, user_id, COALESCE(title, ''), created_at, updated_at
+		 FROM rag_conversations WHERE id = $1 AND tenant_id = $2 AND user_id IS NOT DISTINCT FROM $3`,
+		id, tenantID, conversationUser(ctx),
+	).Scan(&c.ID, &c.TenantID, &c.UserID, &c.Title, &c.CreatedAt, &c.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrConversationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get conversation: %w", err)
+	}
+	return &c, nil
+}
+
+func (s *PgConversationStore) History(ctx context.Context, id uuid.UUID, limit int) ([]memory.Entry, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT role, content, metadata, created_at FROM (
+			SELECT m.role, m.content, m.metadata, m.created_at
+			FROM rag_conversation_messages m
+			JOIN rag_conversations c ON c.id = m.conversation_id
+			WHERE m.conversation_id = $1 AND m.tenant_id = $2 AND c.user_id IS NOT DISTINCT FROM $3
+			ORDER BY m.created_at DESC
+			LIMIT $4
+		 ) recent ORDER BY created_at ASC`,
+		id, tenantID, conversationUser(ctx), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("load conversation history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []memory.Entry
+	for rows.Next() {
+		var e memory.Entry
+		var meta json.RawMessage
+		if err := rows.Scan(&e.Role, &e.Content, &meta, &e.Timestamp); err != nil {
+			return nil, fmt.Errorf("scan conversation message: %w", err)
+		}
+		if len(meta) > 0 {
+			_ = json.Unmarshal(meta, &e.Metadata)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *PgConversationStore) Append(ctx context.Context, id uuid.UUID, entries ...memory.Entry) error {
+	tenantID := tenant.IDFromContext(ctx)
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx,
+		`UPDATE rag_conversations SET updated_at = now()
+		 WHERE id = $1 AND tenant_id = $2 AND user_id IS NOT DISTINCT FROM $3`,
+		id, tenantID, conversationUser(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("touch conversation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConversationNotFound
+	}
+
+	for _, e := range entries {
+		// Timestamps are set explicitly: now() is fixed for the whole
+		// transaction and would make the turn order ambiguous.
+		if e.Timestamp.IsZero() {
+			e.Timestamp = time.Now()
+		}
+		meta, _ := json.Marshal(e.Metadata)
+		_, err := tx.Exec(ctx,
+			`INSERT INTO rag_conversation_messages (conversation_id, tenant_id, role, content, metadata, created_at)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			id, tenantID, e.Role, e.Content, meta, e.Timestamp,
+		)
+		if err != nil {
+			return fmt.Errorf("append %s message: %w", e.Role, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *PgConversationStore) Delete(ctx context.Context, id uuid.UUID) error {
+	tenantID := tenant.IDFromContext(ctx)
+	tag, err := s.db.Exec(ctx,
+		"DELETE FROM rag_conversations WHERE id = $1 AND tenant_id = $2 AND user_id IS NOT DISTINCT FROM $3",
+		id, tenantID, conversationUser(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("delete conversation: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrConversationNotFound
+	}
+	return nil
+}
+
+// Ensure PgConversationStore implements ConversationStore.
+var _ ConversationStore = (*PgConversationStore)(nil)
@@ -0,0 +1,218 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// NotAnswerableMessage is returned as the answer when corrective retrieval
+// finds no relevant support in the knowledge base.
+const NotAnswerableMessage = "This question is not answerable from the knowledge base."
+
+// Corrective retrieval actions, in the order they are tried.
+const (
+	CorrectiveActionInitial = "initial"
+	CorrectiveActionRewrite = "rewrite"
+	CorrectiveActionHyDE    = "hyde"
+)
+
+// CorrectiveOptions tunes the corrective retrieval loop.
+type CorrectiveOptions struct {
+	// RelevanceThreshold is the minimum grade (0–1) for a chunk to count as relevant.
+	RelevanceThreshold float64
+	// MinRelevant is how many relevant chunks a query needs to be answered.
+	// Retrieval is retried while fewer are found.
+	MinRelevant int
+}
+
+func defaultCorrectiveOptions() CorrectiveOptions {
+	return CorrectiveOptions{RelevanceThreshold: 0.5, MinRelevant: 2}
+}
+
+// CorrectiveIteration records one retrieval attempt of the corrective loop.
+type CorrectiveIteration struct {
+	Attempt   int    `json:"attempt"`
+	Action    string `json:"action"`
+	Query     string `json:"query,omitempty"`
+	Retrieved int    `json:"retrieved"`
+	Relevant  int    `json:"relevant"`
+	// Ungraded counts the chunks the grader gave no grade, which are not
+	// counted as relevant.
+	Ungraded int `json:"ungraded,omitempty"`
+}
+
+// CorrectiveInfo is reported in RouteInfo when corrective retrieval ran.
+type CorrectiveInfo struct {
+	Iterations []CorrectiveIteration `json:"iterations"`
+	Answerable bool                  `json:"answerable"`
+}
+
+// RelevanceGrader grades how relevant retrieved chunks are to a query.
+// Unlike a Reranker, it never falls back to retrieval scores, which aren't
+// on the grade's scale: the map holds a grade (0–1) per graded result
+// index, and results it could not grade are left out.
+type RelevanceGrader interface {
+	Grade(ctx context.Context, query string, results []vectorstore.SearchResult) (map[int]float64, error)
+}
+
+// LLMRelevanceGrader grades chunks with an LLM, all in one call.
+type LLMRelevanceGrader struct {
+	gateway llm.Gateway
+	model   string
+}
+
+func NewLLMRelevanceGrader(gw llm.Gateway, model string) *LLMRelevanceGrader {
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &LLMRelevanceGrader{gateway: gw, model: model}
+}
+
+func (g *LLMRelevanceGrader) Grade(ctx context.Context, query string, results []vectorstore.SearchResult) (map[int]float64, error) {
+	if len(results) == 0 {
+		return map[int]float64{}, nil
+	}
+
+	var sb strings.Builder
+	for i, res := range results {
+		fmt.Fprintf(&sb, "[%d] %s\n\n", i, truncate(res.Content, 500))
+	}
+
+	resp, err := g.gateway.Chat(ctx, llm.ChatRequest{
+		Model: g.model,
+		Messages: []llm.Message{
+			{
+				Role: "system",
+				Content: `You grade whether text chunks help answer a query. Grade every chunk from 0.0
+(irrelevant) to 1.0 (directly answers the query).
+Return ONLY a JSON array of objects with "index" and "score" fields, one per chunk. Example:
+[{"index": 0, "score": 0.9}, {"index": 1, "score": 0.1}]`,
+			},
+			{
+				Role:    "user",
+				Content: fmt.Sprintf("Query: %s\n\nChunks:\n%s", query, sb.String()),
+			},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("grade relevance: %w", err)
+	}
+
+	content := strings.TrimSpace(resp.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+
+	var scores []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &scores); err != nil {
+		return nil, fmt.Errorf("parse relevance grades: %w", err)
+	}
+
+	grades := make(map[int]float64, len(scores))
+	for _, s := range scores {
+		if s.Index < 0 || s.Index >= len(results) || s.Score < 0 || s.Score > 1 {
+			continue
+		}
+		grades[s.Index] = s.Score
+	}
+	return grades, nil
+}
+
+// correctiveRetrieve grades the initial results for relevance and, while
+// coverage is below MinRelevant, retries with multi-query rewriting and then
+// HyDE. Relevant chunks from every attempt are kept, best grade first.
+// The query is answerable once MinRelevant chunks are found. A grader
+// failure is returned rather than taken to mean nothing is relevant.
+func (p *pipeline) correctiveRetrieve(ctx context.Context, query string, opts RetrieveOptions, initial []vectorstore.SearchResult) ([]vectorstore.SearchResult, *CorrectiveInfo, error) {
+	info := &CorrectiveInfo{}
+	relevant := make(map[uuid.UUID]vectorstore.SearchResult)
+
+	grade := func(action, q string, results []vectorstore.SearchResult) error {
+		it := CorrectiveIteration{
+			Attempt:   len(info.Iterations) + 1,
+			Action:    action,
+			Query:     q,
+			Retrieved: len(results),
+		}
+		if len(results) > 0 {
+			grades, err := p.grader.Grade(ctx, query, results)
+			if err != nil {
+				return fmt.Errorf("grade %s results: %w", action, err)
+			}
+			for i, r := range results {
+				score, ok := grades[i]
+				if !ok {
+					it.Ungraded++
+					continue
+				}
+				if score < p.corrective.RelevanceThreshold {
+					continue
+				}
+				it.Relevant++
+				r.Score = score
+				if prev, ok := relevant[r.ChunkID]; !ok || r.Score > prev.Score {
+					relevant[r.ChunkID] = r
+				}
+			}
+		}
+		info.Iterations = append(info.Iterations, it)
+		return nil
+	}
+
+	minRelevant := max(p.corrective.MinRelevant, 1)
+
+	if err := grade(CorrectiveActionInitial, query, initial); err != nil {
+		return nil, nil, err
+	}
+
+	if len(relevant) < minRelevant {
+		queries, err := p.queryRewriter.Rewrite(ctx, query)
+		if err == nil && len(queries) > 1 {
+			results, err := p.multiQueryRetrieve(ctx, queries, opts)
+			if err != nil {
+				return nil, nil, fmt.Errorf("corrective rewrite: %w", err)
+			}
+			if err := grade(CorrectiveActionRewrite, queries[1], results); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if len(relevant) < minRelevant {
+		hypoDoc, err := p.hyde.GenerateHypothetical(ctx, query)
+		if err == nil && hypoDoc != "" {
+			results, err := p.retriever.Retrieve(ctx, hypoDoc, opts)
+			if err != nil {
+				return nil, nil, fmt.Errorf("corrective hyde: %w", err)
+			}
+			if err := grade(CorrectiveActionHyDE, "", results); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	merged := make([]vectorstore.SearchResult, 0, len(relevant))
+	for _, r := range relevant {
+		merged = append(merged, r)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	if opts.TopK > 0 && len(merged) > opts.TopK {
+		merged = merged[:opts.TopK]
+	}
+
+	info.Answerable = len(relevant) >= minRelevant
+	return merged, info, nil
+}
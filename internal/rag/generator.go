@@ -5,16 +5,27 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/nikhilbhutani/backendwithai/internal/eval"
 	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/memory"
 	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
 )
 
+// defaultContextTokens is the prompt budget used to fit conversation history.
+const defaultContextTokens = 8192
+
 type Generator struct {
-	gateway llm.Gateway
+	gateway        llm.Gateway
+	contextEngine  *memory.ContextEngine
+	claimExtractor *eval.ClaimExtractor
 }
 
 func NewGenerator(gw llm.Gateway) *Generator {
-	return &Generator{gateway: gw}
+	return &Generator{
+		gateway:        gw,
+		contextEngine:  memory.NewContextEngine(defaultContextTokens),
+		claimExtractor: eval.NewClaimExtractor(gw, ""),
+	}
 }
 
 type GenerateRequest struct {
@@ -22,19 +33,36 @@ type GenerateRequest struct {
 	Context  []vectorstore.SearchResult
 	Model    string
 	Provider string
+	// History holds prior conversation turns; only the most recent turns that
+	// fit the context engine's memory budget are sent to the model.
+	History []memory.Entry
+	// VerifyClaims runs claim extraction over the answer and flags
+	// sentences whose claims are not supported by the retrieved context.
+	VerifyClaims bool
 }
 
 type GenerateResponse struct {
-	Answer    string     `json:"answer"`
-	Citations []Citation `json:"citations"`
+	Answer    string                `json:"answer"`
+	Citations []Citation            `json:"citations"`
+	Sentences []SentenceAttribution `json:"sentences"`
 	Usage     llm.ChatResponse
 }
 
+// Citation is a retrieved chunk the answer actually cites. Sources that are
+// retrieved but never referenced by a [Source N] marker are dropped.
 type Citation struct {
-	DocumentID string `json:"document_id"`
-	ChunkID    string `json:"chunk_id"`
-	Content    string `json:"content"`
+	DocumentID string  `json:"document_id"`
+	ChunkID    string  `json:"chunk_id"`
+	Content    string  `json:"content"`
 	Score      float64 `json:"score"`
+	// SourceIndex is the N used in the answer's [Source N] markers.
+	SourceIndex int `json:"source_index"`
+	// Spans locate the citing sentences in the answer.
+	Spans []AnswerSpan `json:"spans"`
+	// DocumentStart and DocumentEnd locate the chunk in the original
+	// document text, when offsets were recorded at ingest.
+	DocumentStart *int `json:"document_start,omitempty"`
+	DocumentEnd   *int `json:"document_end,omitempty"`
 }
 
 func (g *Generator) Generate(ctx context.Context, req GenerateRequest) (*GenerateResponse, error) {
@@ -47,12 +75,18 @@ func (g *Generator) Generate(ctx context.Context, req GenerateRequest) (*Generat
 If the context doesn't contain enough information, say so. Always cite which sources you used.
 Format citations as [Source N] where N corresponds to the context chunk number.`,
 		},
-		{
-			Role:    "user",
-			Content: fmt.Sprintf("Context:\n%s\n\nQuestion: %s", contextStr, req.Query),
-		},
 	}
 
+	history, _ := g.contextEngine.FitHistory(req.History)
+	for _, h := range history {
+		messages = append(messages, llm.Message{Role: h.Role, Content: h.Content})
+	}
+
+	messages = append(messages, llm.Message{
+		Role:    "user",
+		Content: fmt.Sprintf("Context:\n%s\n\nQuestion: %s", contextStr, req.Query),
+	})
+
 	chatReq := llm.ChatRequest{
 		Provider: req.Provider,
 		Model:    req.Model,
@@ -64,27 +98,82 @@ Format citations as [Source N] where N corresponds to the context chunk number.`
 		return nil, fmt.Errorf("generate answer: %w", err)
 	}
 
-	citations := make([]Citation, len(req.Context))
-	for i, c := range req.Context {
-		citations[i] = Citation{
-			DocumentID: c.DocumentID.String(),
-			ChunkID:    c.ChunkID.String(),
-			Content:    truncate(c.Content, 200),
-			Score:      c.Score,
+	sentences := attributeSentences(resp.Content, len(req.Context))
+
+	if req.VerifyClaims && len(req.Context) > 0 {
+		contextTexts := make([]string, len(req.Context))
+		for i, c := range req.Context {
+			contextTexts[i] = c.Content
+		}
+		// Verification is best-effort: a failure leaves sentences unflagged.
+		if claims, err := g.claimExtractor.ExtractAndVerify(ctx, resp.Content, contextTexts); err == nil {
+			applyClaimVerification(sentences, claims)
 		}
 	}
 
 	return &GenerateResponse{
 		Answer:    resp.Content,
-		Citations: citations,
+		Citations: buildCitations(req.Context, sentences),
+		Sentences: sentences,
 		Usage:     *resp,
 	}, nil
 }
 
+// buildCitations returns one Citation per cited source, in source order,
+// with the answer spans that cite it.
+func buildCitations(results []vectorstore.SearchResult, sentences []SentenceAttribution) []Citation {
+	spans := make(map[int][]AnswerSpan)
+	for _, s := range sentences {
+		for _, n := range s.Sources {
+			spans[n] = append(spans[n], AnswerSpan{Start: s.Start, End: s.End})
+		}
+	}
+
+	citations := make([]Citation, 0, len(spans))
+	for i, c := range results {
+		n := i + 1
+		if len(spans[n]) == 0 {
+			continue
+		}
+		citation := Citation{
+			DocumentID:  c.DocumentID.String(),
+			ChunkID:     c.ChunkID.String(),
+			Content:     truncate(c.Content, 200),
+			Score:       c.Score,
+			SourceIndex: n,
+			Spans:       spans[n],
+		}
+		if start, ok := metaInt(c.Metadata, "start_offset"); ok {
+			citation.DocumentStart = &start
+		}
+		if end, ok := metaInt(c.Metadata, "end_offset"); ok {
+			citation.DocumentEnd = &end
+		}
+		citations = append(citations, citation)
+	}
+	return citations
+}
+
+// metaInt reads an integer from chunk metadata. Values decoded from JSONB
+// arrive as float64.
+func metaInt(meta map[string]interface{}, key string) (int, bool) {
+	switch v := meta[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
 func buildContext(results []vectorstore.SearchResult) string {
 	var sb strings.Builder
 	for i, r := range results {
-		fmt.Fprintf(&sb, "[Source %d] (score: %.3f)\n%s\n\n", i+1, r.Score, r.Content)
+		fmt.Fprintf(&sb, "[Source %d] (score: %.3f)\n", i+1, r.Score)
+		if header, _ := r.Metadata["context_header"].(string); header != "" {
+			fmt.Fprintf(&sb, "%s\n---\n", header)
+		}
+		fmt.Fprintf(&sb, "%s\n\n", r.Content)
 	}
 	return sb.String()
 }
@@ -0,0 +1,148 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/embedding"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// GraphRetriever answers multi-hop questions by matching query entities in
+// the knowledge graph, expanding to their neighbours and returning the
+// chunks that mention them, plus relevant community summaries.
+type GraphRetriever struct {
+	graph    vectorstore.GraphStore
+	embedSvc *embedding.Service
+	// Hops is how far to expand from matched entities (default 1).
+	Hops int
+	// SeedEntities is how many entities to match by similarity (default 5).
+	SeedEntities int
+	// Communities is how many community summaries to include (default 2).
+	Communities int
+}
+
+func NewGraphRetriever(graph vectorstore.GraphStore, embedSvc *embedding.Service) *GraphRetriever {
+	return &GraphRetriever{
+		graph:        graph,
+		embedSvc:     embedSvc,
+		Hops:         1,
+		SeedEntities: 5,
+		Communities:  2,
+	}
+}
+
+// Retrieve returns graph-derived results best first. Relation facts and
+// community summaries are synthetic results tagged with metadata chunk_type
+// "graph_relations" and "community"; they have no document ID.
+func (g *GraphRetriever) Retrieve(ctx context.Context, query string, opts RetrieveOptions) ([]vectorstore.SearchResult, error) {
+	queryVec, err := g.embedSvc.EmbedSingle(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	seeds, err := g.graph.SearchEntities(ctx, queryVec, vectorstore.SearchOptions{
+		TenantID: opts.TenantID,
+		TopK:     g.SeedEntities,
+		MinScore: opts.MinScore,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("search entities: %w", err)
+	}
+
+	var results []vectorstore.SearchResult
+
+	if len(seeds) > 0 {
+		seedIDs := make([]uuid.UUID, len(seeds))
+		for i, e := range seeds {
+			seedIDs[i] = e.ID
+		}
+
+		neighbors, relations, err := g.graph.Neighbors(ctx, opts.TenantID, seedIDs, g.Hops, opts.TopK*4)
+		if err != nil {
+			return nil, fmt.Errorf("expand entities: %w", err)
+		}
+
+		entityIDs := append([]uuid.UUID{}, seedIDs...)
+		for _, e := range neighbors {
+			entityIDs = append(entityIDs, e.ID)
+		}
+
+		chunks, err := g.graph.ChunksForEntities(ctx, entityIDs, vectorstore.SearchOptions{
+			TenantID:          opts.TenantID,
+			TopK:              opts.TopK,
+			CollectionIDs:     opts.CollectionIDs,
+			Principals:        opts.Principals,
+			IncludeSuperseded: opts.IncludeSuperseded,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("entity chunks: %w", err)
+		}
+		stripContextHeaders(chunks)
+		results = append(results, chunks...)
+
+		if facts := describeRelations(append(seeds, neighbors...), relations); facts != "" {
+			results = append(results, vectorstore.SearchResult{
+				ChunkID:  uuid.New(),
+				Content:  facts,
+				Score:    seeds[0].Score,
+				Metadata: map[string]interface{}{"chunk_type": "graph_relations"},
+			})
+		}
+	}
+
+	if g.Communities > 0 {
+		communities, err := g.graph.SearchCommunities(ctx, queryVec, vectorstore.SearchOptions{
+			TenantID: opts.TenantID,
+			TopK:     g.Communities,
+			MinScore: opts.MinScore,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("search communities: %w", err)
+		}
+		for _, c := range communities {
+			results = append(results, vectorstore.SearchResult{
+				ChunkID: c.ID,
+				Content: c.Summary,
+				Score:   c.Score,
+				Metadata: map[string]interface{}{
+					"chunk_type":   "community",
+					"community_id": c.ID.String(),
+				},
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// describeRelations renders traversed edges as "A —relation→ B" lines so the
+// generator can follow multi-hop paths explicitly.
+func describeRelations(entities []vectorstore.Entity, relations []vectorstore.Relation) string {
+	names := make(map[uuid.UUID]string, len(entities))
+	for _, e := range entities {
+		names[e.ID] = e.Name
+	}
+
+	seen := make(map[string]bool)
+	var lines []string
+	for _, r := range relations {
+		src, ok1 := names[r.SourceID]
+		dst, ok2 := names[r.TargetID]
+		if !ok1 || !ok2 {
+			continue
+		}
+		line := fmt.Sprintf("%s —%s→ %s", src, r.Type, dst)
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Known relationships:\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,395 @@
+package indexing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/embedding"
+	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// GraphIndexer implements GraphRAG indexing:
+//   - Stores chunks as usual so they remain searchable by vector similarity.
+//   - Extracts entities and relations from each chunk with the LLM.
+//   - Merges entities per tenant and links them back to their source chunks.
+//   - Groups entities into communities and stores an LLM summary of each.
+//     Summarising a tenant's communities takes up to maxCommunities LLM
+//     calls, so with a scheduler it runs as a separate job that coalesces
+//     the rebuilds requested by documents indexed close together.
+type GraphIndexer struct {
+	store    vectorstore.VectorStore
+	graph    vectorstore.GraphStore
+	embedSvc *embedding.Service
+	gateway  llm.Gateway
+	model    string
+	// maxCommunities caps how many communities are summarised per rebuild (default 50).
+	maxCommunities int
+	// minCommunitySize skips communities too small to be worth summarising (default 3).
+	minCommunitySize int
+	scheduler        CommunityScheduler
+}
+
+// CommunityScheduler queues a rebuild of a tenant's community summaries.
+// Requests made while one is already queued are expected to be merged
+// into it.
+type CommunityScheduler interface {
+	ScheduleCommunityBuild(ctx context.Context, tenantID uuid.UUID) error
+}
+
+func NewGraphIndexer(store vectorstore.VectorStore, graph vectorstore.GraphStore, embedSvc *embedding.Service, gw llm.Gateway, model string) *GraphIndexer {
+	if model == "" {
+		model = "gpt-4o-mini"
+	}
+	return &GraphIndexer{
+		store:            store,
+		graph:            graph,
+		embedSvc:         embedSvc,
+		gateway:          gw,
+		model:            model,
+		maxCommunities:   50,
+		minCommunitySize: 3,
+	}
+}
+
+type extractedGraph struct {
+	Entities []struct {
+		Name        string `json:"name"`
+		Type        string `json:"type"`
+		Description string `json:"description"`
+	} `json:"entities"`
+	Relations []struct {
+		Source      string  `json:"source"`
+		Target      string  `json:"target"`
+		Relation    string  `json:"relation"`
+		Description string  `json:"description"`
+		Weight      float64 `json:"weight"`
+	} `json:"relations"`
+}
+
+// WithScheduler returns a copy of the indexer that queues community
+// rebuilds with s instead of running them during Index.
+func (g *GraphIndexer) WithScheduler(s CommunityScheduler) *GraphIndexer {
+	c := *g
+	c.scheduler = s
+	return &c
+}
+
+// Index stores the chunks, extracts the knowledge graph from them and
+// rebuilds the tenant's community summaries, or queues the rebuild when
+// the indexer has a scheduler. Chunks are tagged with chunk_type = "graph"
+// in metadata.
+func (g *GraphIndexer) Index(ctx context.Context, chunks []vectorstore.Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	for i := range chunks {
+		chunks[i].ID = orNewUUID(chunks[i].ID)
+		meta := copyMeta(chunks[i].Metadata)
+		meta["chunk_type"] = "graph"
+		chunks[i].Metadata = meta
+	}
+
+	if err := g.store.Upsert(ctx, chunks); err != nil {
+		return fmt.Errorf("graph: store chunks: %w", err)
+	}
+
+	for _, c := range chunks {
+		extracted, err := g.extract(ctx, c.Content)
+		if err != nil || len(extracted.Entities) == 0 {
+			// A chunk without extractable entities is still searchable.
+			continue
+		}
+		if err := g.indexChunkGraph(ctx, c, extracted); err != nil {
+			return fmt.Errorf("graph: index chunk %d: %w", c.ChunkIndex, err)
+		}
+	}
+
+	if g.scheduler != nil {
+		if err := g.scheduler.ScheduleCommunityBuild(ctx, chunks[0].TenantID); err != nil {
+			return fmt.Errorf("graph: schedule community build: %w", err)
+		}
+		return nil
+	}
+	if err := g.BuildCommunities(ctx, chunks[0].TenantID); err != nil {
+		return fmt.Errorf("graph: build communities: %w", err)
+	}
+	return nil
+}
+
+// extract asks the LLM for the entities and relations in a passage.
+func (g *GraphIndexer) extract(ctx context.Context, content string) (*extractedGraph, error) {
+	resp, err := g.gateway.Chat(ctx, llm.ChatRequest{
+		Model: g.model,
+		Messages: []llm.Message{
+			{
+				Role: "system",
+				Content: `Extract a knowledge graph from the passage.
+Entities are specific people, organisations, teams, products, systems, places, or concepts.
+Relations connect two extracted entities with a short verb phrase (e.g. "owns", "supplies", "depends on").
+Respond with ONLY a JSON object:
+{"entities": [{"name": "...", "type": "person|organization|team|product|system|location|concept", "description": "one sentence"}],
+ "relations": [{"source": "entity name", "target": "entity name", "relation": "...", "description": "one sentence", "weight": 1-10}]}`,
+			},
+			{Role: "user", Content: content},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	raw := strings.TrimSpace(resp.Content)
+	raw = strings.TrimPrefix(raw, "```json")
+	raw = strings.TrimPrefix(raw, "```")
+	raw = strings.TrimSuffix(raw, "```")
+
+	var out extractedGraph
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &out); err != nil {
+		return nil, fmt.Errorf("parse extraction: %w", err)
+	}
+	return &out, nil
+}
+
+// indexChunkGraph embeds and upserts the chunk's entities, then records
+// mentions and relations against the merged entity IDs.
+func (g *GraphIndexer) indexChunkGraph(ctx context.Context, c vectorstore.Chunk, extracted *extractedGraph) error {
+	var entities []vectorstore.Entity
+	seen := make(map[string]bool)
+	for _, e := range extracted.Entities {
+		name := strings.TrimSpace(e.Name)
+		key := vectorstore.NormalizeEntityName(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		entities = append(entities, vectorstore.Entity{
+			TenantID:    c.TenantID,
+			Name:        name,
+			Type:        strings.ToLower(strings.TrimSpace(e.Type)),
+			Description: strings.TrimSpace(e.Description),
+		})
+	}
+
+	texts := make([]string, len(entities))
+	for i, e := range entities {
+		texts[i] = e.Name + ": " + e.Description
+	}
+	embeddings, err := g.embedSvc.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("embed entities: %w", err)
+	}
+	for i := range entities {
+		entities[i].Embedding = embeddings[i]
+	}
+
+	stored, err := g.graph.UpsertEntities(ctx, entities)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]uuid.UUID, len(stored))
+	mentions := make([]vectorstore.EntityMention, len(stored))
+	for i, e := range stored {
+		ids[vectorstore.NormalizeEntityName(e.Name)] = e.ID
+		mentions[i] = vectorstore.EntityMention{
+			EntityID:   e.ID,
+			ChunkID:    c.ID,
+			DocumentID: c.DocumentID,
+			TenantID:   c.TenantID,
+		}
+	}
+	if err := g.graph.AddMentions(ctx, mentions); err != nil {
+		return err
+	}
+
+	var relations []vectorstore.Relation
+	for _, r := range extracted.Relations {
+		src, ok1 := ids[vectorstore.NormalizeEntityName(r.Source)]
+		dst, ok2 := ids[vectorstore.NormalizeEntityName(r.Target)]
+		if !ok1 || !ok2 || src == dst {
+			continue
+		}
+		relations = append(relations, vectorstore.Relation{
+			TenantID:    c.TenantID,
+			SourceID:    src,
+			TargetID:    dst,
+			Type:        strings.TrimSpace(r.Relation),
+			Description: strings.TrimSpace(r.Description),
+			Weight:      r.Weight,
+			ChunkID:     c.ID,
+			DocumentID:  c.DocumentID,
+		})
+	}
+	if len(relations) == 0 {
+		return nil
+	}
+	return g.graph.AddRelations(ctx, relations)
+}
+
+// BuildCommunities detects communities over the tenant's whole graph with
+// label propagation and replaces the stored community summaries.
+func (g *GraphIndexer) BuildCommunities(ctx context.Context, tenantID uuid.UUID) error {
+	relations, err := g.graph.ListRelations(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	groups := detectCommunities(relations)
+	var selected [][]uuid.UUID
+	for _, members := range groups {
+		if len(members) >= g.minCommunitySize {
+			selected = append(selected, members)
+		}
+	}
+	if len(selected) > g.maxCommunities {
+		selected = selected[:g.maxCommunities]
+	}
+
+	communities := make([]vectorstore.Community, 0, len(selected))
+	for _, members := range selected {
+		summary, err := g.summariseCommunity(ctx, tenantID, members, relations)
+		if err != nil || summary == "" {
+			continue
+		}
+		communities = append(communities, vectorstore.Community{
+			TenantID:  tenantID,
+			Summary:   summary,
+			EntityIDs: members,
+		})
+	}
+
+	if len(communities) > 0 {
+		texts := make([]string, len(communities))
+		for i, c := range communities {
+			texts[i] = c.Summary
+		}
+		embeddings, err := g.embedSvc.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embed communities: %w", err)
+		}
+		for i := range communities {
+			communities[i].Embedding = embeddings[i]
+		}
+	}
+
+	return g.graph.ReplaceCommunities(ctx, tenantID, communities)
+}
+
+// summariseCommunity describes a community's entities and the relations
+// among them, and asks the LLM for a short report.
+func (g *GraphIndexer) summariseCommunity(ctx context.Context, tenantID uuid.UUID, members []uuid.UUID, relations []vectorstore.Relation) (string, error) {
+	entities, err := g.graph.GetEntities(ctx, tenantID, members)
+	if err != nil {
+		return "", err
+	}
+
+	names := make(map[uuid.UUID]string, len(entities))
+	var sb strings.Builder
+	sb.WriteString("Entities:\n")
+	for _, e := range entities {
+		names[e.ID] = e.Name
+		fmt.Fprintf(&sb, "- %s (%s): %s\n", e.Name, e.Type, e.Description)
+	}
+	sb.WriteString("\nRelations:\n")
+	for _, r := range relations {
+		src, ok1 := names[r.SourceID]
+		dst, ok2 := names[r.TargetID]
+		if ok1 && ok2 {
+			fmt.Fprintf(&sb, "- %s %s %s\n", src, r.Type, dst)
+		}
+	}
+
+	resp, err := g.gateway.Chat(ctx, llm.ChatRequest{
+		Model: g.model,
+		Messages: []llm.Message{
+			{
+				Role: "system",
+				Content: "Write a concise summary (3-5 sentences) of the group of related entities below. " +
+					"Explain who or what they are and how they are connected. Use the entity names.",
+			},
+			{Role: "user", Content: sb.String()},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// detectCommunities runs weighted label propagation over the relation graph
+// and returns the communities largest first.
+func detectCommunities(relations []vectorstore.Relation) [][]uuid.UUID {
+	adj := make(map[uuid.UUID]map[uuid.UUID]float64)
+	addEdge := func(a, b uuid.UUID, w float64) {
+		if adj[a] == nil {
+			adj[a] = make(map[uuid.UUID]float64)
+		}
+		adj[a][b] += w
+	}
+	for _, r := range relations {
+		w := r.Weight
+		if w <= 0 {
+			w = 1
+		}
+		addEdge(r.SourceID, r.TargetID, w)
+		addEdge(r.TargetID, r.SourceID, w)
+	}
+
+	// Deterministic node order keeps community assignment stable across rebuilds.
+	nodes := make([]uuid.UUID, 0, len(adj))
+	for n := range adj {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].String() < nodes[j].String() })
+
+	labels := make(map[uuid.UUID]uuid.UUID, len(nodes))
+	for _, n := range nodes {
+		labels[n] = n
+	}
+
+	for iter := 0; iter < 20; iter++ {
+		changed := false
+		for _, n := range nodes {
+			votes := make(map[uuid.UUID]float64)
+			for nb, w := range adj[n] {
+				votes[labels[nb]] += w
+			}
+			best, bestVote := labels[n], votes[labels[n]]
+			for label, v := range votes {
+				if v > bestVote || (v == bestVote && label.String() < best.String()) {
+					best, bestVote = label, v
+				}
+			}
+			if best != labels[n] {
+				labels[n] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	byLabel := make(map[uuid.UUID][]uuid.UUID)
+	for _, n := range nodes {
+		byLabel[labels[n]] = append(byLabel[labels[n]], n)
+	}
+	groups := make([][]uuid.UUID, 0, len(byLabel))
+	for _, members := range byLabel {
+		groups = append(groups, members)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i]) != len(groups[j]) {
+			return len(groups[i]) > len(groups[j])
+		}
+		return groups[i][0].String() < groups[j][0].String()
+	})
+	return groups
+}
@@ -59,14 +59,19 @@ func (m *MultiRepIndexer) Index(ctx context.Context, chunks []vectorstore.Chunk)
 		meta["summary"] = summaries[i]
 
 		indexed[i] = vectorstore.Chunk{
-			ID:         orNewUUID(c.ID),
-			DocumentID: c.DocumentID,
-			TenantID:   c.TenantID,
-			ChunkIndex: c.ChunkIndex,
-			Content:    c.Content,   // full original content
-			Embedding:  embeddings[i], // summary embedding
-			TokenCount: c.TokenCount,
-			Metadata:   meta,
+			ID:           orNewUUID(c.ID),
+			DocumentID:   c.DocumentID,
+			TenantID:     c.TenantID,
+			ChunkIndex:   c.ChunkIndex,
+			Content:      c.Content,     // full original content
+			Embedding:    embeddings[i], // summary embedding
+			TokenCount:   c.TokenCount,
+			Metadata:     meta,
+			CollectionID: c.CollectionID,
+			Access:       c.Access,
+			// No content hash: the embedding is the summary's, which must
+			// not be reused for the chunk's text.
+			Superseded: c.Superseded,
 		}
 	}
 
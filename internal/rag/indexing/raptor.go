@@ -104,11 +104,17 @@ func (r *RaptorIndexer) Index(ctx context.Context, chunks []vectorstore.Chunk) e
 
 // summariseClusters generates one summary chunk per cluster.
 func (r *RaptorIndexer) summariseClusters(ctx context.Context, clusters [][]vectorstore.Chunk, level int) ([]vectorstore.Chunk, error) {
-	// Use the first chunk's document/tenant IDs as defaults.
-	var docID, tenantID uuid.UUID
+	// Use the first chunk's document/tenant/collection IDs, access list and
+	// version state as defaults.
+	var docID, tenantID, collectionID uuid.UUID
+	var access []uuid.UUID
+	var superseded bool
 	if len(clusters) > 0 && len(clusters[0]) > 0 {
 		docID = clusters[0][0].DocumentID
 		tenantID = clusters[0][0].TenantID
+		collectionID = clusters[0][0].CollectionID
+		access = clusters[0][0].Access
+		superseded = clusters[0][0].Superseded
 	}
 
 	result := make([]vectorstore.Chunk, 0, len(clusters))
@@ -152,6 +158,11 @@ func (r *RaptorIndexer) summariseClusters(ctx context.Context, clusters [][]vect
 			ChunkIndex: ci,
 			Content:    strings.TrimSpace(resp.Content),
 			Metadata:   meta,
+			// Summaries are searched within the same collection as their leaves.
+			CollectionID: collectionID,
+			// Summaries carry their leaves' content, so their ACL too.
+			Access:     access,
+			Superseded: superseded,
 		})
 	}
 
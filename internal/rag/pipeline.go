@@ -2,16 +2,28 @@ package rag
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/nikhilbhutani/backendwithai/internal/embedding"
 	"github.com/nikhilbhutani/backendwithai/internal/llm"
+	"github.com/nikhilbhutani/backendwithai/internal/memory"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
 	"github.com/nikhilbhutani/backendwithai/internal/rag/indexing"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
 	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
 	"github.com/nikhilbhutani/backendwithai/pkg/chunker"
+	"github.com/nikhilbhutani/backendwithai/pkg/tokenizer"
 )
 
+// ErrInvalidRequest wraps the problems found in a query or search request,
+// such as options naming a feature that isn't enabled.
+var ErrInvalidRequest = errors.New("invalid request")
+
 type Pipeline interface {
 	Ingest(ctx context.Context, req IngestRequest) error
 	Query(ctx context.Context, req QueryRequest) (*QueryResponse, error)
@@ -23,6 +35,7 @@ const (
 	IndexTypeStandard = "standard"
 	IndexTypeRaptor   = "raptor"
 	IndexTypeMultiRep = "multi_rep"
+	IndexTypeGraph    = "graph"
 )
 
 type IngestRequest struct {
@@ -30,8 +43,28 @@ type IngestRequest struct {
 	TenantID   uuid.UUID
 	Content    string
 	ChunkOpts  chunker.ChunkOptions
-	// IndexType selects the indexing strategy: "standard" (default), "raptor", "multi_rep".
+	// IndexType selects the indexing strategy: "standard" (default), "raptor", "multi_rep", "graph".
 	IndexType string
+	// Title is the document title, used in context headers.
+	Title string
+	// ContextHeaders prepends a header to each chunk before embedding and
+	// keyword indexing: "structural" (title + section path) or "llm" (plus an
+	// LLM-written situating sentence). Empty disables.
+	ContextHeaders string
+	// CollectionIDs indexes the document into each of these collections,
+	// with the collection's settings overriding the ones above, instead of
+	// into the tenant's default pool.
+	CollectionIDs []uuid.UUID
+	// Access is the document's resolved ACL (models.DocumentACL.Access),
+	// copied onto every chunk. Nil leaves the chunks unrestricted.
+	Access []uuid.UUID
+	// PreviousVersion is the document version this one replaces. Chunks
+	// whose text is unchanged from it, or from this document's own earlier
+	// indexing, reuse their embeddings instead of being embedded again.
+	PreviousVersion uuid.UUID
+	// Superseded indexes the chunks of an old version, which searches skip
+	// by default.
+	Superseded bool
 }
 
 type QueryRequest struct {
@@ -46,38 +79,94 @@ type QueryRequest struct {
 	UseHyDE      bool    `json:"use_hyde,omitempty"`      // enable HyDE
 	// Strategy overrides automatic routing: "simple", "complex", "comparison".
 	Strategy string `json:"strategy,omitempty"`
+	// VerifyCitations flags answer sentences whose claims the cited sources
+	// do not support (one extra LLM call).
+	VerifyCitations bool `json:"verify_citations,omitempty"`
+	// Corrective grades retrieved chunks and retries retrieval with query
+	// rewriting and HyDE when coverage is poor. If nothing relevant is found
+	// the response is marked not answerable instead of generating.
+	Corrective bool `json:"corrective,omitempty"`
+	// UseGraph adds knowledge-graph results (entity chunks, relations and
+	// community summaries) to retrieval. Requires graph-indexed documents.
+	UseGraph bool `json:"use_graph,omitempty"`
+	// Ranking applies MMR diversity, time decay and per-document caps after
+	// reranking.
+	Ranking *RankOptions `json:"ranking,omitempty"`
+	// Tuning overrides approximate index search parameters (hnsw ef_search,
+	// ivfflat probes) to trade latency for recall.
+	Tuning *vectorstore.SearchTuning `json:"tuning,omitempty"`
+	// CollectionIDs restricts retrieval to these collections. Empty
+	// searches the default pool, the chunks ingested without a collection.
+	CollectionIDs []uuid.UUID `json:"collection_ids,omitempty"`
+	// Compress shrinks retrieved chunks to their query-relevant sentences
+	// before generation: "llm" (extractive) or "embedding" (similarity filter).
+	Compress string `json:"compress,omitempty"`
+	// ConversationID continues a stored conversation: prior turns are loaded
+	// and the new turn is persisted. History is used when no ID is given;
+	// its roles must be user or assistant.
+	ConversationID string         `json:"conversation_id,omitempty"`
+	History        []memory.Entry `json:"history,omitempty"`
+	// IncludeSuperseded also retrieves from old document versions.
+	IncludeSuperseded bool `json:"include_superseded,omitempty"`
 }
 
 type QueryResponse struct {
-	Answer      string     `json:"answer"`
-	Citations   []Citation `json:"citations"`
-	Model       string     `json:"model"`
-	Tokens      int        `json:"tokens"`
-	RoutingInfo RouteInfo  `json:"routing_info,omitempty"`
+	Answer      string                `json:"answer"`
+	Citations   []Citation            `json:"citations"`
+	Sentences   []SentenceAttribution `json:"sentences,omitempty"`
+	Model       string                `json:"model"`
+	Tokens      int                   `json:"tokens"`
+	RoutingInfo RouteInfo             `json:"routing_info,omitempty"`
+	// StandaloneQuery is the condensed follow-up used for retrieval, set
+	// only when the query was asked with conversation history.
+	StandaloneQuery string `json:"standalone_query,omitempty"`
+	ConversationID  string `json:"conversation_id,omitempty"`
+	// NotAnswerable is set when corrective retrieval found no relevant support.
+	NotAnswerable bool `json:"not_answerable,omitempty"`
+	// Compression reports kept spans and tokens saved when Compress was set.
+	Compression *CompressionInfo `json:"compression,omitempty"`
 }
 
 type SearchRequest struct {
-	Query        string  `json:"query"`
-	TopK         int     `json:"top_k,omitempty"`
-	MinScore     float64 `json:"min_score,omitempty"`
-	Hybrid       bool    `json:"hybrid,omitempty"`
-	Rerank       bool    `json:"rerank,omitempty"`
-	QueryRewrite bool    `json:"query_rewrite,omitempty"`
-	UseHyDE      bool    `json:"use_hyde,omitempty"`
+	Query        string                    `json:"query"`
+	TopK         int                       `json:"top_k,omitempty"`
+	MinScore     float64                   `json:"min_score,omitempty"`
+	Hybrid       bool                      `json:"hybrid,omitempty"`
+	Rerank       bool                      `json:"rerank,omitempty"`
+	QueryRewrite bool                      `json:"query_rewrite,omitempty"`
+	UseHyDE      bool                      `json:"use_hyde,omitempty"`
+	UseGraph     bool                      `json:"use_graph,omitempty"`
+	Ranking      *RankOptions              `json:"ranking,omitempty"`
+	Tuning       *vectorstore.SearchTuning `json:"tuning,omitempty"`
+	// CollectionIDs restricts the search to these collections, instead of
+	// the default pool.
+	CollectionIDs []uuid.UUID `json:"collection_ids,omitempty"`
+	// IncludeSuperseded also searches old document versions.
+	IncludeSuperseded bool `json:"include_superseded,omitempty"`
 }
 
 type pipeline struct {
-	store          vectorstore.VectorStore
-	embedSvc       *embedding.Service
-	retriever      *Retriever
-	generator      *Generator
-	reranker       Reranker
-	queryRewriter  QueryRewriter
-	hyde           *HyDE
-	router         QueryRouter
-	decomposer     Decomposer
-	raptorIndexer  *indexing.RaptorIndexer
+	store           vectorstore.VectorStore
+	embedSvc        *embedding.Service
+	retriever       *Retriever
+	generator       *Generator
+	reranker        Reranker
+	queryRewriter   QueryRewriter
+	hyde            *HyDE
+	router          QueryRouter
+	decomposer      Decomposer
+	raptorIndexer   *indexing.RaptorIndexer
 	multiRepIndexer *indexing.MultiRepIndexer
+	graphIndexer    *indexing.GraphIndexer
+	graphRetriever  *GraphRetriever
+	condenser       QueryCondenser
+	conversations   ConversationStore
+	collections     CollectionStore
+	grader          RelevanceGrader
+	corrective      CorrectiveOptions
+	llmCompressor   Compressor
+	embedCompressor Compressor
+	contextualizer  ChunkContextualizer
 }
 
 // PipelineOptions allows optional component injection.
@@ -86,6 +175,28 @@ type PipelineOptions struct {
 	Decomposer      Decomposer
 	RaptorIndexer   *indexing.RaptorIndexer
 	MultiRepIndexer *indexing.MultiRepIndexer
+	// Graph enables GraphRAG indexing and graph retrieval. Without it,
+	// IndexTypeGraph and UseGraph are rejected.
+	Graph        vectorstore.GraphStore
+	GraphIndexer *indexing.GraphIndexer
+	Condenser    QueryCondenser
+	// Conversations enables server-side conversation history. Without it,
+	// QueryRequest.ConversationID is rejected and only History is used.
+	Conversations ConversationStore
+	// Collections enables knowledge-base collections. Without it,
+	// collection IDs in ingest, query and search requests are rejected.
+	Collections CollectionStore
+	// ContextEngine sets the token budget for conversation history in generation.
+	ContextEngine *memory.ContextEngine
+	// Grader grades chunk relevance for corrective retrieval (default: LLMRelevanceGrader).
+	Grader     RelevanceGrader
+	Corrective *CorrectiveOptions
+	// LLMCompressor and EmbeddingCompressor back the "llm" and "embedding"
+	// compression methods.
+	LLMCompressor       Compressor
+	EmbeddingCompressor Compressor
+	// Contextualizer writes the situating sentence for "llm" context headers.
+	Contextualizer ChunkContextualizer
 }
 
 func NewPipeline(store vectorstore.VectorStore, embedSvc *embedding.Service, gw llm.Gateway) Pipeline {
@@ -110,53 +221,260 @@ func NewPipelineWithOptions(store vectorstore.VectorStore, embedSvc *embedding.S
 	if multiRepIndexer == nil {
 		multiRepIndexer = indexing.NewMultiRepIndexer(store, embedSvc, gw, "")
 	}
+	graphIndexer := opts.GraphIndexer
+	var graphRetriever *GraphRetriever
+	if opts.Graph != nil {
+		if graphIndexer == nil {
+			graphIndexer = indexing.NewGraphIndexer(store, opts.Graph, embedSvc, gw, "")
+		}
+		graphRetriever = NewGraphRetriever(opts.Graph, embedSvc)
+	}
+	condenser := opts.Condenser
+	if condenser == nil {
+		condenser = NewLLMQueryCondenser(gw, "")
+	}
+	generator := NewGenerator(gw)
+	if opts.ContextEngine != nil {
+		generator.contextEngine = opts.ContextEngine
+	}
+	reranker := NewLLMReranker(gw, "")
+	grader := opts.Grader
+	if grader == nil {
+		grader = NewLLMRelevanceGrader(gw, "")
+	}
+	llmCompressor := opts.LLMCompressor
+	if llmCompressor == nil {
+		llmCompressor = NewLLMCompressor(gw, "")
+	}
+	embedCompressor := opts.EmbeddingCompressor
+	if embedCompressor == nil {
+		embedCompressor = NewEmbeddingCompressor(embedSvc, 0)
+	}
+	contextualizer := opts.Contextualizer
+	if contextualizer == nil {
+		contextualizer = NewLLMChunkContextualizer(gw, "")
+	}
+	corrective := defaultCorrectiveOptions()
+	if opts.Corrective != nil {
+		corrective = *opts.Corrective
+	}
 
 	return &pipeline{
 		store:           store,
 		embedSvc:        embedSvc,
 		retriever:       NewRetriever(store, embedSvc),
-		generator:       NewGenerator(gw),
-		reranker:        NewLLMReranker(gw, ""),
+		generator:       generator,
+		reranker:        reranker,
 		queryRewriter:   NewLLMQueryRewriter(gw, ""),
 		hyde:            NewHyDE(gw, ""),
 		router:          router,
 		decomposer:      decomposer,
 		raptorIndexer:   raptorIndexer,
 		multiRepIndexer: multiRepIndexer,
+		graphIndexer:    graphIndexer,
+		graphRetriever:  graphRetriever,
+		condenser:       condenser,
+		conversations:   opts.Conversations,
+		collections:     opts.Collections,
+		grader:          grader,
+		corrective:      corrective,
+		llmCompressor:   llmCompressor,
+		embedCompressor: embedCompressor,
+		contextualizer:  contextualizer,
 	}
 }
 
 func (p *pipeline) Ingest(ctx context.Context, req IngestRequest) error {
+	// Ingestion usually runs in a worker, outside any request, and takes the
+	// document's tenant from the request. Within a request it must match the
+	// caller's.
+	ctxTenant := tenant.IDFromContext(ctx)
+	switch {
+	case ctxTenant == uuid.Nil && req.TenantID == uuid.Nil:
+		return tenant.ErrNoTenant
+	case ctxTenant == uuid.Nil:
+		ctx = tenant.WithTenant(ctx, &models.Tenant{ID: req.TenantID})
+	case req.TenantID == uuid.Nil:
+		req.TenantID = ctxTenant
+	case req.TenantID != ctxTenant:
+		return fmt.Errorf("ingest: document tenant %s does not match the request's", req.TenantID)
+	}
+
+	// Chunks are replaced per document; without one, the deletes below
+	// would reach every document.
+	if req.DocumentID == uuid.Nil {
+		return fmt.Errorf("ingest: document ID is required")
+	}
+
+	if len(req.CollectionIDs) == 0 {
+		return p.ingest(ctx, req, p.embedSvc, uuid.Nil)
+	}
+	if p.collections == nil {
+		return fmt.Errorf("collections are not enabled")
+	}
+
+	for _, id := range req.CollectionIDs {
+		c, err := p.collections.Get(ctx, id)
+		if err != nil {
+			return fmt.Errorf("load collection %s: %w", id, err)
+		}
+		creq, err := withCollectionSettings(req, c)
+		if err != nil {
+			return fmt.Errorf("collection %s: %w", c.Name, err)
+		}
+
+		if err := p.ingest(ctx, creq, p.embedSvc.WithModel(c.EmbeddingModel), c.ID); err != nil {
+			return fmt.Errorf("ingest into collection %s: %w", c.Name, err)
+		}
+	}
+	return nil
+}
+
+// withCollectionSettings applies a collection's ingestion settings over the
+// request's.
+func withCollectionSettings(req IngestRequest, c *models.Collection) (IngestRequest, error) {
+	opts, err := collectionChunkOptions(c)
+	if err != nil {
+		return req, err
+	}
+	if opts.ChunkSize > 0 {
+		req.ChunkOpts = opts
+	}
+	if c.IndexType != "" {
+		req.IndexType = c.IndexType
+	}
+	if c.ContextHeaders != "" {
+		req.ContextHeaders = c.ContextHeaders
+	}
+	return req, nil
+}
+
+// ingest chunks, embeds and indexes one document into the given collection
+// (uuid.Nil for the default pool), replacing the chunks it already has
+// there so a retried or repeated ingestion doesn't duplicate them.
+func (p *pipeline) ingest(ctx context.Context, req IngestRequest, embedSvc *embedding.Service, collectionID uuid.UUID) error {
+	// Entities, relations and community summaries are shared across the
+	// tenant and cannot be filtered per caller.
+	if req.IndexType == IndexTypeGraph && len(req.Access) > 0 {
+		return fmt.Errorf("graph indexing is not supported for access-restricted documents")
+	}
+
 	opts := req.ChunkOpts
 	if opts.ChunkSize == 0 {
 		opts = chunker.DefaultOptions()
 	}
 
-	chunkResults := ChunkTextWithEmbeddings(ctx, req.Content, opts, p.embedSvc)
+	chunkResults := ChunkTextWithEmbeddings(ctx, req.Content, opts, embedSvc)
 	if len(chunkResults) == 0 {
 		return fmt.Errorf("no chunks generated from content")
 	}
 
+	// Structural headers are cheap to rebuild; LLM situating sentences are
+	// not, so a chunk whose text and structural header are unchanged reuses
+	// the header stored with it along with its embedding. Its hash covers
+	// the header mode, the structural header and the chunk text, not the
+	// situating sentence, which differs from one LLM call to the next.
+	var sections [][]string
+	var headers []string
+	switch req.ContextHeaders {
+	case "":
+	case ContextHeaderStructural, ContextHeaderLLM:
+		sections = sectionPaths(req.Content, chunkResults)
+		headers = make([]string, len(chunkResults))
+		for i := range chunkResults {
+			headers[i] = buildContextHeader(req.Title, sections[i], "")
+		}
+	default:
+		return fmt.Errorf("unknown context header mode %q", req.ContextHeaders)
+	}
+
+	hashes := make([]string, len(chunkResults))
+	for i, c := range chunkResults {
+		var structural string
+		if headers != nil {
+			structural = headers[i]
+		}
+		hashes[i] = contentHash(embedSvc.Model(), req.ContextHeaders, structural, c.Content)
+	}
+	stored, err := p.storedEmbeddings(ctx, req, collectionID, hashes)
+	if err != nil {
+		return err
+	}
+
+	if req.ContextHeaders == ContextHeaderLLM {
+		// A stored header without a situating sentence is one whose LLM
+		// call failed; those chunks are situated again.
+		var situate []int
+		for i := range chunkResults {
+			if s, ok := stored[hashes[i]]; ok && s.ContextHeader != headers[i] {
+				headers[i] = s.ContextHeader
+				continue
+			}
+			delete(stored, hashes[i])
+			situate = append(situate, i)
+		}
+		if len(situate) > 0 {
+			situating := p.situateChunks(ctx, req, chunkResults, situate)
+			for _, i := range situate {
+				headers[i] = buildContextHeader(req.Title, sections[i], situating[i])
+			}
+		}
+	}
+
 	texts := make([]string, len(chunkResults))
 	for i, c := range chunkResults {
 		texts[i] = c.Content
+		if headers != nil && headers[i] != "" {
+			texts[i] = headers[i] + contextHeaderSeparator + c.Content
+		}
 	}
 
-	embeddings, err := p.embedSvc.Embed(ctx, texts)
+	embeddings, err := embedMissing(ctx, embedSvc, texts, hashes, stored)
 	if err != nil {
-		return fmt.Errorf("generate embeddings: %w", err)
+		return err
+	}
+
+	replaced := vectorstore.DeleteFilter{TenantID: req.TenantID, DocumentID: req.DocumentID, CollectionID: collectionID}
+	if collectionID == uuid.Nil {
+		replaced.DefaultPool = true
 	}
+	if err := p.store.Delete(ctx, replaced); err != nil {
+		return fmt.Errorf("clear document chunks: %w", err)
+	}
+
+	// Form feeds separate pages in extracted text (e.g. PDFs).
+	paged := strings.ContainsRune(req.Content, '\f')
 
 	chunks := make([]vectorstore.Chunk, len(chunkResults))
 	for i, cr := range chunkResults {
 		chunks[i] = vectorstore.Chunk{
-			ID:         uuid.New(),
-			DocumentID: req.DocumentID,
-			TenantID:   req.TenantID,
-			ChunkIndex: cr.Index,
-			Content:    cr.Content,
-			Embedding:  embeddings[i],
-			TokenCount: cr.TokenCount,
+			ID:           uuid.New(),
+			DocumentID:   req.DocumentID,
+			TenantID:     req.TenantID,
+			ChunkIndex:   cr.Index,
+			Content:      texts[i],
+			Embedding:    embeddings[i],
+			TokenCount:   tokenizer.CountTokens(texts[i]),
+			Metadata:     map[string]interface{}{},
+			CollectionID: collectionID,
+			Access:       req.Access,
+			ContentHash:  hashes[i],
+			Superseded:   req.Superseded,
+		}
+		// Offsets let citations point back into the original document.
+		if cr.End > cr.Start {
+			chunks[i].Metadata["start_offset"] = cr.Start
+			chunks[i].Metadata["end_offset"] = cr.End
+		}
+		if paged {
+			chunks[i].Metadata["page"] = cr.Page
+		}
+		if len(cr.SectionPath) > 0 {
+			chunks[i].Metadata["section_path"] = cr.SectionPath
+		}
+		// The header is stripped again at retrieval so the original text is displayed.
+		if headers != nil && headers[i] != "" {
+			chunks[i].Metadata["context_header"] = headers[i]
 		}
 	}
 
@@ -165,6 +483,11 @@ func (p *pipeline) Ingest(ctx context.Context, req IngestRequest) error {
 		return p.raptorIndexer.Index(ctx, chunks)
 	case IndexTypeMultiRep:
 		return p.multiRepIndexer.Index(ctx, chunks)
+	case IndexTypeGraph:
+		if p.graphIndexer == nil {
+			return fmt.Errorf("%w: graph indexing is not enabled; it requires the pgvector backend", ErrInvalidRequest)
+		}
+		return p.graphIndexer.Index(ctx, chunks)
 	default:
 		if err := p.store.Upsert(ctx, chunks); err != nil {
 			return fmt.Errorf("store chunks: %w", err)
@@ -174,21 +497,107 @@ func (p *pipeline) Ingest(ctx context.Context, req IngestRequest) error {
 	return nil
 }
 
+// storedEmbeddings returns the embeddings, with their context headers,
+// already stored for the given hashes on this document or the version it
+// replaces.
+func (p *pipeline) storedEmbeddings(ctx context.Context, req IngestRequest, collectionID uuid.UUID, hashes []string) (map[string]vectorstore.StoredEmbedding, error) {
+	documentIDs := []uuid.UUID{req.DocumentID}
+	if req.PreviousVersion != uuid.Nil {
+		documentIDs = append(documentIDs, req.PreviousVersion)
+	}
+	stored, err := p.store.Embeddings(ctx, vectorstore.EmbeddingLookup{
+		TenantID:     req.TenantID,
+		DocumentIDs:  documentIDs,
+		CollectionID: collectionID,
+		Hashes:       hashes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("look up stored embeddings: %w", err)
+	}
+	return stored, nil
+}
+
+// embedMissing returns the embeddings of texts, taking those in stored by
+// hash and embedding only the rest.
+func embedMissing(ctx context.Context, embedSvc *embedding.Service, texts, hashes []string, stored map[string]vectorstore.StoredEmbedding) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	var missing []int
+	var pending []string
+	for i, hash := range hashes {
+		if s, ok := stored[hash]; ok {
+			embeddings[i] = s.Embedding
+			continue
+		}
+		missing = append(missing, i)
+		pending = append(pending, texts[i])
+	}
+	if len(pending) == 0 {
+		return embeddings, nil
+	}
+
+	embedded, err := embedSvc.Embed(ctx, pending)
+	if err != nil {
+		return nil, fmt.Errorf("generate embeddings: %w", err)
+	}
+	for j, i := range missing {
+		embeddings[i] = embedded[j]
+	}
+	return embeddings, nil
+}
+
+// contentHash identifies a chunk by the model embedding it, its context
+// header mode, its structural header and its text.
+func contentHash(model, headerMode, structuralHeader, text string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + headerMode + "\x00" + structuralHeader + "\x00" + text))
+	return hex.EncodeToString(sum[:])
+}
+
 func (p *pipeline) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
 	if req.TopK <= 0 {
 		req.TopK = 5
 	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Checked up front so a bad method doesn't cost a retrieval first.
+	if err := ValidateCompression(req.Compress); err != nil {
+		return nil, err
+	}
+
+	history, convID, err := p.loadHistory(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Condense a follow-up into a standalone query so routing and retrieval
+	// don't depend on conversational references.
+	query := req.Query
+	if len(history) > 0 {
+		query, _ = p.condenser.Condense(ctx, history, req.Query)
+	}
 
-	tenantID := getTenantIDFromContext(ctx)
 	retrieveOpts := RetrieveOptions{
-		TenantID: tenantID,
-		TopK:     req.TopK,
-		MinScore: req.MinScore,
-		Hybrid:   req.Hybrid,
+		TenantID:          tenantID,
+		TopK:              req.TopK,
+		MinScore:          req.MinScore,
+		Hybrid:            req.Hybrid,
+		Principals:        tenant.PrincipalsFromContext(ctx),
+		IncludeSuperseded: req.IncludeSuperseded,
+	}
+	if req.Tuning != nil {
+		retrieveOpts.Tuning = *req.Tuning
+	}
+	if err := p.scopeToCollections(ctx, &retrieveOpts, req.CollectionIDs, req.UseGraph); err != nil {
+		return nil, err
+	}
+	if req.Ranking.enabled() {
+		retrieveOpts.TopK = req.TopK * rankCandidateFactor
+		retrieveOpts.WithEmbeddings = req.Ranking.MMR
 	}
 
 	// Route the query (or use caller-specified strategy).
-	route, err := p.router.Route(ctx, req.Query)
+	route, err := p.router.Route(ctx, query)
 	if err != nil {
 		route = defaultRoute()
 	}
@@ -210,53 +619,218 @@ func (p *pipeline) Query(ctx context.Context, req QueryRequest) (*QueryResponse,
 
 	switch {
 	case route.UseDecompose:
-		results, err = p.decomposeAndRetrieve(ctx, req.Query, retrieveOpts)
+		results, err = p.decomposeAndRetrieve(ctx, query, retrieveOpts)
 	case route.UseRewrite || route.UseHyDE:
-		results, err = p.retrieve(ctx, req.Query, retrieveOpts, route.UseRewrite, route.UseHyDE)
+		results, err = p.retrieve(ctx, query, retrieveOpts, route.UseRewrite, route.UseHyDE)
 	default:
-		results, err = p.retriever.Retrieve(ctx, req.Query, retrieveOpts)
+		results, err = p.retriever.Retrieve(ctx, query, retrieveOpts)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("retrieve: %w", err)
 	}
 
-	if req.Rerank && len(results) > 0 {
-		results, err = p.reranker.Rerank(ctx, req.Query, results)
+	if req.UseGraph {
+		results, err = p.withGraph(ctx, query, retrieveOpts, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	routeInfo := routeInfoFromRoute(route)
+	routeInfo.UseGraph = req.UseGraph
+
+	// Corrective results are already ordered by relevance grade, so the
+	// separate rerank step is skipped.
+	if req.Corrective {
+		results, routeInfo.Corrective, err = p.correctiveRetrieve(ctx, query, retrieveOpts, results)
+		if err != nil {
+			return nil, fmt.Errorf("corrective retrieve: %w", err)
+		}
+		if !routeInfo.Corrective.Answerable {
+			resp := &QueryResponse{
+				Answer:        NotAnswerableMessage,
+				Citations:     []Citation{},
+				RoutingInfo:   routeInfo,
+				NotAnswerable: true,
+			}
+			if err := p.finishTurn(ctx, req, resp, query, history, convID); err != nil {
+				return nil, err
+			}
+			return resp, nil
+		}
+	} else if req.Rerank && len(results) > 0 {
+		results, err = p.reranker.Rerank(ctx, query, results)
 		if err != nil {
 			return nil, fmt.Errorf("rerank: %w", err)
 		}
 	}
 
+	if req.Ranking.enabled() {
+		results, err = applyRanking(ctx, query, req.Ranking, results, req.TopK)
+		if err != nil {
+			return nil, fmt.Errorf("rank: %w", err)
+		}
+	}
+
+	var compression *CompressionInfo
+	if req.Compress != "" {
+		results, compression, err = p.compress(ctx, req.Compress, query, results)
+		if err != nil {
+			return nil, fmt.Errorf("compress: %w", err)
+		}
+	}
+
 	genResp, err := p.generator.Generate(ctx, GenerateRequest{
-		Query:    req.Query,
-		Context:  results,
-		Model:    req.Model,
-		Provider: req.Provider,
+		Query:        query,
+		Context:      results,
+		Model:        req.Model,
+		Provider:     req.Provider,
+		History:      history,
+		VerifyClaims: req.VerifyCitations,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("generate: %w", err)
 	}
 
-	return &QueryResponse{
+	resp := &QueryResponse{
 		Answer:      genResp.Answer,
 		Citations:   genResp.Citations,
+		Sentences:   genResp.Sentences,
 		Model:       genResp.Usage.Model,
 		Tokens:      genResp.Usage.TotalTokens,
-		RoutingInfo: routeInfoFromRoute(route),
-	}, nil
+		RoutingInfo: routeInfo,
+		Compression: compression,
+	}
+	if err := p.finishTurn(ctx, req, resp, query, history, convID); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// finishTurn records the standalone query a follow-up was condensed to and,
+// with a conversation, appends the question and answer to it. Every answer,
+// including a refusal, is a turn: a later follow-up may refer to it.
+func (p *pipeline) finishTurn(ctx context.Context, req QueryRequest, resp *QueryResponse, query string, history []memory.Entry, convID uuid.UUID) error {
+	if len(history) > 0 {
+		resp.StandaloneQuery = query
+	}
+	if convID == uuid.Nil {
+		return nil
+	}
+	err := p.conversations.Append(ctx, convID,
+		memory.Entry{Role: "user", Content: req.Query},
+		memory.Entry{Role: "assistant", Content: resp.Answer},
+	)
+	if err != nil {
+		return fmt.Errorf("save conversation turn: %w", err)
+	}
+	resp.ConversationID = convID.String()
+	return nil
+}
+
+// compress runs the compressor for the requested method.
+func (p *pipeline) compress(ctx context.Context, method, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, *CompressionInfo, error) {
+	switch method {
+	case CompressionLLM:
+		return p.llmCompressor.Compress(ctx, query, results)
+	case CompressionEmbedding:
+		return p.embedCompressor.Compress(ctx, query, results)
+	default:
+		return nil, nil, fmt.Errorf("unknown compression method %q", method)
+	}
+}
+
+// scopeToCollections restricts retrieval to the given collections of the
+// tenant, noting which use their own embedding model. Graph retrieval can't
+// be scoped: entities, relations and community summaries span the tenant.
+func (p *pipeline) scopeToCollections(ctx context.Context, opts *RetrieveOptions, ids []uuid.UUID, useGraph bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if p.collections == nil {
+		return fmt.Errorf("%w: collections are not enabled", ErrInvalidRequest)
+	}
+	if useGraph {
+		return fmt.Errorf("%w: use_graph can't be combined with collection_ids", ErrInvalidRequest)
+	}
+
+	opts.CollectionIDs = ids
+	opts.collectionModels = make(map[uuid.UUID]string, len(ids))
+	for _, id := range ids {
+		c, err := p.collections.Get(ctx, id)
+		if err != nil {
+			return fmt.Errorf("load collection %s: %w", id, err)
+		}
+		if c.EmbeddingModel != "" {
+			opts.collectionModels[id] = c.EmbeddingModel
+		}
+	}
+	return nil
+}
+
+// maxHistoryTurns caps how many turns, stored or client-supplied, are used
+// per query; the generator's context engine trims further to fit the token
+// budget.
+const maxHistoryTurns = 20
+
+// loadHistory resolves the conversation history for a query. A conversation
+// ID takes precedence over client-supplied history, which may only hold
+// user and assistant turns and is cut to the most recent maxHistoryTurns.
+func (p *pipeline) loadHistory(ctx context.Context, req QueryRequest) ([]memory.Entry, uuid.UUID, error) {
+	if req.ConversationID == "" {
+		for i, h := range req.History {
+			if h.Role != "user" && h.Role != "assistant" {
+				return nil, uuid.Nil, fmt.Errorf("%w: history[%d] has role %q; only user and assistant are allowed", ErrInvalidRequest, i, h.Role)
+			}
+		}
+		return req.History[max(len(req.History)-maxHistoryTurns, 0):], uuid.Nil, nil
+	}
+	if p.conversations == nil {
+		return nil, uuid.Nil, fmt.Errorf("%w: conversations are not enabled", ErrInvalidRequest)
+	}
+
+	convID, err := uuid.Parse(req.ConversationID)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("%w: invalid conversation ID: %v", ErrInvalidRequest, err)
+	}
+
+	if _, err := p.conversations.Get(ctx, convID); err != nil {
+		return nil, uuid.Nil, fmt.Errorf("load conversation: %w", err)
+	}
+
+	history, err := p.conversations.History(ctx, convID, maxHistoryTurns)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("load conversation: %w", err)
+	}
+	return history, convID, nil
 }
 
 func (p *pipeline) Search(ctx context.Context, req SearchRequest) ([]vectorstore.SearchResult, error) {
 	if req.TopK <= 0 {
 		req.TopK = 10
 	}
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	tenantID := getTenantIDFromContext(ctx)
 	retrieveOpts := RetrieveOptions{
-		TenantID: tenantID,
-		TopK:     req.TopK,
-		MinScore: req.MinScore,
-		Hybrid:   req.Hybrid,
+		TenantID:          tenantID,
+		TopK:              req.TopK,
+		MinScore:          req.MinScore,
+		Hybrid:            req.Hybrid,
+		Principals:        tenant.PrincipalsFromContext(ctx),
+		IncludeSuperseded: req.IncludeSuperseded,
+	}
+	if req.Tuning != nil {
+		retrieveOpts.Tuning = *req.Tuning
+	}
+	if err := p.scopeToCollections(ctx, &retrieveOpts, req.CollectionIDs, req.UseGraph); err != nil {
+		return nil, err
+	}
+	if req.Ranking.enabled() {
+		retrieveOpts.TopK = req.TopK * rankCandidateFactor
+		retrieveOpts.WithEmbeddings = req.Ranking.MMR
 	}
 
 	results, err := p.retrieve(ctx, req.Query, retrieveOpts, req.QueryRewrite, req.UseHyDE)
@@ -264,10 +838,21 @@ func (p *pipeline) Search(ctx context.Context, req SearchRequest) ([]vectorstore
 		return nil, err
 	}
 
+	if req.UseGraph {
+		results, err = p.withGraph(ctx, req.Query, retrieveOpts, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if req.Rerank && len(results) > 0 {
 		results, _ = p.reranker.Rerank(ctx, req.Query, results)
 	}
 
+	if req.Ranking.enabled() {
+		return applyRanking(ctx, req.Query, req.Ranking, results, req.TopK)
+	}
+
 	return results, nil
 }
 
@@ -293,6 +878,21 @@ func (p *pipeline) retrieve(ctx context.Context, query string, opts RetrieveOpti
 	return p.retriever.Retrieve(ctx, query, opts)
 }
 
+// withGraph fuses graph retrieval results into the vector results with RRF.
+func (p *pipeline) withGraph(ctx context.Context, query string, opts RetrieveOptions, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+	if p.graphRetriever == nil {
+		return nil, fmt.Errorf("%w: graph retrieval is not enabled; it requires the pgvector backend", ErrInvalidRequest)
+	}
+	graphResults, err := p.graphRetriever.Retrieve(ctx, query, opts)
+	if err != nil {
+		return nil, fmt.Errorf("graph retrieve: %w", err)
+	}
+	if len(graphResults) == 0 {
+		return results, nil
+	}
+	return reciprocalRankFusion([][]vectorstore.SearchResult{graphResults, results}, 60, opts.TopK), nil
+}
+
 // decomposeAndRetrieve breaks the query into sub-questions, retrieves for each,
 // then merges with RRF fusion.
 func (p *pipeline) decomposeAndRetrieve(ctx context.Context, query string, opts RetrieveOptions) ([]vectorstore.SearchResult, error) {
@@ -334,11 +934,3 @@ func (p *pipeline) multiQueryRetrieve(ctx context.Context, queries []string, opt
 
 	return reciprocalRankFusion(resultSets, 60, opts.TopK), nil
 }
-
-func getTenantIDFromContext(ctx context.Context) uuid.UUID {
-	type tenantIDKey string
-	if id, ok := ctx.Value(tenantIDKey("tenant_id")).(uuid.UUID); ok {
-		return id
-	}
-	return uuid.Nil
-}
@@ -0,0 +1,240 @@
+package rag
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// RankOptions selects post-retrieval rankers for a request. Rankers run after
+// reranking in the order time decay → MMR → per-document cap.
+type RankOptions struct {
+	// MMR reorders results for diversity using Maximal Marginal Relevance.
+	MMR bool `json:"mmr,omitempty"`
+	// MMRLambda trades relevance (1.0) against diversity (0.0). Default 0.7.
+	MMRLambda float64 `json:"mmr_lambda,omitempty"`
+	// HalfLifeDays enables time decay: a result's score halves every
+	// HalfLifeDays of document age. 0 disables.
+	HalfLifeDays float64 `json:"half_life_days,omitempty"`
+	// DateField is the chunk metadata field holding the date to decay on.
+	// Falls back to the document's created_at when empty or missing.
+	DateField string `json:"date_field,omitempty"`
+	// MaxPerDocument caps how many chunks one document can contribute. 0 disables.
+	MaxPerDocument int `json:"max_per_document,omitempty"`
+}
+
+func (o *RankOptions) enabled() bool {
+	return o != nil && (o.MMR || o.HalfLifeDays > 0 || o.MaxPerDocument > 0)
+}
+
+// ranker builds the chain of rankers the options select.
+func (o *RankOptions) ranker() Reranker {
+	var rankers []Reranker
+	if o.HalfLifeDays > 0 {
+		rankers = append(rankers, NewTimeDecayRanker(time.Duration(o.HalfLifeDays*24*float64(time.Hour)), o.DateField))
+	}
+	if o.MMR {
+		rankers = append(rankers, NewMMRRanker(o.MMRLambda))
+	}
+	if o.MaxPerDocument > 0 {
+		rankers = append(rankers, NewDocumentCapRanker(o.MaxPerDocument))
+	}
+	return NewRankerChain(rankers...)
+}
+
+// rankCandidateFactor is how many more candidates are retrieved when rankers
+// are enabled, so diversity and per-document caps can still fill TopK.
+const rankCandidateFactor = 3
+
+// applyRanking runs the selected rankers and trims the result to topK.
+func applyRanking(ctx context.Context, query string, opts *RankOptions, results []vectorstore.SearchResult, topK int) ([]vectorstore.SearchResult, error) {
+	ranked, err := opts.ranker().Rerank(ctx, query, results)
+	if err != nil {
+		return nil, err
+	}
+	if topK > 0 && len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+	return ranked, nil
+}
+
+// RankerChain applies rankers in sequence, each receiving the previous output.
+type RankerChain struct {
+	rankers []Reranker
+}
+
+func NewRankerChain(rankers ...Reranker) *RankerChain {
+	return &RankerChain{rankers: rankers}
+}
+
+func (c *RankerChain) Rerank(ctx context.Context, query string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+	var err error
+	for _, r := range c.rankers {
+		results, err = r.Rerank(ctx, query, results)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// MMRRanker reorders results with Maximal Marginal Relevance:
+//
+//	mmr(d) = λ · score(d) − (1 − λ) · max sim(d, selected)
+//
+// using the stored chunk embeddings for similarity. Scores are left unchanged;
+// only the order is meaningful. Results without embeddings never count as
+// redundant.
+type MMRRanker struct {
+	lambda float64
+}
+
+func NewMMRRanker(lambda float64) *MMRRanker {
+	if lambda <= 0 || lambda > 1 {
+		lambda = 0.7
+	}
+	return &MMRRanker{lambda: lambda}
+}
+
+func (m *MMRRanker) Rerank(_ context.Context, _ string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+	if len(results) < 2 {
+		return results, nil
+	}
+
+	remaining := make([]vectorstore.SearchResult, len(results))
+	copy(remaining, results)
+	selected := make([]vectorstore.SearchResult, 0, len(results))
+
+	for len(remaining) > 0 {
+		best, bestScore := 0, math.Inf(-1)
+		for i, cand := range remaining {
+			redundancy := 0.0
+			for _, s := range selected {
+				if sim := cosine(cand.Embedding, s.Embedding); sim > redundancy {
+					redundancy = sim
+				}
+			}
+			score := m.lambda*cand.Score - (1-m.lambda)*redundancy
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		selected = append(selected, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+	return selected, nil
+}
+
+// TimeDecayRanker multiplies each score by 0.5^(age / halfLife), so newer
+// documents outrank older versions of similar content. The date comes from
+// chunk metadata (dateField) when present, else the document's created_at.
+// Results with no known date are not decayed.
+type TimeDecayRanker struct {
+	halfLife  time.Duration
+	dateField string
+	now       func() time.Time
+}
+
+func NewTimeDecayRanker(halfLife time.Duration, dateField string) *TimeDecayRanker {
+	if halfLife <= 0 {
+		halfLife = 90 * 24 * time.Hour
+	}
+	return &TimeDecayRanker{halfLife: halfLife, dateField: dateField, now: time.Now}
+}
+
+func (t *TimeDecayRanker) Rerank(_ context.Context, _ string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+	now := t.now()
+	ranked := make([]vectorstore.SearchResult, len(results))
+	copy(ranked, results)
+
+	for i := range ranked {
+		date, ok := t.resultDate(ranked[i])
+		if !ok {
+			continue
+		}
+		age := now.Sub(date)
+		if age < 0 {
+			age = 0
+		}
+		ranked[i].Score *= math.Pow(0.5, float64(age)/float64(t.halfLife))
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	return ranked, nil
+}
+
+var metadataDateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"}
+
+func (t *TimeDecayRanker) resultDate(r vectorstore.SearchResult) (time.Time, bool) {
+	if t.dateField != "" {
+		if s, ok := r.Metadata[t.dateField].(string); ok {
+			for _, layout := range metadataDateLayouts {
+				if d, err := time.Parse(layout, s); err == nil {
+					return d, true
+				}
+			}
+		}
+	}
+	if r.CreatedAt != nil {
+		return *r.CreatedAt, true
+	}
+	return time.Time{}, false
+}
+
+// DocumentCapRanker keeps at most maxPerDocument results per document,
+// preserving order. Synthetic results without a document are never capped.
+type DocumentCapRanker struct {
+	maxPerDocument int
+}
+
+func NewDocumentCapRanker(maxPerDocument int) *DocumentCapRanker {
+	if maxPerDocument <= 0 {
+		maxPerDocument = 2
+	}
+	return &DocumentCapRanker{maxPerDocument: maxPerDocument}
+}
+
+func (d *DocumentCapRanker) Rerank(_ context.Context, _ string, results []vectorstore.SearchResult) ([]vectorstore.SearchResult, error) {
+	counts := make(map[uuid.UUID]int)
+	capped := make([]vectorstore.SearchResult, 0, len(results))
+	for _, r := range results {
+		if r.DocumentID != uuid.Nil {
+			if counts[r.DocumentID] >= d.maxPerDocument {
+				continue
+			}
+			counts[r.DocumentID]++
+		}
+		capped = append(capped, r)
+	}
+	return capped, nil
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// Ensure the rankers implement Reranker.
+var (
+	_ Reranker = (*RankerChain)(nil)
+	_ Reranker = (*MMRRanker)(nil)
+	_ Reranker = (*TimeDecayRanker)(nil)
+	_ Reranker = (*DocumentCapRanker)(nil)
+)
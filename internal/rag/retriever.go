@@ -3,6 +3,7 @@ package rag
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/google/uuid"
 	"github.com/nikhilbhutani/backendwithai/internal/embedding"
@@ -23,22 +24,96 @@ type RetrieveOptions struct {
 	TopK     int
 	MinScore float64
 	Hybrid   bool // use hybrid search (vector + keyword)
+	// Tuning sets per-query index search parameters (recall vs latency).
+	Tuning vectorstore.SearchTuning
+	// CollectionIDs restricts retrieval to these collections.
+	CollectionIDs []uuid.UUID
+	// Principals are the caller's user, role and group IDs; chunks of
+	// access-restricted documents are only retrieved for listed principals.
+	Principals []uuid.UUID
+	// IncludeSuperseded also retrieves the chunks of old document versions.
+	IncludeSuperseded bool
+	// WithEmbeddings returns the chunk vectors, for MMR.
+	WithEmbeddings bool
+	// collectionModels maps collections with their own embedding model to
+	// that model; the query must be embedded with the same one.
+	collectionModels map[uuid.UUID]string
 }
 
 func (r *Retriever) Retrieve(ctx context.Context, query string, opts RetrieveOptions) ([]vectorstore.SearchResult, error) {
-	queryVec, err := r.embedSvc.EmbedSingle(ctx, query)
+	groups := r.modelGroups(opts)
+	if len(groups) <= 1 {
+		var model string
+		for m := range groups {
+			model = m
+		}
+		return r.search(ctx, query, model, opts.CollectionIDs, opts)
+	}
+
+	// Vectors from different models are not comparable: each group is
+	// searched with its own query embedding and the results fused by rank.
+	models := make([]string, 0, len(groups))
+	for m := range groups {
+		models = append(models, m)
+	}
+	sort.Strings(models)
+
+	resultSets := make([][]vectorstore.SearchResult, 0, len(models))
+	for _, m := range models {
+		results, err := r.search(ctx, query, m, groups[m], opts)
+		if err != nil {
+			return nil, err
+		}
+		resultSets = append(resultSets, results)
+	}
+	return reciprocalRankFusion(resultSets, 60, opts.TopK), nil
+}
+
+// modelGroups groups the requested collections by embedding model, with ""
+// for the default model. It returns nil when retrieval is not scoped to
+// collections.
+func (r *Retriever) modelGroups(opts RetrieveOptions) map[string][]uuid.UUID {
+	if len(opts.CollectionIDs) == 0 {
+		return nil
+	}
+	groups := make(map[string][]uuid.UUID)
+	for _, id := range opts.CollectionIDs {
+		model := opts.collectionModels[id]
+		if model == r.embedSvc.Model() {
+			model = ""
+		}
+		groups[model] = append(groups[model], id)
+	}
+	return groups
+}
+
+func (r *Retriever) search(ctx context.Context, query, model string, collectionIDs []uuid.UUID, opts RetrieveOptions) ([]vectorstore.SearchResult, error) {
+	queryVec, err := r.embedSvc.WithModel(model).EmbedSingle(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("embed query: %w", err)
 	}
 
 	searchOpts := vectorstore.SearchOptions{
-		TenantID: opts.TenantID,
-		TopK:     opts.TopK,
-		MinScore: opts.MinScore,
+		TenantID:          opts.TenantID,
+		TopK:              opts.TopK,
+		MinScore:          opts.MinScore,
+		CollectionIDs:     collectionIDs,
+		Principals:        opts.Principals,
+		IncludeSuperseded: opts.IncludeSuperseded,
+		Tuning:            opts.Tuning,
+		WithEmbeddings:    opts.WithEmbeddings,
 	}
 
+	var results []vectorstore.SearchResult
 	if opts.Hybrid {
-		return r.store.HybridSearch(ctx, query, queryVec, searchOpts)
+		results, err = r.store.HybridSearch(ctx, query, queryVec, searchOpts)
+	} else {
+		results, err = r.store.SimilaritySearch(ctx, queryVec, searchOpts)
 	}
-	return r.store.SimilaritySearch(ctx, queryVec, searchOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	stripContextHeaders(results)
+	return results, nil
 }
@@ -98,8 +98,10 @@ func defaultRoute() *QueryRoute {
 
 // RouteInfo is included in QueryResponse to expose routing decisions.
 type RouteInfo struct {
-	Strategy  string `json:"strategy"`
-	Reasoning string `json:"reasoning"`
+	Strategy   string          `json:"strategy"`
+	Reasoning  string          `json:"reasoning"`
+	Corrective *CorrectiveInfo `json:"corrective,omitempty"`
+	UseGraph   bool            `json:"use_graph,omitempty"`
 }
 
 func routeInfoFromRoute(r *QueryRoute) RouteInfo {
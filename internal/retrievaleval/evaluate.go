@@ -0,0 +1,170 @@
+package retrievaleval
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// Limits on one run's size.
+const (
+	maxCases   = 1000
+	maxConfigs = 20
+)
+
+// Case is a labeled query. When RelevantChunkIDs is set, relevance is
+// judged per chunk; otherwise per document.
+type Case struct {
+	Query               string      `json:"query"`
+	RelevantDocumentIDs []uuid.UUID `json:"relevant_document_ids,omitempty"`
+	RelevantChunkIDs    []uuid.UUID `json:"relevant_chunk_ids,omitempty"`
+}
+
+func (c Case) byChunk() bool {
+	return len(c.RelevantChunkIDs) > 0
+}
+
+func (c Case) relevant() map[uuid.UUID]bool {
+	ids := c.RelevantDocumentIDs
+	if c.byChunk() {
+		ids = c.RelevantChunkIDs
+	}
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// Config is a named retrieval configuration: the search request fields
+// (hybrid, use_hyde, query_rewrite, rerank, ...) each case's query runs
+// with. Its TopK is the k of the metrics.
+type Config struct {
+	Name string `json:"name"`
+	rag.SearchRequest
+}
+
+// DefaultConfigs compares plain vector search with the pipeline's retrieval
+// options, one at a time.
+func DefaultConfigs(topK int) []Config {
+	return []Config{
+		{Name: "vector", SearchRequest: rag.SearchRequest{TopK: topK}},
+		{Name: "hybrid", SearchRequest: rag.SearchRequest{TopK: topK, Hybrid: true}},
+		{Name: "hyde", SearchRequest: rag.SearchRequest{TopK: topK, UseHyDE: true}},
+		{Name: "query_rewrite", SearchRequest: rag.SearchRequest{TopK: topK, QueryRewrite: true}},
+		{Name: "hybrid_rerank", SearchRequest: rag.SearchRequest{TopK: topK, Hybrid: true, Rerank: true}},
+	}
+}
+
+// Validate checks a run's cases and configurations before it is queued.
+func Validate(cases []Case, configs []Config) error {
+	if len(cases) == 0 {
+		return fmt.Errorf("cases are required")
+	}
+	if len(cases) > maxCases {
+		return fmt.Errorf("at most %d cases per run", maxCases)
+	}
+	for i, c := range cases {
+		if c.Query == "" {
+			return fmt.Errorf("case %d: query is required", i)
+		}
+		if len(c.RelevantDocumentIDs) == 0 && len(c.RelevantChunkIDs) == 0 {
+			return fmt.Errorf("case %d: relevant_document_ids or relevant_chunk_ids is required", i)
+		}
+	}
+
+	if len(configs) > maxConfigs {
+		return fmt.Errorf("at most %d configs per run", maxConfigs)
+	}
+	names := make(map[string]bool, len(configs))
+	for i, c := range configs {
+		if c.Name == "" {
+			return fmt.Errorf("config %d: name is required", i)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("config %q is listed twice", c.Name)
+		}
+		names[c.Name] = true
+		if c.TopK < 0 {
+			return fmt.Errorf("config %q: top_k must not be negative", c.Name)
+		}
+	}
+	return nil
+}
+
+// Searcher runs one retrieval; rag.Pipeline implements it.
+type Searcher interface {
+	Search(ctx context.Context, req rag.SearchRequest) ([]vectorstore.SearchResult, error)
+}
+
+// ConfigResult is the score of one configuration over the whole set.
+// Failed cases (search errors) are excluded from the means.
+type ConfigResult struct {
+	Config string `json:"config"`
+	K      int    `json:"k"`
+	Metrics
+	Latency LatencyStats `json:"latency"`
+	Failed  int          `json:"failed"`
+	Cases   []CaseResult `json:"cases"`
+}
+
+// CaseResult is the score of one configuration on one query.
+type CaseResult struct {
+	Query string `json:"query"`
+	Metrics
+	LatencyMs float64     `json:"latency_ms"`
+	Retrieved []uuid.UUID `json:"retrieved"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// Evaluate runs every case through every configuration. progress, if set,
+// is called after each configuration with the number completed.
+func Evaluate(ctx context.Context, searcher Searcher, cases []Case, configs []Config, progress func(done int)) ([]ConfigResult, error) {
+	results := make([]ConfigResult, 0, len(configs))
+	for i, cfg := range configs {
+		req := cfg.SearchRequest
+		if req.TopK <= 0 {
+			req.TopK = 10
+		}
+
+		res := ConfigResult{Config: cfg.Name, K: req.TopK, Cases: make([]CaseResult, 0, len(cases))}
+		var scores []Metrics
+		var latencies []time.Duration
+		for _, c := range cases {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			req.Query = c.Query
+			start := time.Now()
+			found, err := searcher.Search(ctx, req)
+			elapsed := time.Since(start)
+
+			cr := CaseResult{Query: c.Query, LatencyMs: millis(elapsed)}
+			if err != nil {
+				cr.Error = err.Error()
+				res.Failed++
+				res.Cases = append(res.Cases, cr)
+				continue
+			}
+			cr.Retrieved = rankedIDs(found, c.byChunk())
+			cr.Metrics = score(cr.Retrieved, c.relevant(), req.TopK)
+
+			scores = append(scores, cr.Metrics)
+			latencies = append(latencies, elapsed)
+			res.Cases = append(res.Cases, cr)
+		}
+
+		res.Metrics = mean(scores)
+		res.Latency = latencyStats(latencies)
+		results = append(results, res)
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,85 @@
+package retrievaleval
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/rag"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// fakeSearcher returns canned results per query, and an error for queries
+// it has none for.
+type fakeSearcher struct {
+	results map[string][]vectorstore.SearchResult
+	topKs   []int
+}
+
+func (f *fakeSearcher) Search(ctx context.Context, req rag.SearchRequest) ([]vectorstore.SearchResult, error) {
+	f.topKs = append(f.topKs, req.TopK)
+	res, ok := f.results[req.Query]
+	if !ok {
+		return nil, errors.New("search failed")
+	}
+	return res, nil
+}
+
+func docResults(ids ...uuid.UUID) []vectorstore.SearchResult {
+	out := make([]vectorstore.SearchResult, len(ids))
+	for i, id := range ids {
+		out[i] = vectorstore.SearchResult{ChunkID: uuid.New(), DocumentID: id}
+	}
+	return out
+}
+
+func TestEvaluate(t *testing.T) {
+	searcher := &fakeSearcher{results: map[string][]vectorstore.SearchResult{
+		"first":  docResults(idA, idB),
+		"second": docResults(idB, idC),
+	}}
+	cases := []Case{
+		{Query: "first", RelevantDocumentIDs: []uuid.UUID{idA}},
+		{Query: "second", RelevantDocumentIDs: []uuid.UUID{idC}},
+		{Query: "broken", RelevantDocumentIDs: []uuid.UUID{idA}},
+	}
+	configs := []Config{
+		{Name: "k2", SearchRequest: rag.SearchRequest{TopK: 2}},
+		{Name: "default"},
+	}
+
+	var progress []int
+	results, err := Evaluate(context.Background(), searcher, cases, configs, func(done int) {
+		progress = append(progress, done)
+	})
+	if err != nil {
+		t.Fatalf("Evaluate: %v", err)
+	}
+	if len(results) != 2 || len(progress) != 2 || progress[1] != 2 {
+		t.Fatalf("got %d results and progress %v, want 2 and [1 2]", len(results), progress)
+	}
+
+	k2 := results[0]
+	if k2.K != 2 || k2.Failed != 1 || len(k2.Cases) != 3 || k2.Cases[2].Error == "" {
+		t.Errorf("k2 = K %d, Failed %d, %d cases; want K 2, one failed case of 3", k2.K, k2.Failed, len(k2.Cases))
+	}
+	// The failed case is left out of the means: MRR is (1 + 1/2) / 2.
+	if want := (Metrics{RecallAtK: 1, PrecisionAtK: 0.5, MRR: 0.75, NDCG: (1 + 1/math.Log2(3)) / 2}); !approxEqual(k2.Metrics, want) {
+		t.Errorf("k2 metrics = %+v, want %+v", k2.Metrics, want)
+	}
+	if results[1].K != 10 || searcher.topKs[len(searcher.topKs)-1] != 10 {
+		t.Errorf("default config ran with k %d, want 10", results[1].K)
+	}
+}
+
+func TestEvaluateCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Evaluate(ctx, &fakeSearcher{}, []Case{{Query: "q", RelevantDocumentIDs: []uuid.UUID{idA}}},
+		[]Config{{Name: "vector"}}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Evaluate error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,132 @@
+package retrievaleval
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+// Metrics are the ranking scores of one query, or their mean over a set.
+// Relevance is binary.
+type Metrics struct {
+	// RecallAtK is the share of the relevant items found in the top k.
+	RecallAtK float64 `json:"recall_at_k"`
+	// PrecisionAtK is the share of the top k that is relevant.
+	PrecisionAtK float64 `json:"precision_at_k"`
+	// MRR is the reciprocal rank of the first relevant item (0 if none).
+	MRR float64 `json:"mrr"`
+	// NDCG is the normalized discounted cumulative gain at k.
+	NDCG float64 `json:"ndcg"`
+}
+
+// LatencyStats summarizes search latencies in milliseconds.
+type LatencyStats struct {
+	MeanMs float64 `json:"mean_ms"`
+	P50Ms  float64 `json:"p50_ms"`
+	P95Ms  float64 `json:"p95_ms"`
+}
+
+// rankedIDs returns the IDs relevance is judged on, in rank order. At
+// document level a document counts once, at the rank of its best chunk, so
+// several chunks of one relevant document don't inflate precision.
+func rankedIDs(results []vectorstore.SearchResult, byChunk bool) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(results))
+	seen := make(map[uuid.UUID]bool, len(results))
+	for _, r := range results {
+		id := r.DocumentID
+		if byChunk {
+			id = r.ChunkID
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// score computes the metrics of one ranking at cutoff k.
+func score(ranked []uuid.UUID, relevant map[uuid.UUID]bool, k int) Metrics {
+	if len(relevant) == 0 || k <= 0 {
+		return Metrics{}
+	}
+	if len(ranked) > k {
+		ranked = ranked[:k]
+	}
+
+	var m Metrics
+	var hits int
+	var dcg float64
+	for i, id := range ranked {
+		if !relevant[id] {
+			continue
+		}
+		hits++
+		dcg += 1 / math.Log2(float64(i+2))
+		if m.MRR == 0 {
+			m.MRR = 1 / float64(i+1)
+		}
+	}
+
+	var idcg float64
+	for i := 0; i < min(len(relevant), k); i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	m.RecallAtK = float64(hits) / float64(len(relevant))
+	m.PrecisionAtK = float64(hits) / float64(k)
+	m.NDCG = dcg / idcg
+	return m
+}
+
+// mean averages the metrics of several queries.
+func mean(all []Metrics) Metrics {
+	var m Metrics
+	if len(all) == 0 {
+		return m
+	}
+	for _, x := range all {
+		m.RecallAtK += x.RecallAtK
+		m.PrecisionAtK += x.PrecisionAtK
+		m.MRR += x.MRR
+		m.NDCG += x.NDCG
+	}
+	n := float64(len(all))
+	m.RecallAtK /= n
+	m.PrecisionAtK /= n
+	m.MRR /= n
+	m.NDCG /= n
+	return m
+}
+
+func latencyStats(durations []time.Duration) LatencyStats {
+	if len(durations) == 0 {
+		return LatencyStats{}
+	}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return LatencyStats{
+		MeanMs: millis(total / time.Duration(len(sorted))),
+		P50Ms:  millis(percentile(sorted, 0.50)),
+		P95Ms:  millis(percentile(sorted, 0.95)),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)]
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,170 @@
+package retrievaleval
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+var (
+	idA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	idB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	idC = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+	idD = uuid.MustParse("00000000-0000-0000-0000-00000000000d")
+)
+
+func set(ids ...uuid.UUID) map[uuid.UUID]bool {
+	m := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m
+}
+
+// approxEqual compares metrics to four decimal places.
+func approxEqual(a, b Metrics) bool {
+	eq := func(x, y float64) bool { return math.Abs(x-y) < 1e-4 }
+	return eq(a.RecallAtK, b.RecallAtK) && eq(a.PrecisionAtK, b.PrecisionAtK) &&
+		eq(a.MRR, b.MRR) && eq(a.NDCG, b.NDCG)
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		ranked   []uuid.UUID
+		relevant map[uuid.UUID]bool
+		k        int
+		want     Metrics
+	}{
+		{
+			name:     "all relevant found",
+			ranked:   []uuid.UUID{idA, idB, idC},
+			relevant: set(idA, idC),
+			k:        3,
+			// DCG = 1/log2(2) + 1/log2(4) = 1.5; IDCG = 1 + 1/log2(3).
+			want: Metrics{RecallAtK: 1, PrecisionAtK: 2.0 / 3, MRR: 1, NDCG: 1.5 / (1 + 1/math.Log2(3))},
+		},
+		{
+			name:     "first hit at rank three",
+			ranked:   []uuid.UUID{idA, idB, idC},
+			relevant: set(idC),
+			k:        3,
+			want:     Metrics{RecallAtK: 1, PrecisionAtK: 1.0 / 3, MRR: 1.0 / 3, NDCG: 0.5},
+		},
+		{
+			name:     "hit beyond the cutoff",
+			ranked:   []uuid.UUID{idA, idB, idC},
+			relevant: set(idC),
+			k:        2,
+			want:     Metrics{},
+		},
+		{
+			name:     "no hits",
+			ranked:   []uuid.UUID{idA, idB},
+			relevant: set(idD),
+			k:        5,
+			want:     Metrics{},
+		},
+		{
+			name:     "fewer results than k",
+			ranked:   []uuid.UUID{idA},
+			relevant: set(idA),
+			k:        5,
+			// Precision is over k, not over what was returned.
+			want: Metrics{RecallAtK: 1, PrecisionAtK: 0.2, MRR: 1, NDCG: 1},
+		},
+		{
+			name:     "more relevant items than k",
+			ranked:   []uuid.UUID{idA, idB, idD},
+			relevant: set(idA, idB, idC),
+			k:        2,
+			// The ideal ranking is cut at k too, so a full top k scores 1.
+			want: Metrics{RecallAtK: 2.0 / 3, PrecisionAtK: 1, MRR: 1, NDCG: 1},
+		},
+		{
+			name:     "second of two ranks",
+			ranked:   []uuid.UUID{idB, idA},
+			relevant: set(idA),
+			k:        2,
+			want:     Metrics{RecallAtK: 1, PrecisionAtK: 0.5, MRR: 0.5, NDCG: 1 / math.Log2(3)},
+		},
+		{
+			name:     "nothing relevant",
+			ranked:   []uuid.UUID{idA},
+			relevant: set(),
+			k:        3,
+			want:     Metrics{},
+		},
+		{
+			name:     "zero k",
+			ranked:   []uuid.UUID{idA},
+			relevant: set(idA),
+			k:        0,
+			want:     Metrics{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := score(tt.ranked, tt.relevant, tt.k); !approxEqual(got, tt.want) {
+				t.Errorf("score = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankedIDs(t *testing.T) {
+	c1, c2, c3 := uuid.New(), uuid.New(), uuid.New()
+	results := []vectorstore.SearchResult{
+		{ChunkID: c1, DocumentID: idA},
+		{ChunkID: c2, DocumentID: idB},
+		{ChunkID: c3, DocumentID: idA},
+	}
+
+	// A document counts once, at the rank of its best chunk.
+	if got := rankedIDs(results, false); !slices.Equal(got, []uuid.UUID{idA, idB}) {
+		t.Errorf("by document = %v, want [A B]", got)
+	}
+	if got := rankedIDs(results, true); !slices.Equal(got, []uuid.UUID{c1, c2, c3}) {
+		t.Errorf("by chunk = %v, want every chunk in order", got)
+	}
+}
+
+func TestMean(t *testing.T) {
+	got := mean([]Metrics{
+		{RecallAtK: 1, PrecisionAtK: 0.5, MRR: 1, NDCG: 1},
+		{RecallAtK: 0, PrecisionAtK: 0.1, MRR: 0.5, NDCG: 0.2},
+	})
+	want := Metrics{RecallAtK: 0.5, PrecisionAtK: 0.3, MRR: 0.75, NDCG: 0.6}
+	if !approxEqual(got, want) {
+		t.Errorf("mean = %+v, want %+v", got, want)
+	}
+	if got := mean(nil); got != (Metrics{}) {
+		t.Errorf("mean(nil) = %+v, want zero", got)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	var durations []time.Duration
+	for i := 10; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	got := latencyStats(durations)
+	// Nearest rank: p50 is the 5th of 10, p95 the 10th.
+	want := LatencyStats{MeanMs: 5.5, P50Ms: 5, P95Ms: 10}
+	if got != want {
+		t.Errorf("latencyStats = %+v, want %+v", got, want)
+	}
+	if durations[0] != 10*time.Millisecond {
+		t.Error("latencyStats sorted its argument in place")
+	}
+	if got := latencyStats(nil); got != (LatencyStats{}) {
+		t.Errorf("latencyStats(nil) = %+v, want zero", got)
+	}
+	if got := latencyStats([]time.Duration{1500 * time.Microsecond}); got.P50Ms != 1.5 || got.P95Ms != 1.5 {
+		t.Errorf("single latency = %+v, want 1.5 ms throughout", got)
+	}
+}
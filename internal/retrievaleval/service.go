@@ -0,0 +1,213 @@
+package retrievaleval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+)
+
+// Service runs retrieval evaluations as queued jobs and keeps their results
+// for comparison over time.
+type Service struct {
+	db       *pgxpool.Pool
+	queue    *queue.Client
+	searcher Searcher
+	users    *tenant.Service
+}
+
+func NewService(db *pgxpool.Pool, qc *queue.Client, searcher Searcher, users *tenant.Service) *Service {
+	return &Service{db: db, queue: qc, searcher: searcher, users: users}
+}
+
+type StartRequest struct {
+	// Name identifies the query set; runs with the same name are compared.
+	Name  string `json:"name"`
+	Cases []Case `json:"cases"`
+	// DatasetID takes the cases from a generated eval dataset instead; the
+	// handler resolves it before the run is queued.
+	DatasetID *uuid.UUID `json:"dataset_id,omitempty"`
+	// Configs defaults to DefaultConfigs(TopK).
+	Configs []Config `json:"configs,omitempty"`
+	TopK    int      `json:"top_k,omitempty"`
+}
+
+// Validate checks the request and fills in the default configurations.
+func (r *StartRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Configs) == 0 {
+		r.Configs = DefaultConfigs(r.TopK)
+	}
+	return Validate(r.Cases, r.Configs)
+}
+
+// runColumns selects a run with the results expression in %s: the full
+// results for a single run, a summary without per-case detail for lists.
+const runColumns = `id, tenant_id, created_by, name, jsonb_array_length(cases), configs, status, progress, %s,
+	COALESCE(error, ''), started_at, completed_at, created_at`
+
+const summaryResults = `(SELECT jsonb_agg(r - 'cases') FROM jsonb_array_elements(results) r)`
+
+func scanRun(row interface{ Scan(...any) error }) (*models.RetrievalEvalRun, error) {
+	var run models.RetrievalEvalRun
+	err := row.Scan(&run.ID, &run.TenantID, &run.CreatedBy, &run.Name, &run.CaseCount, &run.Configs, &run.Status,
+		&run.Progress, &run.Results, &run.Error, &run.StartedAt, &run.CompletedAt, &run.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// StartRun records a run and queues it for the worker. Searches run as the
+// requesting user, so document ACLs apply as they would to their queries.
+func (s *Service) StartRun(ctx context.Context, req StartRequest) (*models.RetrievalEvalRun, error) {
+	tenantID, err := tenant.RequireID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var createdBy *uuid.UUID
+	if u := tenant.UserFromContext(ctx); u != nil {
+		createdBy = &u.ID
+	}
+
+	casesJSON, err := json.Marshal(req.Cases)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cases: %w", err)
+	}
+	configsJSON, err := json.Marshal(req.Configs)
+	if err != nil {
+		return nil, fmt.Errorf("marshal configs: %w", err)
+	}
+
+	run, err := scanRun(s.db.QueryRow(ctx,
+		`INSERT INTO retrieval_eval_runs (tenant_id, created_by, name, cases, configs, status)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+fmt.Sprintf(runColumns, "results"),
+		tenantID, createdBy, req.Name, casesJSON, configsJSON, models.JobStatusPending,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("insert eval run: %w", err)
+	}
+
+	if err := s.queue.EnqueueRetrievalEval(queue.RetrievalEvalPayload{
+		RunID:    run.ID.String(),
+		TenantID: tenantID.String(),
+	}); err != nil {
+		return nil, fmt.Errorf("enqueue eval run: %w", err)
+	}
+	return run, nil
+}
+
+// ListRuns returns the tenant's runs, newest first, with summary results.
+// A non-empty name restricts the list to runs of that query set.
+func (s *Service) ListRuns(ctx context.Context, name string) ([]models.RetrievalEvalRun, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT `+fmt.Sprintf(runColumns, summaryResults)+` FROM retrieval_eval_runs
+		 WHERE tenant_id = $1 AND ($2 = '' OR name = $2)
+		 ORDER BY created_at DESC LIMIT 100`,
+		tenantID, name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list eval runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []models.RetrievalEvalRun
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan eval run: %w", err)
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+func (s *Service) GetRun(ctx context.Context, id uuid.UUID) (*models.RetrievalEvalRun, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	run, err := scanRun(s.db.QueryRow(ctx,
+		`SELECT `+fmt.Sprintf(runColumns, "results")+` FROM retrieval_eval_runs WHERE id = $1 AND tenant_id = $2`,
+		id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get eval run: %w", err)
+	}
+	return run, nil
+}
+
+// RunJob evaluates a queued run and stores its results.
+func (s *Service) RunJob(ctx context.Context, runID uuid.UUID) error {
+	var tenantID uuid.UUID
+	var createdBy *uuid.UUID
+	var casesJSON, configsJSON []byte
+	err := s.db.QueryRow(ctx,
+		`UPDATE retrieval_eval_runs SET status = $2, progress = 0, started_at = now(), error = NULL
+		 WHERE id = $1 RETURNING tenant_id, created_by, cases, configs`,
+		runID, models.JobStatusRunning,
+	).Scan(&tenantID, &createdBy, &casesJSON, &configsJSON)
+	if err != nil {
+		return fmt.Errorf("start eval run: %w", err)
+	}
+
+	var cases []Case
+	if err := json.Unmarshal(casesJSON, &cases); err != nil {
+		return s.fail(ctx, runID, fmt.Errorf("decode cases: %w", err))
+	}
+	var configs []Config
+	if err := json.Unmarshal(configsJSON, &configs); err != nil {
+		return s.fail(ctx, runID, fmt.Errorf("decode configs: %w", err))
+	}
+
+	runCtx, err := s.users.JobContext(ctx, tenantID, createdBy)
+	if err != nil {
+		return s.fail(ctx, runID, err)
+	}
+
+	progress := func(done int) {
+		if _, err := s.db.Exec(ctx,
+			"UPDATE retrieval_eval_runs SET progress = $2 WHERE id = $1",
+			runID, done*100/len(configs),
+		); err != nil {
+			slog.Warn("failed to record eval progress", "run_id", runID, "error", err)
+		}
+	}
+
+	results, err := Evaluate(runCtx, s.searcher, cases, configs, progress)
+	if err != nil {
+		return s.fail(ctx, runID, err)
+	}
+	resultsJSON, err := json.Marshal(results)
+	if err != nil {
+		return s.fail(ctx, runID, fmt.Errorf("marshal results: %w", err))
+	}
+
+	_, err = s.db.Exec(ctx,
+		`UPDATE retrieval_eval_runs SET status = $2, progress = 100, results = $3, completed_at = now()
+		 WHERE id = $1`,
+		runID, models.JobStatusSucceeded, resultsJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("complete eval run: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) fail(ctx context.Context, runID uuid.UUID, cause error) error {
+	_, err := s.db.Exec(context.WithoutCancel(ctx),
+		"UPDATE retrieval_eval_runs SET status = $2, error = $3, completed_at = now() WHERE id = $1",
+		runID, models.JobStatusFailed, cause.Error(),
+	)
+	if err != nil {
+		slog.Error("failed to record eval run failure", "run_id", runID, "error", err)
+	}
+	return cause
+}
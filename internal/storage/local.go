@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStorage keeps files under a directory, one subdirectory per bucket,
+// for development and tests. It serves presigned URLs itself: mount it
+// at the path of its base URL. Files are never served without a signature,
+// so GetPublicURL's URLs only work once signed.
+type LocalStorage struct {
+	root       string
+	baseURL    string
+	signingKey []byte
+}
+
+func NewLocalStorage(root, baseURL string, signingKey []byte) *LocalStorage {
+	return &LocalStorage{
+		root:       root,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		signingKey: signingKey,
+	}
+}
+
+// file returns the file an object is stored in, refusing bucket names and
+// paths that would leave the root.
+func (s *LocalStorage) file(bucket, path string) (string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("invalid bucket %q", bucket)
+	}
+	clean := filepath.Clean("/" + filepath.FromSlash(path))
+	if clean == string(filepath.Separator) || clean != filepath.FromSlash("/"+path) {
+		return "", fmt.Errorf("invalid path %q", path)
+	}
+	return filepath.Join(s.root, bucket, clean), nil
+}
+
+// Upload streams the data to a temporary file next to the object and
+// renames it into place, so readers never see a partial file.
+func (s *LocalStorage) Upload(ctx context.Context, bucket, path string, data io.Reader, contentType string) error {
+	name, err := s.file(bucket, path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, contextReader{ctx, data}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("store file: %w", err)
+	}
+	return nil
+}
+
+// contextReader stops a copy once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+func (s *LocalStorage) Download(ctx context.Context, bucket, path string) (io.ReadCloser, error) {
+	name, err := s.file(bucket, path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	return f, nil
+}
+
+// Delete removes the object; deleting a missing object succeeds.
+func (s *LocalStorage) Delete(ctx context.Context, bucket, path string) error {
+	name, err := s.file(bucket, path)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) GetPublicURL(bucket, path string) string {
+	return s.baseURL + "/" + url.PathEscape(bucket) + "/" + escapeObjectPath(path)
+}
+
+// PresignedURL signs the object's URL with an HMAC of the bucket, path and
+// expiry time.
+func (s *LocalStorage) PresignedURL(ctx context.Context, bucket, path string, expires time.Duration) (string, error) {
+	if len(s.signingKey) == 0 {
+		return "", fmt.Errorf("no signing key configured for local storage")
+	}
+	if _, err := s.file(bucket, path); err != nil {
+		return "", err
+	}
+	expiry := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{"expires": {expiry}, "signature": {s.sign(bucket, path, expiry)}}
+	return s.GetPublicURL(bucket, path) + "?" + query.Encode(), nil
+}
+
+func (s *LocalStorage) sign(bucket, path, expiry string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(bucket + "/" + path + "\n" + expiry))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves /<bucket>/<path> to holders of an unexpired signature.
+func (s *LocalStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bucket, path, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	expiry := r.URL.Query().Get("expires")
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if !ok || err != nil || len(s.signingKey) == 0 ||
+		!hmac.Equal([]byte(r.URL.Query().Get("signature")), []byte(s.sign(bucket, path, expiry))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expiresAt {
+		http.Error(w, "URL expired", http.StatusForbidden)
+		return
+	}
+
+	name, err := s.file(bucket, path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(name)))
+	http.ServeContent(w, r, filepath.Base(name), info.ModTime(), f)
+}
+
+// escapeObjectPath escapes each segment of an object path.
+func escapeObjectPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
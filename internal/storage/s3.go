@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nikhilbhutani/backendwithai/pkg/sigv4"
+)
+
+const (
+	// minPartSize is S3's smallest part, except for the last.
+	minPartSize     = 5 << 20
+	defaultPartSize = 16 << 20
+)
+
+// S3Config is an S3 or S3-compatible endpoint such as MinIO.
+type S3Config struct {
+	// Endpoint is the store's base URL, e.g. http://minio:9000; default:
+	// https://s3.<region>.amazonaws.com.
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// VirtualHosted addresses buckets as bucket.endpoint, as AWS prefers,
+	// instead of endpoint/bucket, which MinIO expects.
+	VirtualHosted bool
+	// PartSize is the size of multipart upload parts, and the most of an
+	// upload held in memory at once.
+	PartSize int64
+}
+
+// S3Storage stores files in S3-compatible buckets, signing requests with
+// AWS Signature Version 4. Uploads larger than one part use multipart
+// upload, so no more than a part is buffered.
+type S3Storage struct {
+	cfg        S3Config
+	httpClient *http.Client
+	// parts recycles part-sized upload buffers (*[]byte) between uploads.
+	parts sync.Pool
+}
+
+func NewS3Storage(cfg S3Config) *S3Storage {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	if cfg.PartSize <= 0 {
+		cfg.PartSize = defaultPartSize
+	}
+	cfg.PartSize = max(cfg.PartSize, minPartSize)
+	return &S3Storage{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Minute},
+		parts: sync.Pool{New: func() any {
+			part := make([]byte, cfg.PartSize)
+			return &part
+		}},
+	}
+}
+
+func (s *S3Storage) credentials() sigv4.Credentials {
+	return sigv4.Credentials{AccessKeyID: s.cfg.AccessKeyID, SecretAccessKey: s.cfg.SecretAccessKey}
+}
+
+// objectURL returns the URL of an object, with its path escaped the way
+// SigV4 signs it.
+func (s *S3Storage) objectURL(bucket, key string, query url.Values) (*url.URL, error) {
+	u, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parse endpoint: %w", err)
+	}
+	objectPath := "/" + key
+	if s.cfg.VirtualHosted {
+		u.Host = bucket + "." + u.Host
+	} else {
+		objectPath = "/" + bucket + objectPath
+	}
+	u.Path = objectPath
+	u.RawPath = sigv4.EscapePath(objectPath)
+	u.RawQuery = sigv4.CanonicalQuery(query)
+	return u, nil
+}
+
+// do sends a signed request and returns the response if it succeeded.
+func (s *S3Storage) do(ctx context.Context, method, bucket, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u, err := s.objectURL(bucket, key, query)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	payloadHash := sigv4.EmptyPayloadHash
+	if len(body) > 0 {
+		payloadHash = sigv4.HashHex(body)
+	}
+	if s.cfg.AccessKeyID != "" {
+		sigv4.Sign(req, s.credentials(), s.cfg.Region, "s3", payloadHash, time.Now())
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("request failed (%d): %s", resp.StatusCode, string(msg))
+	}
+	return resp, nil
+}
+
+// Upload reads the data a part at a time: data that fits in one part is
+// sent with PutObject, anything larger with multipart upload. Data whose
+// length is known and below a part is read into a buffer of its size;
+// otherwise part buffers come from a pool.
+func (s *S3Storage) Upload(ctx context.Context, bucket, path string, data io.Reader, contentType string) error {
+	if size, ok := remaining(data); ok && size < s.cfg.PartSize {
+		// One byte more than reported tells whether the length was right.
+		buf := make([]byte, size+1)
+		n, err := io.ReadFull(data, buf)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return s.putObject(ctx, bucket, path, buf[:n], contentType)
+		}
+		if err != nil {
+			return fmt.Errorf("read upload data: %w", err)
+		}
+		data = io.MultiReader(bytes.NewReader(buf), data)
+	}
+
+	part := s.parts.Get().(*[]byte)
+	n, err := io.ReadFull(data, *part)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = s.putObject(ctx, bucket, path, (*part)[:n], contentType)
+		s.releasePart(part, err)
+		return err
+	}
+	if err != nil {
+		s.parts.Put(part)
+		return fmt.Errorf("read upload data: %w", err)
+	}
+
+	uploadID, err := s.createMultipart(ctx, bucket, path, contentType)
+	if err != nil {
+		s.parts.Put(part)
+		return err
+	}
+	err = s.uploadParts(ctx, bucket, path, uploadID, *part, data)
+	s.releasePart(part, err)
+	if err != nil {
+		// Abandoned parts are billed until the upload is aborted.
+		if resp, abortErr := s.do(context.WithoutCancel(ctx), http.MethodDelete, bucket, path,
+			url.Values{"uploadId": {uploadID}}, nil, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) putObject(ctx context.Context, bucket, path string, body []byte, contentType string) error {
+	resp, err := s.do(ctx, http.MethodPut, bucket, path, nil, body, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return fmt.Errorf("upload file: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// releasePart returns a part buffer to the pool after a successful upload.
+// After a failed request the transport may still be reading the buffer, so
+// it is left to the garbage collector.
+func (s *S3Storage) releasePart(part *[]byte, err error) {
+	if err == nil {
+		s.parts.Put(part)
+	}
+}
+
+// remaining reports how many bytes are left to read from data, for readers
+// that can tell: in-memory readers and seekable files.
+func remaining(data io.Reader) (int64, bool) {
+	switch r := data.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len()), true
+	case io.Seeker:
+		cur, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := r.Seek(cur, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - cur, true
+	}
+	return 0, false
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *S3Storage) createMultipart(ctx context.Context, bucket, path, contentType string) (string, error) {
+	resp, err := s.do(ctx, http.MethodPost, bucket, path, url.Values{"uploads": {""}}, nil,
+		http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return "", fmt.Errorf("start multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil || result.UploadID == "" {
+		return "", fmt.Errorf("decode multipart upload: %v", err)
+	}
+	return result.UploadID, nil
+}
+
+// uploadParts sends the first part, already read, then the rest of the
+// data a part at a time, and completes the upload.
+func (s *S3Storage) uploadParts(ctx context.Context, bucket, path, uploadID string, part []byte, data io.Reader) error {
+	var parts []completedPart
+	n := len(part)
+	for number := 1; n > 0; number++ {
+		resp, err := s.do(ctx, http.MethodPut, bucket, path,
+			url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}, part[:n], nil)
+		if err != nil {
+			return fmt.Errorf("upload part %d: %w", number, err)
+		}
+		resp.Body.Close()
+		parts = append(parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		var readErr error
+		n, readErr = io.ReadFull(data, part)
+		if readErr != nil && !errors.Is(readErr, io.EOF) && !errors.Is(readErr, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("read upload data: %w", readErr)
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return fmt.Errorf("encode multipart completion: %w", err)
+	}
+	resp, err := s.do(ctx, http.MethodPost, bucket, path, url.Values{"uploadId": {uploadID}}, body,
+		http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return fmt.Errorf("complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+	// S3 may report a failed completion in a 200 response.
+	result, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if bytes.Contains(result, []byte("<Error>")) {
+		return fmt.Errorf("complete multipart upload: %s", string(result))
+	}
+	return nil
+}
+
+func (s *S3Storage) Download(ctx context.Context, bucket, path string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, bucket, path, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("download file: %w", err)
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, bucket, path string) error {
+	resp, err := s.do(ctx, http.MethodDelete, bucket, path, nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("delete file: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) GetPublicURL(bucket, path string) string {
+	u, err := s.objectURL(bucket, path, nil)
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// PresignedURL signs a GetObject URL in its query string; it is valid for
+// at most seven days. Without credentials it is the public URL.
+func (s *S3Storage) PresignedURL(ctx context.Context, bucket, path string, expires time.Duration) (string, error) {
+	u, err := s.objectURL(bucket, path, nil)
+	if err != nil {
+		return "", err
+	}
+	if s.cfg.AccessKeyID == "" {
+		return u.String(), nil
+	}
+	expires = min(expires, sigv4.MaxPresignExpiry)
+	return sigv4.Presign(http.MethodGet, u, s.credentials(), s.cfg.Region, "s3", expires, time.Now()), nil
+}
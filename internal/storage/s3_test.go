@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is an in-memory stand-in for the subset of the S3 API that
+// S3Storage uses, with path-style addressing.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+	uploads map[string]map[int][]byte
+	nextID  int
+	// failPart makes uploads of this part number fail.
+	failPart int
+	requests []string
+	aborted  []string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *S3Storage) {
+	t.Helper()
+	f := &fakeS3{
+		objects: map[string][]byte{},
+		types:   map[string]string{},
+		uploads: map[string]map[int][]byte{},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	s := NewS3Storage(S3Config{
+		Endpoint:        srv.URL,
+		AccessKeyID:     "test",
+		SecretAccessKey: "secret",
+		PartSize:        minPartSize,
+	})
+	return f, s
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := r.URL.Path
+	q := r.URL.Query()
+	uploadID := q.Get("uploadId")
+	_, initiate := q["uploads"]
+
+	switch {
+	case r.Method == http.MethodPost && initiate:
+		f.requests = append(f.requests, "initiate")
+		f.nextID++
+		id := "upload-" + strconv.Itoa(f.nextID)
+		f.uploads[id] = map[int][]byte{}
+		f.types[key] = r.Header.Get("Content-Type")
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+
+	case r.Method == http.MethodPut && uploadID != "":
+		number, _ := strconv.Atoi(q.Get("partNumber"))
+		f.requests = append(f.requests, "part "+strconv.Itoa(number))
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		if number == f.failPart {
+			http.Error(w, "part rejected", http.StatusInternalServerError)
+			return
+		}
+		parts[number] = body
+		w.Header().Set("ETag", fmt.Sprintf(`"etag-%d"`, number))
+
+	case r.Method == http.MethodPost && uploadID != "":
+		f.requests = append(f.requests, "complete")
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			http.Error(w, "no such upload", http.StatusNotFound)
+			return
+		}
+		var completion struct {
+			Parts []completedPart `xml:"Part"`
+		}
+		if err := xml.Unmarshal(body, &completion); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var object []byte
+		for i, p := range completion.Parts {
+			if p.PartNumber != i+1 || p.ETag != fmt.Sprintf(`"etag-%d"`, p.PartNumber) {
+				fmt.Fprintf(w, "<Error><Code>InvalidPart</Code></Error>")
+				return
+			}
+			object = append(object, parts[p.PartNumber]...)
+		}
+		f.objects[key] = object
+		delete(f.uploads, uploadID)
+		fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+
+	case r.Method == http.MethodDelete && uploadID != "":
+		f.requests = append(f.requests, "abort")
+		f.aborted = append(f.aborted, uploadID)
+		delete(f.uploads, uploadID)
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut:
+		f.requests = append(f.requests, "put")
+		f.objects[key] = body
+		f.types[key] = r.Header.Get("Content-Type")
+
+	case r.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+
+	case r.Method == http.MethodDelete:
+		f.requests = append(f.requests, "delete")
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "unsupported request", http.StatusMethodNotAllowed)
+	}
+}
+
+// payload returns n bytes of non-repeating-per-part content, so misordered
+// parts are caught.
+func payload(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i / 1024)
+	}
+	return data
+}
+
+// streamOnly hides Len and Seek so Upload cannot learn the length.
+type streamOnly struct{ io.Reader }
+
+func TestS3Upload(t *testing.T) {
+	ctx := context.Background()
+	part := int(minPartSize)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		reader   func([]byte) io.Reader
+		requests []string
+	}{
+		{
+			name:     "small known length",
+			data:     []byte("hello"),
+			reader:   func(b []byte) io.Reader { return bytes.NewReader(b) },
+			requests: []string{"put"},
+		},
+		{
+			name:     "empty",
+			data:     []byte{},
+			reader:   func(b []byte) io.Reader { return bytes.NewReader(b) },
+			requests: []string{"put"},
+		},
+		{
+			name:     "small unknown length",
+			data:     []byte("hello"),
+			reader:   func(b []byte) io.Reader { return streamOnly{bytes.NewReader(b)} },
+			requests: []string{"put"},
+		},
+		{
+			name:     "exactly one part",
+			data:     payload(part),
+			reader:   func(b []byte) io.Reader { return bytes.NewReader(b) },
+			requests: []string{"initiate", "part 1", "complete"},
+		},
+		{
+			name:     "multipart known length",
+			data:     payload(2*part + part/2),
+			reader:   func(b []byte) io.Reader { return bytes.NewReader(b) },
+			requests: []string{"initiate", "part 1", "part 2", "part 3", "complete"},
+		},
+		{
+			name:     "multipart unknown length",
+			data:     payload(part + 1),
+			reader:   func(b []byte) io.Reader { return streamOnly{bytes.NewReader(b)} },
+			requests: []string{"initiate", "part 1", "part 2", "complete"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, s := newFakeS3(t)
+			if err := s.Upload(ctx, "docs", "a/file.bin", tt.reader(tt.data), "application/pdf"); err != nil {
+				t.Fatalf("Upload: %v", err)
+			}
+			if !slices.Equal(f.requests, tt.requests) {
+				t.Errorf("requests = %v, want %v", f.requests, tt.requests)
+			}
+			if got := f.objects["/docs/a/file.bin"]; !bytes.Equal(got, tt.data) {
+				t.Errorf("stored %d bytes, want %d matching bytes", len(got), len(tt.data))
+			}
+			if got := f.types["/docs/a/file.bin"]; got != "application/pdf" {
+				t.Errorf("content type = %q", got)
+			}
+		})
+	}
+}
+
+// shortLen under-reports its length, as a reader shared with another
+// consumer might.
+type shortLen struct{ *bytes.Reader }
+
+func (r shortLen) Len() int { return 1 }
+
+func TestS3UploadWrongLength(t *testing.T) {
+	f, s := newFakeS3(t)
+	data := payload(int(minPartSize) + 10)
+	if err := s.Upload(context.Background(), "docs", "k", shortLen{bytes.NewReader(data)}, "text/plain"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	if got := f.objects["/docs/k"]; !bytes.Equal(got, data) {
+		t.Errorf("stored %d bytes, want %d matching bytes", len(got), len(data))
+	}
+}
+
+func TestS3UploadAbortsFailedMultipart(t *testing.T) {
+	f, s := newFakeS3(t)
+	f.failPart = 2
+	err := s.Upload(context.Background(), "docs", "k", bytes.NewReader(payload(3*int(minPartSize))), "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "upload part 2") {
+		t.Fatalf("Upload error = %v, want a part 2 failure", err)
+	}
+	if len(f.aborted) != 1 || len(f.uploads) != 0 {
+		t.Errorf("aborted = %v, open uploads = %d; want the upload aborted", f.aborted, len(f.uploads))
+	}
+	if _, ok := f.objects["/docs/k"]; ok {
+		t.Error("failed upload left an object")
+	}
+}
+
+func TestS3DownloadDelete(t *testing.T) {
+	ctx := context.Background()
+	f, s := newFakeS3(t)
+	if err := s.Upload(ctx, "docs", "dir/name with space.txt", strings.NewReader("content"), "text/plain"); err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+
+	rc, err := s.Download(ctx, "docs", "dir/name with space.txt")
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(got) != "content" {
+		t.Fatalf("Download = %q, %v", got, err)
+	}
+
+	if err := s.Delete(ctx, "docs", "dir/name with space.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(f.objects) != 0 {
+		t.Errorf("objects after delete = %d", len(f.objects))
+	}
+	if _, err := s.Download(ctx, "docs", "dir/name with space.txt"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Download after delete error = %v, want a 404", err)
+	}
+}
@@ -0,0 +1,40 @@
+// Package storage stores uploaded files in Supabase Storage, on the local
+// filesystem or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+)
+
+type Storage interface {
+	Upload(ctx context.Context, bucket, path string, data io.Reader, contentType string) error
+	Download(ctx context.Context, bucket, path string) (io.ReadCloser, error)
+	Delete(ctx context.Context, bucket, path string) error
+	GetPublicURL(bucket, path string) string
+	// PresignedURL returns a URL anyone can download the object from until
+	// expires has passed.
+	PresignedURL(ctx context.Context, bucket, path string, expires time.Duration) (string, error)
+}
+
+// NewFromConfig returns the backend cfg.Backend selects.
+func NewFromConfig(cfg config.StorageConfig) Storage {
+	switch cfg.Backend {
+	case "local":
+		return NewLocalStorage(cfg.LocalDir, cfg.LocalBaseURL, []byte(cfg.SigningKey))
+	case "s3":
+		return NewS3Storage(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			VirtualHosted:   cfg.S3VirtualHosted,
+			PartSize:        cfg.S3PartSize,
+		})
+	default:
+		return NewSupabaseStorage(cfg.SupabaseURL, cfg.SupabaseKey)
+	}
+}
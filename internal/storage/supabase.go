@@ -3,19 +3,13 @@ package storage
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"time"
 )
 
-type Storage interface {
-	Upload(ctx context.Context, bucket, path string, data io.Reader, contentType string) error
-	Download(ctx context.Context, bucket, path string) (io.ReadCloser, error)
-	Delete(ctx context.Context, bucket, path string) error
-	GetPublicURL(bucket, path string) string
-}
-
 type SupabaseStorage struct {
 	baseURL    string
 	serviceKey string
@@ -33,12 +27,8 @@ func NewSupabaseStorage(supabaseURL, serviceKey string) *SupabaseStorage {
 func (s *SupabaseStorage) Upload(ctx context.Context, bucket, path string, data io.Reader, contentType string) error {
 	url := fmt.Sprintf("%s/object/%s/%s", s.baseURL, bucket, path)
 
-	buf := &bytes.Buffer{}
-	if _, err := io.Copy(buf, data); err != nil {
-		return fmt.Errorf("read upload data: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, buf)
+	// The body is streamed with chunked encoding rather than buffered.
+	req, err := http.NewRequestWithContext(ctx, "POST", url, data)
 	if err != nil {
 		return fmt.Errorf("create upload request: %w", err)
 	}
@@ -106,3 +96,34 @@ func (s *SupabaseStorage) Delete(ctx context.Context, bucket, path string) error
 func (s *SupabaseStorage) GetPublicURL(bucket, path string) string {
 	return fmt.Sprintf("%s/object/public/%s/%s", s.baseURL, bucket, path)
 }
+
+// PresignedURL asks Supabase for a signed URL to the object.
+func (s *SupabaseStorage) PresignedURL(ctx context.Context, bucket, path string, expires time.Duration) (string, error) {
+	url := fmt.Sprintf("%s/object/sign/%s/%s", s.baseURL, bucket, path)
+	body, _ := json.Marshal(map[string]int{"expiresIn": int(expires.Seconds())})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("create sign request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.serviceKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("sign file URL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		msg, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("sign failed (%d): %s", resp.StatusCode, string(msg))
+	}
+	var signed struct {
+		SignedURL string `json:"signedURL"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return "", fmt.Errorf("decode signed URL: %w", err)
+	}
+	return s.baseURL + signed.SignedURL, nil
+}
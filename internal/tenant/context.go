@@ -2,6 +2,7 @@ package tenant
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
 	"github.com/nikhilbhutani/backendwithai/internal/models"
@@ -30,6 +31,19 @@ func IDFromContext(ctx context.Context) uuid.UUID {
 	return uuid.Nil
 }
 
+// ErrNoTenant is returned by operations that must be scoped to a tenant when
+// the context carries none.
+var ErrNoTenant = errors.New("no tenant in context")
+
+// RequireID returns the context's tenant ID, or ErrNoTenant.
+func RequireID(ctx context.Context) (uuid.UUID, error) {
+	id := IDFromContext(ctx)
+	if id == uuid.Nil {
+		return uuid.Nil, ErrNoTenant
+	}
+	return id, nil
+}
+
 func WithUser(ctx context.Context, u *models.User) context.Context {
 	return context.WithValue(ctx, userKey, u)
 }
@@ -38,3 +52,14 @@ func UserFromContext(ctx context.Context) *models.User {
 	u, _ := ctx.Value(userKey).(*models.User)
 	return u
 }
+
+// PrincipalsFromContext returns the IDs document ACLs are checked against:
+// the user's own, their role's and their groups'. Without a user (e.g. a
+// tenant-wide API key) it is empty, and only unrestricted documents are
+// readable.
+func PrincipalsFromContext(ctx context.Context) []uuid.UUID {
+	if u := UserFromContext(ctx); u != nil {
+		return u.Principals()
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package tenant
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+)
+
+// Groups are sets of users that document ACLs can grant access to. All
+// group operations are scoped to the tenant in the context.
+
+func (s *Service) CreateGroup(ctx context.Context, name string) (*models.Group, error) {
+	g := models.Group{MemberIDs: []uuid.UUID{}}
+	err := s.db.QueryRow(ctx,
+		`INSERT INTO user_groups (tenant_id, name) VALUES ($1, $2)
+		 RETURNING id, tenant_id, name, created_at`,
+		IDFromContext(ctx), name,
+	).Scan(&g.ID, &g.TenantID, &g.Name, &g.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("create group: %w", err)
+	}
+	return &g, nil
+}
+
+func (s *Service) ListGroups(ctx context.Context) ([]models.Group, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT g.id, g.tenant_id, g.name, g.created_at,
+		        COALESCE(array_agg(m.user_id) FILTER (WHERE m.user_id IS NOT NULL), '{}')
+		 FROM user_groups g
+		 LEFT JOIN user_group_members m ON m.group_id = g.id
+		 WHERE g.tenant_id = $1
+		 GROUP BY g.id
+		 ORDER BY g.name`,
+		IDFromContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err := rows.Scan(&g.ID, &g.TenantID, &g.Name, &g.CreatedAt, &g.MemberIDs); err != nil {
+			return nil, fmt.Errorf("scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+func (s *Service) DeleteGroup(ctx context.Context, id uuid.UUID) error {
+	tag, err := s.db.Exec(ctx, "DELETE FROM user_groups WHERE id = $1 AND tenant_id = $2", id, IDFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("delete group: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("group %s not found", id)
+	}
+	return nil
+}
+
+// AddGroupMember adds a user of the same tenant to the group.
+func (s *Service) AddGroupMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	tag, err := s.db.Exec(ctx,
+		`INSERT INTO user_group_members (group_id, user_id)
+		 SELECT g.id, u.id FROM user_groups g
+		 JOIN users u ON u.tenant_id = g.tenant_id
+		 WHERE g.id = $1 AND u.id = $2 AND g.tenant_id = $3
+		 ON CONFLICT DO NOTHING`,
+		groupID, userID, IDFromContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("add group member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		// Either already a member or the group/user is not in this tenant.
+		var exists bool
+		err := s.db.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM user_group_members m JOIN user_groups g ON g.id = m.group_id
+			 WHERE m.group_id = $1 AND m.user_id = $2 AND g.tenant_id = $3)`,
+			groupID, userID, IDFromContext(ctx),
+		).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("add group member: %w", err)
+		}
+		if !exists {
+			return fmt.Errorf("group or user not found")
+		}
+	}
+	return nil
+}
+
+func (s *Service) RemoveGroupMember(ctx context.Context, groupID, userID uuid.UUID) error {
+	tag, err := s.db.Exec(ctx,
+		`DELETE FROM user_group_members m USING user_groups g
+		 WHERE m.group_id = g.id AND g.id = $1 AND g.tenant_id = $2 AND m.user_id = $3`,
+		groupID, IDFromContext(ctx), userID,
+	)
+	if err != nil {
+		return fmt.Errorf("remove group member: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user %s is not in group %s", userID, groupID)
+	}
+	return nil
+}
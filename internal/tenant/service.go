@@ -60,5 +60,30 @@ func (s *Service) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User,
 	if err != nil {
 		return nil, fmt.Errorf("get user: %w", err)
 	}
+
+	err = s.db.QueryRow(ctx,
+		"SELECT COALESCE(array_agg(group_id), '{}') FROM user_group_members WHERE user_id = $1", id,
+	).Scan(&u.GroupIDs)
+	if err != nil {
+		return nil, fmt.Errorf("get user groups: %w", err)
+	}
 	return &u, nil
 }
+
+// JobContext scopes a queued job to the tenant it was started in and, while
+// they still exist, the principals of the user who started it, so the job
+// sees the documents they could.
+func (s *Service) JobContext(ctx context.Context, tenantID uuid.UUID, userID *uuid.UUID) (context.Context, error) {
+	ctx = WithTenant(ctx, &models.Tenant{ID: tenantID})
+	if userID == nil {
+		return ctx, nil
+	}
+	u, err := s.GetUserByID(ctx, *userID)
+	if err != nil {
+		return nil, fmt.Errorf("load job user: %w", err)
+	}
+	if u.TenantID != tenantID {
+		return nil, fmt.Errorf("job user %s belongs to another tenant", u.ID)
+	}
+	return WithUser(ctx, u), nil
+}
@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Archive formats a batch accepts.
+const (
+	formatZip   = "zip"
+	formatTar   = "tar"
+	formatTarGz = "tar.gz"
+)
+
+// ErrUnsupportedArchive is returned for batches that aren't ZIP or
+// (gzipped) tar archives.
+var ErrUnsupportedArchive = errors.New("unsupported archive; send a ZIP, tar or tar.gz file")
+
+// archiveFormat identifies an archive from its first 512 bytes.
+func archiveFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return formatZip
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		return formatTarGz
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return formatTar
+	}
+	return ""
+}
+
+// walkArchive calls fn for each regular file in an archive, with its
+// cleaned path and declared size, until fn returns an error. Directories,
+// links and hidden or macOS metadata files are passed over.
+func walkArchive(f *os.File, size int64, fn func(name string, size int64, r io.Reader) error) error {
+	head := make([]byte, 512)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read archive: %w", err)
+	}
+
+	switch archiveFormat(head[:n]) {
+	case formatZip:
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return fmt.Errorf("open zip archive: %w", err)
+		}
+		for _, entry := range zr.File {
+			name, ok := entryName(entry.Name)
+			if !ok || !entry.Mode().IsRegular() {
+				continue
+			}
+			rc, err := entry.Open()
+			if err != nil {
+				return fmt.Errorf("open %s: %w", name, err)
+			}
+			err = fn(name, int64(entry.UncompressedSize64), rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case formatTar, formatTarGz:
+		var r io.Reader = io.NewSectionReader(f, 0, size)
+		if head[0] == 0x1f {
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				return fmt.Errorf("open gzip archive: %w", err)
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read tar archive: %w", err)
+			}
+			name, ok := entryName(hdr.Name)
+			if !ok || hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := fn(name, hdr.Size, tr); err != nil {
+				return err
+			}
+		}
+	}
+	return ErrUnsupportedArchive
+}
+
+// entryName cleans an entry's path, and reports whether it is worth
+// reading: hidden files and the metadata macOS adds to archives aren't.
+func entryName(name string) (string, bool) {
+	name = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, `\`, "/")), "/")
+	if name == "" {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return "", false
+		}
+	}
+	return name, true
+}
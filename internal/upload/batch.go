@@ -0,0 +1,472 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/jackc/pgx/v5"
+	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
+)
+
+type BatchRequest struct {
+	Filename string
+	Data     io.Reader
+	Options  DocumentOptions
+}
+
+const batchColumns = `id, tenant_id, created_by, filename, options, status, COALESCE(error, ''), created_at, completed_at`
+
+func scanBatch(row interface{ Scan(...any) error }) (*models.DocumentBatch, error) {
+	var b models.DocumentBatch
+	err := row.Scan(&b.ID, &b.TenantID, &b.CreatedBy, &b.Filename, &b.Options, &b.Status, &b.Error, &b.CreatedAt,
+		&b.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CreateBatch stores an archive and queues its expansion into documents.
+func (s *Service) CreateBatch(ctx context.Context, req BatchRequest) (*models.DocumentBatch, error) {
+	data := bufio.NewReaderSize(req.Data, 512)
+	head, _ := data.Peek(512)
+	format := archiveFormat(head)
+	if format == "" {
+		return nil, ErrUnsupportedArchive
+	}
+
+	tenantID := tenant.IDFromContext(ctx)
+	var userID *uuid.UUID
+	if user := tenant.UserFromContext(ctx); user != nil {
+		userID = &user.ID
+	}
+	options, err := json.Marshal(req.Options)
+	if err != nil {
+		return nil, fmt.Errorf("marshal batch options: %w", err)
+	}
+	filename := strings.TrimSpace(req.Filename)
+	if filename == "" {
+		filename = "archive." + format
+	}
+
+	id := uuid.New()
+	path := fmt.Sprintf("%s/batches/%s.%s", tenantID, id, format)
+	if err := s.store.Upload(ctx, s.bucket, path, data, "application/octet-stream"); err != nil {
+		return nil, fmt.Errorf("upload to storage: %w", err)
+	}
+
+	batch, err := scanBatch(s.db.QueryRow(ctx,
+		`INSERT INTO document_batches (id, tenant_id, created_by, filename, file_path, options)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING `+batchColumns,
+		id, tenantID, userID, filename, path, options,
+	))
+	if err != nil {
+		_ = s.store.Delete(context.WithoutCancel(ctx), s.bucket, path)
+		return nil, fmt.Errorf("insert document batch: %w", err)
+	}
+
+	if err := s.queue.EnqueueDocumentBatchExpand(queue.DocumentBatchExpandPayload{
+		BatchID:  batch.ID.String(),
+		TenantID: tenantID.String(),
+	}); err != nil {
+		s.fail(ctx, batch.ID, err)
+		return nil, fmt.Errorf("enqueue document batch: %w", err)
+	}
+	return batch, nil
+}
+
+// ListBatches returns the tenant's batches, newest first, without their
+// progress.
+func (s *Service) ListBatches(ctx context.Context, limit, offset int) ([]models.DocumentBatch, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+batchColumns+` FROM document_batches WHERE tenant_id = $1
+		 ORDER BY created_at DESC LIMIT $2 OFFSET $3`,
+		tenant.IDFromContext(ctx), limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list document batches: %w", err)
+	}
+	defer rows.Close()
+
+	batches := []models.DocumentBatch{}
+	for rows.Next() {
+		b, err := scanBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan document batch: %w", err)
+		}
+		batches = append(batches, *b)
+	}
+	return batches, rows.Err()
+}
+
+// GetBatch returns one of the tenant's batches with its progress.
+func (s *Service) GetBatch(ctx context.Context, id uuid.UUID) (*models.DocumentBatch, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	batch, err := scanBatch(s.db.QueryRow(ctx,
+		`SELECT `+batchColumns+` FROM document_batches WHERE id = $1 AND tenant_id = $2`, id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get document batch: %w", err)
+	}
+
+	progress := &models.BatchProgress{Documents: map[string]int{}}
+	if err := s.db.QueryRow(ctx,
+		`SELECT count(*),
+		        count(*) FILTER (WHERE status = $2),
+		        count(*) FILTER (WHERE status = $3),
+		        count(*) FILTER (WHERE status = $4)
+		 FROM document_batch_entries WHERE batch_id = $1`,
+		id, models.BatchEntryCreated, models.BatchEntrySkipped, models.BatchEntryFailed,
+	).Scan(&progress.Entries, &progress.Created, &progress.Skipped, &progress.Failed); err != nil {
+		return nil, fmt.Errorf("count batch entries: %w", err)
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT status, count(*) FROM documents WHERE batch_id = $1 AND tenant_id = $2 GROUP BY status`, id, tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("count batch documents: %w", err)
+	}
+	defer rows.Close()
+	total, finished := 0, 0
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("scan batch documents: %w", err)
+		}
+		progress.Documents[status] = count
+		total += count
+		if status == models.DocStatusReady || status == models.DocStatusFailed {
+			finished += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("count batch documents: %w", err)
+	}
+
+	if total > 0 {
+		progress.Percent = float64(finished) * 100 / float64(total)
+	}
+	expanded := batch.Status == models.BatchStatusExpanded || batch.Status == models.BatchStatusFailed
+	progress.Done = expanded && finished == total
+	batch.Progress = progress
+	return batch, nil
+}
+
+// BatchEntries lists what became of each file in a batch's archive; status
+// optionally keeps the entries with that status.
+func (s *Service) BatchEntries(ctx context.Context, id uuid.UUID, status string, limit, offset int) ([]models.BatchEntry, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT e.path, e.size, e.status, e.document_id, COALESCE(d.status, ''), COALESCE(e.error, '')
+		 FROM document_batch_entries e
+		 JOIN document_batches b ON b.id = e.batch_id
+		 LEFT JOIN documents d ON d.id = e.document_id
+		 WHERE e.batch_id = $1 AND b.tenant_id = $2 AND ($3 = '' OR e.status = $3)
+		 ORDER BY e.path LIMIT $4 OFFSET $5`,
+		id, tenantID, status, limit, offset,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list batch entries: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.BatchEntry{}
+	for rows.Next() {
+		var e models.BatchEntry
+		if err := rows.Scan(&e.Path, &e.Size, &e.Status, &e.DocumentID, &e.DocumentStatus, &e.Error); err != nil {
+			return nil, fmt.Errorf("scan batch entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// Expand creates a document for each supported file in a batch's archive
+// and queues it for processing. Files already handled by an earlier,
+// interrupted expansion are passed over. Unsupported and oversized files
+// are recorded as skipped. The archive is deleted once it is expanded.
+func (s *Service) Expand(ctx context.Context, tenantID, id uuid.UUID) error {
+	// An expanding batch is one whose last attempt was interrupted.
+	batch, err := scanBatch(s.db.QueryRow(ctx,
+		`UPDATE document_batches SET status = $3 WHERE id = $1 AND tenant_id = $2 AND status IN ($3, $4)
+		 RETURNING `+batchColumns,
+		id, tenantID, models.BatchStatusExpanding, models.BatchStatusPending,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		slog.Info("document batch is already expanded or was deleted", "batch_id", id)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("claim document batch: %w", err)
+	}
+	var filePath string
+	if err := s.db.QueryRow(ctx, `SELECT COALESCE(file_path, '') FROM document_batches WHERE id = $1`, id).
+		Scan(&filePath); err != nil {
+		return s.retryOrFail(ctx, id, fmt.Errorf("get batch archive: %w", err))
+	}
+
+	// Documents are created as the user who uploaded the archive.
+	jobCtx, err := s.users.JobContext(ctx, tenantID, batch.CreatedBy)
+	if err != nil {
+		s.fail(ctx, id, err)
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	ctx = jobCtx
+	var opts DocumentOptions
+	if err := json.Unmarshal(batch.Options, &opts); err != nil {
+		s.fail(ctx, id, err)
+		return fmt.Errorf("decode batch options: %w: %w", err, asynq.SkipRetry)
+	}
+
+	archive, size, err := s.download(ctx, filePath)
+	if err != nil {
+		return s.retryOrFail(ctx, id, err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	rows, err := s.db.Query(ctx, `SELECT path, status FROM document_batch_entries WHERE batch_id = $1`, id)
+	if err != nil {
+		return s.retryOrFail(ctx, id, fmt.Errorf("list batch entries: %w", err))
+	}
+	recorded, err := pgx.CollectRows(rows, pgx.RowToStructByPos[struct{ Path, Status string }])
+	if err != nil {
+		return s.retryOrFail(ctx, id, fmt.Errorf("list batch entries: %w", err))
+	}
+	// A pending entry was being ingested when the last attempt stopped; its
+	// document may or may not have been created.
+	seen := make(map[string]bool, len(recorded))
+	interrupted := make(map[string]bool)
+	for _, e := range recorded {
+		seen[e.Path] = true
+		if e.Status == models.BatchEntryPending {
+			interrupted[e.Path] = true
+		}
+	}
+
+	var recordErr error
+	errTooManyEntries := fmt.Errorf("the archive has more than %d files; the rest were not read", s.cfg.ArchiveMaxEntries)
+	err = walkArchive(archive, size, func(name string, size int64, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		resumed := interrupted[name]
+		if seen[name] && !resumed {
+			return nil
+		}
+		if !resumed {
+			if len(seen) >= s.cfg.ArchiveMaxEntries {
+				return errTooManyEntries
+			}
+			seen[name] = true
+			// The entry is recorded before its document is created, so a
+			// crash in between leaves a pending entry to resume from.
+			if _, err := s.db.Exec(ctx,
+				`INSERT INTO document_batch_entries (batch_id, path, size, status) VALUES ($1, $2, $3, $4)`,
+				id, name, size, models.BatchEntryPending,
+			); err != nil {
+				recordErr = fmt.Errorf("record batch entry: %w", err)
+				return recordErr
+			}
+		}
+		status, docID, entryErr := s.expandEntry(ctx, batch, opts, name, size, r, resumed)
+		if entryErr != nil {
+			slog.Warn("batch entry not ingested", "batch_id", id, "path", name, "error", entryErr)
+		}
+		var msg *string
+		if entryErr != nil {
+			m := entryErr.Error()
+			msg = &m
+		}
+		if _, err := s.db.Exec(context.WithoutCancel(ctx),
+			`UPDATE document_batch_entries SET status = $3, document_id = $4, error = $5 WHERE batch_id = $1 AND path = $2`,
+			id, name, status, docID, msg,
+		); err != nil {
+			recordErr = fmt.Errorf("record batch entry: %w", err)
+			return recordErr
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errTooManyEntries):
+		// What was read is kept; the batch notes what wasn't.
+		s.complete(ctx, id, filePath, err)
+	case recordErr != nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return s.retryOrFail(ctx, id, err)
+	case err != nil:
+		// A corrupt or unsupported archive fails the same way every time.
+		s.fail(ctx, id, err)
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	default:
+		s.complete(ctx, id, filePath, nil)
+	}
+	return nil
+}
+
+// expandEntry creates the document for one archive file. A resumed entry's
+// document is looked up first, as an interrupted attempt may have created it.
+func (s *Service) expandEntry(ctx context.Context, batch *models.DocumentBatch, opts DocumentOptions, name string, size int64, r io.Reader, resumed bool) (string, *uuid.UUID, error) {
+	if resumed {
+		var docID uuid.UUID
+		var docStatus string
+		err := s.db.QueryRow(ctx,
+			`SELECT id, status FROM documents
+			 WHERE batch_id = $1 AND tenant_id = $2 AND metadata->>'archive_path' = $3
+			 ORDER BY created_at LIMIT 1`,
+			batch.ID, batch.TenantID, name,
+		).Scan(&docID, &docStatus)
+		switch {
+		case err == nil:
+			return s.enqueueEntry(ctx, batch.TenantID, docID, docStatus)
+		case !errors.Is(err, pgx.ErrNoRows):
+			return models.BatchEntryFailed, nil, fmt.Errorf("find batch document: %w", err)
+		}
+	}
+
+	maxSize := s.cfg.ArchiveMaxEntrySize
+	tooLarge := fmt.Errorf("larger than %d MB", maxSize>>20)
+	if size > maxSize {
+		return models.BatchEntrySkipped, nil, tooLarge
+	}
+	// The declared size isn't trusted: compressed entries may expand past it.
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return models.BatchEntryFailed, nil, fmt.Errorf("read file: %w", err)
+	}
+	if int64(len(data)) > maxSize {
+		return models.BatchEntrySkipped, nil, tooLarge
+	}
+	format := textextract.Detect(bytes.NewReader(data), int64(len(data)), name)
+	if format == "" {
+		return models.BatchEntrySkipped, nil, ErrUnsupportedType
+	}
+
+	externalID := ""
+	if prefix := strings.TrimSpace(opts.ExternalID); prefix != "" {
+		externalID = strings.TrimSuffix(prefix, "/") + "/" + name
+	}
+	doc, err := s.docs.Upload(ctx, document.UploadRequest{
+		Title:    name,
+		FileType: format,
+		FileSize: int64(len(data)),
+		Data:     bytes.NewReader(data),
+		Metadata: map[string]interface{}{
+			"batch_id":     batch.ID.String(),
+			"archive_path": name,
+		},
+		ACL:           opts.ACL,
+		IngestOptions: opts.IngestOptions,
+		ExternalID:    externalID,
+		BatchID:       &batch.ID,
+	})
+	if err != nil {
+		return models.BatchEntryFailed, nil, err
+	}
+	return s.enqueueEntry(ctx, doc.TenantID, doc.ID, doc.Status)
+}
+
+// enqueueEntry queues a batch document for processing unless it was
+// already picked up.
+func (s *Service) enqueueEntry(ctx context.Context, tenantID, docID uuid.UUID, docStatus string) (string, *uuid.UUID, error) {
+	if docStatus != models.DocStatusPending {
+		return models.BatchEntryCreated, &docID, nil
+	}
+	if err := s.queue.EnqueueDocumentProcess(queue.DocumentProcessPayload{
+		DocumentID: docID.String(),
+		TenantID:   tenantID.String(),
+	}); err != nil {
+		if recErr := s.docs.RecordError(ctx, docID, err, true); recErr != nil {
+			slog.Error("failed to record document error", "document_id", docID, "error", recErr)
+		}
+		return models.BatchEntryFailed, &docID, fmt.Errorf("enqueue document: %w", err)
+	}
+	return models.BatchEntryCreated, &docID, nil
+}
+
+// download copies a batch's archive to a temporary file, which zip needs
+// to read from anywhere in it.
+func (s *Service) download(ctx context.Context, filePath string) (*os.File, int64, error) {
+	rc, err := s.store.Download(ctx, s.bucket, filePath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("download archive: %w", err)
+	}
+	defer rc.Close()
+
+	f, err := os.CreateTemp("", "batch-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("create archive file: %w", err)
+	}
+	size, err := io.Copy(f, rc)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, 0, fmt.Errorf("download archive: %w", err)
+	}
+	return f, size, nil
+}
+
+// complete marks a batch expanded and deletes its archive; cause notes why
+// some of the archive wasn't read.
+func (s *Service) complete(ctx context.Context, id uuid.UUID, filePath string, cause error) {
+	ctx = context.WithoutCancel(ctx)
+	var msg *string
+	if cause != nil {
+		m := cause.Error()
+		msg = &m
+	}
+	if _, err := s.db.Exec(ctx,
+		`UPDATE document_batches SET status = $2, error = $3, file_path = NULL, completed_at = now() WHERE id = $1`,
+		id, models.BatchStatusExpanded, msg,
+	); err != nil {
+		slog.Error("failed to complete document batch", "batch_id", id, "error", err)
+		return
+	}
+	if err := s.store.Delete(ctx, s.bucket, filePath); err != nil {
+		slog.Error("failed to delete batch archive", "batch_id", id, "error", err)
+	}
+}
+
+// retryOrFail returns an interrupted batch to pending for the task's
+// retry, or marks it failed once the task won't be retried, and returns
+// cause.
+func (s *Service) retryOrFail(ctx context.Context, id uuid.UUID, cause error) error {
+	if retried, ok := asynq.GetRetryCount(ctx); ok {
+		if maxRetry, ok := asynq.GetMaxRetry(ctx); ok && retried >= maxRetry {
+			s.fail(ctx, id, cause)
+			return cause
+		}
+	}
+	if _, err := s.db.Exec(context.WithoutCancel(ctx),
+		`UPDATE document_batches SET status = $2 WHERE id = $1`, id, models.BatchStatusPending,
+	); err != nil {
+		slog.Error("failed to requeue document batch", "batch_id", id, "error", err)
+	}
+	return cause
+}
+
+// fail marks a batch failed. Documents already created from it are kept.
+func (s *Service) fail(ctx context.Context, id uuid.UUID, cause error) {
+	if _, err := s.db.Exec(context.WithoutCancel(ctx),
+		`UPDATE document_batches SET status = $2, error = $3, completed_at = now() WHERE id = $1`,
+		id, models.BatchStatusFailed, cause.Error(),
+	); err != nil {
+		slog.Error("failed to record document batch error", "batch_id", id, "error", err)
+	}
+}
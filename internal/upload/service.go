@@ -0,0 +1,420 @@
+// Package upload receives files too large for one request, and archives of
+// many files. A resumable upload is created with the file's size, sent in
+// pieces each starting where the last ended, and completed into a document
+// or a batch. A batch is an archive expanded by the worker into one
+// document per supported file.
+package upload
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+	"github.com/nikhilbhutani/backendwithai/internal/document"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/storage"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/pkg/textextract"
+)
+
+var (
+	// ErrInvalidUpload wraps the problems found in a create request.
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrTooLarge is returned for uploads over the configured size, and
+	// for pieces that run past the declared size.
+	ErrTooLarge = errors.New("upload exceeds its size")
+	// ErrExpired is returned for unfinished uploads past their expiry.
+	ErrExpired = errors.New("upload expired")
+	// ErrOffsetMismatch is returned when a piece doesn't start where the
+	// upload's data ends.
+	ErrOffsetMismatch = errors.New("offset does not match the upload's")
+	// ErrBusy is returned while another piece of the upload is being
+	// written.
+	ErrBusy = errors.New("upload is being written")
+	// ErrIncomplete is returned when completing an upload that hasn't
+	// received all its data.
+	ErrIncomplete = errors.New("upload is incomplete")
+	// ErrCompleted is returned when writing to or completing an upload
+	// that was already completed.
+	ErrCompleted = errors.New("upload is already completed")
+	// ErrUnsupportedType is returned when a completed file can't be
+	// extracted.
+	ErrUnsupportedType = errors.New("unsupported file type")
+)
+
+// DocumentOptions are the settings of the documents an upload or batch
+// creates. For a batch, Title is ignored and ExternalID is a prefix: each
+// file's external ID is the prefix, a slash and its path in the archive,
+// so uploading a changed archive again creates new versions.
+type DocumentOptions struct {
+	Title      string             `json:"title,omitempty"`
+	ACL        models.DocumentACL `json:"acl"`
+	ExternalID string             `json:"external_id,omitempty"`
+	document.IngestOptions
+}
+
+// Service stages resumable uploads on disk and turns them, and archives,
+// into documents.
+type Service struct {
+	db     *pgxpool.Pool
+	docs   *document.Service
+	store  storage.Storage
+	bucket string
+	queue  *queue.Client
+	users  *tenant.Service
+	cfg    config.UploadConfig
+}
+
+func NewService(db *pgxpool.Pool, docs *document.Service, store storage.Storage, bucket string, qc *queue.Client, users *tenant.Service, cfg config.UploadConfig) *Service {
+	return &Service{
+		db:     db,
+		docs:   docs,
+		store:  store,
+		bucket: bucket,
+		queue:  qc,
+		users:  users,
+		cfg:    cfg,
+	}
+}
+
+type CreateRequest struct {
+	Filename    string          `json:"filename"`
+	ContentType string          `json:"content_type,omitempty"`
+	Size        int64           `json:"size"`
+	Options     DocumentOptions `json:"options"`
+}
+
+const uploadColumns = `id, tenant_id, created_by, filename, COALESCE(content_type, ''), size, received, options, status,
+	document_id, batch_id, expires_at, created_at, updated_at`
+
+func scanUpload(row interface{ Scan(...any) error }) (*models.Upload, error) {
+	var u models.Upload
+	err := row.Scan(&u.ID, &u.TenantID, &u.CreatedBy, &u.Filename, &u.ContentType, &u.Size, &u.Offset, &u.Options,
+		&u.Status, &u.DocumentID, &u.BatchID, &u.ExpiresAt, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// file is where an upload's data is staged.
+func (s *Service) file(id uuid.UUID) string {
+	return filepath.Join(s.cfg.Dir, id.String())
+}
+
+// Create starts a resumable upload. Unfinished uploads that have expired
+// are discarded first.
+func (s *Service) Create(ctx context.Context, req CreateRequest) (*models.Upload, error) {
+	req.Filename = strings.TrimSpace(req.Filename)
+	switch {
+	case req.Filename == "":
+		return nil, fmt.Errorf("%w: filename is required", ErrInvalidUpload)
+	case req.Size <= 0:
+		return nil, fmt.Errorf("%w: size must be positive", ErrInvalidUpload)
+	case req.Size > s.cfg.MaxSize:
+		return nil, fmt.Errorf("%w: uploads are limited to %d MB", ErrTooLarge, s.cfg.MaxSize>>20)
+	}
+	s.expire(ctx)
+
+	tenantID := tenant.IDFromContext(ctx)
+	var userID *uuid.UUID
+	if user := tenant.UserFromContext(ctx); user != nil {
+		userID = &user.ID
+	}
+	options, err := json.Marshal(req.Options)
+	if err != nil {
+		return nil, fmt.Errorf("marshal upload options: %w", err)
+	}
+
+	id := uuid.New()
+	if err := os.MkdirAll(s.cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create upload directory: %w", err)
+	}
+	f, err := os.Create(s.file(id))
+	if err != nil {
+		return nil, fmt.Errorf("create upload file: %w", err)
+	}
+	f.Close()
+
+	u, err := scanUpload(s.db.QueryRow(ctx,
+		`INSERT INTO uploads (id, tenant_id, created_by, filename, content_type, size, options, expires_at)
+		 VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7, now() + make_interval(secs => $8))
+		 RETURNING `+uploadColumns,
+		id, tenantID, userID, req.Filename, req.ContentType, req.Size, options, s.cfg.Expiry.Seconds(),
+	))
+	if err != nil {
+		os.Remove(s.file(id))
+		return nil, fmt.Errorf("insert upload: %w", err)
+	}
+	return u, nil
+}
+
+// expire discards unfinished uploads past their expiry.
+func (s *Service) expire(ctx context.Context) {
+	rows, err := s.db.Query(ctx,
+		`DELETE FROM uploads WHERE status = $1 AND expires_at < now() RETURNING id`, models.UploadStatusPending)
+	if err != nil {
+		slog.Error("failed to expire uploads", "error", err)
+		return
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		slog.Error("failed to expire uploads", "error", err)
+		return
+	}
+	for _, id := range ids {
+		if err := os.Remove(s.file(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("failed to remove expired upload", "upload_id", id, "error", err)
+		}
+	}
+}
+
+// Get returns one of the tenant's uploads.
+func (s *Service) Get(ctx context.Context, id uuid.UUID) (*models.Upload, error) {
+	u, err := scanUpload(s.db.QueryRow(ctx,
+		`SELECT `+uploadColumns+` FROM uploads WHERE id = $1 AND tenant_id = $2`,
+		id, tenant.IDFromContext(ctx),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get upload: %w", err)
+	}
+	return u, nil
+}
+
+// lock claims an upload for writing or completing, and returns it with
+// the function that releases it. Only one request may hold an upload, on
+// any API replica: the claim is a Postgres advisory lock, held by a pool
+// connection until it is released.
+func (s *Service) lock(ctx context.Context, id uuid.UUID) (*models.Upload, func(), error) {
+	conn, err := s.db.Acquire(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("acquire connection: %w", err)
+	}
+	key := lockKey(id)
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, nil, fmt.Errorf("lock upload: %w", err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, nil, ErrBusy
+	}
+	unlock := func() {
+		if _, err := conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, key); err != nil {
+			// Closing the session releases its locks; the pool then
+			// discards the connection instead of reusing it.
+			slog.Error("failed to unlock upload", "upload_id", id, "error", err)
+			conn.Conn().Close(context.WithoutCancel(ctx))
+		}
+		conn.Release()
+	}
+
+	u, err := s.Get(ctx, id)
+	switch {
+	case err != nil:
+	case u.Status != models.UploadStatusPending:
+		err = ErrCompleted
+	case u.ExpiresAt.Before(time.Now()):
+		err = ErrExpired
+	}
+	if err != nil {
+		unlock()
+		return nil, nil, err
+	}
+	return u, unlock, nil
+}
+
+// lockKey is the advisory lock key of an upload. Keys of different
+// uploads may collide, which only makes one of them briefly busy.
+func lockKey(id uuid.UUID) int64 {
+	return int64(binary.BigEndian.Uint64(id[:8]))
+}
+
+// Write appends a piece of the upload's data, which must start at offset,
+// the end of the data received so far. It returns the new offset: if data
+// fails partway, what was read before it failed is kept, and the client
+// resumes from there.
+func (s *Service) Write(ctx context.Context, id uuid.UUID, offset int64, data io.Reader) (int64, error) {
+	u, unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	if offset != u.Offset {
+		return u.Offset, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.file(id), os.O_WRONLY, 0)
+	if err != nil {
+		return u.Offset, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return u.Offset, fmt.Errorf("seek upload file: %w", err)
+	}
+	n, writeErr := io.Copy(f, io.LimitReader(data, u.Size-offset))
+	if writeErr == nil {
+		// Anything left over runs past the declared size.
+		var extra [1]byte
+		if m, _ := data.Read(extra[:]); m > 0 {
+			writeErr = fmt.Errorf("%w: declared size is %d bytes", ErrTooLarge, u.Size)
+		}
+	}
+	if n > 0 {
+		// The offset is only advanced once the data is on disk, and only
+		// from the offset the piece was written at.
+		if err := f.Sync(); err != nil {
+			return u.Offset, fmt.Errorf("write upload file: %w", err)
+		}
+		tag, err := s.db.Exec(context.WithoutCancel(ctx),
+			`UPDATE uploads SET received = received + $3, updated_at = now() WHERE id = $1 AND received = $2`,
+			id, offset, n,
+		)
+		if err != nil {
+			return u.Offset, fmt.Errorf("update upload offset: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return u.Offset, ErrOffsetMismatch
+		}
+	}
+	if writeErr != nil {
+		if !errors.Is(writeErr, ErrTooLarge) {
+			writeErr = fmt.Errorf("receive upload data: %w", writeErr)
+		}
+		return offset + n, writeErr
+	}
+	return offset + n, nil
+}
+
+// Complete turns a fully received upload into a document and queues it for
+// processing.
+func (s *Service) Complete(ctx context.Context, id uuid.UUID) (*models.Document, error) {
+	u, unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if u.Offset < u.Size {
+		return nil, fmt.Errorf("%w: %d of %d bytes received", ErrIncomplete, u.Offset, u.Size)
+	}
+	var opts DocumentOptions
+	if err := json.Unmarshal(u.Options, &opts); err != nil {
+		return nil, fmt.Errorf("decode upload options: %w", err)
+	}
+
+	f, err := os.Open(s.file(id))
+	if err != nil {
+		return nil, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+	format := textextract.Detect(f, u.Size, u.ContentType, u.Filename)
+	if format == "" {
+		return nil, ErrUnsupportedType
+	}
+	title := opts.Title
+	if title == "" {
+		title = u.Filename
+	}
+
+	doc, err := s.docs.Upload(ctx, document.UploadRequest{
+		Title:         title,
+		FileType:      format,
+		FileSize:      u.Size,
+		Data:          f,
+		ACL:           opts.ACL,
+		IngestOptions: opts.IngestOptions,
+		ExternalID:    strings.TrimSpace(opts.ExternalID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The document exists now, so completing again mustn't create another.
+	s.finish(ctx, u, &doc.ID, nil)
+	if err := s.queue.EnqueueDocumentProcess(queue.DocumentProcessPayload{
+		DocumentID: doc.ID.String(),
+		TenantID:   doc.TenantID.String(),
+	}); err != nil {
+		if recErr := s.docs.RecordError(ctx, doc.ID, err, true); recErr != nil {
+			slog.Error("failed to record document error", "document_id", doc.ID, "error", recErr)
+		}
+		return nil, fmt.Errorf("enqueue document: %w", err)
+	}
+	return doc, nil
+}
+
+// CompleteBatch turns a fully received upload of an archive into a batch.
+func (s *Service) CompleteBatch(ctx context.Context, id uuid.UUID) (*models.DocumentBatch, error) {
+	u, unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if u.Offset < u.Size {
+		return nil, fmt.Errorf("%w: %d of %d bytes received", ErrIncomplete, u.Offset, u.Size)
+	}
+	var opts DocumentOptions
+	if err := json.Unmarshal(u.Options, &opts); err != nil {
+		return nil, fmt.Errorf("decode upload options: %w", err)
+	}
+
+	f, err := os.Open(s.file(id))
+	if err != nil {
+		return nil, fmt.Errorf("open upload file: %w", err)
+	}
+	defer f.Close()
+	batch, err := s.CreateBatch(ctx, BatchRequest{Filename: u.Filename, Data: f, Options: opts})
+	if err != nil {
+		return nil, err
+	}
+
+	s.finish(ctx, u, nil, &batch.ID)
+	return batch, nil
+}
+
+// finish marks a completed upload with what it became and removes its
+// staged data.
+func (s *Service) finish(ctx context.Context, u *models.Upload, docID, batchID *uuid.UUID) {
+	if _, err := s.db.Exec(context.WithoutCancel(ctx),
+		`UPDATE uploads SET status = $2, document_id = $3, batch_id = $4, updated_at = now() WHERE id = $1`,
+		u.ID, models.UploadStatusCompleted, docID, batchID,
+	); err != nil {
+		slog.Error("failed to complete upload", "upload_id", u.ID, "error", err)
+	}
+	if err := os.Remove(s.file(u.ID)); err != nil {
+		slog.Error("failed to remove completed upload", "upload_id", u.ID, "error", err)
+	}
+}
+
+// Abort discards an unfinished upload.
+func (s *Service) Abort(ctx context.Context, id uuid.UUID) error {
+	u, unlock, err := s.lock(ctx, id)
+	if errors.Is(err, ErrExpired) {
+		u, err = s.Get(ctx, id)
+	} else if err == nil {
+		defer unlock()
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(ctx, `DELETE FROM uploads WHERE id = $1`, u.ID); err != nil {
+		return fmt.Errorf("delete upload: %w", err)
+	}
+	if err := os.Remove(s.file(u.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("failed to remove aborted upload", "upload_id", u.ID, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package vectorindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/models"
+	"github.com/nikhilbhutani/backendwithai/internal/queue"
+	"github.com/nikhilbhutani/backendwithai/internal/tenant"
+	"github.com/nikhilbhutani/backendwithai/internal/vectorstore"
+)
+
+var (
+	// ErrGlobalIndex is returned when a tenant asks for an index shared by
+	// every tenant. Those are the operator's, managed with cmd/vectorindex.
+	ErrGlobalIndex = errors.New("global vector indexes are managed by the operator")
+	// ErrIndexNotFound is returned for indexes the caller's tenant doesn't own.
+	ErrIndexNotFound = errors.New("vector index not found")
+)
+
+// Service runs a tenant's vector index builds as queued jobs and exposes
+// the tenant's own indexes of document_chunks. Global indexes span every
+// tenant, so tenants can neither see nor change them here.
+type Service struct {
+	db      *pgxpool.Pool
+	manager *vectorstore.PgIndexManager
+	queue   *queue.Client
+}
+
+func NewService(db *pgxpool.Pool, qc *queue.Client) *Service {
+	return &Service{
+		db:      db,
+		manager: vectorstore.NewPgIndexManager(db),
+		queue:   qc,
+	}
+}
+
+type BuildRequest struct {
+	vectorstore.IndexSpec
+	// PerTenant builds a partial index over the caller's tenant only. It is
+	// required: global indexes are built by the operator.
+	PerTenant bool `json:"per_tenant,omitempty"`
+}
+
+const jobColumns = `id, tenant_id, spec, status, COALESCE(phase, ''), progress, COALESCE(index_name, ''),
+	COALESCE(error, ''), started_at, completed_at, created_at`
+
+func scanJob(row interface{ Scan(...any) error }) (*models.VectorIndexJob, error) {
+	var j models.VectorIndexJob
+	err := row.Scan(&j.ID, &j.TenantID, &j.Spec, &j.Status, &j.Phase, &j.Progress, &j.IndexName,
+		&j.Error, &j.StartedAt, &j.CompletedAt, &j.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// StartBuild records a build job for the caller's per-tenant index and
+// queues it for the worker.
+func (s *Service) StartBuild(ctx context.Context, req BuildRequest) (*models.VectorIndexJob, error) {
+	if !req.PerTenant {
+		return nil, fmt.Errorf("%w: set per_tenant to build an index over your tenant's chunks", ErrGlobalIndex)
+	}
+	tenantID := tenant.IDFromContext(ctx)
+
+	spec := req.IndexSpec
+	if spec.Method == "" {
+		spec.Method = vectorstore.IndexHNSW
+	}
+	spec.TenantID = &tenantID
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal spec: %w", err)
+	}
+
+	job, err := scanJob(s.db.QueryRow(ctx,
+		`INSERT INTO vector_index_jobs (tenant_id, spec, status, index_name)
+		 VALUES ($1, $2, $3, $4)
+		 RETURNING `+jobColumns,
+		tenantID, specJSON, models.JobStatusPending, spec.Name(),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("insert index job: %w", err)
+	}
+
+	if err := s.queue.EnqueueVectorIndexBuild(queue.VectorIndexBuildPayload{
+		JobID:    job.ID.String(),
+		TenantID: tenantID.String(),
+	}); err != nil {
+		return nil, fmt.Errorf("enqueue index job: %w", err)
+	}
+	return job, nil
+}
+
+func (s *Service) ListJobs(ctx context.Context) ([]models.VectorIndexJob, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	rows, err := s.db.Query(ctx,
+		`SELECT `+jobColumns+` FROM vector_index_jobs WHERE tenant_id = $1 ORDER BY created_at DESC LIMIT 100`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list index jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []models.VectorIndexJob
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan index job: %w", err)
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, nil
+}
+
+func (s *Service) GetJob(ctx context.Context, id uuid.UUID) (*models.VectorIndexJob, error) {
+	tenantID := tenant.IDFromContext(ctx)
+	job, err := scanJob(s.db.QueryRow(ctx,
+		`SELECT `+jobColumns+` FROM vector_index_jobs WHERE id = $1 AND tenant_id = $2`,
+		id, tenantID,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("get index job: %w", err)
+	}
+	return job, nil
+}
+
+// ListIndexes returns the caller's per-tenant indexes.
+func (s *Service) ListIndexes(ctx context.Context) ([]vectorstore.VectorIndex, error) {
+	indexes, err := s.manager.ListIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tenantID := tenant.IDFromContext(ctx)
+	own := []vectorstore.VectorIndex{}
+	for _, idx := range indexes {
+		if idx.TenantID != nil && *idx.TenantID == tenantID {
+			own = append(own, idx)
+		}
+	}
+	return own, nil
+}
+
+// DropIndex drops one of the caller's per-tenant indexes by name.
+func (s *Service) DropIndex(ctx context.Context, name string) error {
+	indexes, err := s.ListIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return s.manager.DropIndex(ctx, name)
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrIndexNotFound, name)
+}
+
+// RunJob builds the index for a queued job, recording progress on the job
+// row as the build advances.
+func (s *Service) RunJob(ctx context.Context, jobID uuid.UUID) error {
+	var specJSON []byte
+	err := s.db.QueryRow(ctx,
+		`UPDATE vector_index_jobs SET status = $2, started_at = now(), error = NULL
+		 WHERE id = $1 RETURNING spec`,
+		jobID, models.JobStatusRunning,
+	).Scan(&specJSON)
+	if err != nil {
+		return fmt.Errorf("start index job: %w", err)
+	}
+
+	var spec vectorstore.IndexSpec
+	if err := json.Unmarshal(specJSON, &spec); err != nil {
+		return s.fail(ctx, jobID, fmt.Errorf("decode spec: %w", err))
+	}
+
+	lastPercent := -1
+	progress := func(phase string, percent int) {
+		if percent == lastPercent {
+			return
+		}
+		lastPercent = percent
+		if _, err := s.db.Exec(ctx,
+			"UPDATE vector_index_jobs SET phase = $2, progress = $3 WHERE id = $1",
+			jobID, phase, percent,
+		); err != nil {
+			slog.Warn("failed to record index build progress", "job_id", jobID, "error", err)
+		}
+	}
+
+	idx, err := s.manager.BuildIndex(ctx, spec, progress)
+	if err != nil {
+		return s.fail(ctx, jobID, err)
+	}
+
+	_, err = s.db.Exec(ctx,
+		`UPDATE vector_index_jobs SET status = $2, phase = 'done', progress = 100, index_name = $3, completed_at = now()
+		 WHERE id = $1`,
+		jobID, models.JobStatusSucceeded, idx.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("complete index job: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) fail(ctx context.Context, jobID uuid.UUID, cause error) error {
+	_, err := s.db.Exec(context.WithoutCancel(ctx),
+		"UPDATE vector_index_jobs SET status = $2, error = $3, completed_at = now() WHERE id = $1",
+		jobID, models.JobStatusFailed, cause.Error(),
+	)
+	if err != nil {
+		slog.Error("failed to record index job failure", "job_id", jobID, "error", err)
+	}
+	return cause
+}
@@ -0,0 +1,47 @@
+package vectorstore
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+)
+
+// NewFromConfig returns the backend cfg.Backend selects. A memory store
+// whose file can't be loaded is an error rather than an empty store, since
+// its next write would replace the file.
+func NewFromConfig(cfg config.VectorStoreConfig, db *pgxpool.Pool) (VectorStore, error) {
+	switch cfg.Backend {
+	case "", "pgvector":
+		return NewPgVectorStore(db), nil
+	case "memory":
+		if cfg.MemoryPath == "" {
+			return NewMemoryStore(), nil
+		}
+		vs, err := OpenMemoryStore(cfg.MemoryPath)
+		if err != nil {
+			return nil, fmt.Errorf("open memory vector store %s: %w", cfg.MemoryPath, err)
+		}
+		return vs, nil
+	case "qdrant":
+		return NewQdrantStore(QdrantConfig{
+			URL:        cfg.QdrantURL,
+			APIKey:     cfg.QdrantAPIKey,
+			Collection: cfg.QdrantCollection,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown vector store backend %q: use pgvector, memory or qdrant", cfg.Backend)
+	}
+}
+
+// GraphStoreFromConfig returns the knowledge graph store for cfg.Backend,
+// or nil when GraphRAG can't run on it. The graph lives in Postgres and its
+// mentions reference document_chunks, so it only works with pgvector.
+func GraphStoreFromConfig(cfg config.VectorStoreConfig, db *pgxpool.Pool) GraphStore {
+	switch cfg.Backend {
+	case "", "pgvector":
+		return NewPgGraphStore(db)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package vectorstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nikhilbhutani/backendwithai/internal/config"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.VectorStoreConfig
+		wantErr bool
+	}{
+		{"memory", config.VectorStoreConfig{Backend: "memory"}, false},
+		{"memory with new file", config.VectorStoreConfig{Backend: "memory", MemoryPath: filepath.Join(dir, "new.json")}, false},
+		{"memory with unreadable file", config.VectorStoreConfig{Backend: "memory", MemoryPath: corrupt}, true},
+		{"qdrant", config.VectorStoreConfig{Backend: "qdrant", QdrantURL: "http://localhost:6333"}, false},
+		{"unknown backend", config.VectorStoreConfig{Backend: "pgvectr"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs, err := NewFromConfig(tt.cfg, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewFromConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && vs == nil {
+				t.Fatal("NewFromConfig returned no store")
+			}
+		})
+	}
+
+	// The unreadable file must be left as it was.
+	if data, _ := os.ReadFile(corrupt); string(data) != "{not json" {
+		t.Errorf("unreadable store file was rewritten: %q", data)
+	}
+}
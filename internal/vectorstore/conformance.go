@@ -0,0 +1,518 @@
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// CheckConformance exercises a VectorStore against the behaviour the RAG
+// pipeline relies on and returns every violation found, joined. Call it from
+// a test with a fresh, empty store (for Qdrant, a throwaway collection):
+//
+//	if err := vectorstore.CheckConformance(ctx, vectorstore.NewMemoryStore()); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// It writes 3-dimensional vectors under two random tenants and deletes them
+// again before returning.
+func CheckConformance(ctx context.Context, store VectorStore) error {
+	c := &conformance{store: store, tenantA: uuid.New(), tenantB: uuid.New(), docA: uuid.New(), docB: uuid.New()}
+	defer func() {
+		_ = store.Delete(ctx, DeleteFilter{TenantID: c.tenantA})
+		_ = store.Delete(ctx, DeleteFilter{TenantID: c.tenantB})
+	}()
+
+	steps := []func(context.Context) error{
+		c.upsert,
+		c.similarity,
+		c.minScore,
+		c.tenantIsolation,
+		c.crossTenant,
+		c.sample,
+		c.reupsert,
+		c.hybrid,
+		c.deleteDocument,
+		c.collections,
+		c.defaultPool,
+		c.access,
+		c.versions,
+		c.deleteTenant,
+	}
+	var errs []error
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type conformance struct {
+	store            VectorStore
+	tenantA, tenantB uuid.UUID
+	docA, docB       uuid.UUID
+	ids              [4]uuid.UUID
+}
+
+func (c *conformance) upsert(ctx context.Context) error {
+	for i := range c.ids {
+		c.ids[i] = uuid.New()
+	}
+	chunks := []Chunk{
+		{ID: c.ids[0], DocumentID: c.docA, TenantID: c.tenantA, ChunkIndex: 0, Content: "Quarterly revenue grew in Europe.", Embedding: []float32{1, 0, 0}, TokenCount: 6, Metadata: map[string]interface{}{"source": "report"}},
+		{ID: c.ids[1], DocumentID: c.docA, TenantID: c.tenantA, ChunkIndex: 1, Content: "Headcount stayed flat.", Embedding: []float32{0.8, 0.6, 0}, TokenCount: 4},
+		{ID: c.ids[2], DocumentID: c.docB, TenantID: c.tenantA, ChunkIndex: 0, Content: "The zeppelin maintenance schedule.", Embedding: []float32{0, 0, 1}, TokenCount: 5},
+	}
+	if err := c.store.Upsert(ctx, chunks); err != nil {
+		return fmt.Errorf("upsert: %w", err)
+	}
+	other := Chunk{ID: c.ids[3], DocumentID: c.docA, TenantID: c.tenantB, ChunkIndex: 0, Content: "Another tenant's revenue figures.", Embedding: []float32{1, 0, 0}, TokenCount: 5}
+	if err := c.store.Upsert(ctx, []Chunk{other}); err != nil {
+		return fmt.Errorf("upsert: %w", err)
+	}
+	return nil
+}
+
+func (c *conformance) similarity(ctx context.Context) error {
+	results, err := c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantA, TopK: 2})
+	if err != nil {
+		return fmt.Errorf("similarity search: %w", err)
+	}
+	if len(results) != 2 {
+		return fmt.Errorf("similarity search: got %d results, want 2 (TopK)", len(results))
+	}
+	if results[0].ChunkID != c.ids[0] || results[1].ChunkID != c.ids[1] {
+		return fmt.Errorf("similarity search: got order %v, %v, want %v, %v", results[0].ChunkID, results[1].ChunkID, c.ids[0], c.ids[1])
+	}
+	if math.Abs(results[0].Score-1) > 1e-3 || math.Abs(results[1].Score-0.8) > 1e-3 {
+		return fmt.Errorf("similarity search: got scores %.3f, %.3f, want cosine similarities 1, 0.8", results[0].Score, results[1].Score)
+	}
+
+	r := results[0]
+	if r.DocumentID != c.docA || r.ChunkIndex != 0 || r.Content != "Quarterly revenue grew in Europe." {
+		return fmt.Errorf("similarity search: chunk fields not round-tripped: %+v", r)
+	}
+	if r.Metadata["source"] != "report" {
+		return fmt.Errorf("similarity search: metadata not round-tripped: %v", r.Metadata)
+	}
+	if r.Embedding != nil {
+		return fmt.Errorf("similarity search: embedding returned without WithEmbeddings")
+	}
+
+	results, err = c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantA, TopK: 1, WithEmbeddings: true})
+	if err != nil {
+		return fmt.Errorf("similarity search with embeddings: %w", err)
+	}
+	if len(results) != 1 || len(results[0].Embedding) != 3 {
+		return fmt.Errorf("similarity search: embedding not returned with WithEmbeddings")
+	}
+	return nil
+}
+
+func (c *conformance) minScore(ctx context.Context) error {
+	results, err := c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantA, TopK: 10, MinScore: 0.9})
+	if err != nil {
+		return fmt.Errorf("min score search: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != c.ids[0] {
+		return fmt.Errorf("min score search: got %d results, want only the exact match", len(results))
+	}
+	return nil
+}
+
+func (c *conformance) tenantIsolation(ctx context.Context) error {
+	results, err := c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantB, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("tenant search: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != c.ids[3] {
+		return fmt.Errorf("tenant search: got %d results, want only the tenant's own chunk", len(results))
+	}
+	return nil
+}
+
+// crossTenant checks that nothing reaches across tenants: calls without a
+// tenant are refused, batches may not span tenants, and one tenant's
+// searches, deletes and access changes never touch another's chunks.
+func (c *conformance) crossTenant(ctx context.Context) error {
+	var errs []error
+	refused := func(what string, err error) {
+		if !errors.Is(err, ErrNoTenant) {
+			errs = append(errs, fmt.Errorf("cross-tenant: %s without a tenant: got %v, want ErrNoTenant", what, err))
+		}
+	}
+	_, err := c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TopK: 10})
+	refused("similarity search", err)
+	_, err = c.store.HybridSearch(ctx, "revenue", []float32{1, 0, 0}, SearchOptions{TopK: 10})
+	refused("hybrid search", err)
+	refused("upsert", c.store.Upsert(ctx, []Chunk{{DocumentID: c.docA, Content: "Unowned chunk.", Embedding: []float32{1, 0, 0}}}))
+	refused("delete", c.store.Delete(ctx, DeleteFilter{DocumentID: c.docA}))
+	refused("set access", c.store.SetAccess(ctx, uuid.Nil, c.docA, []uuid.UUID{uuid.New()}))
+
+	mixed := []Chunk{
+		{DocumentID: c.docB, TenantID: c.tenantA, Content: "Mixed batch.", Embedding: []float32{0, 1, 0}},
+		{DocumentID: c.docB, TenantID: c.tenantB, Content: "Mixed batch.", Embedding: []float32{0, 1, 0}},
+	}
+	if err := c.store.Upsert(ctx, mixed); err == nil {
+		errs = append(errs, fmt.Errorf("cross-tenant: upsert accepted a batch spanning tenants"))
+	}
+
+	// The keyword matches both tenants' chunks.
+	results, err := c.store.HybridSearch(ctx, "revenue", []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantB, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("cross-tenant hybrid search: %w", err)
+	}
+	for _, r := range results {
+		if r.ChunkID != c.ids[3] {
+			errs = append(errs, fmt.Errorf("cross-tenant: hybrid search returned another tenant's chunk %s", r.ChunkID))
+		}
+	}
+
+	// docB only exists under tenant A; tenant B's calls must be no-ops.
+	if err := c.store.SetAccess(ctx, c.tenantB, c.docB, []uuid.UUID{uuid.New()}); err != nil {
+		return fmt.Errorf("cross-tenant set access: %w", err)
+	}
+	if err := c.store.Delete(ctx, DeleteFilter{TenantID: c.tenantB, DocumentID: c.docB}); err != nil {
+		return fmt.Errorf("cross-tenant delete: %w", err)
+	}
+	results, err = c.store.SimilaritySearch(ctx, []float32{0, 0, 1}, SearchOptions{TenantID: c.tenantA, TopK: 1})
+	if err != nil {
+		return fmt.Errorf("cross-tenant search: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != c.ids[2] {
+		errs = append(errs, fmt.Errorf("cross-tenant: another tenant's delete or access change reached the chunk"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *conformance) sample(ctx context.Context) error {
+	results, err := c.store.Sample(ctx, SampleOptions{TenantID: c.tenantA, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("sample: %w", err)
+	}
+	if len(results) != 3 {
+		return fmt.Errorf("sample: got %d chunks, want the tenant's 3", len(results))
+	}
+	for _, r := range results {
+		if r.ChunkID == c.ids[3] {
+			return fmt.Errorf("sample: returned another tenant's chunk")
+		}
+	}
+
+	results, err = c.store.Sample(ctx, SampleOptions{TenantID: c.tenantA, Limit: 2})
+	if err != nil {
+		return fmt.Errorf("sample: %w", err)
+	}
+	if len(results) != 2 {
+		return fmt.Errorf("sample: got %d chunks, want 2 (Limit)", len(results))
+	}
+
+	results, err = c.store.Sample(ctx, SampleOptions{TenantID: c.tenantA, DocumentIDs: []uuid.UUID{c.docB}, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("sample: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != c.ids[2] || results[0].Content == "" {
+		return fmt.Errorf("sample: document filter returned %d chunks, want only the document's one", len(results))
+	}
+
+	if _, err := c.store.Sample(ctx, SampleOptions{Limit: 10}); !errors.Is(err, ErrNoTenant) {
+		return fmt.Errorf("sample: without a tenant: got %v, want ErrNoTenant", err)
+	}
+	return nil
+}
+
+func (c *conformance) reupsert(ctx context.Context) error {
+	updated := Chunk{ID: c.ids[1], DocumentID: c.docA, TenantID: c.tenantA, ChunkIndex: 1, Content: "Headcount grew slightly.", Embedding: []float32{0.8, 0.6, 0}, TokenCount: 4}
+	if err := c.store.Upsert(ctx, []Chunk{updated}); err != nil {
+		return fmt.Errorf("re-upsert: %w", err)
+	}
+	results, err := c.store.SimilaritySearch(ctx, []float32{0.8, 0.6, 0}, SearchOptions{TenantID: c.tenantA, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("re-upsert search: %w", err)
+	}
+	if len(results) != 3 {
+		return fmt.Errorf("re-upsert: got %d chunks, want 3 (upsert by ID must replace)", len(results))
+	}
+	if results[0].ChunkID != c.ids[1] || results[0].Content != updated.Content {
+		return fmt.Errorf("re-upsert: content not replaced: %q", results[0].Content)
+	}
+	return nil
+}
+
+func (c *conformance) hybrid(ctx context.Context) error {
+	// The query vector points away from the zeppelin chunk; only the keyword
+	// match can surface it.
+	results, err := c.store.HybridSearch(ctx, "zeppelin", []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantA, TopK: 3})
+	if err != nil {
+		return fmt.Errorf("hybrid search: %w", err)
+	}
+	for _, r := range results {
+		if r.ChunkID == c.ids[3] {
+			return fmt.Errorf("hybrid search: returned another tenant's chunk")
+		}
+	}
+	for _, r := range results {
+		if r.ChunkID == c.ids[2] {
+			return nil
+		}
+	}
+	return fmt.Errorf("hybrid search: keyword match missing from %d results", len(results))
+}
+
+func (c *conformance) deleteDocument(ctx context.Context) error {
+	if err := c.store.Delete(ctx, DeleteFilter{TenantID: c.tenantA, DocumentID: c.docA}); err != nil {
+		return fmt.Errorf("delete document: %w", err)
+	}
+	results, err := c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantA, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("delete document search: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != c.ids[2] {
+		return fmt.Errorf("delete document: got %d chunks left, want only the other document's", len(results))
+	}
+
+	// The same document ID under another tenant is untouched.
+	results, err = c.store.SimilaritySearch(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: c.tenantB, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("delete document search: %w", err)
+	}
+	if len(results) != 1 {
+		return fmt.Errorf("delete document: removed another tenant's chunks")
+	}
+	return nil
+}
+
+func (c *conformance) collections(ctx context.Context) error {
+	collection, id := uuid.New(), uuid.New()
+	chunk := Chunk{ID: id, DocumentID: c.docB, TenantID: c.tenantA, ChunkIndex: 0, Content: "The zeppelin maintenance schedule.", Embedding: []float32{0, 0, 1}, TokenCount: 5, CollectionID: collection}
+	if err := c.store.Upsert(ctx, []Chunk{chunk}); err != nil {
+		return fmt.Errorf("collection upsert: %w", err)
+	}
+
+	results, err := c.store.SimilaritySearch(ctx, []float32{0, 0, 1}, SearchOptions{TenantID: c.tenantA, TopK: 10, CollectionIDs: []uuid.UUID{collection}})
+	if err != nil {
+		return fmt.Errorf("collection search: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != id {
+		return fmt.Errorf("collection search: got %d results, want only the collection's chunk", len(results))
+	}
+	if results[0].CollectionID == nil || *results[0].CollectionID != collection {
+		return fmt.Errorf("collection search: collection ID not round-tripped")
+	}
+
+	// Unscoped searches and samples only see the default pool.
+	results, err = c.store.SimilaritySearch(ctx, []float32{0, 0, 1}, SearchOptions{TenantID: c.tenantA, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("default pool search: %w", err)
+	}
+	hybrid, err := c.store.HybridSearch(ctx, "zeppelin", []float32{0, 0, 1}, SearchOptions{TenantID: c.tenantA, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("default pool hybrid search: %w", err)
+	}
+	sampled, err := c.store.Sample(ctx, SampleOptions{TenantID: c.tenantA, DocumentIDs: []uuid.UUID{c.docB}, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("default pool sample: %w", err)
+	}
+	for _, r := range append(append(results, hybrid...), sampled...) {
+		if r.ChunkID == id {
+			return fmt.Errorf("default pool: a search without collections returned a collection's chunk")
+		}
+	}
+
+	if err := c.store.Delete(ctx, DeleteFilter{TenantID: c.tenantA, CollectionID: collection}); err != nil {
+		return fmt.Errorf("delete collection: %w", err)
+	}
+	results, err = c.store.SimilaritySearch(ctx, []float32{0, 0, 1}, SearchOptions{TenantID: c.tenantA, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("delete collection search: %w", err)
+	}
+	if len(results) != 1 || results[0].ChunkID != c.ids[2] {
+		return fmt.Errorf("delete collection: got %d chunks left, want only the chunk outside the collection", len(results))
+	}
+	return nil
+}
+
+// defaultPool checks that a default-pool delete leaves the document's
+// collection chunks alone. It writes under tenant B, whose chunks the other
+// steps don't count.
+func (c *conformance) defaultPool(ctx context.Context) error {
+	doc, collection, pooled, collected := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	chunks := []Chunk{
+		{ID: pooled, DocumentID: doc, TenantID: c.tenantB, ChunkIndex: 0, Content: "Pooled copy.", Embedding: []float32{0, 1, 0}, TokenCount: 2},
+		{ID: collected, DocumentID: doc, TenantID: c.tenantB, ChunkIndex: 0, Content: "Collection copy.", Embedding: []float32{0, 1, 0}, TokenCount: 2, CollectionID: collection},
+	}
+	if err := c.store.Upsert(ctx, chunks); err != nil {
+		return fmt.Errorf("default pool upsert: %w", err)
+	}
+	defer func() { _ = c.store.Delete(ctx, DeleteFilter{TenantID: c.tenantB, DocumentID: doc}) }()
+
+	if err := c.store.Delete(ctx, DeleteFilter{TenantID: c.tenantB, DocumentID: doc, DefaultPool: true}); err != nil {
+		return fmt.Errorf("delete default pool: %w", err)
+	}
+	found := make(map[uuid.UUID]bool)
+	for _, scope := range [][]uuid.UUID{nil, {collection}} {
+		results, err := c.store.SimilaritySearch(ctx, []float32{0, 1, 0}, SearchOptions{TenantID: c.tenantB, TopK: 10, CollectionIDs: scope})
+		if err != nil {
+			return fmt.Errorf("delete default pool search: %w", err)
+		}
+		for _, r := range results {
+			found[r.ChunkID] = true
+		}
+	}
+	if found[pooled] || !found[collected] {
+		return fmt.Errorf("delete default pool: want only the collection chunk left, got pooled=%v collected=%v", found[pooled], found[collected])
+	}
+	return nil
+}
+
+func (c *conformance) access(ctx context.Context) error {
+	doc, id, principal := uuid.New(), uuid.New(), uuid.New()
+	chunk := Chunk{ID: id, DocumentID: doc, TenantID: c.tenantA, ChunkIndex: 0, Content: "Restricted salary bands.", Embedding: []float32{0, 1, 0}, TokenCount: 3, Access: []uuid.UUID{principal}}
+	if err := c.store.Upsert(ctx, []Chunk{chunk}); err != nil {
+		return fmt.Errorf("access upsert: %w", err)
+	}
+
+	// found counts the searches (similarity, hybrid) returning the chunk.
+	found := func(principals []uuid.UUID) (int, error) {
+		opts := SearchOptions{TenantID: c.tenantA, TopK: 10, Principals: principals}
+		vector, err := c.store.SimilaritySearch(ctx, []float32{0, 1, 0}, opts)
+		if err != nil {
+			return 0, fmt.Errorf("access search: %w", err)
+		}
+		hybrid, err := c.store.HybridSearch(ctx, "salary", []float32{0, 1, 0}, opts)
+		if err != nil {
+			return 0, fmt.Errorf("access hybrid search: %w", err)
+		}
+		n := 0
+		for _, r := range append(vector, hybrid...) {
+			if r.ChunkID == id {
+				n++
+			}
+		}
+		return n, nil
+	}
+
+	checks := []struct {
+		principals []uuid.UUID
+		want       int
+		what       string
+	}{
+		{nil, 0, "returned to a caller without principals"},
+		{[]uuid.UUID{uuid.New()}, 0, "returned to an unlisted principal"},
+		{[]uuid.UUID{uuid.New(), principal}, 2, "not returned to a listed principal"},
+	}
+	for _, check := range checks {
+		n, err := found(check.principals)
+		if err != nil {
+			return err
+		}
+		if n != check.want {
+			return fmt.Errorf("access: restricted chunk %s", check.what)
+		}
+	}
+
+	if err := c.store.SetAccess(ctx, c.tenantA, doc, nil); err != nil {
+		return fmt.Errorf("set access: %w", err)
+	}
+	n, err := found(nil)
+	if err != nil {
+		return err
+	}
+	if n != 2 {
+		return fmt.Errorf("set access: chunk still restricted after clearing its access list")
+	}
+	return nil
+}
+
+func (c *conformance) versions(ctx context.Context) error {
+	doc, id := uuid.New(), uuid.New()
+	chunk := Chunk{ID: id, DocumentID: doc, TenantID: c.tenantA, ChunkIndex: 0, Content: "Travel policy, first edition.", Embedding: []float32{0, 1, 0}, TokenCount: 4, ContentHash: "hash-1",
+		Metadata: map[string]interface{}{"context_header": "Document: Travel policy"}}
+	if err := c.store.Upsert(ctx, []Chunk{chunk}); err != nil {
+		return fmt.Errorf("versions upsert: %w", err)
+	}
+
+	// found counts the searches (similarity, hybrid) returning the chunk.
+	found := func(includeSuperseded bool) (int, error) {
+		opts := SearchOptions{TenantID: c.tenantA, TopK: 10, IncludeSuperseded: includeSuperseded}
+		vector, err := c.store.SimilaritySearch(ctx, []float32{0, 1, 0}, opts)
+		if err != nil {
+			return 0, fmt.Errorf("versions search: %w", err)
+		}
+		hybrid, err := c.store.HybridSearch(ctx, "travel", []float32{0, 1, 0}, opts)
+		if err != nil {
+			return 0, fmt.Errorf("versions hybrid search: %w", err)
+		}
+		n := 0
+		for _, r := range append(vector, hybrid...) {
+			if r.ChunkID == id {
+				n++
+			}
+		}
+		return n, nil
+	}
+
+	if err := c.store.SetSuperseded(ctx, c.tenantA, doc, true); err != nil {
+		return fmt.Errorf("set superseded: %w", err)
+	}
+	if n, err := found(false); err != nil {
+		return err
+	} else if n != 0 {
+		return fmt.Errorf("versions: superseded chunk returned by default")
+	}
+	if n, err := found(true); err != nil {
+		return err
+	} else if n != 2 {
+		return fmt.Errorf("versions: superseded chunk missing with IncludeSuperseded")
+	}
+	sampled, err := c.store.Sample(ctx, SampleOptions{TenantID: c.tenantA, DocumentIDs: []uuid.UUID{doc}, Limit: 10})
+	if err != nil {
+		return fmt.Errorf("versions sample: %w", err)
+	}
+	if len(sampled) != 0 {
+		return fmt.Errorf("versions: superseded chunk sampled")
+	}
+
+	embeddings, err := c.store.Embeddings(ctx, EmbeddingLookup{TenantID: c.tenantA, DocumentIDs: []uuid.UUID{doc}, Hashes: []string{"hash-1", "hash-2"}})
+	if err != nil {
+		return fmt.Errorf("embeddings: %w", err)
+	}
+	if len(embeddings) != 1 || len(embeddings["hash-1"].Embedding) != 3 || embeddings["hash-1"].ContextHeader != "Document: Travel policy" {
+		return fmt.Errorf("embeddings: got %d, want the stored chunk's, with its context header, by its hash", len(embeddings))
+	}
+	embeddings, err = c.store.Embeddings(ctx, EmbeddingLookup{TenantID: c.tenantA, DocumentIDs: []uuid.UUID{doc}, CollectionID: uuid.New(), Hashes: []string{"hash-1"}})
+	if err != nil {
+		return fmt.Errorf("embeddings: %w", err)
+	}
+	if len(embeddings) != 0 {
+		return fmt.Errorf("embeddings: returned a default-pool chunk for a collection")
+	}
+
+	if err := c.store.SetSuperseded(ctx, c.tenantA, doc, false); err != nil {
+		return fmt.Errorf("set superseded: %w", err)
+	}
+	if n, err := found(false); err != nil {
+		return err
+	} else if n != 2 {
+		return fmt.Errorf("versions: chunk still hidden after it became current again")
+	}
+	return nil
+}
+
+func (c *conformance) deleteTenant(ctx context.Context) error {
+	if err := c.store.Delete(ctx, DeleteFilter{TenantID: c.tenantA}); err != nil {
+		return fmt.Errorf("delete tenant: %w", err)
+	}
+	results, err := c.store.SimilaritySearch(ctx, []float32{0, 0, 1}, SearchOptions{TenantID: c.tenantA, TopK: 10})
+	if err != nil {
+		return fmt.Errorf("delete tenant search: %w", err)
+	}
+	if len(results) != 0 {
+		return fmt.Errorf("delete tenant: got %d chunks left, want 0", len(results))
+	}
+	return nil
+}
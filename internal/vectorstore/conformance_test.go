@@ -0,0 +1,390 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestMemoryStoreConformance(t *testing.T) {
+	if err := CheckConformance(context.Background(), NewMemoryStore()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQdrantStoreConformance(t *testing.T) {
+	srv := httptest.NewServer(newFakeQdrant())
+	defer srv.Close()
+
+	store := NewQdrantStore(QdrantConfig{URL: srv.URL, Collection: "conformance"})
+	if err := CheckConformance(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPgVectorStoreConformance needs a Postgres with the vector extension;
+// see newPgTestDB.
+func TestPgVectorStoreConformance(t *testing.T) {
+	db := newPgTestDB(t)
+	if err := CheckConformance(context.Background(), NewPgVectorStore(db)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newPgTestDB connects to the Postgres at VECTORSTORE_TEST_DSN, skipping the
+// test if it is not set. It creates the chunk and graph tables, with
+// 3-dimensional embeddings and the chunks' row-level security policy, in a
+// scratch schema that is dropped when the test ends.
+func newPgTestDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+	dsn := os.Getenv("VECTORSTORE_TEST_DSN")
+	if dsn == "" {
+		t.Skip("VECTORSTORE_TEST_DSN not set")
+	}
+	ctx := context.Background()
+
+	schema := "vectorstore_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ", public"
+	db, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec(ctx, `DROP SCHEMA IF EXISTS `+schema+` CASCADE`); err != nil {
+			t.Errorf("drop schema: %v", err)
+		}
+		db.Close()
+	})
+
+	ddl := []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE SCHEMA ` + schema,
+		`CREATE TABLE documents (
+		     id UUID PRIMARY KEY,
+		     created_at TIMESTAMPTZ DEFAULT now()
+		 )`,
+		`CREATE TABLE document_chunks (
+		     id UUID PRIMARY KEY,
+		     document_id UUID NOT NULL,
+		     tenant_id UUID NOT NULL,
+		     chunk_index INT NOT NULL,
+		     content TEXT NOT NULL,
+		     embedding vector(3),
+		     token_count INT,
+		     metadata JSONB DEFAULT '{}',
+		     created_at TIMESTAMPTZ DEFAULT now(),
+		     collection_id UUID,
+		     access UUID[],
+		     content_hash TEXT,
+		     superseded BOOLEAN NOT NULL DEFAULT false,
+		     tsv tsvector GENERATED ALWAYS AS (to_tsvector('english', content)) STORED
+		 )`,
+		`ALTER TABLE document_chunks ENABLE ROW LEVEL SECURITY`,
+		`ALTER TABLE document_chunks FORCE ROW LEVEL SECURITY`,
+		`CREATE POLICY document_chunks_tenant_isolation ON document_chunks
+		     USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+		     WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)`,
+		`CREATE TABLE kg_entities (
+		     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		     tenant_id UUID NOT NULL,
+		     name TEXT NOT NULL,
+		     normalized_name TEXT NOT NULL,
+		     entity_type TEXT NOT NULL DEFAULT 'concept',
+		     description TEXT,
+		     embedding vector(3),
+		     community_id UUID,
+		     created_at TIMESTAMPTZ DEFAULT now(),
+		     UNIQUE(tenant_id, normalized_name, entity_type)
+		 )`,
+		`CREATE TABLE kg_entity_chunks (
+		     entity_id UUID NOT NULL REFERENCES kg_entities(id) ON DELETE CASCADE,
+		     chunk_id UUID NOT NULL,
+		     document_id UUID NOT NULL,
+		     tenant_id UUID NOT NULL,
+		     PRIMARY KEY (entity_id, chunk_id)
+		 )`,
+		`CREATE TABLE kg_relations (
+		     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		     tenant_id UUID NOT NULL,
+		     source_entity_id UUID NOT NULL REFERENCES kg_entities(id) ON DELETE CASCADE,
+		     target_entity_id UUID NOT NULL REFERENCES kg_entities(id) ON DELETE CASCADE,
+		     relation TEXT NOT NULL,
+		     description TEXT,
+		     weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+		     chunk_id UUID,
+		     document_id UUID,
+		     created_at TIMESTAMPTZ DEFAULT now()
+		 )`,
+		`CREATE TABLE kg_communities (
+		     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		     tenant_id UUID NOT NULL,
+		     level INT NOT NULL DEFAULT 0,
+		     summary TEXT NOT NULL,
+		     embedding vector(3),
+		     entity_count INT NOT NULL DEFAULT 0,
+		     created_at TIMESTAMPTZ DEFAULT now()
+		 )`,
+	}
+	for _, stmt := range ddl {
+		if _, err := db.Exec(ctx, stmt); err != nil {
+			t.Fatalf("create schema: %v", err)
+		}
+	}
+	return db
+}
+
+// fakeQdrant is an in-memory stand-in for the parts of Qdrant's REST API
+// QdrantStore uses: one collection, exact cosine search, scrolling, payload
+// updates and deletes by filter. Full-text matches are case-insensitive
+// word matches.
+type fakeQdrant struct {
+	mu          sync.Mutex
+	collections map[string]map[string]*fakePoint
+}
+
+type fakePoint struct {
+	vector  []float32
+	payload map[string]interface{}
+}
+
+func newFakeQdrant() *fakeQdrant {
+	return &fakeQdrant{collections: make(map[string]map[string]*fakePoint)}
+}
+
+func (q *fakeQdrant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/collections/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	name, op, _ := strings.Cut(rest, "/")
+	points, exists := q.collections[name]
+
+	switch {
+	case op == "" && r.Method == http.MethodGet:
+		if !exists {
+			http.Error(w, "collection not found", http.StatusNotFound)
+			return
+		}
+		fakeQdrantReply(w, map[string]interface{}{"status": "green"})
+	case op == "" && r.Method == http.MethodPut:
+		q.collections[name] = make(map[string]*fakePoint)
+		fakeQdrantReply(w, true)
+	case !exists:
+		http.Error(w, "collection not found", http.StatusNotFound)
+	case op == "index":
+		fakeQdrantReply(w, map[string]interface{}{"status": "completed"})
+	case op == "points" && r.Method == http.MethodPut:
+		var req struct {
+			Points []struct {
+				ID      string                 `json:"id"`
+				Vector  []float32              `json:"vector"`
+				Payload map[string]interface{} `json:"payload"`
+			} `json:"points"`
+		}
+		if !fakeQdrantDecode(w, r, &req) {
+			return
+		}
+		for _, p := range req.Points {
+			points[p.ID] = &fakePoint{vector: p.Vector, payload: p.Payload}
+		}
+		fakeQdrantReply(w, map[string]interface{}{"status": "completed"})
+	case op == "points/search":
+		var req qdrantSearchReq
+		if !fakeQdrantDecode(w, r, &req) {
+			return
+		}
+		var hits []map[string]interface{}
+		var scores []float64
+		for id, p := range matchingPoints(points, req.Filter) {
+			score := cosineSimilarity(req.Vector, p.vector)
+			if req.ScoreThreshold != nil && score < *req.ScoreThreshold {
+				continue
+			}
+			hits = append(hits, fakePointJSON(id, p, req.WithVector, score))
+			scores = append(scores, score)
+		}
+		sort.Sort(byScore{hits, scores})
+		if len(hits) > req.Limit {
+			hits = hits[:req.Limit]
+		}
+		fakeQdrantReply(w, hits)
+	case op == "points/scroll":
+		var req qdrantScrollReq
+		if !fakeQdrantDecode(w, r, &req) {
+			return
+		}
+		page := []map[string]interface{}{}
+		for id, p := range matchingPoints(points, req.Filter) {
+			if len(page) == req.Limit {
+				break
+			}
+			page = append(page, fakePointJSON(id, p, req.WithVector, 0))
+		}
+		fakeQdrantReply(w, map[string]interface{}{"points": page})
+	case op == "points/delete":
+		var req struct {
+			Filter *qdrantFilter `json:"filter"`
+		}
+		if !fakeQdrantDecode(w, r, &req) {
+			return
+		}
+		for id := range matchingPoints(points, req.Filter) {
+			delete(points, id)
+		}
+		fakeQdrantReply(w, map[string]interface{}{"status": "completed"})
+	case op == "points/payload":
+		var req struct {
+			Payload map[string]interface{} `json:"payload"`
+			Filter  *qdrantFilter          `json:"filter"`
+		}
+		if !fakeQdrantDecode(w, r, &req) {
+			return
+		}
+		for _, p := range matchingPoints(points, req.Filter) {
+			for k, v := range req.Payload {
+				p.payload[k] = v
+			}
+		}
+		fakeQdrantReply(w, map[string]interface{}{"status": "completed"})
+	case op == "points/payload/delete":
+		var req struct {
+			Keys   []string      `json:"keys"`
+			Filter *qdrantFilter `json:"filter"`
+		}
+		if !fakeQdrantDecode(w, r, &req) {
+			return
+		}
+		for _, p := range matchingPoints(points, req.Filter) {
+			for _, k := range req.Keys {
+				delete(p.payload, k)
+			}
+		}
+		fakeQdrantReply(w, map[string]interface{}{"status": "completed"})
+	default:
+		http.Error(w, fmt.Sprintf("unsupported: %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+	}
+}
+
+type byScore struct {
+	hits   []map[string]interface{}
+	scores []float64
+}
+
+func (b byScore) Len() int           { return len(b.hits) }
+func (b byScore) Less(i, j int) bool { return b.scores[i] > b.scores[j] }
+func (b byScore) Swap(i, j int) {
+	b.hits[i], b.hits[j] = b.hits[j], b.hits[i]
+	b.scores[i], b.scores[j] = b.scores[j], b.scores[i]
+}
+
+func fakeQdrantDecode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func fakeQdrantReply(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": result, "status": "ok"})
+}
+
+func fakePointJSON(id string, p *fakePoint, withVector bool, score float64) map[string]interface{} {
+	out := map[string]interface{}{"id": id, "score": score, "payload": p.payload}
+	if withVector {
+		out["vector"] = p.vector
+	}
+	return out
+}
+
+func matchingPoints(points map[string]*fakePoint, f *qdrantFilter) map[string]*fakePoint {
+	out := make(map[string]*fakePoint)
+	for id, p := range points {
+		if f == nil || fakeFilterMatches(p.payload, f) {
+			out[id] = p
+		}
+	}
+	return out
+}
+
+func fakeFilterMatches(payload map[string]interface{}, f *qdrantFilter) bool {
+	for _, c := range f.Must {
+		if !fakeConditionMatches(payload, c) {
+			return false
+		}
+	}
+	for _, c := range f.MustNot {
+		if fakeConditionMatches(payload, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func fakeConditionMatches(payload map[string]interface{}, c qdrantCondition) bool {
+	if len(c.Should) > 0 {
+		for _, s := range c.Should {
+			if fakeConditionMatches(payload, s) {
+				return true
+			}
+		}
+		return false
+	}
+	if c.IsEmpty != nil {
+		v, ok := payload[c.IsEmpty.Key]
+		if list, isList := v.([]interface{}); isList {
+			return len(list) == 0
+		}
+		return !ok || v == nil
+	}
+	if c.Match == nil {
+		return false
+	}
+
+	// A condition on an array field matches if any element does.
+	values := []interface{}{payload[c.Key]}
+	if list, ok := payload[c.Key].([]interface{}); ok {
+		values = list
+	}
+	for _, v := range values {
+		switch {
+		case c.Match.Text != "":
+			s, _ := v.(string)
+			for _, word := range tokenize(s) {
+				if word == strings.ToLower(c.Match.Text) {
+					return true
+				}
+			}
+		case c.Match.Any != nil:
+			for _, a := range c.Match.Any {
+				if v == a {
+					return true
+				}
+			}
+		default:
+			if v == c.Match.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
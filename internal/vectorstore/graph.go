@@ -0,0 +1,82 @@
+package vectorstore
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Entity is a node in the knowledge graph extracted from document chunks.
+type Entity struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	Name        string    `json:"name"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Embedding   []float32 `json:"-"`
+	Score       float64   `json:"score,omitempty"` // similarity, set by SearchEntities
+}
+
+// Relation is a directed, typed edge between two entities, tied to the chunk
+// it was extracted from.
+type Relation struct {
+	ID          uuid.UUID `json:"id"`
+	TenantID    uuid.UUID `json:"tenant_id"`
+	SourceID    uuid.UUID `json:"source_id"`
+	TargetID    uuid.UUID `json:"target_id"`
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Weight      float64   `json:"weight"`
+	ChunkID     uuid.UUID `json:"chunk_id"`
+	DocumentID  uuid.UUID `json:"document_id"`
+}
+
+// EntityMention links an entity to a chunk that mentions it.
+type EntityMention struct {
+	EntityID   uuid.UUID
+	ChunkID    uuid.UUID
+	DocumentID uuid.UUID
+	TenantID   uuid.UUID
+}
+
+// Community is a cluster of related entities with an LLM-written summary.
+type Community struct {
+	ID        uuid.UUID   `json:"id"`
+	TenantID  uuid.UUID   `json:"tenant_id"`
+	Level     int         `json:"level"`
+	Summary   string      `json:"summary"`
+	Embedding []float32   `json:"-"`
+	EntityIDs []uuid.UUID `json:"entity_ids,omitempty"`
+	Score     float64     `json:"score,omitempty"` // similarity, set by SearchCommunities
+}
+
+// GraphStore persists the knowledge graph built by GraphRAG indexing.
+type GraphStore interface {
+	// UpsertEntities merges entities by tenant, normalized name and type,
+	// returning them with their canonical IDs in input order.
+	UpsertEntities(ctx context.Context, entities []Entity) ([]Entity, error)
+	AddMentions(ctx context.Context, mentions []EntityMention) error
+	AddRelations(ctx context.Context, relations []Relation) error
+	ListRelations(ctx context.Context, tenantID uuid.UUID) ([]Relation, error)
+	GetEntities(ctx context.Context, tenantID uuid.UUID, ids []uuid.UUID) ([]Entity, error)
+
+	SearchEntities(ctx context.Context, query []float32, opts SearchOptions) ([]Entity, error)
+	// Neighbors returns entities within hops edges of the given entities,
+	// excluding the seeds, along with the edges traversed.
+	Neighbors(ctx context.Context, tenantID uuid.UUID, entityIDs []uuid.UUID, hops, limit int) ([]Entity, []Relation, error)
+	// ChunksForEntities returns up to opts.TopK chunks mentioning any of the
+	// entities, scored by the fraction of the given entities each chunk
+	// mentions. opts filters them like a search (MinScore, Tuning and
+	// WithEmbeddings aside).
+	ChunksForEntities(ctx context.Context, entityIDs []uuid.UUID, opts SearchOptions) ([]SearchResult, error)
+
+	// ReplaceCommunities swaps the tenant's community summaries for a new set.
+	ReplaceCommunities(ctx context.Context, tenantID uuid.UUID, communities []Community) error
+	SearchCommunities(ctx context.Context, query []float32, opts SearchOptions) ([]Community, error)
+}
+
+// NormalizeEntityName is the key entities are merged on.
+func NormalizeEntityName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
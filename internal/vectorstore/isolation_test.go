@@ -0,0 +1,311 @@
+package vectorstore
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// isolationFixture holds two tenants with look-alike data: the same document
+// ID, content, content hash and a near-identical vector. Only tenant_id tells
+// their chunks apart, so any lookup that drops it returns both.
+type isolationFixture struct {
+	tenantA, tenantB uuid.UUID
+	// shared is the document ID both tenants use; onlyA exists under A only.
+	shared, onlyA      uuid.UUID
+	chunkA, chunkB     uuid.UUID
+	chunkOnlyA         uuid.UUID
+	vectorA, vectorB   []float32
+	sharedHash         string
+	sharedContent      string
+	sharedQueryKeyword string
+}
+
+func newIsolationFixture() *isolationFixture {
+	return &isolationFixture{
+		tenantA:            uuid.New(),
+		tenantB:            uuid.New(),
+		shared:             uuid.New(),
+		onlyA:              uuid.New(),
+		chunkA:             uuid.New(),
+		chunkB:             uuid.New(),
+		chunkOnlyA:         uuid.New(),
+		vectorA:            []float32{1, 0, 0},
+		vectorB:            []float32{0.99, 0.1, 0},
+		sharedHash:         "shared-hash",
+		sharedContent:      "Merger negotiations with the supplier.",
+		sharedQueryKeyword: "merger",
+	}
+}
+
+func (f *isolationFixture) seed(t *testing.T, store VectorStore) {
+	t.Helper()
+	ctx := context.Background()
+	a := []Chunk{
+		{ID: f.chunkA, DocumentID: f.shared, TenantID: f.tenantA, Content: f.sharedContent, Embedding: f.vectorA, TokenCount: 5, ContentHash: f.sharedHash},
+		{ID: f.chunkOnlyA, DocumentID: f.onlyA, TenantID: f.tenantA, Content: "Merger timeline for the board.", Embedding: f.vectorA, TokenCount: 5, ContentHash: "only-a"},
+	}
+	b := []Chunk{
+		{ID: f.chunkB, DocumentID: f.shared, TenantID: f.tenantB, Content: f.sharedContent, Embedding: f.vectorB, TokenCount: 5, ContentHash: f.sharedHash},
+	}
+	for _, batch := range [][]Chunk{a, b} {
+		if err := store.Upsert(ctx, batch); err != nil {
+			t.Fatalf("upsert: %v", err)
+		}
+	}
+	t.Cleanup(func() {
+		_ = store.Delete(ctx, DeleteFilter{TenantID: f.tenantA})
+		_ = store.Delete(ctx, DeleteFilter{TenantID: f.tenantB})
+	})
+}
+
+// own returns the chunk IDs tenant may see.
+func (f *isolationFixture) own(tenantID uuid.UUID) map[uuid.UUID]bool {
+	if tenantID == f.tenantA {
+		return map[uuid.UUID]bool{f.chunkA: true, f.chunkOnlyA: true}
+	}
+	return map[uuid.UUID]bool{f.chunkB: true}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	stores := map[string]func(t *testing.T) VectorStore{
+		"memory": func(t *testing.T) VectorStore { return NewMemoryStore() },
+		"qdrant": func(t *testing.T) VectorStore {
+			srv := httptest.NewServer(newFakeQdrant())
+			t.Cleanup(srv.Close)
+			return NewQdrantStore(QdrantConfig{URL: srv.URL, Collection: "isolation"})
+		},
+		"pgvector": func(t *testing.T) VectorStore { return NewPgVectorStore(newPgTestDB(t)) },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			testTenantIsolation(t, open(t))
+		})
+	}
+}
+
+func testTenantIsolation(t *testing.T, store VectorStore) {
+	ctx := context.Background()
+
+	t.Run("search", func(t *testing.T) {
+		f := newIsolationFixture()
+		f.seed(t, store)
+		for _, tenantID := range []uuid.UUID{f.tenantA, f.tenantB} {
+			opts := SearchOptions{TenantID: tenantID, TopK: 10}
+			vector, err := store.SimilaritySearch(ctx, f.vectorA, opts)
+			if err != nil {
+				t.Fatalf("similarity search: %v", err)
+			}
+			hybrid, err := store.HybridSearch(ctx, f.sharedQueryKeyword, f.vectorA, opts)
+			if err != nil {
+				t.Fatalf("hybrid search: %v", err)
+			}
+			own := f.own(tenantID)
+			if len(vector) != len(own) {
+				t.Errorf("similarity search: got %d results, want the tenant's %d", len(vector), len(own))
+			}
+			for _, r := range append(vector, hybrid...) {
+				if !own[r.ChunkID] {
+					t.Errorf("search for tenant %s returned another tenant's chunk %s", tenantID, r.ChunkID)
+				}
+			}
+		}
+	})
+
+	t.Run("sample", func(t *testing.T) {
+		f := newIsolationFixture()
+		f.seed(t, store)
+		for _, opts := range []SampleOptions{
+			{TenantID: f.tenantB, Limit: 10},
+			{TenantID: f.tenantB, DocumentIDs: []uuid.UUID{f.shared, f.onlyA}, Limit: 10},
+		} {
+			sampled, err := store.Sample(ctx, opts)
+			if err != nil {
+				t.Fatalf("sample: %v", err)
+			}
+			if len(sampled) != 1 || sampled[0].ChunkID != f.chunkB {
+				t.Errorf("sample for tenant B (documents %v): got %d chunks, want only its own", opts.DocumentIDs, len(sampled))
+			}
+		}
+	})
+
+	t.Run("embeddings", func(t *testing.T) {
+		f := newIsolationFixture()
+		f.seed(t, store)
+		embeddings, err := store.Embeddings(ctx, EmbeddingLookup{
+			TenantID:    f.tenantB,
+			DocumentIDs: []uuid.UUID{f.shared, f.onlyA},
+			Hashes:      []string{f.sharedHash, "only-a"},
+		})
+		if err != nil {
+			t.Fatalf("embeddings: %v", err)
+		}
+		if len(embeddings) != 1 {
+			t.Fatalf("embeddings for tenant B: got %d, want only its own chunk's", len(embeddings))
+		}
+		if got := embeddings[f.sharedHash].Embedding; len(got) != 3 || got[1] != f.vectorB[1] {
+			t.Errorf("embeddings for tenant B: got %v, want its own vector %v", got, f.vectorB)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		f := newIsolationFixture()
+		f.seed(t, store)
+		// Tenant B deletes the shared document, then everything it has.
+		if err := store.Delete(ctx, DeleteFilter{TenantID: f.tenantB, DocumentID: f.shared}); err != nil {
+			t.Fatalf("delete document: %v", err)
+		}
+		if err := store.Delete(ctx, DeleteFilter{TenantID: f.tenantB, DocumentID: f.onlyA}); err != nil {
+			t.Fatalf("delete other tenant's document: %v", err)
+		}
+		if err := store.Delete(ctx, DeleteFilter{TenantID: f.tenantB}); err != nil {
+			t.Fatalf("delete tenant: %v", err)
+		}
+		results, err := store.SimilaritySearch(ctx, f.vectorA, SearchOptions{TenantID: f.tenantA, TopK: 10})
+		if err != nil {
+			t.Fatalf("similarity search: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("tenant B's deletes left tenant A %d of its 2 chunks", len(results))
+		}
+	})
+
+	t.Run("access and versions", func(t *testing.T) {
+		f := newIsolationFixture()
+		f.seed(t, store)
+		// Tenant B restricts and retires "its" copy of the shared document.
+		if err := store.SetAccess(ctx, f.tenantB, f.shared, []uuid.UUID{uuid.New()}); err != nil {
+			t.Fatalf("set access: %v", err)
+		}
+		if err := store.SetSuperseded(ctx, f.tenantB, f.shared, true); err != nil {
+			t.Fatalf("set superseded: %v", err)
+		}
+		results, err := store.SimilaritySearch(ctx, f.vectorA, SearchOptions{TenantID: f.tenantA, TopK: 10})
+		if err != nil {
+			t.Fatalf("similarity search: %v", err)
+		}
+		if len(results) != 2 {
+			t.Errorf("tenant B's access and version changes hid tenant A's chunks: %d of 2 left", len(results))
+		}
+	})
+}
+
+// TestPgGraphTenantIsolation gives two tenants the same entities, relation,
+// mention and community, and checks each graph lookup stays in its tenant.
+func TestPgGraphTenantIsolation(t *testing.T) {
+	db := newPgTestDB(t)
+	ctx := context.Background()
+	store, graph := NewPgVectorStore(db), NewPgGraphStore(db)
+
+	type tenantGraph struct {
+		id        uuid.UUID
+		chunk     uuid.UUID
+		entities  []Entity
+		community uuid.UUID
+	}
+	doc := uuid.New()
+	tenants := []*tenantGraph{{id: uuid.New(), chunk: uuid.New()}, {id: uuid.New(), chunk: uuid.New()}}
+	for _, tg := range tenants {
+		chunk := Chunk{ID: tg.chunk, DocumentID: doc, TenantID: tg.id, Content: "Acme supplies the billing team.", Embedding: []float32{1, 0, 0}, TokenCount: 6}
+		if err := store.Upsert(ctx, []Chunk{chunk}); err != nil {
+			t.Fatalf("upsert chunk: %v", err)
+		}
+
+		entities, err := graph.UpsertEntities(ctx, []Entity{
+			{TenantID: tg.id, Name: "Acme", Type: "organization", Embedding: []float32{1, 0, 0}},
+			{TenantID: tg.id, Name: "Billing team", Type: "team", Embedding: []float32{0, 1, 0}},
+		})
+		if err != nil {
+			t.Fatalf("upsert entities: %v", err)
+		}
+		tg.entities = entities
+
+		var mentions []EntityMention
+		for _, e := range entities {
+			mentions = append(mentions, EntityMention{EntityID: e.ID, ChunkID: tg.chunk, DocumentID: doc, TenantID: tg.id})
+		}
+		if err := graph.AddMentions(ctx, mentions); err != nil {
+			t.Fatalf("add mentions: %v", err)
+		}
+		if err := graph.AddRelations(ctx, []Relation{{TenantID: tg.id, SourceID: entities[0].ID, TargetID: entities[1].ID, Type: "supplies", ChunkID: tg.chunk, DocumentID: doc}}); err != nil {
+			t.Fatalf("add relations: %v", err)
+		}
+
+		tg.community = uuid.New()
+		community := Community{ID: tg.community, Summary: "Acme and billing.", Embedding: []float32{1, 0, 0}, EntityIDs: []uuid.UUID{entities[0].ID, entities[1].ID}}
+		if err := graph.ReplaceCommunities(ctx, tg.id, []Community{community}); err != nil {
+			t.Fatalf("replace communities: %v", err)
+		}
+	}
+	a, b := tenants[0], tenants[1]
+	if a.entities[0].ID == b.entities[0].ID {
+		t.Fatal("upsert entities merged the same name across tenants")
+	}
+
+	// Tenant B's rebuild must not touch tenant A's communities.
+	if err := graph.ReplaceCommunities(ctx, b.id, nil); err != nil {
+		t.Fatalf("replace communities: %v", err)
+	}
+
+	entities, err := graph.SearchEntities(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: b.id, TopK: 10})
+	if err != nil {
+		t.Fatalf("search entities: %v", err)
+	}
+	for _, e := range entities {
+		if e.TenantID != b.id {
+			t.Errorf("search entities for tenant B returned tenant A's %q", e.Name)
+		}
+	}
+
+	aIDs := []uuid.UUID{a.entities[0].ID, a.entities[1].ID}
+	if got, err := graph.GetEntities(ctx, b.id, aIDs); err != nil {
+		t.Fatalf("get entities: %v", err)
+	} else if len(got) != 0 {
+		t.Errorf("get entities for tenant B returned %d of tenant A's entities", len(got))
+	}
+
+	neighbors, relations, err := graph.Neighbors(ctx, b.id, aIDs[:1], 2, 10)
+	if err != nil {
+		t.Fatalf("neighbors: %v", err)
+	}
+	if len(neighbors) != 0 || len(relations) != 0 {
+		t.Errorf("neighbors for tenant B crossed into tenant A's graph: %d entities, %d relations", len(neighbors), len(relations))
+	}
+
+	if got, err := graph.ListRelations(ctx, b.id); err != nil {
+		t.Fatalf("list relations: %v", err)
+	} else if len(got) != 1 || got[0].TenantID != b.id {
+		t.Errorf("list relations for tenant B: got %d, want only its own", len(got))
+	}
+
+	chunks, err := graph.ChunksForEntities(ctx, aIDs, SearchOptions{TenantID: b.id, TopK: 10})
+	if err != nil {
+		t.Fatalf("chunks for entities: %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("chunks for tenant A's entities returned %d chunks to tenant B", len(chunks))
+	}
+	chunks, err = graph.ChunksForEntities(ctx, aIDs, SearchOptions{TenantID: a.id, TopK: 10})
+	if err != nil {
+		t.Fatalf("chunks for entities: %v", err)
+	}
+	if len(chunks) != 1 || chunks[0].ChunkID != a.chunk {
+		t.Errorf("chunks for tenant A's entities: got %d, want its own chunk", len(chunks))
+	}
+
+	for _, tg := range tenants {
+		communities, err := graph.SearchCommunities(ctx, []float32{1, 0, 0}, SearchOptions{TenantID: tg.id, TopK: 10})
+		if err != nil {
+			t.Fatalf("search communities: %v", err)
+		}
+		want := 0
+		if tg == a {
+			want = 1
+		}
+		if len(communities) != want || (want == 1 && communities[0].ID != a.community) {
+			t.Errorf("search communities for tenant %s: got %d, want %d of its own", tg.id, len(communities), want)
+		}
+	}
+}
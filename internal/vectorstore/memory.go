@@ -0,0 +1,512 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore is an in-process VectorStore with exact (brute-force) cosine
+// search and keyword scoring. It is meant for local development and tests;
+// with a path set, every write is persisted to a JSON file and reloaded by
+// OpenMemoryStore.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	path   string
+	chunks map[uuid.UUID]memoryChunk
+	// docCreated stands in for documents.created_at: the time a document's
+	// first chunk was stored.
+	docCreated map[uuid.UUID]time.Time
+}
+
+type memoryChunk struct {
+	Chunk
+	terms map[string]int
+}
+
+// memorySnapshot is the on-disk format.
+type memorySnapshot struct {
+	Chunks     []Chunk                 `json:"chunks"`
+	DocCreated map[uuid.UUID]time.Time `json:"doc_created"`
+}
+
+// NewMemoryStore returns an empty, non-persistent store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		chunks:     make(map[uuid.UUID]memoryChunk),
+		docCreated: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// OpenMemoryStore returns a store persisted at path, loading its contents if
+// the file exists.
+func OpenMemoryStore(path string) (*MemoryStore, error) {
+	s := NewMemoryStore()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read memory store: %w", err)
+	}
+
+	var snap memorySnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("decode memory store: %w", err)
+	}
+	for _, c := range snap.Chunks {
+		s.chunks[c.ID] = memoryChunk{Chunk: c, terms: termFrequencies(c.Content)}
+	}
+	for id, t := range snap.DocCreated {
+		s.docCreated[id] = t
+	}
+	return s, nil
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, chunks []Chunk) error {
+	if _, err := chunksTenant(chunks); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, c := range chunks {
+		if c.ID == uuid.Nil {
+			c.ID = uuid.New()
+		}
+		c.Embedding = append([]float32(nil), c.Embedding...)
+		c.Metadata = copyMetadata(c.Metadata)
+		c.Access = append([]uuid.UUID(nil), c.Access...) // nil when empty: unrestricted
+		s.chunks[c.ID] = memoryChunk{Chunk: c, terms: termFrequencies(c.Content)}
+		if _, ok := s.docCreated[c.DocumentID]; !ok {
+			s.docCreated[c.DocumentID] = now
+		}
+	}
+	return s.persist()
+}
+
+func (s *MemoryStore) SimilaritySearch(ctx context.Context, query []float32, opts SearchOptions) ([]SearchResult, error) {
+	if opts.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var results []SearchResult
+	for _, c := range s.vectorCandidates(query, opts, opts.TopK) {
+		if opts.MinScore > 0 && c.score < opts.MinScore {
+			continue
+		}
+		results = append(results, s.result(c.chunk, c.score, opts.WithEmbeddings))
+	}
+	return results, nil
+}
+
+// HybridSearch mirrors PgVectorStore: the top 2*TopK vector and keyword
+// candidates are merged and scored 0.7*vector + 0.3*keyword.
+func (s *MemoryStore) HybridSearch(ctx context.Context, query string, queryVec []float32, opts SearchOptions) ([]SearchResult, error) {
+	if opts.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	combined := make(map[uuid.UUID]*scoredChunk)
+	for _, c := range s.vectorCandidates(queryVec, opts, opts.TopK*2) {
+		combined[c.chunk.ID] = &scoredChunk{chunk: c.chunk, score: c.score * 0.7}
+	}
+
+	queryTerms := queryTerms(query)
+	var keyword []scoredChunk
+	for _, c := range s.chunks {
+		if !c.matches(opts) {
+			continue
+		}
+		if score := keywordScore(queryTerms, c.terms); score > 0 {
+			keyword = append(keyword, scoredChunk{chunk: c, score: score})
+		}
+	}
+	for _, c := range topScored(keyword, opts.TopK*2) {
+		if existing, ok := combined[c.chunk.ID]; ok {
+			existing.score += c.score * 0.3
+		} else {
+			combined[c.chunk.ID] = &scoredChunk{chunk: c.chunk, score: c.score * 0.3}
+		}
+	}
+
+	merged := make([]scoredChunk, 0, len(combined))
+	for _, c := range combined {
+		merged = append(merged, *c)
+	}
+
+	var results []SearchResult
+	for _, c := range topScored(merged, opts.TopK) {
+		if opts.MinScore > 0 && c.score < opts.MinScore {
+			continue
+		}
+		results = append(results, s.result(c.chunk, c.score, opts.WithEmbeddings))
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, filter DeleteFilter) error {
+	if filter.TenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.chunks {
+		if c.TenantID != filter.TenantID {
+			continue
+		}
+		if filter.DocumentID != uuid.Nil && c.DocumentID != filter.DocumentID {
+			continue
+		}
+		if filter.CollectionID != uuid.Nil && c.CollectionID != filter.CollectionID {
+			continue
+		}
+		if filter.DefaultPool && c.CollectionID != uuid.Nil {
+			continue
+		}
+		delete(s.chunks, id)
+	}
+
+	// Forget creation times of documents with no chunks left.
+	live := make(map[uuid.UUID]bool)
+	for _, c := range s.chunks {
+		live[c.DocumentID] = true
+	}
+	for id := range s.docCreated {
+		if !live[id] {
+			delete(s.docCreated, id)
+		}
+	}
+	return s.persist()
+}
+
+func (s *MemoryStore) SetAccess(ctx context.Context, tenantID, documentID uuid.UUID, access []uuid.UUID) error {
+	if tenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.chunks {
+		if c.TenantID == tenantID && c.DocumentID == documentID {
+			c.Access = append([]uuid.UUID(nil), access...)
+			s.chunks[id] = c
+		}
+	}
+	return s.persist()
+}
+
+func (s *MemoryStore) SetSuperseded(ctx context.Context, tenantID, documentID uuid.UUID, superseded bool) error {
+	if tenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, c := range s.chunks {
+		if c.TenantID == tenantID && c.DocumentID == documentID {
+			c.Superseded = superseded
+			s.chunks[id] = c
+		}
+	}
+	return s.persist()
+}
+
+func (s *MemoryStore) Embeddings(ctx context.Context, lookup EmbeddingLookup) (map[string]StoredEmbedding, error) {
+	if lookup.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	hashes := make(map[string]bool, len(lookup.Hashes))
+	for _, h := range lookup.Hashes {
+		hashes[h] = true
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	embeddings := make(map[string]StoredEmbedding)
+	for _, c := range s.chunks {
+		if c.TenantID != lookup.TenantID || c.CollectionID != lookup.CollectionID || !hashes[c.ContentHash] {
+			continue
+		}
+		if !containsAny(lookup.DocumentIDs, []uuid.UUID{c.DocumentID}) {
+			continue
+		}
+		header, _ := c.Metadata["context_header"].(string)
+		embeddings[c.ContentHash] = StoredEmbedding{Embedding: append([]float32(nil), c.Embedding...), ContextHeader: header}
+	}
+	return embeddings, nil
+}
+
+// Len returns the number of stored chunks.
+func (s *MemoryStore) Sample(ctx context.Context, opts SampleOptions) ([]SearchResult, error) {
+	if opts.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search := SearchOptions{TenantID: opts.TenantID, CollectionIDs: opts.CollectionIDs, Principals: opts.Principals}
+	var matched []memoryChunk
+	for _, c := range s.chunks {
+		if !c.matches(search) {
+			continue
+		}
+		if len(opts.DocumentIDs) > 0 && !containsAny(opts.DocumentIDs, []uuid.UUID{c.DocumentID}) {
+			continue
+		}
+		matched = append(matched, c)
+	}
+	rand.Shuffle(len(matched), func(i, j int) { matched[i], matched[j] = matched[j], matched[i] })
+	if len(matched) > opts.Limit {
+		matched = matched[:opts.Limit]
+	}
+
+	results := make([]SearchResult, len(matched))
+	for i, c := range matched {
+		results[i] = s.result(c, 0, false)
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.chunks)
+}
+
+type scoredChunk struct {
+	chunk memoryChunk
+	score float64
+}
+
+// matches reports whether the chunk is within the search's tenant and
+// collections (the default pool if none), readable by its principals and, unless the search includes
+// old versions, current.
+func (c memoryChunk) matches(opts SearchOptions) bool {
+	if c.TenantID != opts.TenantID {
+		return false
+	}
+	if c.Superseded && !opts.IncludeSuperseded {
+		return false
+	}
+	if c.Access != nil && !containsAny(c.Access, opts.Principals) {
+		return false
+	}
+	if len(opts.CollectionIDs) == 0 {
+		return c.CollectionID == uuid.Nil
+	}
+	return containsAny(opts.CollectionIDs, []uuid.UUID{c.CollectionID})
+}
+
+func containsAny(set, ids []uuid.UUID) bool {
+	for _, a := range set {
+		for _, b := range ids {
+			if a == b {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vectorCandidates returns the k chunks matching opts nearest to query.
+// Callers hold the read lock.
+func (s *MemoryStore) vectorCandidates(query []float32, opts SearchOptions, k int) []scoredChunk {
+	var scored []scoredChunk
+	for _, c := range s.chunks {
+		if !c.matches(opts) {
+			continue
+		}
+		scored = append(scored, scoredChunk{chunk: c, score: cosineSimilarity(query, c.Embedding)})
+	}
+	return topScored(scored, k)
+}
+
+func (s *MemoryStore) result(c memoryChunk, score float64, withEmbedding bool) SearchResult {
+	r := SearchResult{
+		ChunkID:    c.ID,
+		DocumentID: c.DocumentID,
+		Content:    c.Content,
+		Score:      score,
+		ChunkIndex: c.ChunkIndex,
+		Metadata:   copyMetadata(c.Metadata),
+	}
+	if withEmbedding {
+		r.Embedding = append([]float32(nil), c.Embedding...)
+	}
+	if t, ok := s.docCreated[c.DocumentID]; ok {
+		r.CreatedAt = &t
+	}
+	if c.CollectionID != uuid.Nil {
+		id := c.CollectionID
+		r.CollectionID = &id
+	}
+	return r
+}
+
+// persist writes the store to disk via a temp file and rename, so a crash
+// never leaves a truncated file. Callers hold the write lock.
+func (s *MemoryStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	snap := memorySnapshot{Chunks: make([]Chunk, 0, len(s.chunks)), DocCreated: s.docCreated}
+	for _, c := range s.chunks {
+		snap.Chunks = append(snap.Chunks, c.Chunk)
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encode memory store: %w", err)
+	}
+	if dir := filepath.Dir(s.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("create memory store dir: %w", err)
+		}
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write memory store: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("rename memory store: %w", err)
+	}
+	return nil
+}
+
+// topScored sorts by descending score (ties by chunk ID, for stable output)
+// and keeps the first k.
+func topScored(scored []scoredChunk, k int) []scoredChunk {
+	sort.Slice(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].chunk.ID.String() < scored[j].chunk.ID.String()
+	})
+	if len(scored) > k {
+		scored = scored[:k]
+	}
+	return scored
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// stopWords are skipped by keyword scoring, like Postgres' english config.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "how": true, "in": true,
+	"is": true, "it": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "what": true, "when": true,
+	"where": true, "which": true, "who": true, "why": true, "with": true,
+}
+
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := words[:0]
+	for _, w := range words {
+		if !stopWords[w] {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+func termFrequencies(text string) map[string]int {
+	tf := make(map[string]int)
+	for _, t := range tokenize(text) {
+		tf[t]++
+	}
+	return tf
+}
+
+// queryTerms returns the distinct terms of a keyword query.
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(query) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// keywordScore is a ts_rank-like score in [0, 1): the mean over query terms
+// of tf/(tf+1), so repeated terms saturate. Zero means no term matched.
+func keywordScore(query []string, tf map[string]int) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+	var score float64
+	for _, t := range query {
+		if n := tf[t]; n > 0 {
+			score += float64(n) / float64(n+1)
+		}
+	}
+	return score / float64(len(query))
+}
+
+func copyMetadata(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
+
+// Ensure MemoryStore implements VectorStore.
+var _ VectorStore = (*MemoryStore)(nil)
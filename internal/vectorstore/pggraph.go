@@ -0,0 +1,371 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgvector/pgvector-go"
+)
+
+// PgGraphStore stores the GraphRAG knowledge graph in Postgres alongside
+// document_chunks, using pgvector for entity and community similarity.
+type PgGraphStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPgGraphStore(db *pgxpool.Pool) *PgGraphStore {
+	return &PgGraphStore{db: db}
+}
+
+func (s *PgGraphStore) UpsertEntities(ctx context.Context, entities []Entity) ([]Entity, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	out := make([]Entity, len(entities))
+	for i, e := range entities {
+		if e.Type == "" {
+			e.Type = "concept"
+		}
+
+		var embedding *pgvector.Vector
+		if len(e.Embedding) > 0 {
+			v := pgvector.NewVector(e.Embedding)
+			embedding = &v
+		}
+
+		// Keep the first description and embedding seen for an entity; later
+		// mentions only add chunk links and relations.
+		err := tx.QueryRow(ctx,
+			`INSERT INTO kg_entities (tenant_id, name, normalized_name, entity_type, description, embedding)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 ON CONFLICT (tenant_id, normalized_name, entity_type) DO UPDATE SET
+			     description = COALESCE(NULLIF(kg_entities.description, ''), EXCLUDED.description),
+			     embedding = COALESCE(kg_entities.embedding, EXCLUDED.embedding)
+			 RETURNING id`,
+			e.TenantID, e.Name, NormalizeEntityName(e.Name), e.Type, e.Description, embedding,
+		).Scan(&e.ID)
+		if err != nil {
+			return nil, fmt.Errorf("upsert entity %q: %w", e.Name, err)
+		}
+		out[i] = e
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("commit entities: %w", err)
+	}
+	return out, nil
+}
+
+func (s *PgGraphStore) AddMentions(ctx context.Context, mentions []EntityMention) error {
+	for _, m := range mentions {
+		_, err := s.db.Exec(ctx,
+			`INSERT INTO kg_entity_chunks (entity_id, chunk_id, document_id, tenant_id)
+			 VALUES ($1, $2, $3, $4)
+			 ON CONFLICT DO NOTHING`,
+			m.EntityID, m.ChunkID, m.DocumentID, m.TenantID,
+		)
+		if err != nil {
+			return fmt.Errorf("add entity mention: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *PgGraphStore) AddRelations(ctx context.Context, relations []Relation) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, r := range relations {
+		weight := r.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		_, err := tx.Exec(ctx,
+			`INSERT INTO kg_relations (tenant_id, source_entity_id, target_entity_id, relation, description, weight, chunk_id, document_id)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			r.TenantID, r.SourceID, r.TargetID, r.Type, r.Description, weight, r.ChunkID, r.DocumentID,
+		)
+		if err != nil {
+			return fmt.Errorf("insert relation %q: %w", r.Type, err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *PgGraphStore) ListRelations(ctx context.Context, tenantID uuid.UUID) ([]Relation, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT id, tenant_id, source_entity_id, target_entity_id, relation, COALESCE(description, ''), weight,
+		        COALESCE(chunk_id, '00000000-0000-0000-0000-000000000000'), COALESCE(document_id, '00000000-0000-0000-0000-000000000000')
+		 FROM kg_relations WHERE tenant_id = $1`,
+		tenantID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list relations: %w", err)
+	}
+	defer rows.Close()
+
+	var relations []Relation
+	for rows.Next() {
+		var r Relation
+		if err := rows.Scan(&r.ID, &r.TenantID, &r.SourceID, &r.TargetID, &r.Type, &r.Description, &r.Weight, &r.ChunkID, &r.DocumentID); err != nil {
+			return nil, fmt.Errorf("scan relation: %w", err)
+		}
+		relations = append(relations, r)
+	}
+	return relations, nil
+}
+
+func (s *PgGraphStore) GetEntities(ctx context.Context, tenantID uuid.UUID, ids []uuid.UUID) ([]Entity, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := s.db.Query(ctx,
+		`SELECT id, tenant_id, name, entity_type, COALESCE(description, '')
+		 FROM kg_entities WHERE tenant_id = $1 AND id = ANY($2)`,
+		tenantID, ids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get entities: %w", err)
+	}
+	defer rows.Close()
+
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Name, &e.Type, &e.Description); err != nil {
+			return nil, fmt.Errorf("scan entity: %w", err)
+		}
+		entities = append(entities, e)
+	}
+	return entities, nil
+}
+
+func (s *PgGraphStore) SearchEntities(ctx context.Context, query []float32, opts SearchOptions) ([]Entity, error) {
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT id, tenant_id, name, entity_type, COALESCE(description, ''),
+		        1 - (embedding <=> $1) AS score
+		 FROM kg_entities
+		 WHERE tenant_id = $2 AND embedding IS NOT NULL
+		 ORDER BY embedding <=> $1
+		 LIMIT $3`,
+		pgvector.NewVector(query), opts.TenantID, opts.TopK,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search entities: %w", err)
+	}
+	defer rows.Close()
+
+	var entities []Entity
+	for rows.Next() {
+		var e Entity
+		if err := rows.Scan(&e.ID, &e.TenantID, &e.Name, &e.Type, &e.Description, &e.Score); err != nil {
+			return nil, fmt.Errorf("scan entity: %w", err)
+		}
+		if opts.MinScore > 0 && e.Score < opts.MinScore {
+			continue
+		}
+		entities = append(entities, e)
+	}
+	return entities, nil
+}
+
+func (s *PgGraphStore) Neighbors(ctx context.Context, tenantID uuid.UUID, entityIDs []uuid.UUID, hops, limit int) ([]Entity, []Relation, error) {
+	if hops <= 0 {
+		hops = 1
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	visited := make(map[uuid.UUID]bool, len(entityIDs))
+	for _, id := range entityIDs {
+		visited[id] = true
+	}
+
+	frontier := entityIDs
+	var neighborIDs []uuid.UUID
+	var edges []Relation
+
+	for hop := 0; hop < hops && len(frontier) > 0 && len(neighborIDs) < limit; hop++ {
+		rows, err := s.db.Query(ctx,
+			`SELECT id, tenant_id, source_entity_id, target_entity_id, relation, COALESCE(description, ''), weight,
+			        COALESCE(chunk_id, '00000000-0000-0000-0000-000000000000'), COALESCE(document_id, '00000000-0000-0000-0000-000000000000')
+			 FROM kg_relations
+			 WHERE tenant_id = $1 AND (source_entity_id = ANY($2) OR target_entity_id = ANY($2))
+			 ORDER BY weight DESC
+			 LIMIT $3`,
+			tenantID, frontier, limit*4,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("expand neighbors: %w", err)
+		}
+
+		var next []uuid.UUID
+		for rows.Next() {
+			var r Relation
+			if err := rows.Scan(&r.ID, &r.TenantID, &r.SourceID, &r.TargetID, &r.Type, &r.Description, &r.Weight, &r.ChunkID, &r.DocumentID); err != nil {
+				rows.Close()
+				return nil, nil, fmt.Errorf("scan relation: %w", err)
+			}
+			edges = append(edges, r)
+			for _, id := range []uuid.UUID{r.SourceID, r.TargetID} {
+				if !visited[id] && len(neighborIDs) < limit {
+					visited[id] = true
+					next = append(next, id)
+					neighborIDs = append(neighborIDs, id)
+				}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("expand neighbors: %w", err)
+		}
+		frontier = next
+	}
+
+	neighbors, err := s.GetEntities(ctx, tenantID, neighborIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return neighbors, edges, nil
+}
+
+func (s *PgGraphStore) ChunksForEntities(ctx context.Context, entityIDs []uuid.UUID, opts SearchOptions) ([]SearchResult, error) {
+	if len(entityIDs) == 0 {
+		return nil, nil
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	var results []SearchResult
+	err := withTenant(ctx, s.db, opts.TenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			`SELECT c.id, c.document_id, c.content, c.chunk_index, c.metadata,
+			        COUNT(DISTINCT m.entity_id)::float8 / $3 AS score
+			 FROM kg_entity_chunks m
+			 JOIN document_chunks c ON c.id = m.chunk_id
+			 WHERE m.tenant_id = $1 AND m.entity_id = ANY($2) AND c.tenant_id = $1
+			   AND (c.collection_id = ANY($5::uuid[]) OR ($5::uuid[] IS NULL AND c.collection_id IS NULL))
+			   AND (c.access IS NULL OR c.access && $6::uuid[]) AND ($7 OR NOT c.superseded)
+			 GROUP BY c.id
+			 ORDER BY score DESC
+			 LIMIT $4`,
+			opts.TenantID, entityIDs, float64(len(entityIDs)), opts.TopK,
+			idFilter(opts.CollectionIDs), principals(opts.Principals), opts.IncludeSuperseded,
+		)
+		if err != nil {
+			return fmt.Errorf("chunks for entities: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var r SearchResult
+			if err := rows.Scan(&r.ChunkID, &r.DocumentID, &r.Content, &r.ChunkIndex, &r.Metadata, &r.Score); err != nil {
+				return fmt.Errorf("scan chunk: %w", err)
+			}
+			results = append(results, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *PgGraphStore) ReplaceCommunities(ctx context.Context, tenantID uuid.UUID, communities []Community) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "UPDATE kg_entities SET community_id = NULL WHERE tenant_id = $1", tenantID); err != nil {
+		return fmt.Errorf("clear entity communities: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM kg_communities WHERE tenant_id = $1", tenantID); err != nil {
+		return fmt.Errorf("delete communities: %w", err)
+	}
+
+	for _, c := range communities {
+		id := c.ID
+		if id == uuid.Nil {
+			id = uuid.New()
+		}
+
+		var embedding *pgvector.Vector
+		if len(c.Embedding) > 0 {
+			v := pgvector.NewVector(c.Embedding)
+			embedding = &v
+		}
+
+		_, err := tx.Exec(ctx,
+			`INSERT INTO kg_communities (id, tenant_id, level, summary, embedding, entity_count)
+			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			id, tenantID, c.Level, c.Summary, embedding, len(c.EntityIDs),
+		)
+		if err != nil {
+			return fmt.Errorf("insert community: %w", err)
+		}
+
+		_, err = tx.Exec(ctx,
+			"UPDATE kg_entities SET community_id = $1 WHERE tenant_id = $2 AND id = ANY($3)",
+			id, tenantID, c.EntityIDs,
+		)
+		if err != nil {
+			return fmt.Errorf("assign community: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *PgGraphStore) SearchCommunities(ctx context.Context, query []float32, opts SearchOptions) ([]Community, error) {
+	if opts.TopK <= 0 {
+		opts.TopK = 3
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT id, tenant_id, level, summary, 1 - (embedding <=> $1) AS score
+		 FROM kg_communities
+		 WHERE tenant_id = $2 AND embedding IS NOT NULL
+		 ORDER BY embedding <=> $1
+		 LIMIT $3`,
+		pgvector.NewVector(query), opts.TenantID, opts.TopK,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search communities: %w", err)
+	}
+	defer rows.Close()
+
+	var communities []Community
+	for rows.Next() {
+		var c Community
+		if err := rows.Scan(&c.ID, &c.TenantID, &c.Level, &c.Summary, &c.Score); err != nil {
+			return nil, fmt.Errorf("scan community: %w", err)
+		}
+		if opts.MinScore > 0 && c.Score < opts.MinScore {
+			continue
+		}
+		communities = append(communities, c)
+	}
+	return communities, nil
+}
+
+// Ensure PgGraphStore implements GraphStore.
+var _ GraphStore = (*PgGraphStore)(nil)
@@ -0,0 +1,372 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Index methods for IndexSpec.Method.
+const (
+	IndexHNSW    = "hnsw"
+	IndexIVFFlat = "ivfflat"
+)
+
+// IndexSpec describes an approximate-nearest-neighbour index on
+// document_chunks.embedding.
+type IndexSpec struct {
+	Method string `json:"method"` // "hnsw" (default) or "ivfflat"
+	// HNSW build parameters: connections per node (default 16) and
+	// candidate list size while building (default 64).
+	M              int `json:"m,omitempty"`
+	EfConstruction int `json:"ef_construction,omitempty"`
+	// Lists is the IVFFlat cluster count. By default rows/1000 up to a
+	// million rows and sqrt(rows) beyond, at least 10.
+	Lists int `json:"lists,omitempty"`
+	// TenantID builds a partial index over one tenant's chunks only. It plays
+	// the role of a per-tenant partition: the graph holds no other tenants'
+	// vectors, so filtered searches keep their recall. (The table itself is
+	// not partitioned, since several tables reference document_chunks(id).)
+	// The planner only uses it with a custom plan, which knows the searched
+	// tenant; PgVectorStore searches force custom plans for this.
+	TenantID *uuid.UUID `json:"tenant_id,omitempty"`
+}
+
+func (s IndexSpec) Validate() error {
+	switch s.Method {
+	case "", IndexHNSW:
+		if s.M != 0 && (s.M < 2 || s.M > 100) {
+			return fmt.Errorf("m must be between 2 and 100")
+		}
+		if s.EfConstruction != 0 && (s.EfConstruction < 4 || s.EfConstruction > 1000) {
+			return fmt.Errorf("ef_construction must be between 4 and 1000")
+		}
+		if s.M != 0 && s.EfConstruction != 0 && s.EfConstruction < 2*s.M {
+			return fmt.Errorf("ef_construction must be at least twice m")
+		}
+		if s.Lists != 0 {
+			return fmt.Errorf("lists applies to ivfflat indexes only")
+		}
+	case IndexIVFFlat:
+		if s.Lists < 0 || s.Lists > 32768 {
+			return fmt.Errorf("lists must be between 1 and 32768")
+		}
+		if s.M != 0 || s.EfConstruction != 0 {
+			return fmt.Errorf("m and ef_construction apply to hnsw indexes only")
+		}
+	default:
+		return fmt.Errorf("unknown index method %q (want hnsw or ivfflat)", s.Method)
+	}
+	return nil
+}
+
+// Name is the index's name; rebuilding a spec replaces the index of the same
+// name.
+func (s IndexSpec) Name() string {
+	method := s.Method
+	if method == "" {
+		method = IndexHNSW
+	}
+	if s.TenantID != nil {
+		return "dc_embedding_" + method + "_" + strings.ReplaceAll(s.TenantID.String(), "-", "")
+	}
+	return "document_chunks_embedding_" + method + "_idx"
+}
+
+// VectorIndex is an existing vector index on document_chunks.
+type VectorIndex struct {
+	Name       string     `json:"name"`
+	Method     string     `json:"method"`
+	Definition string     `json:"definition"`
+	TenantID   *uuid.UUID `json:"tenant_id,omitempty"`
+	SizeBytes  int64      `json:"size_bytes"`
+	// Valid is false for an index left behind by a failed concurrent build.
+	Valid bool `json:"valid"`
+}
+
+// IndexProgress receives build progress: the Postgres build phase and an
+// overall percentage that never decreases.
+type IndexProgress func(phase string, percent int)
+
+// PgIndexManager creates, rebuilds and drops the vector indexes of
+// document_chunks. Builds run CONCURRENTLY so searches and ingestion
+// continue while an index is built.
+type PgIndexManager struct {
+	db           *pgxpool.Pool
+	pollInterval time.Duration
+}
+
+func NewPgIndexManager(db *pgxpool.Pool) *PgIndexManager {
+	return &PgIndexManager{db: db, pollInterval: 2 * time.Second}
+}
+
+var partialTenantRe = regexp.MustCompile(`tenant_id = '([0-9a-f-]{36})'::uuid`)
+
+func (m *PgIndexManager) ListIndexes(ctx context.Context) ([]VectorIndex, error) {
+	rows, err := m.db.Query(ctx,
+		`SELECT i.relname, am.amname, pg_get_indexdef(i.oid), ix.indisvalid, pg_relation_size(i.oid)
+		 FROM pg_index ix
+		 JOIN pg_class i ON i.oid = ix.indexrelid
+		 JOIN pg_am am ON am.oid = i.relam
+		 WHERE ix.indrelid = 'document_chunks'::regclass AND am.amname IN ('hnsw', 'ivfflat')
+		 ORDER BY i.relname`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list vector indexes: %w", err)
+	}
+	defer rows.Close()
+
+	var indexes []VectorIndex
+	for rows.Next() {
+		var idx VectorIndex
+		if err := rows.Scan(&idx.Name, &idx.Method, &idx.Definition, &idx.Valid, &idx.SizeBytes); err != nil {
+			return nil, fmt.Errorf("scan vector index: %w", err)
+		}
+		if m := partialTenantRe.FindStringSubmatch(idx.Definition); m != nil {
+			if id, err := uuid.Parse(m[1]); err == nil {
+				idx.TenantID = &id
+			}
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// BuildIndex builds the index described by spec under a temporary name,
+// then drops every other vector index with the same scope (global, or the
+// same tenant) and renames the new one into place. Searches use the old
+// index until the new one is ready.
+func (m *PgIndexManager) BuildIndex(ctx context.Context, spec IndexSpec, progress IndexProgress) (*VectorIndex, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(string, int) {}
+	}
+
+	ddl, err := m.createIndexSQL(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+	name := spec.Name()
+	tmp := name + "_new"
+
+	existing, err := m.ListIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A failed earlier build leaves an invalid index behind.
+	if err := m.drop(ctx, tmp); err != nil {
+		return nil, err
+	}
+
+	progress("initializing", 0)
+	if err := m.buildConcurrently(ctx, fmt.Sprintf(ddl, pgx.Identifier{tmp}.Sanitize()), progress); err != nil {
+		_ = m.drop(context.WithoutCancel(ctx), tmp)
+		return nil, fmt.Errorf("build index: %w", err)
+	}
+
+	progress("replacing previous index", 99)
+	for _, idx := range existing {
+		if idx.Name != tmp && sameTenant(idx.TenantID, spec.TenantID) {
+			if err := m.drop(ctx, idx.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, err := m.db.Exec(ctx, fmt.Sprintf("ALTER INDEX %s RENAME TO %s",
+		pgx.Identifier{tmp}.Sanitize(), pgx.Identifier{name}.Sanitize())); err != nil {
+		return nil, fmt.Errorf("rename index: %w", err)
+	}
+	progress("done", 100)
+
+	indexes, err := m.ListIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return &idx, nil
+		}
+	}
+	return nil, fmt.Errorf("index %s missing after build", name)
+}
+
+// DropIndex drops a vector index of document_chunks by name. Other indexes
+// cannot be dropped through it.
+func (m *PgIndexManager) DropIndex(ctx context.Context, name string) error {
+	indexes, err := m.ListIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		if idx.Name == name {
+			return m.drop(ctx, name)
+		}
+	}
+	return fmt.Errorf("vector index %q not found", name)
+}
+
+// countChunks counts a tenant's chunks, or estimates the whole table's from
+// the planner statistics: row-level security hides other tenants' rows from
+// an exact count, and an estimate is all sizing the lists needs.
+func (m *PgIndexManager) countChunks(ctx context.Context, tenantID *uuid.UUID) (int64, error) {
+	var rows int64
+	if tenantID == nil {
+		err := m.db.QueryRow(ctx,
+			"SELECT GREATEST(reltuples, 0)::bigint FROM pg_class WHERE oid = 'document_chunks'::regclass",
+		).Scan(&rows)
+		if err != nil {
+			return 0, fmt.Errorf("estimate chunks: %w", err)
+		}
+		return rows, nil
+	}
+
+	err := withTenant(ctx, m.db, *tenantID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, "SELECT count(*) FROM document_chunks WHERE tenant_id = $1", *tenantID).Scan(&rows)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("count chunks: %w", err)
+	}
+	return rows, nil
+}
+
+// createIndexSQL renders the CREATE INDEX statement with a %s placeholder
+// for the index name.
+func (m *PgIndexManager) createIndexSQL(ctx context.Context, spec IndexSpec) (string, error) {
+	var with string
+	switch spec.Method {
+	case IndexIVFFlat:
+		lists := spec.Lists
+		if lists == 0 {
+			rows, err := m.countChunks(ctx, spec.TenantID)
+			if err != nil {
+				return "", err
+			}
+			lists = defaultLists(rows)
+		}
+		with = fmt.Sprintf("ivfflat (embedding vector_cosine_ops) WITH (lists = %d)", lists)
+	default:
+		mConn, ef := spec.M, spec.EfConstruction
+		if mConn == 0 {
+			mConn = 16
+		}
+		if ef == 0 {
+			ef = 64
+		}
+		with = fmt.Sprintf("hnsw (embedding vector_cosine_ops) WITH (m = %d, ef_construction = %d)", mConn, ef)
+	}
+
+	ddl := "CREATE INDEX CONCURRENTLY %s ON document_chunks USING " + with
+	if spec.TenantID != nil {
+		// A literal, not a parameter: the planner can only use a partial
+		// index when the query's tenant matches its predicate. Searches pass
+		// the tenant as a parameter, so only a custom plan, made with its
+		// value, qualifies (see withSearchTuning).
+		ddl += fmt.Sprintf(" WHERE tenant_id = '%s'::uuid", spec.TenantID.String())
+	}
+	return ddl, nil
+}
+
+// defaultLists follows pgvector's guidance for IVFFlat list counts.
+func defaultLists(rows int64) int {
+	lists := int(rows / 1000)
+	if rows > 1_000_000 {
+		lists = int(math.Sqrt(float64(rows)))
+	}
+	return max(lists, 10)
+}
+
+// buildConcurrently runs the CREATE INDEX on a dedicated connection and
+// reports pg_stat_progress_create_index for it until the build finishes.
+func (m *PgIndexManager) buildConcurrently(ctx context.Context, ddl string, progress IndexProgress) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var pid int32
+	if err := conn.QueryRow(ctx, "SELECT pg_backend_pid()").Scan(&pid); err != nil {
+		return fmt.Errorf("get backend pid: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, ddl)
+		done <- err
+	}()
+
+	ticker := time.NewTicker(m.pollInterval)
+	defer ticker.Stop()
+	last := 0
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			phase, percent, ok := m.buildProgress(ctx, pid)
+			if !ok {
+				continue
+			}
+			last = max(last, percent)
+			progress(phase, last)
+		}
+	}
+}
+
+// buildProgress maps a build's phase and counters to an overall percentage:
+// building the index is 5-85%, validating it (concurrent builds) 85-98%.
+func (m *PgIndexManager) buildProgress(ctx context.Context, pid int32) (string, int, bool) {
+	var phase string
+	var blocksTotal, blocksDone, tuplesTotal, tuplesDone int64
+	err := m.db.QueryRow(ctx,
+		`SELECT phase, blocks_total, blocks_done, tuples_total, tuples_done
+		 FROM pg_stat_progress_create_index WHERE pid = $1`,
+		pid,
+	).Scan(&phase, &blocksTotal, &blocksDone, &tuplesTotal, &tuplesDone)
+	if err != nil {
+		return "", 0, false
+	}
+
+	fraction := 0.0
+	switch {
+	case tuplesTotal > 0:
+		fraction = float64(tuplesDone) / float64(tuplesTotal)
+	case blocksTotal > 0:
+		fraction = float64(blocksDone) / float64(blocksTotal)
+	}
+
+	switch {
+	case strings.HasPrefix(phase, "building index"):
+		return phase, 5 + int(fraction*80), true
+	case strings.HasPrefix(phase, "index validation"):
+		return phase, 85 + int(fraction*13), true
+	case strings.Contains(phase, "before validation"):
+		return phase, 85, true
+	default:
+		return phase, 0, true
+	}
+}
+
+func (m *PgIndexManager) drop(ctx context.Context, name string) error {
+	if _, err := m.db.Exec(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return fmt.Errorf("drop index %s: %w", name, err)
+	}
+	return nil
+}
+
+func sameTenant(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
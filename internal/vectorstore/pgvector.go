@@ -3,8 +3,10 @@ package vectorstore
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pgvector/pgvector-go"
 )
@@ -18,32 +20,43 @@ func NewPgVectorStore(db *pgxpool.Pool) *PgVectorStore {
 }
 
 func (s *PgVectorStore) Upsert(ctx context.Context, chunks []Chunk) error {
-	tx, err := s.db.Begin(ctx)
+	if len(chunks) == 0 {
+		return nil
+	}
+	tenantID, err := chunksTenant(chunks)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return err
 	}
-	defer tx.Rollback(ctx)
 
-	for _, c := range chunks {
-		id := c.ID
-		if id == uuid.Nil {
-			id = uuid.New()
-		}
+	return withTenant(ctx, s.db, tenantID, func(tx pgx.Tx) error {
+		for _, c := range chunks {
+			id := c.ID
+			if id == uuid.Nil {
+				id = uuid.New()
+			}
 
-		embedding := pgvector.NewVector(c.Embedding)
+			embedding := pgvector.NewVector(c.Embedding)
 
-		_, err := tx.Exec(ctx,
-			`INSERT INTO document_chunks (id, document_id, tenant_id, chunk_index, content, embedding, token_count, metadata)
-			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-			 ON CONFLICT (id) DO UPDATE SET content = $5, embedding = $6, token_count = $7, metadata = $8`,
-			id, c.DocumentID, c.TenantID, c.ChunkIndex, c.Content, embedding, c.TokenCount, c.Metadata,
-		)
-		if err != nil {
-			return fmt.Errorf("upsert chunk %d: %w", c.ChunkIndex, err)
-		}
-	}
+			var collectionID *uuid.UUID
+			if c.CollectionID != uuid.Nil {
+				collectionID = &c.CollectionID
+			}
 
-	return tx.Commit(ctx)
+			_, err := tx.Exec(ctx,
+				`INSERT INTO document_chunks (id, document_id, tenant_id, chunk_index, content, embedding, token_count, metadata, collection_id, access,
+				                             content_hash, superseded)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+				 ON CONFLICT (id) DO UPDATE SET content = $5, embedding = $6, token_count = $7, metadata = $8, collection_id = $9, access = $10,
+				                                content_hash = NULLIF($11, ''), superseded = $12`,
+				id, c.DocumentID, c.TenantID, c.ChunkIndex, c.Content, embedding, c.TokenCount, c.Metadata, collectionID, accessList(c.Access),
+				c.ContentHash, c.Superseded,
+			)
+			if err != nil {
+				return fmt.Errorf("upsert chunk %d: %w", c.ChunkIndex, err)
+			}
+		}
+		return nil
+	})
 }
 
 func (s *PgVectorStore) SimilaritySearch(ctx context.Context, query []float32, opts SearchOptions) ([]SearchResult, error) {
@@ -53,30 +66,29 @@ func (s *PgVectorStore) SimilaritySearch(ctx context.Context, query []float32, o
 
 	embedding := pgvector.NewVector(query)
 
-	rows, err := s.db.Query(ctx,
-		`SELECT id, document_id, content, chunk_index, metadata,
-		        1 - (embedding <=> $1) AS score
-		 FROM document_chunks
-		 WHERE tenant_id = $2
-		 ORDER BY embedding <=> $1
-		 LIMIT $3`,
-		embedding, opts.TenantID, opts.TopK,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("similarity search: %w", err)
-	}
-	defer rows.Close()
-
 	var results []SearchResult
-	for rows.Next() {
-		var r SearchResult
-		if err := rows.Scan(&r.ChunkID, &r.DocumentID, &r.Content, &r.ChunkIndex, &r.Metadata, &r.Score); err != nil {
-			return nil, fmt.Errorf("scan result: %w", err)
-		}
-		if opts.MinScore > 0 && r.Score < opts.MinScore {
-			continue
+	err := s.withSearchTuning(ctx, opts.TenantID, opts.Tuning, opts.TopK, func(q pgx.Tx) error {
+		rows, err := q.Query(ctx,
+			`SELECT c.id, c.document_id, c.content, c.chunk_index, c.metadata, c.collection_id,
+			        CASE WHEN $7 THEN c.embedding END, d.created_at,
+			        1 - (c.embedding <=> $1) AS score
+			 FROM document_chunks c
+			 LEFT JOIN documents d ON d.id = c.document_id
+			 WHERE c.tenant_id = $2 AND (c.collection_id = ANY($4::uuid[]) OR ($4::uuid[] IS NULL AND c.collection_id IS NULL))
+			   AND (c.access IS NULL OR c.access && $5::uuid[]) AND ($6 OR NOT c.superseded)
+			 ORDER BY c.embedding <=> $1
+			 LIMIT $3`,
+			embedding, opts.TenantID, opts.TopK, idFilter(opts.CollectionIDs), principals(opts.Principals), opts.IncludeSuperseded,
+			opts.WithEmbeddings,
+		)
+		if err != nil {
+			return fmt.Errorf("similarity search: %w", err)
 		}
-		results = append(results, r)
+		results, err = scanResults(rows, opts.MinScore, "scan result")
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 	return results, nil
 }
@@ -89,62 +101,280 @@ func (s *PgVectorStore) HybridSearch(ctx context.Context, query string, queryVec
 	embedding := pgvector.NewVector(queryVec)
 
 	// Hybrid: combine vector similarity with keyword (FTS) ranking
-	rows, err := s.db.Query(ctx,
-		`WITH vector_results AS (
-			SELECT id, document_id, content, chunk_index, metadata,
-			       1 - (embedding <=> $1) AS vector_score
-			FROM document_chunks
-			WHERE tenant_id = $2
-			ORDER BY embedding <=> $1
-			LIMIT $3 * 2
-		),
-		keyword_results AS (
-			SELECT id, document_id, content, chunk_index, metadata,
-			       ts_rank(tsv, plainto_tsquery('english', $4)) AS keyword_score
-			FROM document_chunks
-			WHERE tenant_id = $2 AND tsv @@ plainto_tsquery('english', $4)
-			LIMIT $3 * 2
+	var results []SearchResult
+	err := s.withSearchTuning(ctx, opts.TenantID, opts.Tuning, opts.TopK*2, func(q pgx.Tx) error {
+		rows, err := q.Query(ctx,
+			`WITH vector_results AS (
+				SELECT id, document_id, content, chunk_index, metadata, collection_id,
+				       1 - (embedding <=> $1) AS vector_score
+				FROM document_chunks
+				WHERE tenant_id = $2 AND (collection_id = ANY($5::uuid[]) OR ($5::uuid[] IS NULL AND collection_id IS NULL))
+				  AND (access IS NULL OR access && $6::uuid[]) AND ($7 OR NOT superseded)
+				ORDER BY embedding <=> $1
+				LIMIT $3 * 2
+			),
+			keyword_results AS (
+				SELECT id, document_id, content, chunk_index, metadata, collection_id,
+				       ts_rank(tsv, plainto_tsquery('english', $4)) AS keyword_score
+				FROM document_chunks
+				WHERE tenant_id = $2 AND tsv @@ plainto_tsquery('english', $4)
+				  AND (collection_id = ANY($5::uuid[]) OR ($5::uuid[] IS NULL AND collection_id IS NULL))
+				  AND (access IS NULL OR access && $6::uuid[]) AND ($7 OR NOT superseded)
+				LIMIT $3 * 2
+			)
+			SELECT COALESCE(v.id, k.id) AS id,
+			       COALESCE(v.document_id, k.document_id) AS document_id,
+			       COALESCE(v.content, k.content) AS content,
+			       COALESCE(v.chunk_index, k.chunk_index) AS chunk_index,
+			       COALESCE(v.metadata, k.metadata) AS metadata,
+			       COALESCE(v.collection_id, k.collection_id) AS collection_id,
+			       e.embedding,
+			       d.created_at,
+			       (COALESCE(v.vector_score, 0) * 0.7 + COALESCE(k.keyword_score, 0) * 0.3) AS score
+			FROM vector_results v
+			FULL OUTER JOIN keyword_results k ON v.id = k.id
+			LEFT JOIN documents d ON d.id = COALESCE(v.document_id, k.document_id)
+			LEFT JOIN document_chunks e ON $8 AND e.id = COALESCE(v.id, k.id)
+			ORDER BY score DESC
+			LIMIT $3`,
+			embedding, opts.TenantID, opts.TopK, query, idFilter(opts.CollectionIDs), principals(opts.Principals), opts.IncludeSuperseded,
+			opts.WithEmbeddings,
 		)
-		SELECT COALESCE(v.id, k.id) AS id,
-		       COALESCE(v.document_id, k.document_id) AS document_id,
-		       COALESCE(v.content, k.content) AS content,
-		       COALESCE(v.chunk_index, k.chunk_index) AS chunk_index,
-		       COALESCE(v.metadata, k.metadata) AS metadata,
-		       (COALESCE(v.vector_score, 0) * 0.7 + COALESCE(k.keyword_score, 0) * 0.3) AS score
-		FROM vector_results v
-		FULL OUTER JOIN keyword_results k ON v.id = k.id
-		ORDER BY score DESC
-		LIMIT $3`,
-		embedding, opts.TenantID, opts.TopK, query,
-	)
+		if err != nil {
+			return fmt.Errorf("hybrid search: %w", err)
+		}
+		results, err = scanResults(rows, opts.MinScore, "scan hybrid result")
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("hybrid search: %w", err)
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *PgVectorStore) Sample(ctx context.Context, opts SampleOptions) ([]SearchResult, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+
+	var results []SearchResult
+	err := withTenant(ctx, s.db, opts.TenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			`SELECT c.id, c.document_id, c.content, c.chunk_index, c.metadata, c.collection_id, NULL::vector, d.created_at,
+			        0::float8 AS score
+			 FROM document_chunks c
+			 LEFT JOIN documents d ON d.id = c.document_id
+			 WHERE c.tenant_id = $1 AND ($2::uuid[] IS NULL OR c.document_id = ANY($2::uuid[]))
+			   AND (c.collection_id = ANY($3::uuid[]) OR ($3::uuid[] IS NULL AND c.collection_id IS NULL))
+			   AND (c.access IS NULL OR c.access && $4::uuid[]) AND NOT c.superseded
+			 ORDER BY random()
+			 LIMIT $5`,
+			opts.TenantID, idFilter(opts.DocumentIDs), idFilter(opts.CollectionIDs), principals(opts.Principals), opts.Limit,
+		)
+		if err != nil {
+			return fmt.Errorf("sample chunks: %w", err)
+		}
+		results, err = scanResults(rows, 0, "scan sample")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// withTenant runs fn in a transaction scoped to tenantID. document_chunks
+// enforces row-level security on the app.tenant_id setting, so statements
+// outside such a transaction see no rows at all.
+func withTenant(ctx context.Context, db *pgxpool.Pool, tenantID uuid.UUID, fn func(pgx.Tx) error) error {
+	if tenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Transaction-local, so the setting never leaks to other users of the
+	// pooled connection.
+	if _, err := tx.Exec(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenantID.String()); err != nil {
+		return fmt.Errorf("set tenant: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
 	}
+	return tx.Commit(ctx)
+}
+
+// maxEfSearch is pgvector's upper bound for hnsw.ef_search.
+const maxEfSearch = 1000
+
+// withSearchTuning runs fn in the tenant's transaction with the query's
+// index search parameters applied. An HNSW scan returns at most ef_search
+// rows, so ef_search is raised to limit (the rows the query needs) whenever
+// it would otherwise cap the result. Parameters are set transaction-locally
+// like the tenant.
+//
+// Searches also force custom plans. pgx prepares its statements, and after a
+// few executions Postgres may switch to a generic plan, made without the
+// tenant's value. A generic plan can't use a per-tenant partial index
+// (IndexSpec.TenantID), because it can't prove "tenant_id = $2" implies
+// the index predicate.
+func (s *PgVectorStore) withSearchTuning(ctx context.Context, tenantID uuid.UUID, tuning SearchTuning, limit int, fn func(pgx.Tx) error) error {
+	ef := tuning.EfSearch
+	if ef > 0 || limit > 40 {
+		ef = min(max(ef, limit), maxEfSearch)
+	}
+
+	return withTenant(ctx, s.db, tenantID, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT set_config('plan_cache_mode', 'force_custom_plan', true)"); err != nil {
+			return fmt.Errorf("set plan_cache_mode: %w", err)
+		}
+		if ef > 0 {
+			if _, err := tx.Exec(ctx, "SELECT set_config('hnsw.ef_search', $1, true)", strconv.Itoa(ef)); err != nil {
+				return fmt.Errorf("set hnsw.ef_search: %w", err)
+			}
+		}
+		if tuning.Probes > 0 {
+			if _, err := tx.Exec(ctx, "SELECT set_config('ivfflat.probes', $1, true)", strconv.Itoa(tuning.Probes)); err != nil {
+				return fmt.Errorf("set ivfflat.probes: %w", err)
+			}
+		}
+		return fn(tx)
+	})
+}
+
+// scanResults reads search rows, dropping those below minScore.
+func scanResults(rows pgx.Rows, minScore float64, what string) ([]SearchResult, error) {
 	defer rows.Close()
 
 	var results []SearchResult
 	for rows.Next() {
 		var r SearchResult
-		if err := rows.Scan(&r.ChunkID, &r.DocumentID, &r.Content, &r.ChunkIndex, &r.Metadata, &r.Score); err != nil {
-			return nil, fmt.Errorf("scan hybrid result: %w", err)
+		var vec *pgvector.Vector
+		if err := rows.Scan(&r.ChunkID, &r.DocumentID, &r.Content, &r.ChunkIndex, &r.Metadata, &r.CollectionID, &vec, &r.CreatedAt, &r.Score); err != nil {
+			return nil, fmt.Errorf("%s: %w", what, err)
+		}
+		if vec != nil {
+			r.Embedding = vec.Slice()
 		}
-		if opts.MinScore > 0 && r.Score < opts.MinScore {
+		if minScore > 0 && r.Score < minScore {
 			continue
 		}
 		results = append(results, r)
 	}
-	return results, nil
+	return results, rows.Err()
 }
 
 func (s *PgVectorStore) Delete(ctx context.Context, filter DeleteFilter) error {
+	query := "DELETE FROM document_chunks WHERE tenant_id = $1"
+	args := []interface{}{filter.TenantID}
 	if filter.DocumentID != uuid.Nil {
-		_, err := s.db.Exec(ctx,
-			"DELETE FROM document_chunks WHERE document_id = $1 AND tenant_id = $2",
-			filter.DocumentID, filter.TenantID,
-		)
+		args = append(args, filter.DocumentID)
+		query += fmt.Sprintf(" AND document_id = $%d", len(args))
+	}
+	if filter.CollectionID != uuid.Nil {
+		args = append(args, filter.CollectionID)
+		query += fmt.Sprintf(" AND collection_id = $%d", len(args))
+	}
+	if filter.DefaultPool {
+		query += " AND collection_id IS NULL"
+	}
+
+	return withTenant(ctx, s.db, filter.TenantID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, query, args...)
 		return err
+	})
+}
+
+func (s *PgVectorStore) SetAccess(ctx context.Context, tenantID, documentID uuid.UUID, access []uuid.UUID) error {
+	return withTenant(ctx, s.db, tenantID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			"UPDATE document_chunks SET access = $3 WHERE tenant_id = $1 AND document_id = $2",
+			tenantID, documentID, accessList(access),
+		)
+		if err != nil {
+			return fmt.Errorf("set chunk access: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *PgVectorStore) SetSuperseded(ctx context.Context, tenantID, documentID uuid.UUID, superseded bool) error {
+	return withTenant(ctx, s.db, tenantID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			"UPDATE document_chunks SET superseded = $3 WHERE tenant_id = $1 AND document_id = $2",
+			tenantID, documentID, superseded,
+		)
+		if err != nil {
+			return fmt.Errorf("set chunks superseded: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *PgVectorStore) Embeddings(ctx context.Context, lookup EmbeddingLookup) (map[string]StoredEmbedding, error) {
+	embeddings := make(map[string]StoredEmbedding)
+	if len(lookup.DocumentIDs) == 0 || len(lookup.Hashes) == 0 {
+		return embeddings, nil
+	}
+	var collectionID *uuid.UUID
+	if lookup.CollectionID != uuid.Nil {
+		collectionID = &lookup.CollectionID
 	}
 
-	_, err := s.db.Exec(ctx, "DELETE FROM document_chunks WHERE tenant_id = $1", filter.TenantID)
-	return err
+	err := withTenant(ctx, s.db, lookup.TenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			`SELECT DISTINCT ON (content_hash) content_hash, embedding, COALESCE(metadata->>'context_header', '')
+			 FROM document_chunks
+			 WHERE tenant_id = $1 AND document_id = ANY($2) AND collection_id IS NOT DISTINCT FROM $3
+			   AND content_hash = ANY($4)`,
+			lookup.TenantID, lookup.DocumentIDs, collectionID, lookup.Hashes,
+		)
+		if err != nil {
+			return fmt.Errorf("look up embeddings: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var hash, header string
+			var vec pgvector.Vector
+			if err := rows.Scan(&hash, &vec, &header); err != nil {
+				return fmt.Errorf("scan embedding: %w", err)
+			}
+			embeddings[hash] = StoredEmbedding{Embedding: vec.Slice(), ContextHeader: header}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+// accessList stores an empty access list as NULL (unrestricted).
+func accessList(access []uuid.UUID) []uuid.UUID {
+	if len(access) == 0 {
+		return nil
+	}
+	return access
+}
+
+// principals returns the caller's principals as a non-NULL array, so
+// restricted chunks never match a caller without any.
+func principals(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
+
+// idFilter returns IDs as a query argument, or nil (SQL NULL, no filter)
+// when there are none.
+func idFilter(ids []uuid.UUID) []uuid.UUID {
+	if len(ids) == 0 {
+		return nil
+	}
+	return ids
 }
@@ -0,0 +1,669 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QdrantConfig holds configuration for the Qdrant backend.
+type QdrantConfig struct {
+	URL        string // default: "http://localhost:6333"
+	APIKey     string
+	Collection string // default: "document_chunks"
+}
+
+// QdrantStore stores chunks as points in a single Qdrant collection, speaking
+// Qdrant's REST API. Tenant and document IDs are payload fields with keyword
+// indexes, and chunk content has a full-text index for hybrid search. The
+// collection is created on first write, sized by the first embedding.
+type QdrantStore struct {
+	cfg        QdrantConfig
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	ready bool
+}
+
+// NewQdrantStore creates a QdrantStore with defaults applied.
+func NewQdrantStore(cfg QdrantConfig) *QdrantStore {
+	if cfg.URL == "" {
+		cfg.URL = "http://localhost:6333"
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = "document_chunks"
+	}
+	cfg.URL = strings.TrimRight(cfg.URL, "/")
+	return &QdrantStore{
+		cfg: cfg,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+type qdrantPoint struct {
+	ID      string        `json:"id"`
+	Vector  []float32     `json:"vector"`
+	Payload qdrantPayload `json:"payload"`
+}
+
+type qdrantPayload struct {
+	DocumentID string                 `json:"document_id"`
+	TenantID   string                 `json:"tenant_id"`
+	ChunkIndex int                    `json:"chunk_index"`
+	Content    string                 `json:"content"`
+	TokenCount int                    `json:"token_count"`
+	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt  *time.Time             `json:"created_at,omitempty"`
+	// CollectionID is empty for chunks outside any collection.
+	CollectionID string `json:"collection_id,omitempty"`
+	// Access is absent for unrestricted chunks.
+	Access      []string `json:"access,omitempty"`
+	ContentHash string   `json:"content_hash,omitempty"`
+	// Superseded is absent for chunks of current document versions.
+	Superseded bool `json:"superseded,omitempty"`
+}
+
+type qdrantScoredPoint struct {
+	ID      string        `json:"id"`
+	Score   float64       `json:"score"`
+	Payload qdrantPayload `json:"payload"`
+	Vector  []float32     `json:"vector"`
+}
+
+type qdrantCondition struct {
+	Key     string            `json:"key,omitempty"`
+	Match   *qdrantMatchValue `json:"match,omitempty"`
+	IsEmpty *qdrantField      `json:"is_empty,omitempty"`
+	// Should nests a filter that any one of its conditions satisfies.
+	Should []qdrantCondition `json:"should,omitempty"`
+}
+
+type qdrantField struct {
+	Key string `json:"key"`
+}
+
+type qdrantMatchValue struct {
+	// Value is a string or a bool.
+	Value interface{} `json:"value,omitempty"`
+	Text  string      `json:"text,omitempty"`
+	Any   []string    `json:"any,omitempty"`
+}
+
+type qdrantFilter struct {
+	Must    []qdrantCondition `json:"must"`
+	MustNot []qdrantCondition `json:"must_not,omitempty"`
+}
+
+type qdrantScrollReq struct {
+	Filter      *qdrantFilter `json:"filter,omitempty"`
+	Limit       int           `json:"limit"`
+	WithPayload bool          `json:"with_payload"`
+	WithVector  bool          `json:"with_vector,omitempty"`
+}
+
+type qdrantSearchReq struct {
+	Vector         []float32     `json:"vector"`
+	Limit          int           `json:"limit"`
+	Filter         *qdrantFilter `json:"filter,omitempty"`
+	WithPayload    bool          `json:"with_payload"`
+	WithVector     bool          `json:"with_vector"`
+	ScoreThreshold *float64      `json:"score_threshold,omitempty"`
+}
+
+func (s *QdrantStore) Upsert(ctx context.Context, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	if _, err := chunksTenant(chunks); err != nil {
+		return err
+	}
+	if err := s.ensureCollection(ctx, len(chunks[0].Embedding)); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	points := make([]qdrantPoint, len(chunks))
+	for i, c := range chunks {
+		id := c.ID
+		if id == uuid.Nil {
+			id = uuid.New()
+		}
+		points[i] = qdrantPoint{
+			ID:     id.String(),
+			Vector: c.Embedding,
+			Payload: qdrantPayload{
+				DocumentID: c.DocumentID.String(),
+				TenantID:   c.TenantID.String(),
+				ChunkIndex: c.ChunkIndex,
+				Content:    c.Content,
+				TokenCount: c.TokenCount,
+				Metadata:   c.Metadata,
+				CreatedAt:  &now,
+			},
+		}
+		if c.CollectionID != uuid.Nil {
+			points[i].Payload.CollectionID = c.CollectionID.String()
+		}
+		points[i].Payload.Access = uuidStrings(c.Access)
+		points[i].Payload.ContentHash = c.ContentHash
+		points[i].Payload.Superseded = c.Superseded
+	}
+
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points?wait=true"
+	if err := s.do(ctx, http.MethodPut, path, map[string]interface{}{"points": points}, nil); err != nil {
+		return fmt.Errorf("qdrant upsert: %w", err)
+	}
+	return nil
+}
+
+func (s *QdrantStore) SimilaritySearch(ctx context.Context, query []float32, opts SearchOptions) ([]SearchResult, error) {
+	if opts.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	req := qdrantSearchReq{
+		Vector:      query,
+		Limit:       opts.TopK,
+		Filter:      searchFilter(opts),
+		WithPayload: true,
+		WithVector:  opts.WithEmbeddings,
+	}
+	if opts.MinScore > 0 {
+		req.ScoreThreshold = &opts.MinScore
+	}
+
+	points, err := s.search(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("similarity search: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(points))
+	for _, p := range points {
+		r, err := p.result()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// HybridSearch mirrors PgVectorStore: the top 2*TopK vector hits and the top
+// 2*TopK full-text matches are merged and scored 0.7*vector + 0.3*keyword.
+// Qdrant has no text ranking, so keyword scores are computed client-side.
+func (s *QdrantStore) HybridSearch(ctx context.Context, query string, queryVec []float32, opts SearchOptions) ([]SearchResult, error) {
+	if opts.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+
+	vectorHits, err := s.search(ctx, qdrantSearchReq{
+		Vector:      queryVec,
+		Limit:       opts.TopK * 2,
+		Filter:      searchFilter(opts),
+		WithPayload: true,
+		WithVector:  opts.WithEmbeddings,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search: %w", err)
+	}
+
+	terms := queryTerms(query)
+	var keywordHits []qdrantScoredPoint
+	if len(terms) > 0 {
+		// A text match requires every word, so match each term separately.
+		seen := make(map[string]bool)
+		for _, term := range terms {
+			filter := searchFilter(opts)
+			filter.Must = append(filter.Must, qdrantCondition{Key: "content", Match: &qdrantMatchValue{Text: term}})
+			hits, err := s.search(ctx, qdrantSearchReq{
+				Vector:      queryVec,
+				Limit:       opts.TopK * 2,
+				Filter:      filter,
+				WithPayload: true,
+				WithVector:  opts.WithEmbeddings,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("hybrid keyword search: %w", err)
+			}
+			for _, h := range hits {
+				if !seen[h.ID] {
+					seen[h.ID] = true
+					keywordHits = append(keywordHits, h)
+				}
+			}
+		}
+	}
+
+	type scored struct {
+		point qdrantScoredPoint
+		score float64
+	}
+	combined := make(map[string]*scored)
+	var order []string
+	for _, h := range vectorHits {
+		combined[h.ID] = &scored{point: h, score: h.Score * 0.7}
+		order = append(order, h.ID)
+	}
+
+	keyword := make([]scored, 0, len(keywordHits))
+	for _, h := range keywordHits {
+		if k := keywordScore(terms, termFrequencies(h.Payload.Content)); k > 0 {
+			keyword = append(keyword, scored{point: h, score: k})
+		}
+	}
+	sort.SliceStable(keyword, func(i, j int) bool { return keyword[i].score > keyword[j].score })
+	if len(keyword) > opts.TopK*2 {
+		keyword = keyword[:opts.TopK*2]
+	}
+	for _, k := range keyword {
+		if existing, ok := combined[k.point.ID]; ok {
+			existing.score += k.score * 0.3
+		} else {
+			combined[k.point.ID] = &scored{point: k.point, score: k.score * 0.3}
+			order = append(order, k.point.ID)
+		}
+	}
+
+	merged := make([]scored, 0, len(order))
+	for _, id := range order {
+		merged = append(merged, *combined[id])
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].score > merged[j].score })
+	if len(merged) > opts.TopK {
+		merged = merged[:opts.TopK]
+	}
+
+	var results []SearchResult
+	for _, m := range merged {
+		if opts.MinScore > 0 && m.score < opts.MinScore {
+			continue
+		}
+		r, err := m.point.result()
+		if err != nil {
+			return nil, err
+		}
+		r.Score = m.score
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func (s *QdrantStore) Delete(ctx context.Context, filter DeleteFilter) error {
+	if filter.TenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+	f := tenantFilter(filter.TenantID)
+	if filter.DocumentID != uuid.Nil {
+		f.Must = append(f.Must, qdrantCondition{Key: "document_id", Match: &qdrantMatchValue{Value: filter.DocumentID.String()}})
+	}
+	if filter.CollectionID != uuid.Nil {
+		f.Must = append(f.Must, qdrantCondition{Key: "collection_id", Match: &qdrantMatchValue{Value: filter.CollectionID.String()}})
+	}
+	if filter.DefaultPool {
+		f.Must = append(f.Must, qdrantCondition{IsEmpty: &qdrantField{Key: "collection_id"}})
+	}
+
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points/delete?wait=true"
+	err := s.do(ctx, http.MethodPost, path, map[string]interface{}{"filter": f}, nil)
+	if isQdrantNotFound(err) {
+		return nil // nothing was ever written
+	}
+	if err != nil {
+		return fmt.Errorf("qdrant delete: %w", err)
+	}
+	return nil
+}
+
+func (s *QdrantStore) SetAccess(ctx context.Context, tenantID, documentID uuid.UUID, access []uuid.UUID) error {
+	if tenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+	f := tenantFilter(tenantID)
+	f.Must = append(f.Must, qdrantCondition{Key: "document_id", Match: &qdrantMatchValue{Value: documentID.String()}})
+
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points/payload"
+	var body map[string]interface{}
+	if len(access) == 0 {
+		path += "/delete"
+		body = map[string]interface{}{"keys": []string{"access"}, "filter": f}
+	} else {
+		body = map[string]interface{}{"payload": map[string]interface{}{"access": uuidStrings(access)}, "filter": f}
+	}
+
+	err := s.do(ctx, http.MethodPost, path+"?wait=true", body, nil)
+	if isQdrantNotFound(err) {
+		return nil // nothing was ever written
+	}
+	if err != nil {
+		return fmt.Errorf("qdrant set access: %w", err)
+	}
+	return nil
+}
+
+func (s *QdrantStore) SetSuperseded(ctx context.Context, tenantID, documentID uuid.UUID, superseded bool) error {
+	if tenantID == uuid.Nil {
+		return ErrNoTenant
+	}
+	f := tenantFilter(tenantID)
+	f.Must = append(f.Must, qdrantCondition{Key: "document_id", Match: &qdrantMatchValue{Value: documentID.String()}})
+
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points/payload"
+	var body map[string]interface{}
+	if superseded {
+		body = map[string]interface{}{"payload": map[string]interface{}{"superseded": true}, "filter": f}
+	} else {
+		path += "/delete"
+		body = map[string]interface{}{"keys": []string{"superseded"}, "filter": f}
+	}
+
+	err := s.do(ctx, http.MethodPost, path+"?wait=true", body, nil)
+	if isQdrantNotFound(err) {
+		return nil // nothing was ever written
+	}
+	if err != nil {
+		return fmt.Errorf("qdrant set superseded: %w", err)
+	}
+	return nil
+}
+
+func (s *QdrantStore) Embeddings(ctx context.Context, lookup EmbeddingLookup) (map[string]StoredEmbedding, error) {
+	if lookup.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	embeddings := make(map[string]StoredEmbedding)
+	if len(lookup.DocumentIDs) == 0 || len(lookup.Hashes) == 0 {
+		return embeddings, nil
+	}
+
+	f := tenantFilter(lookup.TenantID)
+	f.Must = append(f.Must,
+		qdrantCondition{Key: "document_id", Match: &qdrantMatchValue{Any: uuidStrings(lookup.DocumentIDs)}},
+		qdrantCondition{Key: "content_hash", Match: &qdrantMatchValue{Any: lookup.Hashes}},
+	)
+	if lookup.CollectionID != uuid.Nil {
+		f.Must = append(f.Must, qdrantCondition{Key: "collection_id", Match: &qdrantMatchValue{Value: lookup.CollectionID.String()}})
+	} else {
+		f.Must = append(f.Must, qdrantCondition{IsEmpty: &qdrantField{Key: "collection_id"}})
+	}
+
+	var page struct {
+		Points []qdrantScoredPoint `json:"points"`
+	}
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points/scroll"
+	req := qdrantScrollReq{Filter: f, Limit: len(lookup.Hashes) * len(lookup.DocumentIDs), WithPayload: true, WithVector: true}
+	err := s.do(ctx, http.MethodPost, path, req, &page)
+	if isQdrantNotFound(err) {
+		return embeddings, nil // empty until the first upsert
+	}
+	if err != nil {
+		return nil, fmt.Errorf("qdrant look up embeddings: %w", err)
+	}
+	for _, p := range page.Points {
+		if p.Payload.ContentHash != "" && len(p.Vector) > 0 {
+			header, _ := p.Payload.Metadata["context_header"].(string)
+			embeddings[p.Payload.ContentHash] = StoredEmbedding{Embedding: p.Vector, ContextHeader: header}
+		}
+	}
+	return embeddings, nil
+}
+
+// ensureCollection creates the collection and its payload indexes unless it
+// already exists.
+// sampleOversample is how many times the requested sample Sample scrolls
+// through before picking at random.
+const sampleOversample = 5
+
+// Sample picks from the first points a scroll returns. Scrolling has no
+// random order, but point IDs are random UUIDs, so those points are spread
+// across documents.
+func (s *QdrantStore) Sample(ctx context.Context, opts SampleOptions) ([]SearchResult, error) {
+	if opts.TenantID == uuid.Nil {
+		return nil, ErrNoTenant
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = 10
+	}
+
+	filter := searchFilter(SearchOptions{TenantID: opts.TenantID, CollectionIDs: opts.CollectionIDs, Principals: opts.Principals})
+	if len(opts.DocumentIDs) > 0 {
+		filter.Must = append(filter.Must, qdrantCondition{Key: "document_id", Match: &qdrantMatchValue{Any: uuidStrings(opts.DocumentIDs)}})
+	}
+
+	var page struct {
+		Points []qdrantScoredPoint `json:"points"`
+	}
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points/scroll"
+	err := s.do(ctx, http.MethodPost, path, qdrantScrollReq{Filter: filter, Limit: opts.Limit * sampleOversample, WithPayload: true}, &page)
+	if isQdrantNotFound(err) {
+		return nil, nil // empty until the first upsert
+	}
+	if err != nil {
+		return nil, fmt.Errorf("qdrant sample: %w", err)
+	}
+
+	points := page.Points
+	rand.Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+	if len(points) > opts.Limit {
+		points = points[:opts.Limit]
+	}
+
+	results := make([]SearchResult, 0, len(points))
+	for _, p := range points {
+		r, err := p.result()
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+func (s *QdrantStore) ensureCollection(ctx context.Context, dims int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ready {
+		return nil
+	}
+
+	base := "/collections/" + url.PathEscape(s.cfg.Collection)
+	err := s.do(ctx, http.MethodGet, base, nil, nil)
+	if isQdrantNotFound(err) {
+		if dims == 0 {
+			return fmt.Errorf("create qdrant collection: chunks have no embeddings")
+		}
+		err = s.do(ctx, http.MethodPut, base, map[string]interface{}{
+			"vectors": map[string]interface{}{"size": dims, "distance": "Cosine"},
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("create qdrant collection: %w", err)
+		}
+
+		indexes := []struct{ field, schema string }{
+			{"tenant_id", "keyword"},
+			{"document_id", "keyword"},
+			{"collection_id", "keyword"},
+			{"access", "keyword"},
+			{"content_hash", "keyword"},
+			{"superseded", "bool"},
+			{"content", "text"},
+		}
+		for _, idx := range indexes {
+			err := s.do(ctx, http.MethodPut, base+"/index?wait=true", map[string]interface{}{
+				"field_name":   idx.field,
+				"field_schema": idx.schema,
+			}, nil)
+			if err != nil {
+				return fmt.Errorf("create qdrant index %s: %w", idx.field, err)
+			}
+		}
+	} else if err != nil {
+		return fmt.Errorf("get qdrant collection: %w", err)
+	}
+
+	s.ready = true
+	return nil
+}
+
+func (s *QdrantStore) search(ctx context.Context, req qdrantSearchReq) ([]qdrantScoredPoint, error) {
+	var points []qdrantScoredPoint
+	path := "/collections/" + url.PathEscape(s.cfg.Collection) + "/points/search"
+	err := s.do(ctx, http.MethodPost, path, req, &points)
+	if isQdrantNotFound(err) {
+		return nil, nil // empty until the first upsert
+	}
+	return points, err
+}
+
+// qdrantError is a non-2xx response from Qdrant.
+type qdrantError struct {
+	status int
+	body   string
+}
+
+func (e *qdrantError) Error() string {
+	return fmt.Sprintf("qdrant returned %d: %s", e.status, e.body)
+}
+
+func isQdrantNotFound(err error) bool {
+	qe, ok := err.(*qdrantError)
+	return ok && qe.status == http.StatusNotFound
+}
+
+// do sends a JSON request and decodes the "result" field of the response
+// into out (if non-nil).
+func (s *QdrantStore) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.cfg.URL+path, body)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.cfg.APIKey != "" {
+		req.Header.Set("api-key", s.cfg.APIKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &qdrantError{status: resp.StatusCode, body: strings.TrimSpace(string(msg))}
+	}
+	if out == nil {
+		return nil
+	}
+
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if err := json.Unmarshal(envelope.Result, out); err != nil {
+		return fmt.Errorf("decode result: %w", err)
+	}
+	return nil
+}
+
+func tenantFilter(tenantID uuid.UUID) *qdrantFilter {
+	return &qdrantFilter{Must: []qdrantCondition{
+		{Key: "tenant_id", Match: &qdrantMatchValue{Value: tenantID.String()}},
+	}}
+}
+
+// searchFilter restricts a search to the tenant, the chunks its principals
+// may read, current versions unless old ones are included and its
+// collections, or the default pool if none are given.
+func searchFilter(opts SearchOptions) *qdrantFilter {
+	f := tenantFilter(opts.TenantID)
+	if !opts.IncludeSuperseded {
+		f.MustNot = append(f.MustNot, qdrantCondition{Key: "superseded", Match: &qdrantMatchValue{Value: true}})
+	}
+	if len(opts.CollectionIDs) > 0 {
+		f.Must = append(f.Must, qdrantCondition{Key: "collection_id", Match: &qdrantMatchValue{Any: uuidStrings(opts.CollectionIDs)}})
+	} else {
+		f.Must = append(f.Must, qdrantCondition{IsEmpty: &qdrantField{Key: "collection_id"}})
+	}
+
+	access := qdrantCondition{Should: []qdrantCondition{{IsEmpty: &qdrantField{Key: "access"}}}}
+	if len(opts.Principals) > 0 {
+		access.Should = append(access.Should, qdrantCondition{Key: "access", Match: &qdrantMatchValue{Any: uuidStrings(opts.Principals)}})
+	}
+	f.Must = append(f.Must, access)
+	return f
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+func (p qdrantScoredPoint) result() (SearchResult, error) {
+	id, err := uuid.Parse(p.ID)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("parse point id: %w", err)
+	}
+	docID, err := uuid.Parse(p.Payload.DocumentID)
+	if err != nil {
+		return SearchResult{}, fmt.Errorf("parse document id: %w", err)
+	}
+	r := SearchResult{
+		ChunkID:    id,
+		DocumentID: docID,
+		Content:    p.Payload.Content,
+		Score:      p.Score,
+		ChunkIndex: p.Payload.ChunkIndex,
+		Metadata:   p.Payload.Metadata,
+		Embedding:  p.Vector,
+		CreatedAt:  p.Payload.CreatedAt,
+	}
+	if p.Payload.CollectionID != "" {
+		collectionID, err := uuid.Parse(p.Payload.CollectionID)
+		if err != nil {
+			return SearchResult{}, fmt.Errorf("parse collection id: %w", err)
+		}
+		r.CollectionID = &collectionID
+	}
+	return r, nil
+}
+
+// Ensure QdrantStore implements VectorStore.
+var _ VectorStore = (*QdrantStore)(nil)
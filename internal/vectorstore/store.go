@@ -2,10 +2,32 @@ package vectorstore
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrNoTenant is returned for operations without a tenant ID. Every read and
+// write of chunks is scoped to exactly one tenant.
+var ErrNoTenant = errors.New("vectorstore: tenant ID is required")
+
+// chunksTenant returns the tenant all chunks belong to. A batch must not be
+// empty of tenant or span several tenants.
+func chunksTenant(chunks []Chunk) (uuid.UUID, error) {
+	var tenantID uuid.UUID
+	for _, c := range chunks {
+		if c.TenantID == uuid.Nil {
+			return uuid.Nil, ErrNoTenant
+		}
+		if tenantID != uuid.Nil && c.TenantID != tenantID {
+			return uuid.Nil, errors.New("vectorstore: chunks span several tenants")
+		}
+		tenantID = c.TenantID
+	}
+	return tenantID, nil
+}
+
 type Chunk struct {
 	ID         uuid.UUID
 	DocumentID uuid.UUID
@@ -15,12 +37,53 @@ type Chunk struct {
 	Embedding  []float32
 	TokenCount int
 	Metadata   map[string]interface{}
+	// CollectionID is the collection the chunk was indexed for; uuid.Nil
+	// means the tenant's default pool.
+	CollectionID uuid.UUID
+	// Access lists the principals (user, role and group IDs) allowed to read
+	// the chunk, copied from its document's ACL. Nil means unrestricted.
+	Access []uuid.UUID
+	// ContentHash identifies the chunk's text, how its context header was
+	// made and the model that embedded it, so re-ingestion can reuse the
+	// embedding (and header) of an unchanged chunk.
+	ContentHash string
+	// Superseded marks the chunks of a document replaced by a newer
+	// version. Searches and samples skip them unless asked not to.
+	Superseded bool
 }
 
 type SearchOptions struct {
 	TenantID uuid.UUID
 	TopK     int
 	MinScore float64
+	// CollectionIDs restricts the search to chunks of these collections.
+	// Empty searches the default pool, the chunks outside any collection: a
+	// collection holds its own copy of a document's chunks, possibly
+	// embedded with another model, so searching everything would return
+	// duplicates and incomparable scores.
+	CollectionIDs []uuid.UUID
+	// Principals are the caller's user, role and group IDs. Chunks with an
+	// access list are only returned when it contains one of them.
+	Principals []uuid.UUID
+	// IncludeSuperseded also searches the chunks of old document versions.
+	IncludeSuperseded bool
+	// Tuning trades recall for latency on approximate indexes. Backends
+	// that search exactly ignore it.
+	Tuning SearchTuning
+	// WithEmbeddings returns each result's stored vector, which only
+	// diversity rankers need. It is left out otherwise: at 1536 floats per
+	// chunk it dwarfs the rest of the result.
+	WithEmbeddings bool
+}
+
+// SearchTuning sets approximate index search parameters for one query. Zero
+// values keep the server defaults.
+type SearchTuning struct {
+	// EfSearch is the HNSW candidate list size (pgvector default 40, max
+	// 1000). Higher means better recall and slower queries.
+	EfSearch int `json:"ef_search,omitempty"`
+	// Probes is the number of IVFFlat lists scanned (pgvector default 1).
+	Probes int `json:"probes,omitempty"`
 }
 
 type SearchResult struct {
@@ -30,11 +93,53 @@ type SearchResult struct {
 	Score      float64                `json:"score"`
 	ChunkIndex int                    `json:"chunk_index"`
 	Metadata   map[string]interface{} `json:"metadata"`
+	// CollectionID is set for chunks indexed into a collection.
+	CollectionID *uuid.UUID `json:"collection_id,omitempty"`
+	// Embedding is the stored chunk vector, used by diversity rankers (MMR).
+	// Searches only set it with SearchOptions.WithEmbeddings.
+	Embedding []float32 `json:"-"`
+	// CreatedAt is the source document's creation time, used by recency rankers.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
 }
 
 type DeleteFilter struct {
 	TenantID   uuid.UUID
 	DocumentID uuid.UUID
+	// CollectionID limits the delete to one collection's chunks.
+	CollectionID uuid.UUID
+	// DefaultPool limits the delete to chunks outside any collection, e.g.
+	// to replace a document's default-pool chunks on re-ingestion.
+	DefaultPool bool
+}
+
+// SampleOptions selects the chunks Sample draws from: the tenant's current
+// chunks the principals may read, optionally limited to some documents, in
+// the given collections or else the default pool.
+type SampleOptions struct {
+	TenantID      uuid.UUID
+	DocumentIDs   []uuid.UUID
+	CollectionIDs []uuid.UUID
+	Principals    []uuid.UUID
+	Limit         int
+}
+
+// StoredEmbedding is a stored chunk's vector and the context header that
+// was embedded with it.
+type StoredEmbedding struct {
+	Embedding []float32
+	// ContextHeader is the chunk's metadata "context_header", empty for
+	// chunks indexed without one.
+	ContextHeader string
+}
+
+// EmbeddingLookup selects the stored chunks whose embeddings re-ingestion
+// may reuse: those of some documents, in one collection (uuid.Nil for the
+// default pool), with one of the given content hashes.
+type EmbeddingLookup struct {
+	TenantID     uuid.UUID
+	DocumentIDs  []uuid.UUID
+	CollectionID uuid.UUID
+	Hashes       []string
 }
 
 type VectorStore interface {
@@ -42,4 +147,16 @@ type VectorStore interface {
 	SimilaritySearch(ctx context.Context, query []float32, opts SearchOptions) ([]SearchResult, error)
 	HybridSearch(ctx context.Context, query string, queryVec []float32, opts SearchOptions) ([]SearchResult, error)
 	Delete(ctx context.Context, filter DeleteFilter) error
+	// SetAccess replaces the access list of a document's chunks after its
+	// ACL changes; nil makes them unrestricted.
+	SetAccess(ctx context.Context, tenantID, documentID uuid.UUID, access []uuid.UUID) error
+	// SetSuperseded marks a document's chunks as those of an old version,
+	// or of the current one again.
+	SetSuperseded(ctx context.Context, tenantID, documentID uuid.UUID, superseded bool) error
+	// Embeddings returns the embeddings of the chunks matching lookup by
+	// content hash.
+	Embeddings(ctx context.Context, lookup EmbeddingLookup) (map[string]StoredEmbedding, error)
+	// Sample returns up to opts.Limit matching chunks picked at random, for
+	// building test sets. Scores are zero.
+	Sample(ctx context.Context, opts SampleOptions) ([]SearchResult, error)
 }
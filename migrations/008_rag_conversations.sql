@@ -0,0 +1,25 @@
+-- Migration 008: Conversational RAG
+-- Persists RAG conversation turns so follow-up questions can be condensed
+-- against prior history without the client resending it.
+
+CREATE TABLE rag_conversations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    user_id UUID REFERENCES users(id),
+    title TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE rag_conversation_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    conversation_id UUID NOT NULL REFERENCES rag_conversations(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    role TEXT NOT NULL,
+    content TEXT NOT NULL,
+    metadata JSONB DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX ON rag_conversations (tenant_id, updated_at);
+CREATE INDEX ON rag_conversation_messages (conversation_id, created_at);
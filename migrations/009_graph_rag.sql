@@ -0,0 +1,55 @@
+-- Migration 009: GraphRAG knowledge graph
+-- Entities and relations extracted from chunks at ingest, entity mentions
+-- linking back to source chunks, and community summaries over the graph.
+
+CREATE TABLE kg_entities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    normalized_name TEXT NOT NULL,
+    entity_type TEXT NOT NULL DEFAULT 'concept',
+    description TEXT,
+    embedding vector(1536),
+    community_id UUID,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE(tenant_id, normalized_name, entity_type)
+);
+
+CREATE TABLE kg_entity_chunks (
+    entity_id UUID NOT NULL REFERENCES kg_entities(id) ON DELETE CASCADE,
+    chunk_id UUID NOT NULL REFERENCES document_chunks(id) ON DELETE CASCADE,
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    PRIMARY KEY (entity_id, chunk_id)
+);
+
+CREATE TABLE kg_relations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    source_entity_id UUID NOT NULL REFERENCES kg_entities(id) ON DELETE CASCADE,
+    target_entity_id UUID NOT NULL REFERENCES kg_entities(id) ON DELETE CASCADE,
+    relation TEXT NOT NULL,
+    description TEXT,
+    weight DOUBLE PRECISION NOT NULL DEFAULT 1,
+    chunk_id UUID REFERENCES document_chunks(id) ON DELETE CASCADE,
+    document_id UUID REFERENCES documents(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE kg_communities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    level INT NOT NULL DEFAULT 0,
+    summary TEXT NOT NULL,
+    embedding vector(1536),
+    entity_count INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX ON kg_entities USING hnsw (embedding vector_cosine_ops);
+CREATE INDEX ON kg_entities (tenant_id, community_id);
+CREATE INDEX ON kg_entity_chunks (chunk_id);
+CREATE INDEX ON kg_relations (tenant_id, source_entity_id);
+CREATE INDEX ON kg_relations (tenant_id, target_entity_id);
+CREATE INDEX ON kg_communities USING hnsw (embedding vector_cosine_ops);
+CREATE INDEX ON kg_communities (tenant_id);
@@ -0,0 +1,24 @@
+-- Migration 010: vector index management
+-- Replaces the IVFFlat index from 002, whose 100 lists were trained on an
+-- empty table, with HNSW (no training step, better recall). Index builds
+-- and rebuilds requested through the admin API run as jobs tracked below.
+
+DROP INDEX IF EXISTS document_chunks_embedding_idx;
+CREATE INDEX IF NOT EXISTS document_chunks_embedding_hnsw_idx
+    ON document_chunks USING hnsw (embedding vector_cosine_ops) WITH (m = 16, ef_construction = 64);
+
+CREATE TABLE vector_index_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    spec JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    phase TEXT,
+    progress INT NOT NULL DEFAULT 0,
+    index_name TEXT,
+    error TEXT,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_vector_index_jobs_tenant ON vector_index_jobs(tenant_id, created_at DESC);
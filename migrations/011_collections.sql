@@ -0,0 +1,33 @@
+-- Migration 011: knowledge-base collections
+-- Collections group a tenant's documents into namespaces, each with its own
+-- chunking, indexing and embedding settings. A document is indexed once per
+-- collection it belongs to; its chunks there carry the collection's ID.
+-- Chunks with no collection form the tenant's default pool.
+
+CREATE TABLE collections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    description TEXT,
+    chunk_opts JSONB NOT NULL DEFAULT '{}',
+    index_type TEXT NOT NULL DEFAULT 'standard',
+    context_headers TEXT,
+    embedding_model TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE(tenant_id, name)
+);
+
+CREATE TABLE document_collections (
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (document_id, collection_id)
+);
+
+CREATE INDEX idx_document_collections_collection ON document_collections(collection_id);
+
+ALTER TABLE document_chunks
+    ADD COLUMN collection_id UUID REFERENCES collections(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_document_chunks_collection ON document_chunks(tenant_id, collection_id);
@@ -0,0 +1,32 @@
+-- Migration 012: document access control
+-- Documents can be restricted to their owner (created_by) plus listed roles,
+-- user groups and users. The resolved principal list is stored in "access"
+-- (NULL when unrestricted) and copied onto the document's chunks, so vector
+-- search filters on it without a join.
+
+CREATE TABLE user_groups (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE(tenant_id, name)
+);
+
+CREATE TABLE user_group_members (
+    group_id UUID NOT NULL REFERENCES user_groups(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX idx_user_group_members_user ON user_group_members(user_id);
+
+ALTER TABLE documents
+    ADD COLUMN acl_roles UUID[] NOT NULL DEFAULT '{}',
+    ADD COLUMN acl_groups UUID[] NOT NULL DEFAULT '{}',
+    ADD COLUMN acl_users UUID[] NOT NULL DEFAULT '{}',
+    ADD COLUMN access UUID[];
+
+ALTER TABLE document_chunks ADD COLUMN access UUID[];
+
+CREATE INDEX idx_documents_access ON documents USING gin (access);
+CREATE INDEX idx_document_chunks_access ON document_chunks USING gin (access);
@@ -0,0 +1,18 @@
+-- Migration 013: row-level security on document_chunks
+-- Every query on document_chunks already filters by tenant_id; the policy
+-- enforces it in the database as well. The vector store runs each statement
+-- in a transaction that sets app.tenant_id (set_config(..., true)), and rows
+-- of any other tenant are invisible and cannot be written. Without the
+-- setting no rows match at all.
+--
+-- FORCE applies the policy to the table owner too, which the application
+-- usually connects as. Superusers and BYPASSRLS roles are never subject to
+-- row-level security, so the application must not connect as one for this
+-- to take effect.
+
+ALTER TABLE document_chunks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE document_chunks FORCE ROW LEVEL SECURITY;
+
+CREATE POLICY document_chunks_tenant_isolation ON document_chunks
+    USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid)
+    WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::uuid);
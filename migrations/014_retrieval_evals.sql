@@ -0,0 +1,22 @@
+-- Migration 014: retrieval evaluation runs
+-- Each run scores one labeled query set against several retrieval
+-- configurations (recall@k, precision@k, MRR, nDCG, latency). Runs are kept
+-- so the same set can be compared over time.
+
+CREATE TABLE retrieval_eval_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    cases JSONB NOT NULL,
+    configs JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    progress INT NOT NULL DEFAULT 0,
+    results JSONB,
+    error TEXT,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_retrieval_eval_runs_tenant ON retrieval_eval_runs(tenant_id, name, created_at DESC);
@@ -0,0 +1,35 @@
+-- Migration 015: generated eval datasets
+-- Question/answer pairs generated from sampled chunks (single-hop,
+-- multi-hop and unanswerable), usable by the answer eval suite and by
+-- retrieval eval runs.
+
+CREATE TABLE eval_datasets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    spec JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    progress INT NOT NULL DEFAULT 0,
+    stats JSONB,
+    error TEXT,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_eval_datasets_tenant ON eval_datasets(tenant_id, created_at DESC);
+
+CREATE TABLE eval_dataset_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dataset_id UUID NOT NULL REFERENCES eval_datasets(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    query TEXT NOT NULL,
+    expected_answer TEXT NOT NULL,
+    context TEXT[] NOT NULL DEFAULT '{}',
+    relevant_document_ids UUID[] NOT NULL DEFAULT '{}',
+    relevant_chunk_ids UUID[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_eval_dataset_items_dataset ON eval_dataset_items(dataset_id, created_at);
@@ -0,0 +1,19 @@
+-- Migration 016: document ingestion pipeline
+-- Uploads record the indexing settings chosen for them and why processing
+-- failed. Extracted text is kept with its page boundaries, so the embedding
+-- step (and later re-indexing, e.g. into a collection) doesn't download and
+-- extract the file again.
+
+ALTER TABLE documents
+    ADD COLUMN ingest_options JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN error TEXT,
+    ADD COLUMN processed_at TIMESTAMPTZ;
+
+CREATE TABLE document_contents (
+    document_id UUID PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    content TEXT NOT NULL,
+    pages JSONB NOT NULL DEFAULT '[]',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    extracted_at TIMESTAMPTZ DEFAULT now()
+);
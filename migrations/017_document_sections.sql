@@ -0,0 +1,7 @@
+-- Migration 017: document sections
+-- Extraction splits documents along their structure (headings, pages,
+-- slides, sheets, records, email attachments); the sections are kept with
+-- the extracted text.
+
+ALTER TABLE document_contents
+    ADD COLUMN sections JSONB NOT NULL DEFAULT '[]';
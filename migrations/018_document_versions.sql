@@ -0,0 +1,21 @@
+-- Migration 018: document versions
+-- Uploads with the same external ID are versions of one document. Each
+-- version is its own document with its own text and chunks; a version is
+-- superseded once a newer one is indexed, and its chunks are then skipped
+-- by retrieval unless old versions are asked for. Chunks carry a hash of
+-- their embedded text, so re-indexing reuses unchanged chunks' embeddings.
+
+ALTER TABLE documents
+    ADD COLUMN external_id TEXT,
+    ADD COLUMN version INT NOT NULL DEFAULT 1,
+    ADD COLUMN superseded_by UUID REFERENCES documents(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX idx_documents_external_version ON documents(tenant_id, external_id, version)
+    WHERE external_id IS NOT NULL;
+
+ALTER TABLE document_chunks
+    ADD COLUMN content_hash TEXT,
+    ADD COLUMN superseded BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_document_chunks_content_hash ON document_chunks(tenant_id, document_id, content_hash)
+    WHERE content_hash IS NOT NULL;
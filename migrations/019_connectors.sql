@@ -0,0 +1,62 @@
+-- Migration 019: source connectors
+-- A connector source is a local directory, Git repository or S3-compatible
+-- bucket whose files are synced into documents on a schedule. Each synced
+-- file is one document, versioned by the file's external ID, so a changed
+-- file becomes a new version and a removed file's versions are deleted.
+
+CREATE TABLE connector_sources (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    config JSONB NOT NULL DEFAULT '{}',
+    -- 0 syncs only on request.
+    sync_interval_minutes INT NOT NULL DEFAULT 60,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    status TEXT NOT NULL DEFAULT 'idle',
+    -- Where the last sync left off: a commit, or the newest modification time.
+    cursor TEXT,
+    error TEXT,
+    sync_started_at TIMESTAMPTZ,
+    last_synced_at TIMESTAMPTZ,
+    next_sync_at TIMESTAMPTZ DEFAULT now(),
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now(),
+    UNIQUE(tenant_id, name)
+);
+
+CREATE INDEX idx_connector_sources_due ON connector_sources(next_sync_at)
+    WHERE enabled AND sync_interval_minutes > 0;
+
+-- The files a source had at its last sync, with the version (blob hash,
+-- ETag, or size and modification time) that was ingested.
+CREATE TABLE connector_items (
+    source_id UUID NOT NULL REFERENCES connector_sources(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    version TEXT NOT NULL,
+    document_id UUID REFERENCES documents(id) ON DELETE SET NULL,
+    status TEXT NOT NULL,
+    error TEXT,
+    synced_at TIMESTAMPTZ DEFAULT now(),
+    PRIMARY KEY (source_id, path)
+);
+
+CREATE TABLE connector_syncs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    source_id UUID NOT NULL REFERENCES connector_sources(id) ON DELETE CASCADE,
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    status TEXT NOT NULL DEFAULT 'running',
+    cursor TEXT,
+    added INT NOT NULL DEFAULT 0,
+    updated INT NOT NULL DEFAULT 0,
+    deleted INT NOT NULL DEFAULT 0,
+    unchanged INT NOT NULL DEFAULT 0,
+    skipped INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMPTZ DEFAULT now(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_connector_syncs_source ON connector_syncs(source_id, started_at DESC);
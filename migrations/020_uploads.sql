@@ -0,0 +1,56 @@
+-- Migration 020: resumable uploads and archive batches
+-- A resumable upload is a file sent in pieces, each at the offset where the
+-- last one ended, staged until all of it has arrived and it becomes a
+-- document or a batch. A batch is an archive whose supported entries each
+-- become a document; its entries record what became of every file, so an
+-- interrupted expansion resumes without creating documents twice.
+
+CREATE TABLE document_batches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    -- The stored archive, removed once it has been expanded.
+    file_path TEXT,
+    -- Settings applied to every document: ACL, ingest options, external ID prefix.
+    options JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_document_batches_tenant ON document_batches(tenant_id, created_at DESC);
+
+CREATE TABLE document_batch_entries (
+    batch_id UUID NOT NULL REFERENCES document_batches(id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    size BIGINT NOT NULL DEFAULT 0,
+    status TEXT NOT NULL,
+    document_id UUID REFERENCES documents(id) ON DELETE SET NULL,
+    error TEXT,
+    PRIMARY KEY (batch_id, path)
+);
+
+ALTER TABLE documents ADD COLUMN batch_id UUID REFERENCES document_batches(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_documents_batch ON documents(batch_id) WHERE batch_id IS NOT NULL;
+
+CREATE TABLE uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tenant_id UUID NOT NULL REFERENCES tenants(id),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    filename TEXT NOT NULL,
+    content_type TEXT,
+    size BIGINT NOT NULL,
+    received BIGINT NOT NULL DEFAULT 0,
+    options JSONB NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'pending',
+    document_id UUID REFERENCES documents(id) ON DELETE SET NULL,
+    batch_id UUID REFERENCES document_batches(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT now(),
+    updated_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX idx_uploads_expires ON uploads(expires_at) WHERE status = 'pending';
@@ -1,33 +1,96 @@
 package chunker
 
 import (
+	"sort"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/nikhilbhutani/backendwithai/pkg/tokenizer"
 )
 
 type Chunker interface {
 	Chunk(text string, opts ChunkOptions) []TextChunk
 }
 
+// Size units for ChunkOptions.SizeUnit.
+const (
+	SizeUnitTokens     = "tokens"
+	SizeUnitCharacters = "characters"
+)
+
 type ChunkOptions struct {
-	ChunkSize    int    // target chunk size in characters
-	ChunkOverlap int    // overlap between chunks
-	Strategy     string // "fixed", "recursive", "sentence", "semantic"
+	ChunkSize    int    `json:"chunk_size"`    // target chunk size, in SizeUnit
+	ChunkOverlap int    `json:"chunk_overlap"` // overlap between consecutive chunks, in SizeUnit
+	Strategy     string `json:"strategy"`      // "fixed", "recursive", "sentence", "semantic", "markdown", "code", "html"
+	Language     string `json:"language"`      // for "code": "go", "python", "javascript"; detected when empty
+	// SizeUnit is "tokens" or "characters". Empty means characters, so
+	// callers that predate token sizing keep their behaviour.
+	SizeUnit string `json:"size_unit"`
+	// Tokenizer counts tokens when SizeUnit is "tokens" (default tokenizer.CountTokens).
+	Tokenizer func(string) int `json:"-"`
 }
 
 type TextChunk struct {
 	Content string
 	Index   int
-	Start   int // character offset
-	End     int
+	// Start and End are byte offsets of Content in the source text, so
+	// text[Start:End] == Content for every strategy except html, whose
+	// content is extracted text and whose offsets span the source markup.
+	Start int
+	End   int
+	// StartRune and EndRune are the same positions counted in runes.
+	StartRune int
+	EndRune   int
+	// Page is the 1-based page the chunk starts on. Pages are separated by
+	// form feeds ('\f'), as emitted by textextract for PDFs.
+	Page int
+	// Tokens is the chunk's token count.
+	Tokens int
+	// SectionPath is the heading or declaration path the chunk falls under,
+	// set by the structure-aware strategies (markdown, code, html).
+	SectionPath []string
 }
 
 func DefaultOptions() ChunkOptions {
 	return ChunkOptions{
-		ChunkSize:    1000,
-		ChunkOverlap: 200,
+		ChunkSize:    256,
+		ChunkOverlap: 32,
 		Strategy:     "recursive",
+		SizeUnit:     SizeUnitTokens,
+	}
+}
+
+// normalize fills defaults and clamps overlap below the chunk size.
+func (o ChunkOptions) normalize() ChunkOptions {
+	if o.ChunkSize <= 0 {
+		if o.SizeUnit == SizeUnitTokens {
+			o.ChunkSize = 256
+		} else {
+			o.ChunkSize = 1000
+		}
+	}
+	if o.ChunkOverlap < 0 {
+		o.ChunkOverlap = 0
+	}
+	if o.ChunkOverlap >= o.ChunkSize {
+		o.ChunkOverlap = o.ChunkSize / 2
+	}
+	return o
+}
+
+// measure returns the size function for the configured unit.
+func (o ChunkOptions) measure() func(string) int {
+	if o.SizeUnit == SizeUnitTokens {
+		return o.countTokens()
+	}
+	return utf8.RuneCountInString
+}
+
+func (o ChunkOptions) countTokens() func(string) int {
+	if o.Tokenizer != nil {
+		return o.Tokenizer
 	}
+	return tokenizer.CountTokens
 }
 
 type defaultChunker struct{}
@@ -37,167 +100,119 @@ func New() Chunker {
 }
 
 func (c *defaultChunker) Chunk(text string, opts ChunkOptions) []TextChunk {
-	if opts.ChunkSize <= 0 {
-		opts.ChunkSize = 1000
-	}
-	if opts.ChunkOverlap < 0 {
-		opts.ChunkOverlap = 0
-	}
+	opts = opts.normalize()
 
+	var chunks []TextChunk
 	switch opts.Strategy {
 	case "sentence":
-		return chunkBySentence(text, opts)
+		chunks = chunkBySentence(text, opts)
 	case "fixed":
-		return chunkFixed(text, opts)
+		chunks = chunkFixed(text, opts)
+	case "markdown":
+		chunks = chunkMarkdown(text, opts)
+	case "code":
+		chunks = chunkCode(text, opts)
+	case "html":
+		chunks = chunkHTML(text, opts)
 	default:
-		return chunkRecursive(text, opts)
+		chunks = chunkRecursive(text, opts)
 	}
+	return finalize(text, chunks, opts.countTokens())
 }
 
+// chunkFixed cuts windows of ChunkSize characters, or ChunkSize tokens
+// worth of words, stepping by ChunkSize-ChunkOverlap.
 func chunkFixed(text string, opts ChunkOptions) []TextChunk {
-	var chunks []TextChunk
-	runes := []rune(text)
-	idx := 0
+	if opts.SizeUnit == SizeUnitTokens {
+		return packBlocks(text, wordBlocks(text), opts)
+	}
 
-	for start := 0; start < len(runes); {
-		end := start + opts.ChunkSize
-		if end > len(runes) {
-			end = len(runes)
-		}
+	// Byte offset of every rune, plus the end of text.
+	offsets := make([]int, 0, len(text)+1)
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(text))
+	numRunes := len(offsets) - 1
 
-		content := string(runes[start:end])
+	step := opts.ChunkSize - opts.ChunkOverlap
+	var chunks []TextChunk
+	for start := 0; start < numRunes; start += step {
+		end := min(start+opts.ChunkSize, numRunes)
+		content := text[offsets[start]:offsets[end]]
 		if strings.TrimSpace(content) != "" {
 			chunks = append(chunks, TextChunk{
 				Content: content,
-				Index:   idx,
-				Start:   start,
-				End:     end,
+				Start:   offsets[start],
+				End:     offsets[end],
 			})
-			idx++
 		}
-
-		step := opts.ChunkSize - opts.ChunkOverlap
-		if step <= 0 {
-			step = opts.ChunkSize
+		if end == numRunes {
+			break
 		}
-		start += step
 	}
-
 	return chunks
 }
 
+// chunkRecursive splits at the coarsest separator (paragraph, line,
+// sentence, word) that fits, then packs the pieces up to ChunkSize.
 func chunkRecursive(text string, opts ChunkOptions) []TextChunk {
-	separators := []string{"\n\n", "\n", ". ", " "}
-
-	var chunks []TextChunk
-	idx := 0
-
-	parts := splitRecursive(text, separators, opts.ChunkSize)
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
-		byteOffset := strings.Index(text, part)
-		chunks = append(chunks, TextChunk{
-			Content: part,
-			Index:   idx,
-			Start:   byteOffset,
-			End:     byteOffset + utf8.RuneCountInString(part),
-		})
-		idx++
-	}
-
-	return chunks
+	return packBlocks(text, []block{{start: 0, end: len(text)}}, opts)
 }
 
-func splitRecursive(text string, separators []string, chunkSize int) []string {
-	if utf8.RuneCountInString(text) <= chunkSize {
-		return []string{text}
-	}
-
-	if len(separators) == 0 {
-		// Fall back to fixed splitting
-		var result []string
-		runes := []rune(text)
-		for i := 0; i < len(runes); i += chunkSize {
-			end := i + chunkSize
-			if end > len(runes) {
-				end = len(runes)
-			}
-			result = append(result, string(runes[i:end]))
-		}
-		return result
-	}
-
-	sep := separators[0]
-	parts := strings.Split(text, sep)
-	var result []string
-	var current strings.Builder
+// chunkBySentence packs whole sentences up to ChunkSize.
+func chunkBySentence(text string, opts ChunkOptions) []TextChunk {
+	return packBlocks(text, sentenceSpans(text), opts)
+}
 
-	for _, part := range parts {
-		if current.Len() > 0 && utf8.RuneCountInString(current.String()+sep+part) > chunkSize {
-			result = append(result, splitRecursive(current.String(), separators[1:], chunkSize)...)
-			current.Reset()
-		}
-		if current.Len() > 0 {
-			current.WriteString(sep)
+// wordBlocks returns one block per whitespace-separated word.
+func wordBlocks(text string) []block {
+	var blocks []block
+	start := -1
+	for i, r := range text {
+		space := r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f'
+		switch {
+		case space && start >= 0:
+			blocks = append(blocks, block{start: start, end: i})
+			start = -1
+		case !space && start < 0:
+			start = i
 		}
-		current.WriteString(part)
 	}
-
-	if current.Len() > 0 {
-		result = append(result, splitRecursive(current.String(), separators[1:], chunkSize)...)
+	if start >= 0 {
+		blocks = append(blocks, block{start: start, end: len(text)})
 	}
-
-	return result
+	return blocks
 }
 
-func chunkBySentence(text string, opts ChunkOptions) []TextChunk {
-	// Split by sentence-ending punctuation
-	sentences := splitSentences(text)
-
-	var chunks []TextChunk
-	var current strings.Builder
-	idx := 0
-
-	for _, s := range sentences {
-		if current.Len() > 0 && utf8.RuneCountInString(current.String()+s) > opts.ChunkSize {
-			chunks = append(chunks, TextChunk{
-				Content: strings.TrimSpace(current.String()),
-				Index:   idx,
-			})
-			idx++
-			current.Reset()
+// finalize numbers the chunks and fills rune offsets, pages and token counts.
+func finalize(text string, chunks []TextChunk, countTokens func(string) int) []TextChunk {
+	var pageBreaks []int
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\f' {
+			pageBreaks = append(pageBreaks, i)
 		}
-		current.WriteString(s)
-	}
-
-	if current.Len() > 0 {
-		chunks = append(chunks, TextChunk{
-			Content: strings.TrimSpace(current.String()),
-			Index:   idx,
-		})
 	}
 
-	return chunks
-}
-
-func splitSentences(text string) []string {
-	var sentences []string
-	var current strings.Builder
-
-	for i, r := range text {
-		current.WriteRune(r)
-		if (r == '.' || r == '!' || r == '?') && i+1 < len(text) && text[i+1] == ' ' {
-			sentences = append(sentences, current.String())
-			current.Reset()
+	// Starts are nondecreasing for every strategy, so rune positions are
+	// counted incrementally; runeAt falls back to a full count otherwise.
+	lastByte, lastRune := 0, 0
+	runeAt := func(b int) int {
+		if b < lastByte {
+			lastByte, lastRune = 0, 0
 		}
+		lastRune += utf8.RuneCountInString(text[lastByte:b])
+		lastByte = b
+		return lastRune
 	}
 
-	if current.Len() > 0 {
-		sentences = append(sentences, current.String())
+	for i := range chunks {
+		c := &chunks[i]
+		c.Index = i
+		c.StartRune = runeAt(c.Start)
+		c.EndRune = c.StartRune + utf8.RuneCountInString(text[c.Start:c.End])
+		c.Page = 1 + sort.SearchInts(pageBreaks, c.Start)
+		c.Tokens = countTokens(c.Content)
 	}
-
-	return sentences
+	return chunks
 }
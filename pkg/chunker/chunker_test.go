@@ -0,0 +1,181 @@
+package chunker
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wordTokens counts whitespace-separated words, so token sizes in the tests
+// are easy to reason about.
+func wordTokens(s string) int { return len(strings.Fields(s)) }
+
+const sampleText = "Größe matters. The café opened at 9 a.m. on Monday.\n\n" +
+	"Dr. Smith et al. reviewed the report. It was approved! Was it signed? Yes.\n\n" +
+	"\fPage two starts here. Prices rose 3.5% in the U.S. last year.\n" +
+	"A short line.\n\n" +
+	"The final paragraph has a few more words so that it spans chunks."
+
+func TestChunkOffsets(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ChunkOptions
+	}{
+		{"fixed characters", ChunkOptions{Strategy: "fixed", ChunkSize: 40, ChunkOverlap: 10, SizeUnit: SizeUnitCharacters}},
+		{"fixed tokens", ChunkOptions{Strategy: "fixed", ChunkSize: 8, ChunkOverlap: 2, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens}},
+		{"recursive characters", ChunkOptions{Strategy: "recursive", ChunkSize: 60, ChunkOverlap: 15, SizeUnit: SizeUnitCharacters}},
+		{"recursive tokens", ChunkOptions{Strategy: "recursive", ChunkSize: 10, ChunkOverlap: 3, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens}},
+		{"sentence characters", ChunkOptions{Strategy: "sentence", ChunkSize: 80, ChunkOverlap: 20, SizeUnit: SizeUnitCharacters}},
+		{"sentence tokens", ChunkOptions{Strategy: "sentence", ChunkSize: 12, ChunkOverlap: 4, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens}},
+		{"markdown", ChunkOptions{Strategy: "markdown", ChunkSize: 50, ChunkOverlap: 10, SizeUnit: SizeUnitCharacters}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := New().Chunk(sampleText, tt.opts)
+			if len(chunks) < 2 {
+				t.Fatalf("got %d chunks, want several", len(chunks))
+			}
+			measure := tt.opts.normalize().measure()
+			count := tt.opts.countTokens()
+			for i, c := range chunks {
+				if c.Index != i {
+					t.Errorf("chunk %d: Index = %d", i, c.Index)
+				}
+				if got := sampleText[c.Start:c.End]; got != c.Content {
+					t.Errorf("chunk %d: text[Start:End] = %q, Content = %q", i, got, c.Content)
+				}
+				if want := utf8.RuneCountInString(sampleText[:c.Start]); c.StartRune != want {
+					t.Errorf("chunk %d: StartRune = %d, want %d", i, c.StartRune, want)
+				}
+				if want := utf8.RuneCountInString(sampleText[:c.End]); c.EndRune != want {
+					t.Errorf("chunk %d: EndRune = %d, want %d", i, c.EndRune, want)
+				}
+				if want := 1 + strings.Count(sampleText[:c.Start], "\f"); c.Page != want {
+					t.Errorf("chunk %d: Page = %d, want %d", i, c.Page, want)
+				}
+				if want := count(c.Content); c.Tokens != want {
+					t.Errorf("chunk %d: Tokens = %d, want %d", i, c.Tokens, want)
+				}
+				if size := measure(c.Content); size > tt.opts.ChunkSize {
+					t.Errorf("chunk %d: size %d exceeds %d: %q", i, size, tt.opts.ChunkSize, c.Content)
+				}
+				if i > 0 && c.Start < chunks[i-1].Start {
+					t.Errorf("chunk %d starts before chunk %d", i, i-1)
+				}
+			}
+			assertCovered(t, sampleText, chunks)
+		})
+	}
+}
+
+// assertCovered checks that every non-space character of text is in a chunk.
+func assertCovered(t *testing.T, text string, chunks []TextChunk) {
+	t.Helper()
+	covered := make([]bool, len(text))
+	for _, c := range chunks {
+		for i := c.Start; i < c.End; i++ {
+			covered[i] = true
+		}
+	}
+	for i, r := range text {
+		if !covered[i] && !unicode.IsSpace(r) {
+			t.Fatalf("byte %d (%q) is in no chunk", i, r)
+		}
+	}
+}
+
+func TestChunkOverlap(t *testing.T) {
+	text := strings.Repeat("alpha beta gamma delta epsilon zeta eta theta. ", 12)
+	tests := []struct {
+		name string
+		opts ChunkOptions
+	}{
+		{"recursive characters", ChunkOptions{Strategy: "recursive", ChunkSize: 70, ChunkOverlap: 20, SizeUnit: SizeUnitCharacters}},
+		{"recursive tokens", ChunkOptions{Strategy: "recursive", ChunkSize: 12, ChunkOverlap: 4, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens}},
+		{"sentence tokens", ChunkOptions{Strategy: "sentence", ChunkSize: 20, ChunkOverlap: 8, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens}},
+		{"fixed tokens", ChunkOptions{Strategy: "fixed", ChunkSize: 10, ChunkOverlap: 3, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			measure := tt.opts.normalize().measure()
+			chunks := New().Chunk(text, tt.opts)
+			if len(chunks) < 3 {
+				t.Fatalf("got %d chunks, want at least 3", len(chunks))
+			}
+			for i := 1; i < len(chunks); i++ {
+				prev, c := chunks[i-1], chunks[i]
+				if c.Start >= prev.End {
+					t.Fatalf("chunk %d doesn't overlap chunk %d", i, i-1)
+				}
+				shared := text[c.Start:prev.End]
+				if !strings.HasSuffix(prev.Content, shared) || !strings.HasPrefix(c.Content, shared) {
+					t.Errorf("chunk %d: overlap %q isn't the previous chunk's tail", i, shared)
+				}
+				if size := measure(shared); size > tt.opts.ChunkOverlap {
+					t.Errorf("chunk %d: overlap %q measures %d, more than %d", i, shared, size, tt.opts.ChunkOverlap)
+				}
+				if c.Start > 0 && !unicode.IsSpace(rune(text[c.Start-1])) {
+					t.Errorf("chunk %d starts mid-word: %q", i, c.Content)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkNoOverlap(t *testing.T) {
+	text := strings.Repeat("one two three four five six. ", 10)
+	for _, strategy := range []string{"fixed", "recursive", "sentence"} {
+		t.Run(strategy, func(t *testing.T) {
+			chunks := New().Chunk(text, ChunkOptions{Strategy: strategy, ChunkSize: 7, SizeUnit: SizeUnitTokens, Tokenizer: wordTokens})
+			for i := 1; i < len(chunks); i++ {
+				if chunks[i].Start < chunks[i-1].End {
+					t.Errorf("chunk %d overlaps chunk %d without ChunkOverlap", i, i-1)
+				}
+			}
+			assertCovered(t, text, chunks)
+		})
+	}
+}
+
+func TestChunkFixedCharacters(t *testing.T) {
+	tests := []struct {
+		text          string
+		size, overlap int
+		want          []string
+	}{
+		{"abcdefghij", 4, 1, []string{"abcd", "defg", "ghij"}},
+		{"abcdefghij", 5, 0, []string{"abcde", "fghij"}},
+		{"héllo wörld", 6, 2, []string{"héllo ", "o wörl", "rld"}},
+		// Overlap at or above the size is clamped to half of it.
+		{"abcdefgh", 4, 9, []string{"abcd", "cdef", "efgh"}},
+	}
+	for _, tt := range tests {
+		chunks := New().Chunk(tt.text, ChunkOptions{Strategy: "fixed", ChunkSize: tt.size, ChunkOverlap: tt.overlap, SizeUnit: SizeUnitCharacters})
+		var got []string
+		for _, c := range chunks {
+			got = append(got, c.Content)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("Chunk(%q, %d, %d) = %q, want %q", tt.text, tt.size, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestChunkMarkdownSections(t *testing.T) {
+	text := "# Install\n\nRun the installer.\n\n## Linux\n\nUse the package manager.\n\n" +
+		"```\nmake install\n```\n\n# Usage\n\nStart the server."
+	chunks := New().Chunk(text, ChunkOptions{Strategy: "markdown", ChunkSize: 1000, SizeUnit: SizeUnitCharacters})
+	want := [][]string{{"Install"}, {"Install", "Linux"}, {"Usage"}}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i, c := range chunks {
+		if strings.Join(c.SectionPath, "/") != strings.Join(want[i], "/") {
+			t.Errorf("chunk %d: SectionPath = %q, want %q", i, c.SectionPath, want[i])
+		}
+	}
+	if !strings.Contains(chunks[1].Content, "```\nmake install\n```") {
+		t.Errorf("code fence was split: %q", chunks[1].Content)
+	}
+}
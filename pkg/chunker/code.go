@@ -0,0 +1,209 @@
+package chunker
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Languages supported by the code strategy.
+const (
+	LanguageGo         = "go"
+	LanguagePython     = "python"
+	LanguageJavaScript = "javascript"
+)
+
+var (
+	goDeclRe     = regexp.MustCompile(`^(func|type|var|const|import)\b`)
+	goFuncNameRe = regexp.MustCompile(`^func\s*(?:\(\s*\w*\s*\*?\s*(\w+)[^)]*\)\s*)?(\w+)`)
+	goTypeNameRe = regexp.MustCompile(`^type\s+(\w+)`)
+
+	pyDeclRe = regexp.MustCompile(`^(?:async\s+)?(def|class)\s+(\w+)`)
+
+	jsDeclRe = regexp.MustCompile(`^(?:export\s+)?(?:default\s+)?(?:async\s+)?(?:function\*?\s+(\w+)|class\s+(\w+)|(?:const|let|var)\s+(\w+))`)
+)
+
+// chunkCode splits source code at function and class boundaries using
+// line-based parsers for Go, Python and JavaScript/TypeScript. Each chunk's
+// SectionPath names the declaration, e.g. ["Pipeline", "Query"] for a method.
+// Leading comments and decorators stay with the declaration they precede.
+func chunkCode(text string, opts ChunkOptions) []TextChunk {
+	lang := opts.Language
+	if lang == "" {
+		lang = detectLanguage(text)
+	}
+	return packBlocks(text, codeBlocks(text, lang, opts), opts)
+}
+
+// detectLanguage guesses the language from common top-level constructs.
+func detectLanguage(text string) string {
+	for _, l := range splitLines(text) {
+		t := l.text
+		switch {
+		case strings.HasPrefix(t, "package "):
+			return LanguageGo
+		case pyDeclRe.MatchString(t) && strings.HasSuffix(strings.TrimSpace(t), ":"):
+			return LanguagePython
+		}
+	}
+	return LanguageJavaScript
+}
+
+// codeUnit is a top-level declaration spanning lines [first, last].
+type codeUnit struct {
+	first, last int
+	section     []string
+}
+
+func codeBlocks(text, lang string, opts ChunkOptions) []block {
+	opts = opts.normalize()
+	measure := opts.measure()
+
+	lines := splitLines(text)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var units []codeUnit
+	current := codeUnit{}
+
+	for i, l := range lines {
+		name, ok := declName(l.text, lang)
+		if !ok {
+			continue
+		}
+		start := attachLeading(lines, i, lang)
+		if start <= current.first {
+			current.section = name
+			continue
+		}
+		current.last = start - 1
+		units = append(units, current)
+		current = codeUnit{first: start, section: name}
+	}
+	current.last = len(lines) - 1
+	units = append(units, current)
+
+	var blocks []block
+	for _, u := range units {
+		b := block{start: lines[u.first].start, end: lines[u.last].end, section: u.section, atomic: true}
+		oversized := measure(b.text(text)) > opts.ChunkSize
+		if lang == LanguagePython && oversized && isPythonClass(lines[u.first:u.last+1]) {
+			// Oversized class: split at its methods.
+			blocks = append(blocks, pythonMethodBlocks(lines[u.first:u.last+1], u.section)...)
+			continue
+		}
+		if oversized {
+			// Oversized functions are split by lines rather than kept whole.
+			b.atomic = false
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
+
+// declName reports whether line starts a top-level declaration and returns
+// its section path.
+func declName(line, lang string) ([]string, bool) {
+	switch lang {
+	case LanguageGo:
+		if !goDeclRe.MatchString(line) {
+			return nil, false
+		}
+		if m := goFuncNameRe.FindStringSubmatch(line); m != nil {
+			if m[1] != "" {
+				return []string{m[1], m[2]}, true
+			}
+			return []string{m[2]}, true
+		}
+		if m := goTypeNameRe.FindStringSubmatch(line); m != nil {
+			return []string{m[1]}, true
+		}
+		return []string{strings.Fields(line)[0]}, true
+
+	case LanguagePython:
+		if m := pyDeclRe.FindStringSubmatch(line); m != nil {
+			return []string{m[2]}, true
+		}
+		return nil, false
+
+	default:
+		m := jsDeclRe.FindStringSubmatch(line)
+		if m == nil {
+			return nil, false
+		}
+		// Only variables holding functions count as declarations.
+		if m[3] != "" && !strings.Contains(line, "=>") && !strings.Contains(line, "function") {
+			return nil, false
+		}
+		for _, name := range m[1:] {
+			if name != "" {
+				return []string{name}, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// attachLeading moves a declaration's start up over the comments and
+// decorators directly above it.
+func attachLeading(lines []lineAt, i int, lang string) int {
+	for i > 0 {
+		raw := lines[i-1].text
+		prev := strings.TrimSpace(raw)
+		if prev == "" || strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t") {
+			break
+		}
+		switch {
+		case strings.HasPrefix(prev, "//"), strings.HasPrefix(prev, "/*"), strings.HasPrefix(prev, "*"):
+		case lang == LanguagePython && (strings.HasPrefix(prev, "#") || strings.HasPrefix(prev, "@")):
+		case lang == LanguageJavaScript && strings.HasPrefix(prev, "@"):
+		default:
+			return i
+		}
+		i--
+	}
+	return i
+}
+
+func isPythonClass(lines []lineAt) bool {
+	for _, l := range lines {
+		if m := pyDeclRe.FindStringSubmatch(l.text); m != nil {
+			return m[1] == "class"
+		}
+	}
+	return false
+}
+
+// pythonMethodBlocks splits a class body at its directly nested defs.
+func pythonMethodBlocks(lines []lineAt, class []string) []block {
+	indent := ""
+	for _, l := range lines[1:] {
+		if t := strings.TrimLeft(l.text, " \t"); t != "" {
+			indent = l.text[:len(l.text)-len(t)]
+			break
+		}
+	}
+
+	var blocks []block
+	first := 0
+	section := class
+	for i := 1; i < len(lines); i++ {
+		l := lines[i].text
+		if indent == "" || !strings.HasPrefix(l, indent) || strings.HasPrefix(l[len(indent):], " ") {
+			continue
+		}
+		m := pyDeclRe.FindStringSubmatch(l[len(indent):])
+		if m == nil {
+			continue
+		}
+		start := i
+		for start > first+1 && strings.HasPrefix(strings.TrimSpace(lines[start-1].text), "@") {
+			start--
+		}
+		blocks = append(blocks, block{start: lines[first].start, end: lines[start-1].end, section: section})
+		first = start
+		section = []string{class[0], m[2]}
+	}
+	blocks = append(blocks, block{start: lines[first].start, end: lines[len(lines)-1].end, section: section})
+	return blocks
+}
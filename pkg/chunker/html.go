@@ -0,0 +1,260 @@
+package chunker
+
+import (
+	"html"
+	"strings"
+)
+
+// blockTags end the current text block when opened or closed.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true,
+	"footer": true, "nav": true, "aside": true, "main": true, "ul": true,
+	"ol": true, "li": true, "blockquote": true, "br": true, "hr": true,
+	"dl": true, "dt": true, "dd": true, "figure": true, "figcaption": true,
+	"form": true, "body": true, "tr": true, "td": true, "th": true,
+}
+
+// skipTags have content that is never indexed.
+var skipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "noscript": true,
+	"template": true, "svg": true, "iframe": true,
+}
+
+// chunkHTML splits HTML along its block structure. Headings (h1–h6) set the
+// section path, tables and <pre> are kept intact, and script/style content is
+// dropped. Chunk content is plain text; Start/End locate the source HTML.
+func chunkHTML(text string, opts ChunkOptions) []TextChunk {
+	return packBlocks(text, htmlBlocks(text), opts)
+}
+
+type htmlTag struct {
+	name    string
+	closing bool
+	start   int // offset of '<'
+	end     int // offset after '>'
+}
+
+// nextTag finds the next tag at or after pos, skipping comments and doctypes.
+func nextTag(src string, pos int) (htmlTag, bool) {
+	for {
+		i := strings.IndexByte(src[pos:], '<')
+		if i < 0 {
+			return htmlTag{}, false
+		}
+		start := pos + i
+		if strings.HasPrefix(src[start:], "<!--") {
+			j := strings.Index(src[start:], "-->")
+			if j < 0 {
+				return htmlTag{}, false
+			}
+			pos = start + j + 3
+			continue
+		}
+		j := strings.IndexByte(src[start:], '>')
+		if j < 0 {
+			return htmlTag{}, false
+		}
+		end := start + j + 1
+		body := strings.TrimSpace(src[start+1 : end-1])
+		if strings.HasPrefix(body, "!") || strings.HasPrefix(body, "?") {
+			pos = end
+			continue
+		}
+
+		tag := htmlTag{start: start, end: end}
+		if strings.HasPrefix(body, "/") {
+			tag.closing = true
+			body = body[1:]
+		}
+		name := body
+		if k := strings.IndexAny(body, " \t\r\n/"); k >= 0 {
+			name = body[:k]
+		}
+		tag.name = strings.ToLower(name)
+		return tag, true
+	}
+}
+
+// skipTo returns the offset after the closing tag matching name, honouring
+// nesting, or len(src) if it never closes.
+func skipTo(src string, pos int, name string) (inner, after int) {
+	depth := 1
+	for {
+		tag, ok := nextTag(src, pos)
+		if !ok {
+			return len(src), len(src)
+		}
+		if tag.name == name {
+			if tag.closing {
+				depth--
+				if depth == 0 {
+					return tag.start, tag.end
+				}
+			} else {
+				depth++
+			}
+		}
+		pos = tag.end
+	}
+}
+
+func htmlBlocks(src string) []block {
+	var blocks []block
+	var section []string
+	var levels []int
+
+	var text strings.Builder
+	blockStart := -1
+
+	flush := func(end int) {
+		content := collapseSpace(text.String())
+		if content != "" && blockStart >= 0 {
+			blocks = append(blocks, block{start: blockStart, end: end, content: content, section: section})
+		}
+		text.Reset()
+		blockStart = -1
+	}
+	addText := func(s string, at int) {
+		if strings.TrimSpace(s) == "" {
+			text.WriteString(" ")
+			return
+		}
+		if blockStart < 0 {
+			blockStart = at
+		}
+		text.WriteString(html.UnescapeString(s))
+	}
+
+	pos := 0
+	for pos < len(src) {
+		tag, ok := nextTag(src, pos)
+		if !ok {
+			addText(src[pos:], pos)
+			break
+		}
+		addText(src[pos:tag.start], pos)
+		pos = tag.end
+
+		switch {
+		case tag.closing:
+			if blockTags[tag.name] {
+				flush(tag.end)
+			}
+
+		case skipTags[tag.name]:
+			_, pos = skipTo(src, tag.end, tag.name)
+
+		case headingTag(tag.name) > 0:
+			flush(tag.start)
+			inner, after := skipTo(src, tag.end, tag.name)
+			heading := collapseSpace(html.UnescapeString(stripTags(src[tag.end:inner])))
+			pos = after
+			if heading == "" {
+				continue
+			}
+			level := headingTag(tag.name)
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				section = section[:len(section)-1]
+			}
+			levels = append(levels, level)
+			section = append(copySection(section), heading)
+			blocks = append(blocks, block{start: tag.start, end: after, content: heading, section: section})
+
+		case tag.name == "table":
+			flush(tag.start)
+			inner, after := skipTo(src, tag.end, "table")
+			if content := tableText(src[tag.end:inner]); content != "" {
+				blocks = append(blocks, block{start: tag.start, end: after, content: content, section: section, atomic: true})
+			}
+			pos = after
+
+		case tag.name == "pre":
+			flush(tag.start)
+			inner, after := skipTo(src, tag.end, "pre")
+			if content := strings.TrimSpace(html.UnescapeString(stripTags(src[tag.end:inner]))); content != "" {
+				blocks = append(blocks, block{start: tag.start, end: after, content: content, section: section, atomic: true})
+			}
+			pos = after
+
+		case blockTags[tag.name]:
+			flush(tag.start)
+		}
+	}
+	flush(len(src))
+
+	return blocks
+}
+
+func headingTag(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
+
+// tableText renders a table as one line per row with cells joined by " | ".
+func tableText(src string) string {
+	var rows []string
+	var cells []string
+	var cell strings.Builder
+	inCell := false
+
+	endCell := func() {
+		if inCell {
+			cells = append(cells, collapseSpace(html.UnescapeString(cell.String())))
+			cell.Reset()
+			inCell = false
+		}
+	}
+	endRow := func() {
+		endCell()
+		if len(cells) > 0 {
+			rows = append(rows, strings.Join(cells, " | "))
+			cells = nil
+		}
+	}
+
+	pos := 0
+	for pos < len(src) {
+		tag, ok := nextTag(src, pos)
+		if !ok {
+			break
+		}
+		if inCell {
+			cell.WriteString(src[pos:tag.start])
+		}
+		pos = tag.end
+
+		switch tag.name {
+		case "td", "th":
+			endCell()
+			if !tag.closing {
+				inCell = true
+			}
+		case "tr":
+			endRow()
+		}
+	}
+	endRow()
+	return strings.Join(rows, "\n")
+}
+
+func stripTags(src string) string {
+	var sb strings.Builder
+	pos := 0
+	for pos < len(src) {
+		tag, ok := nextTag(src, pos)
+		if !ok {
+			sb.WriteString(src[pos:])
+			break
+		}
+		sb.WriteString(src[pos:tag.start])
+		pos = tag.end
+	}
+	return sb.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
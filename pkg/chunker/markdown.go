@@ -0,0 +1,90 @@
+package chunker
+
+import "strings"
+
+// chunkMarkdown splits Markdown along its heading hierarchy. Fenced code
+// blocks and tables are kept intact, and each chunk's SectionPath is the
+// heading path it falls under, e.g. ["Install", "Linux"].
+func chunkMarkdown(text string, opts ChunkOptions) []TextChunk {
+	return packBlocks(text, markdownBlocks(text), opts)
+}
+
+func markdownBlocks(text string) []block {
+	var blocks []block
+	var section []string
+	var levels []int
+
+	lines := splitLines(text)
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line.text)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case isFence(trimmed):
+			// Fenced code runs to the matching closing fence (or EOF).
+			fence := trimmed[:3]
+			j := i + 1
+			for j < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[j].text), fence) {
+				j++
+			}
+			if j == len(lines) {
+				j--
+			}
+			blocks = append(blocks, block{start: line.start, end: lines[j].end, section: section, atomic: true})
+			i = j + 1
+
+		case isTableRow(trimmed):
+			j := i
+			for j+1 < len(lines) && isTableRow(strings.TrimSpace(lines[j+1].text)) {
+				j++
+			}
+			blocks = append(blocks, block{start: line.start, end: lines[j].end, section: section, atomic: true})
+			i = j + 1
+
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels = levels[:len(levels)-1]
+				section = section[:len(section)-1]
+			}
+			levels = append(levels, level)
+			section = append(copySection(section), strings.TrimSpace(trimmed[level:]))
+			blocks = append(blocks, block{start: line.start, end: line.end, section: section})
+			i++
+
+		default:
+			// A paragraph runs until a blank line or another block starts.
+			j := i
+			for j+1 < len(lines) {
+				next := strings.TrimSpace(lines[j+1].text)
+				if next == "" || isFence(next) || isTableRow(next) || headingLevel(next) > 0 {
+					break
+				}
+				j++
+			}
+			blocks = append(blocks, block{start: line.start, end: lines[j].end, section: section})
+			i = j + 1
+		}
+	}
+	return blocks
+}
+
+func isFence(line string) bool {
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+func isTableRow(line string) bool {
+	return strings.HasPrefix(line, "|")
+}
+
+// headingLevel returns 1–6 for an ATX heading ("## Title"), else 0.
+func headingLevel(line string) int {
+	level := len(line) - len(strings.TrimLeft(line, "#"))
+	if level == 0 || level > 6 || len(line) == level || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
@@ -3,6 +3,7 @@ package chunker
 import (
 	"context"
 	"math"
+	"strconv"
 )
 
 // EmbedFunc is the embedding function signature used by SemanticChunker.
@@ -30,81 +31,68 @@ func NewSemanticChunker(embedFn EmbedFunc, threshold float64, bufferSize int) *S
 	return &SemanticChunker{embedFn: embedFn, threshold: threshold, bufferSize: bufferSize}
 }
 
-// ChunkWithContext splits text semantically, returning TextChunks.
+// ChunkWithContext splits text semantically with DefaultOptions sizing.
 // It requires a context because it makes embedding calls.
 func (sc *SemanticChunker) ChunkWithContext(ctx context.Context, text string) ([]TextChunk, error) {
-	sentences := splitSentences(text)
+	return sc.ChunkWithOptions(ctx, text, DefaultOptions())
+}
+
+// ChunkWithOptions splits text at topic boundaries, then packs each topic's
+// sentences into chunks of at most opts.ChunkSize with opts.ChunkOverlap.
+// Chunks never span a topic boundary.
+func (sc *SemanticChunker) ChunkWithOptions(ctx context.Context, text string, opts ChunkOptions) ([]TextChunk, error) {
+	opts = opts.normalize()
+	sentences := sentenceSpans(text)
 	if len(sentences) == 0 {
 		return nil, nil
 	}
-	if len(sentences) == 1 {
-		return []TextChunk{{Content: sentences[0], Index: 0}}, nil
-	}
 
-	// Build windows: each window is a group of bufferSize adjacent sentences.
-	windows := buildWindows(sentences, sc.bufferSize)
+	topic := make([]int, len(sentences))
+	if len(sentences) > 1 {
+		// Build windows: each window is a group of bufferSize adjacent sentences.
+		windows := buildWindows(text, sentences, sc.bufferSize)
 
-	// Embed all windows in one batch.
-	embeddings, err := sc.embedFn(ctx, windows)
-	if err != nil {
-		return nil, err
-	}
+		// Embed all windows in one batch.
+		embeddings, err := sc.embedFn(ctx, windows)
+		if err != nil {
+			return nil, err
+		}
 
-	// Find split points where cosine similarity drops below threshold.
-	splits := []int{0} // start of first chunk
-	for i := 0; i < len(embeddings)-1; i++ {
-		sim := cosineSimilarity(embeddings[i], embeddings[i+1])
-		if sim < sc.threshold {
-			splits = append(splits, i+1)
+		// A new topic starts where cosine similarity drops below threshold.
+		for i := 0; i < len(embeddings)-1; i++ {
+			topic[i+1] = topic[i]
+			if cosineSimilarity(embeddings[i], embeddings[i+1]) < sc.threshold {
+				topic[i+1]++
+			}
 		}
 	}
-	splits = append(splits, len(sentences)) // end sentinel
 
-	// Build output chunks.
-	chunks := make([]TextChunk, 0, len(splits)-1)
-	for i := 0; i < len(splits)-1; i++ {
-		start := splits[i]
-		end := splits[i+1]
-		content := joinSentences(sentences[start:end])
-		if content == "" {
-			continue
-		}
-		chunks = append(chunks, TextChunk{
-			Content: content,
-			Index:   i,
-		})
+	// The packer never crosses a section, so topics are passed as sections.
+	blocks := make([]block, len(sentences))
+	for i, s := range sentences {
+		blocks[i] = block{start: s.start, end: s.end, section: []string{strconv.Itoa(topic[i])}}
 	}
-	return chunks, nil
+	chunks := packBlocks(text, blocks, opts)
+	for i := range chunks {
+		chunks[i].SectionPath = nil
+	}
+	return finalize(text, chunks, opts.countTokens()), nil
 }
 
 // buildWindows creates one string per sentence where each string is the
-// concatenation of up to bufferSize sentences centered on that sentence.
-func buildWindows(sentences []string, bufferSize int) []string {
+// text spanning up to bufferSize sentences centered on that sentence.
+func buildWindows(text string, sentences []block, bufferSize int) []string {
 	n := len(sentences)
 	windows := make([]string, n)
 	half := bufferSize / 2
 	for i := range sentences {
-		start := i - half
-		if start < 0 {
-			start = 0
-		}
-		end := i + half + 1
-		if end > n {
-			end = n
-		}
-		windows[i] = joinSentences(sentences[start:end])
+		start := max(i-half, 0)
+		end := min(i+half+1, n)
+		windows[i] = text[sentences[start].start:sentences[end-1].end]
 	}
 	return windows
 }
 
-func joinSentences(ss []string) string {
-	result := ""
-	for _, s := range ss {
-		result += s
-	}
-	return result
-}
-
 func cosineSimilarity(a, b []float32) float64 {
 	if len(a) != len(b) || len(a) == 0 {
 		return 0
@@ -0,0 +1,128 @@
+package chunker
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviations are lower-cased words that end with a period without ending
+// the sentence. Multi-part forms are stored without their final period.
+// Words that are also common at the end of a sentence ("no", "est", "ed",
+// "al", "gen", "col") are left out.
+var abbreviations = map[string]bool{
+	"e.g": true, "i.e": true, "cf": true, "vs": true, "viz": true, "approx": true,
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true,
+	"jr": true, "st": true, "mt": true, "rev": true,
+	"inc": true, "ltd": true, "co": true, "corp": true, "dept": true,
+	"fig": true, "vol": true, "pp": true,
+	"jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true,
+	"aug": true, "sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+	"u.s": true, "u.k": true, "a.m": true, "p.m": true, "ph.d": true,
+}
+
+// sentenceSpans splits text into sentences, returning their byte ranges
+// without surrounding whitespace. A terminator (. ! ?), with any closing
+// quotes or brackets, ends a sentence only when followed by whitespace and
+// then anything but a lowercase letter. Periods after
+// known abbreviations ("e.g.", "Dr.") and single-letter initials don't end
+// a sentence. A blank line always does.
+func sentenceSpans(text string) []block {
+	var spans []block
+	start := -1
+
+	emit := func(end int) {
+		if start < 0 {
+			return
+		}
+		s := strings.TrimRightFunc(text[start:end], unicode.IsSpace)
+		if s != "" {
+			spans = append(spans, block{start: start, end: start + len(s)})
+		}
+		start = -1
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if start < 0 {
+			if !unicode.IsSpace(r) {
+				start = i
+			}
+			i += size
+			continue
+		}
+
+		if r == '\n' && strings.HasPrefix(strings.TrimLeft(text[i+1:], " \t\r"), "\n") {
+			emit(i)
+			i += size
+			continue
+		}
+
+		if r == '.' || r == '!' || r == '?' {
+			end := i + size
+			for end < len(text) {
+				c, n := utf8.DecodeRuneInString(text[end:])
+				if !strings.ContainsRune(`"')]}”’`, c) {
+					break
+				}
+				end += n
+			}
+			if isSentenceEnd(text, start, i, end) {
+				emit(end)
+				i = end
+				continue
+			}
+		}
+		i += size
+	}
+	emit(len(text))
+	return spans
+}
+
+// isSentenceEnd decides whether the terminator at text[term] (with closing
+// punctuation up to end) ends the sentence that began at start.
+func isSentenceEnd(text string, start, term, end int) bool {
+	if end == len(text) {
+		return true
+	}
+	rest := text[end:]
+	next := strings.TrimLeftFunc(rest, unicode.IsSpace)
+	if len(next) == len(rest) {
+		return false // "3.14", "example.com"
+	}
+	if next == "" {
+		return true
+	}
+
+	if text[term] == '.' {
+		before := text[start:term]
+		word := before
+		if i := strings.LastIndexFunc(word, unicode.IsSpace); i >= 0 {
+			word = word[i+1:]
+		}
+		word = strings.ToLower(strings.TrimLeft(word, `"'([{`))
+		if abbreviations[word] {
+			return false
+		}
+		if word == "al" && strings.HasSuffix(" "+strings.ToLower(before), " et al") {
+			return false // "Smith et al. found"
+		}
+		if utf8.RuneCountInString(word) == 1 && unicode.IsLetter([]rune(word)[0]) {
+			return false // initial: "J. Smith"
+		}
+	}
+
+	// A lowercase continuation means the period was not a sentence end.
+	r, _ := utf8.DecodeRuneInString(next)
+	return !unicode.IsLower(r)
+}
+
+// splitSentences returns the sentences of text.
+func splitSentences(text string) []string {
+	spans := sentenceSpans(text)
+	sentences := make([]string, len(spans))
+	for i, s := range spans {
+		sentences[i] = text[s.start:s.end]
+	}
+	return sentences
+}
@@ -0,0 +1,105 @@
+package chunker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "terminators",
+			text: "It works. Does it? Yes! Done.",
+			want: []string{"It works.", "Does it?", "Yes!", "Done."},
+		},
+		{
+			name: "abbreviations",
+			text: "Dr. Smith arrived, e.g. at noon. Mrs. Jones left at 5 p.m. Then it rained.",
+			want: []string{"Dr. Smith arrived, e.g. at noon.", "Mrs. Jones left at 5 p.m. Then it rained."},
+		},
+		{
+			name: "multi-part abbreviations",
+			text: "Sales in the U.S. Rose sharply. I.e. they doubled.",
+			want: []string{"Sales in the U.S. Rose sharply.", "I.e. they doubled."},
+		},
+		{
+			name: "initials",
+			text: "J. R. Tolkien wrote it. It is long.",
+			want: []string{"J. R. Tolkien wrote it.", "It is long."},
+		},
+		{
+			name: "et al",
+			text: "Smith et al. Found the cause. Others agreed.",
+			want: []string{"Smith et al. Found the cause.", "Others agreed."},
+		},
+		{
+			name: "numbers and domains",
+			text: "Pi is 3.14 or so. Visit example.com today. It moved.",
+			want: []string{"Pi is 3.14 or so.", "Visit example.com today.", "It moved."},
+		},
+		{
+			name: "lowercase continuation",
+			text: "See the appendix. for details. Next part.",
+			want: []string{"See the appendix. for details.", "Next part."},
+		},
+		{
+			name: "closing quotes and brackets",
+			text: `He said "stop." Then he left. (It was late.) Everyone slept.`,
+			want: []string{`He said "stop."`, "Then he left.", "(It was late.)", "Everyone slept."},
+		},
+		{
+			name: "blank line",
+			text: "A heading without a period\n\nThe body starts here.",
+			want: []string{"A heading without a period", "The body starts here."},
+		},
+		{
+			name: "single newline doesn't split",
+			text: "A sentence wrapped\nover two lines. Next.",
+			want: []string{"A sentence wrapped\nover two lines.", "Next."},
+		},
+		{
+			name: "surrounding whitespace",
+			text: "  \n Leading space. Trailing space.  \n",
+			want: []string{"Leading space.", "Trailing space."},
+		},
+		{
+			name: "words ending a sentence aren't abbreviations",
+			text: "The answer was no. We moved on.",
+			want: []string{"The answer was no.", "We moved on."},
+		},
+		{
+			name: "empty",
+			text: " \n\t",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitSentences(tt.text)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitSentences(%q)\n got %q\nwant %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentenceSpanOffsets(t *testing.T) {
+	// Byte offsets: ö, ß, ä and Ü take two bytes each.
+	text := "  Größe zählt. Über alles?\n\nJa. "
+	want := []block{{start: 2, end: 17}, {start: 18, end: 30}, {start: 32, end: 35}}
+	got := sentenceSpans(text)
+	if len(got) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].start != want[i].start || got[i].end != want[i].end {
+			t.Errorf("span %d = [%d, %d) %q, want [%d, %d) %q", i,
+				got[i].start, got[i].end, text[got[i].start:got[i].end],
+				want[i].start, want[i].end, text[want[i].start:want[i].end])
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package chunker
+
+import (
+	"strings"
+	"unicode"
+)
+
+// block is a unit of text the packer works with: a sentence, word or
+// paragraph for the flat strategies, or a heading, table, code fence or
+// function for the structure-aware ones. start and end are byte offsets
+// into the source text.
+type block struct {
+	start, end int
+	// content is the text to emit; empty means text[start:end].
+	content string
+	section []string
+	// atomic blocks (tables, code) are never split, even when oversized.
+	atomic bool
+}
+
+func (b block) text(src string) string {
+	if b.content != "" {
+		return b.content
+	}
+	return src[b.start:b.end]
+}
+
+// separators are tried coarsest first (page, paragraph, line, sentence,
+// word) when splitting an oversized block.
+var separators = []string{"\f", "\n\n", "\n", ". ", " "}
+
+// packBlocks merges consecutive blocks of the same section into chunks of up
+// to opts.ChunkSize, measured on the chunk's joined text, since summing the
+// blocks' sizes undercounts tokens. Each chunk after the first starts with
+// up to opts.ChunkOverlap of the previous chunk's tail: whole blocks when
+// they fit, otherwise the words ending the last one. A chunk never spans two
+// sections, so each chunk's SectionPath is exact. Oversized non-atomic blocks
+// are split recursively; oversized atomic blocks become a chunk of their own.
+func packBlocks(src string, blocks []block, opts ChunkOptions) []TextChunk {
+	opts = opts.normalize()
+	measure := opts.measure()
+
+	var pieces []block
+	for _, b := range blocks {
+		pieces = append(pieces, splitOversized(src, b, opts.ChunkSize, measure)...)
+	}
+
+	var chunks []TextChunk
+	var cur []block
+	for _, p := range pieces {
+		if len(cur) > 0 {
+			switch {
+			case !sameSection(cur[0].section, p.section):
+				chunks = appendChunk(chunks, src, cur)
+				cur = nil
+			case measure(joinBlocks(src, append(cur[:len(cur):len(cur)], p))) > opts.ChunkSize:
+				chunks = appendChunk(chunks, src, cur)
+				cur = overlapTail(src, cur, p, opts, measure)
+			}
+		}
+		cur = append(cur, p)
+	}
+	return appendChunk(chunks, src, cur)
+}
+
+// overlapTail returns the longest tail of a chunk's blocks within
+// opts.ChunkOverlap that still leaves room for next, to start the following
+// chunk with. The tail may begin at any word of the non-atomic blocks, so a
+// chunk of large paragraphs still overlaps the next.
+func overlapTail(src string, cur []block, next block, opts ChunkOptions, measure func(string) int) []block {
+	if opts.ChunkOverlap == 0 {
+		return nil
+	}
+	fits := func(tail []block) bool {
+		return measure(joinBlocks(src, tail)) <= opts.ChunkOverlap &&
+			measure(joinBlocks(src, append(tail[:len(tail):len(tail)], next))) <= opts.ChunkSize
+	}
+
+	var best []block
+	for i := len(cur) - 1; i >= 0; i-- {
+		b := cur[i]
+		rest := cur[i+1:]
+		if !b.atomic {
+			// Try ever longer word suffixes of this block.
+			text := b.text(src)
+			for _, w := range wordStarts(text) {
+				tail := append([]block{suffixBlock(b, text, w)}, rest...)
+				if !fits(tail) {
+					break
+				}
+				best = tail
+			}
+		}
+		whole := cur[i:]
+		if !fits(whole) {
+			break
+		}
+		best = append([]block(nil), whole...)
+	}
+	return best
+}
+
+// wordStarts returns the byte offsets at which the words of text begin,
+// last first, excluding the first word (the whole text is tried apart).
+func wordStarts(text string) []int {
+	var starts []int
+	for i := len(text) - 1; i > 0; i-- {
+		if !isSpaceByte(text[i]) && isSpaceByte(text[i-1]) {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// suffixBlock is the part of b whose text starts at offset w of text.
+func suffixBlock(b block, text string, w int) block {
+	if b.content != "" {
+		b.content = text[w:]
+		return b
+	}
+	b.start += w
+	return b
+}
+
+// joinBlocks renders blocks as chunk text: verbatim blocks as the source
+// slice they span, others joined by blank lines.
+func joinBlocks(src string, blocks []block) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+	verbatim := true
+	for _, b := range blocks {
+		if b.content != "" {
+			verbatim = false
+			break
+		}
+	}
+	if verbatim {
+		return src[blocks[0].start:blocks[len(blocks)-1].end]
+	}
+	parts := make([]string, len(blocks))
+	for i, b := range blocks {
+		parts[i] = b.text(src)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// appendChunk emits the blocks as one chunk. Verbatim blocks are emitted as
+// the source slice they span, so Content always matches Start:End.
+func appendChunk(chunks []TextChunk, src string, blocks []block) []TextChunk {
+	if len(blocks) == 0 {
+		return chunks
+	}
+	chunk := TextChunk{
+		Content:     joinBlocks(src, blocks),
+		Start:       blocks[0].start,
+		End:         blocks[len(blocks)-1].end,
+		SectionPath: blocks[0].section,
+	}
+	if strings.TrimSpace(chunk.Content) == "" {
+		return chunks
+	}
+	return append(chunks, chunk)
+}
+
+// splitOversized trims a block and, if it is larger than limit and not
+// atomic, splits it at the coarsest separators that fit. Pieces of a
+// verbatim block get their own offsets; pieces of extracted content keep
+// the block's range.
+func splitOversized(src string, b block, limit int, measure func(string) int) []block {
+	if b.content == "" {
+		raw := src[b.start:b.end]
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" {
+			return nil
+		}
+		b.start += len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+		b.end = b.start + len(trimmed)
+	} else {
+		b.content = strings.TrimSpace(b.content)
+		if b.content == "" {
+			return nil
+		}
+	}
+
+	text := b.text(src)
+	if b.atomic || measure(text) <= limit {
+		return []block{b}
+	}
+
+	var out []block
+	for _, s := range splitSpans(text, 0, len(text), separators, limit, measure) {
+		piece := strings.TrimSpace(text[s.start:s.end])
+		if piece == "" {
+			continue
+		}
+		lead := strings.Index(text[s.start:s.end], piece)
+		p := block{section: b.section}
+		if b.content == "" {
+			p.start = b.start + s.start + lead
+			p.end = p.start + len(piece)
+		} else {
+			p.start, p.end, p.content = b.start, b.end, piece
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+// splitSpans breaks text[start:end] at the first separator, recursing with
+// finer separators into pieces that are still larger than limit. Separators
+// stay attached to the piece before them. With no separators left the span
+// is cut by runes.
+func splitSpans(text string, start, end int, seps []string, limit int, measure func(string) int) []block {
+	if measure(text[start:end]) <= limit {
+		return []block{{start: start, end: end}}
+	}
+	if len(seps) == 0 {
+		return hardSplit(text, start, end, limit, measure)
+	}
+
+	var out []block
+	for pos := start; pos < end; {
+		next := end
+		if i := strings.Index(text[pos:end], seps[0]); i >= 0 {
+			next = pos + i + len(seps[0])
+		}
+		out = append(out, splitSpans(text, pos, next, seps[1:], limit, measure)...)
+		pos = next
+	}
+	return out
+}
+
+// hardSplit cuts text[start:end] into the longest rune runs within limit.
+func hardSplit(text string, start, end int, limit int, measure func(string) int) []block {
+	var out []block
+	pieceStart := start
+	for i := range text[start:end] {
+		pos := start + i
+		if pos > pieceStart && measure(text[pieceStart:pos+1]) > limit {
+			out = append(out, block{start: pieceStart, end: pos})
+			pieceStart = pos
+		}
+	}
+	if pieceStart < end {
+		out = append(out, block{start: pieceStart, end: end})
+	}
+	return out
+}
+
+func sameSection(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lineAt is one line of source with its byte range (excluding the newline).
+type lineAt struct {
+	text       string
+	start, end int
+}
+
+func splitLines(src string) []lineAt {
+	var lines []lineAt
+	start := 0
+	for start <= len(src) {
+		i := strings.IndexByte(src[start:], '\n')
+		if i < 0 {
+			if start < len(src) {
+				lines = append(lines, lineAt{text: src[start:], start: start, end: len(src)})
+			}
+			break
+		}
+		lines = append(lines, lineAt{text: src[start : start+i], start: start, end: start + i})
+		start += i + 1
+	}
+	return lines
+}
+
+// copySection returns an independent copy so later pushes don't alias.
+func copySection(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	out := make([]string, len(s))
+	copy(out, s)
+	return out
+}
@@ -0,0 +1,153 @@
+package textextract
+
+import "strings"
+
+// Block types.
+const (
+	BlockHeading   = "heading"
+	BlockParagraph = "paragraph"
+	BlockListItem  = "list_item"
+	BlockTable     = "table"
+	BlockPageBreak = "page_break"
+)
+
+// Block is one element of a laid-out document, in reading order. Page is
+// the 1-based page the block starts on; a page_break block starts the page
+// it names.
+type Block struct {
+	Type string `json:"type"`
+	// Level is a heading's level, or a list item's nesting depth; both
+	// start at 1.
+	Level int        `json:"level,omitempty"`
+	Text  string     `json:"text,omitempty"`
+	Rows  [][]string `json:"rows,omitempty"`
+	Page  int        `json:"page"`
+}
+
+// FromBlocks builds the result for a laid-out document. Content is the
+// blocks as Markdown, pages separated by form feeds; sections split at
+// headings and page breaks.
+func FromBlocks(blocks []Block, metadata map[string]string) *ExtractedText {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	pages := 1
+	for _, b := range blocks {
+		pages = max(pages, b.Page)
+	}
+	return &ExtractedText{
+		Content:  renderBlocks(blocks),
+		Pages:    pages,
+		Metadata: metadata,
+		Sections: blockSections(blocks, pages > 1),
+		Blocks:   blocks,
+	}
+}
+
+// renderBlocks writes blocks as Markdown: headings with '#', list items
+// with '-', tables as pipe tables the markdown chunker keeps whole.
+func renderBlocks(blocks []Block) string {
+	var buf strings.Builder
+	prev := ""
+	for _, b := range blocks {
+		if b.Type == BlockPageBreak {
+			if buf.Len() > 0 {
+				buf.WriteString("\n")
+			}
+			buf.WriteString("\f")
+			prev = b.Type
+			continue
+		}
+		text := blockMarkdown(b)
+		if text == "" {
+			continue
+		}
+		switch {
+		case prev == "" || prev == BlockPageBreak:
+		case prev == BlockListItem && b.Type == BlockListItem:
+			buf.WriteString("\n")
+		default:
+			buf.WriteString("\n\n")
+		}
+		buf.WriteString(text)
+		prev = b.Type
+	}
+	return buf.String()
+}
+
+func blockMarkdown(b Block) string {
+	switch b.Type {
+	case BlockHeading:
+		return strings.Repeat("#", min(max(b.Level, 1), 6)) + " " + b.Text
+	case BlockListItem:
+		return strings.Repeat("  ", max(b.Level-1, 0)) + "- " + b.Text
+	case BlockTable:
+		return tableMarkdown(b.Rows)
+	}
+	return b.Text
+}
+
+// tableMarkdown writes rows as a Markdown pipe table, the first row being
+// the header.
+func tableMarkdown(rows [][]string) string {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if width == 0 {
+		return ""
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		cells := make([]string, width)
+		for j := range cells {
+			if j < len(row) {
+				cells[j] = strings.ReplaceAll(collapseSpace(row[j]), "|", `\|`)
+			}
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", width))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// blockSections groups blocks into sections, starting one at each heading
+// and each page break. A section continued on a new page keeps its
+// heading's title. Untitled sections of paged documents are pages.
+func blockSections(blocks []Block, paged bool) []Section {
+	untitled := SectionText
+	if paged {
+		untitled = SectionPage
+	}
+	var (
+		sections  []Section
+		current   = Section{Kind: untitled, Page: 1}
+		continued bool
+		parts     []Block
+	)
+	flush := func() {
+		current.Content = renderBlocks(parts)
+		if current.Content != "" || (current.Title != "" && !continued) {
+			sections = append(sections, current)
+		}
+		parts = nil
+	}
+	for _, b := range blocks {
+		switch b.Type {
+		case BlockHeading:
+			flush()
+			current = Section{Kind: SectionHeading, Title: b.Text, Level: b.Level, Page: b.Page}
+			continued = false
+		case BlockPageBreak:
+			flush()
+			continued = true
+			current.Page = b.Page
+		default:
+			parts = append(parts, b)
+		}
+	}
+	flush()
+	return sections
+}
@@ -0,0 +1,107 @@
+package textextract
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// rowsPerSection is how many table rows go in one section, so each section
+// (and so each chunk built from it) repeats the column names.
+const rowsPerSection = 50
+
+// extractDelimited extracts CSV or TSV. The first row is taken as the
+// header and every other row is written as "column: value" pairs, in
+// sections of rowsPerSection rows.
+func extractDelimited(data io.ReaderAt, size int64, comma rune) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("read delimited text: %w", err)
+	}
+
+	r := csv.NewReader(strings.NewReader(decodeText(buf)))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parse delimited text: %w", err)
+	}
+
+	sections := tableSections(records, "")
+	metadata := map[string]string{"rows": strconv.Itoa(max(len(records)-1, 0))}
+	if len(records) > 0 {
+		metadata["columns"] = strconv.Itoa(len(records[0]))
+	}
+	return structured(sections, metadata), nil
+}
+
+// tableSections turns rows, the first being the header, into sections of
+// rowsPerSection rows titled by their row range. name prefixes the titles.
+func tableSections(rows [][]string, name string) []Section {
+	rows = nonEmptyRows(rows)
+	if len(rows) == 0 {
+		return nil
+	}
+	header := rows[0]
+	if len(rows) == 1 {
+		return []Section{{Kind: SectionTable, Title: name, Level: 1, Content: strings.Join(header, " | ")}}
+	}
+
+	var sections []Section
+	for start := 1; start < len(rows); start += rowsPerSection {
+		end := min(start+rowsPerSection, len(rows))
+		lines := make([]string, 0, end-start)
+		for _, row := range rows[start:end] {
+			lines = append(lines, recordLine(header, row))
+		}
+		title := fmt.Sprintf("Rows %d-%d", start, end-1)
+		if name != "" {
+			title = name + ": " + title
+		}
+		sections = append(sections, Section{
+			Kind:    SectionTable,
+			Title:   title,
+			Level:   1,
+			Content: strings.Join(lines, "\n"),
+		})
+	}
+	return sections
+}
+
+// recordLine writes a row as "column: value" pairs, skipping empty cells.
+func recordLine(header, row []string) string {
+	pairs := make([]string, 0, len(row))
+	for i, value := range row {
+		value = collapseSpace(value)
+		if value == "" {
+			continue
+		}
+		column := ""
+		if i < len(header) {
+			column = collapseSpace(header[i])
+		}
+		if column == "" {
+			column = "column " + strconv.Itoa(i+1)
+		}
+		pairs = append(pairs, column+": "+value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// nonEmptyRows drops rows with no non-blank cells.
+func nonEmptyRows(rows [][]string) [][]string {
+	kept := rows[:0:0]
+	for _, row := range rows {
+		for _, cell := range row {
+			if strings.TrimSpace(cell) != "" {
+				kept = append(kept, row)
+				break
+			}
+		}
+	}
+	return kept
+}
@@ -0,0 +1,232 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// Formats Detect recognizes and Extract handles.
+const (
+	FormatPDF      = "pdf"
+	FormatDOCX     = "docx"
+	FormatXLSX     = "xlsx"
+	FormatPPTX     = "pptx"
+	FormatEPUB     = "epub"
+	FormatRTF      = "rtf"
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatTSV      = "tsv"
+	FormatJSON     = "json"
+	FormatJSONL    = "jsonl"
+	FormatEmail    = "eml"
+	FormatText     = "txt"
+	FormatPNG      = "png"
+	FormatJPEG     = "jpeg"
+	FormatTIFF     = "tiff"
+)
+
+// declared maps extensions and MIME types to formats.
+var declared = map[string]string{
+	".pdf": FormatPDF, "application/pdf": FormatPDF,
+	".docx": FormatDOCX, "application/vnd.openxmlformats-officedocument.wordprocessingml.document": FormatDOCX,
+	".xlsx": FormatXLSX, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": FormatXLSX,
+	".pptx": FormatPPTX, "application/vnd.openxmlformats-officedocument.presentationml.presentation": FormatPPTX,
+	".epub": FormatEPUB, "application/epub+zip": FormatEPUB,
+	".rtf": FormatRTF, "application/rtf": FormatRTF, "text/rtf": FormatRTF,
+	".html": FormatHTML, ".htm": FormatHTML, ".xhtml": FormatHTML, "text/html": FormatHTML, "application/xhtml+xml": FormatHTML,
+	".md": FormatMarkdown, ".markdown": FormatMarkdown, "text/markdown": FormatMarkdown, "text/x-markdown": FormatMarkdown,
+	".csv": FormatCSV, "text/csv": FormatCSV,
+	".tsv": FormatTSV, ".tab": FormatTSV, "text/tab-separated-values": FormatTSV,
+	".json": FormatJSON, "application/json": FormatJSON,
+	".jsonl": FormatJSONL, ".ndjson": FormatJSONL, "application/jsonl": FormatJSONL, "application/x-ndjson": FormatJSONL,
+	".eml": FormatEmail, "message/rfc822": FormatEmail,
+	".txt": FormatText, ".text": FormatText, "text/plain": FormatText,
+	".png": FormatPNG, "image/png": FormatPNG,
+	".jpg": FormatJPEG, ".jpeg": FormatJPEG, "image/jpeg": FormatJPEG,
+	".tif": FormatTIFF, ".tiff": FormatTIFF, "image/tiff": FormatTIFF,
+}
+
+// Detect identifies a file's format from its content and the hints given
+// (a MIME type, a file name or extension, or a format name). Magic bytes of
+// binary formats win over the hints, since clients often mislabel uploads;
+// the hints decide between text formats, and plain text is sniffed last.
+// It returns "" when the file is binary and unrecognized.
+func Detect(data io.ReaderAt, size int64, hints ...string) string {
+	head := make([]byte, min(size, 4096))
+	n, _ := data.ReadAt(head, 0)
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF
+	case bytes.HasPrefix(head, []byte("{\\rtf")):
+		return FormatRTF
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")):
+		return detectZip(data, size)
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return FormatJPEG
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return FormatTIFF
+	}
+
+	for _, hint := range hints {
+		if f := declaredFormat(hint); f != "" && f != FormatText {
+			return f
+		}
+	}
+
+	if !looksText(head) {
+		return ""
+	}
+	return sniffText(data, size, head)
+}
+
+// declaredFormat maps a hint to a format, or "".
+func declaredFormat(hint string) string {
+	hint = strings.ToLower(strings.TrimSpace(hint))
+	if hint == "" {
+		return ""
+	}
+	if mediaType, _, err := mime.ParseMediaType(hint); err == nil {
+		if f, ok := declared[mediaType]; ok {
+			return f
+		}
+	}
+	if f, ok := declared[hint]; ok {
+		return f
+	}
+	if f, ok := declared["."+hint]; ok {
+		return f
+	}
+	if f, ok := declared[filepath.Ext(hint)]; ok {
+		return f
+	}
+	switch hint {
+	case FormatPDF, FormatDOCX, FormatXLSX, FormatPPTX, FormatEPUB, FormatRTF, FormatHTML,
+		FormatMarkdown, FormatCSV, FormatTSV, FormatJSON, FormatJSONL, FormatEmail, FormatText,
+		FormatPNG, FormatJPEG, FormatTIFF:
+		return hint
+	}
+	return ""
+}
+
+// detectZip tells the zip-based formats apart by their entries.
+func detectZip(data io.ReaderAt, size int64) string {
+	zr, err := zip.NewReader(data, size)
+	if err != nil {
+		return ""
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return FormatDOCX
+		case "xl/workbook.xml":
+			return FormatXLSX
+		case "ppt/presentation.xml":
+			return FormatPPTX
+		case "mimetype":
+			if b, err := readZipFile(f); err == nil && strings.TrimSpace(string(b)) == "application/epub+zip" {
+				return FormatEPUB
+			}
+		case "META-INF/container.xml":
+			return FormatEPUB
+		}
+	}
+	return ""
+}
+
+// looksText reports whether head is text: no NUL bytes outside a UTF-16
+// byte order mark.
+func looksText(head []byte) bool {
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		return true
+	}
+	return bytes.IndexByte(head, 0) < 0
+}
+
+// emailHeaders are header names that, at the very start of a file, mark
+// an email message.
+var emailHeaders = []string{
+	"from:", "to:", "subject:", "date:", "received:", "return-path:", "message-id:",
+	"mime-version:", "delivered-to:", "x-",
+}
+
+// sniffText tells text formats apart by their content.
+func sniffText(data io.ReaderAt, size int64, head []byte) string {
+	text := strings.TrimSpace(decodeText(head))
+	lower := strings.ToLower(text)
+
+	switch {
+	case strings.HasPrefix(lower, "<!doctype html"), strings.HasPrefix(lower, "<html"),
+		strings.HasPrefix(lower, "<?xml") && strings.Contains(lower, "<html"):
+		return FormatHTML
+	case strings.HasPrefix(text, "{"), strings.HasPrefix(text, "["):
+		if f := sniffJSON(data, size); f != "" {
+			return f
+		}
+	}
+
+	headers := 0
+	lines := strings.SplitN(lower, "\n", 6)
+	for _, line := range lines[:min(len(lines), 5)] {
+		for _, h := range emailHeaders {
+			if strings.HasPrefix(line, h) {
+				headers++
+				break
+			}
+		}
+	}
+	if headers >= 3 {
+		return FormatEmail
+	}
+	if looksMarkdown(text) {
+		return FormatMarkdown
+	}
+	return FormatText
+}
+
+// looksMarkdown reports whether text opens with front matter or has an
+// ATX heading or code fence in its first lines.
+func looksMarkdown(text string) bool {
+	if strings.HasPrefix(text, "---\n") {
+		return true
+	}
+	lines := strings.SplitN(text, "\n", 21)
+	for _, line := range lines[:min(len(lines), 20)] {
+		if _, _, ok := atxHeading(line); ok {
+			return true
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffJSON reads the whole file: one JSON value is JSON, several
+// newline-separated ones are JSON Lines, and anything else is "".
+func sniffJSON(data io.ReaderAt, size int64) string {
+	dec := json.NewDecoder(io.NewSectionReader(data, 0, size))
+	values := 0
+	for {
+		var v json.RawMessage
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return ""
+		}
+		values++
+	}
+	if values > 1 {
+		return FormatJSONL
+	}
+	return FormatJSON
+}
@@ -0,0 +1,350 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// docxStyle is what a paragraph style contributes to block typing.
+type docxStyle struct {
+	name    string
+	basedOn string
+	outline int // heading level from w:outlineLvl, 0 if none
+	list    bool
+}
+
+// extractDOCX walks word/document.xml into blocks. Paragraph styles and
+// outline levels make headings, numbering makes list items, tables keep
+// their rows, and page breaks are taken from Word's last layout
+// (w:lastRenderedPageBreak) or, for documents never laid out, from explicit
+// breaks.
+func extractDOCX(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	zr, err := zip.NewReader(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("open DOCX: %w", err)
+	}
+	files := zipFiles(zr)
+
+	doc, ok := files["word/document.xml"]
+	if !ok {
+		return nil, fmt.Errorf("open DOCX: missing document.xml")
+	}
+	content, err := readZipFile(doc)
+	if err != nil {
+		return nil, fmt.Errorf("read document.xml: %w", err)
+	}
+	styles, err := docxStyles(files)
+	if err != nil {
+		return nil, fmt.Errorf("read styles: %w", err)
+	}
+	blocks, err := docxBlocks(content, styles)
+	if err != nil {
+		return nil, fmt.Errorf("parse document.xml: %w", err)
+	}
+
+	return FromBlocks(blocks, coreProperties(files)), nil
+}
+
+// docxStyles reads the paragraph styles of word/styles.xml.
+func docxStyles(files map[string]*zip.File) (map[string]docxStyle, error) {
+	styles := map[string]docxStyle{}
+	f, ok := files["word/styles.xml"]
+	if !ok {
+		return styles, nil
+	}
+	content, err := readZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Styles []struct {
+			Type    string `xml:"type,attr"`
+			ID      string `xml:"styleId,attr"`
+			Name    val    `xml:"name"`
+			BasedOn val    `xml:"basedOn"`
+			PPr     struct {
+				OutlineLvl *val      `xml:"outlineLvl"`
+				NumPr      *struct{} `xml:"numPr"`
+			} `xml:"pPr"`
+		} `xml:"style"`
+	}
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	for _, s := range doc.Styles {
+		if s.Type != "paragraph" {
+			continue
+		}
+		style := docxStyle{name: strings.ToLower(s.Name.Val), basedOn: s.BasedOn.Val, list: s.PPr.NumPr != nil}
+		if s.PPr.OutlineLvl != nil {
+			if lvl, err := strconv.Atoi(s.PPr.OutlineLvl.Val); err == nil && lvl < 9 {
+				style.outline = lvl + 1
+			}
+		}
+		styles[s.ID] = style
+	}
+	return styles, nil
+}
+
+type val struct {
+	Val string `xml:"val,attr"`
+}
+
+// headingLevel resolves a style's heading level: its name ("heading 2",
+// "title"), its outline level, or those of the style it is based on.
+func headingLevel(styles map[string]docxStyle, id string) int {
+	for range 10 { // basedOn chains are short; this bounds cycles
+		s, ok := styles[id]
+		if !ok {
+			return headingName(id)
+		}
+		if level := headingName(s.name); level > 0 {
+			return level
+		}
+		if s.outline > 0 {
+			return s.outline
+		}
+		id = s.basedOn
+	}
+	return 0
+}
+
+// headingName parses "heading 1", "Heading1" and "title".
+func headingName(name string) int {
+	name = strings.ToLower(strings.ReplaceAll(name, " ", ""))
+	switch {
+	case name == "title":
+		return 1
+	case strings.HasPrefix(name, "heading"):
+		if n, err := strconv.Atoi(name[len("heading"):]); err == nil && n >= 1 && n <= 9 {
+			return min(n, 6)
+		}
+	}
+	return 0
+}
+
+func isListStyle(styles map[string]docxStyle, id string) bool {
+	for range 10 {
+		s, ok := styles[id]
+		if !ok {
+			return false
+		}
+		if s.list || strings.HasPrefix(s.name, "list bullet") || strings.HasPrefix(s.name, "list number") {
+			return true
+		}
+		id = s.basedOn
+	}
+	return false
+}
+
+// docxParagraph accumulates one paragraph's properties and text.
+type docxParagraph struct {
+	style   string
+	outline int // from the paragraph's own w:outlineLvl
+	list    bool
+	ilvl    int
+	text    strings.Builder
+}
+
+func (p *docxParagraph) block(styles map[string]docxStyle, page int) (Block, bool) {
+	text := collapseSpace(p.text.String())
+	if text == "" {
+		return Block{}, false
+	}
+	level := headingLevel(styles, p.style)
+	if p.outline > 0 {
+		level = p.outline
+	}
+	switch {
+	case level > 0:
+		return Block{Type: BlockHeading, Level: min(level, 6), Text: text, Page: page}, true
+	case p.list || isListStyle(styles, p.style):
+		return Block{Type: BlockListItem, Level: p.ilvl + 1, Text: text, Page: page}, true
+	}
+	return Block{Type: BlockParagraph, Text: text, Page: page}, true
+}
+
+// docxBlocks streams the document body. Paragraphs inside table cells
+// become cell text; nested tables and text boxes are flattened into the
+// enclosing cell or paragraph.
+func docxBlocks(content []byte, styles map[string]docxStyle) ([]Block, error) {
+	rendered := bytes.Contains(content, []byte("lastRenderedPageBreak"))
+
+	var (
+		blocks     []Block
+		page       = 1
+		para       *docxParagraph
+		paraDepth  int
+		tableDepth int
+		rows       [][]string
+		row        []string
+		cell       []string
+		inText     bool
+		inProps    int // inside paragraph properties, where w:tab is a tab stop
+		skipDepth  int // inside content that duplicates or isn't body text
+	)
+	pageBreak := func() {
+		// A break splits the paragraph it falls in.
+		if para != nil && tableDepth == 0 {
+			if b, ok := para.block(styles, page); ok {
+				blocks = append(blocks, b)
+				para.text.Reset()
+			}
+		}
+		page++
+		blocks = append(blocks, Block{Type: BlockPageBreak, Page: page})
+	}
+
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if skipDepth > 0 {
+				skipDepth++
+				continue
+			}
+			switch t.Name.Local {
+			case "Fallback", "footnoteReference", "commentReference", "del", "instrText", "delInstrText":
+				skipDepth = 1
+			case "p":
+				paraDepth++
+				if paraDepth == 1 {
+					para = &docxParagraph{}
+				} else if para != nil {
+					para.text.WriteByte(' ')
+				}
+			case "pPr":
+				inProps++
+			case "pStyle":
+				if para != nil && paraDepth == 1 {
+					para.style = attrValue(t.Attr, "val")
+				}
+			case "outlineLvl":
+				if lvl, err := strconv.Atoi(attrValue(t.Attr, "val")); err == nil && para != nil && paraDepth == 1 && lvl < 9 {
+					para.outline = lvl + 1
+				}
+			case "numPr":
+				if para != nil && paraDepth == 1 {
+					para.list = true
+				}
+			case "ilvl":
+				if lvl, err := strconv.Atoi(attrValue(t.Attr, "val")); err == nil && para != nil && paraDepth == 1 {
+					para.ilvl = lvl
+				}
+			case "t":
+				inText = true
+			case "tab":
+				if para != nil && inProps == 0 {
+					para.text.WriteByte(' ')
+				}
+			case "br", "cr":
+				switch {
+				case attrValue(t.Attr, "type") == "page" && !rendered:
+					pageBreak()
+				case para != nil:
+					para.text.WriteByte(' ')
+				}
+			case "lastRenderedPageBreak":
+				// Word may mark a break at the very start of a document.
+				if len(blocks) > 0 || (para != nil && para.text.Len() > 0) {
+					pageBreak()
+				}
+			case "tbl":
+				tableDepth++
+				if tableDepth == 1 {
+					rows = nil
+				}
+			case "tr":
+				if tableDepth == 1 {
+					row = nil
+				}
+			case "tc":
+				if tableDepth == 1 {
+					cell = nil
+				}
+			}
+		case xml.EndElement:
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "pPr":
+				inProps--
+			case "p":
+				paraDepth--
+				if paraDepth > 0 || para == nil {
+					continue
+				}
+				if tableDepth > 0 {
+					if text := collapseSpace(para.text.String()); text != "" {
+						cell = append(cell, text)
+					}
+				} else if b, ok := para.block(styles, page); ok {
+					blocks = append(blocks, b)
+				}
+				para = nil
+			case "tc":
+				if tableDepth == 1 {
+					row = append(row, strings.Join(cell, " "))
+				}
+			case "tr":
+				if tableDepth == 1 && strings.TrimSpace(strings.Join(row, "")) != "" {
+					rows = append(rows, row)
+				}
+			case "tbl":
+				tableDepth--
+				if tableDepth == 0 && len(rows) > 0 {
+					blocks = append(blocks, Block{Type: BlockTable, Rows: rows, Page: page})
+				}
+			}
+		case xml.CharData:
+			if inText && skipDepth == 0 && para != nil {
+				para.text.Write(t)
+			}
+		}
+	}
+	return blocks, nil
+}
+
+// coreProperties reads the title and author of an Office document.
+func coreProperties(files map[string]*zip.File) map[string]string {
+	metadata := map[string]string{}
+	f, ok := files["docProps/core.xml"]
+	if !ok {
+		return metadata
+	}
+	content, err := readZipFile(f)
+	if err != nil {
+		return metadata
+	}
+	var core struct {
+		Title   string `xml:"title"`
+		Creator string `xml:"creator"`
+		Subject string `xml:"subject"`
+	}
+	if err := xml.Unmarshal(content, &core); err != nil {
+		return metadata
+	}
+	for k, v := range map[string]string{"title": core.Title, "author": core.Creator, "subject": core.Subject} {
+		if v = strings.TrimSpace(v); v != "" {
+			metadata[k] = v
+		}
+	}
+	return metadata
+}
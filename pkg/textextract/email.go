@@ -0,0 +1,239 @@
+package textextract
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// maxEmailDepth bounds recursion through attached messages and archives.
+const maxEmailDepth = 3
+
+var wordDecoder = mime.WordDecoder{
+	CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		b, err := io.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(decodeCharset(b, charset)), nil
+	},
+}
+
+// emailParts collects a message's body text and attachments while walking
+// its MIME tree.
+type emailParts struct {
+	depth       int
+	body        []string
+	attachments []Section
+	names       []string
+}
+
+// extractEmail extracts an RFC 822 message: a section with the headers and
+// body (plain text preferred over HTML), then one section per attachment
+// holding the attachment's own extracted sections.
+func extractEmail(data io.ReaderAt, size int64, depth int) (*ExtractedText, error) {
+	msg, err := mail.ReadMessage(io.NewSectionReader(data, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("parse email: %w", err)
+	}
+
+	metadata := map[string]string{}
+	var headers []string
+	for _, h := range []string{"From", "To", "Cc", "Date", "Subject"} {
+		v := decodeHeader(msg.Header.Get(h))
+		if v == "" {
+			continue
+		}
+		headers = append(headers, h+": "+v)
+		metadata[strings.ToLower(h)] = v
+	}
+	if id := msg.Header.Get("Message-Id"); id != "" {
+		metadata["message_id"] = strings.Trim(id, "<> ")
+	}
+
+	parts := &emailParts{depth: depth}
+	if err := parts.walk(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, fmt.Errorf("parse email: %w", err)
+	}
+
+	title := metadata["subject"]
+	if title == "" {
+		title = "(no subject)"
+	}
+	metadata["title"] = title
+	if len(parts.names) > 0 {
+		metadata["attachments"] = strings.Join(parts.names, ", ")
+	}
+
+	sections := []Section{{
+		Kind:    SectionMessage,
+		Title:   title,
+		Level:   1,
+		Content: strings.Join(append([]string{strings.Join(headers, "\n")}, parts.body...), "\n\n"),
+	}}
+	sections = append(sections, parts.attachments...)
+	return structured(sections, metadata), nil
+}
+
+// walk visits a MIME part: multipart containers are descended, the first
+// text body is kept and everything else is an attachment.
+func (p *emailParts) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := decodeHeader(cmp.Or(dispParams["filename"], params["name"]))
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return p.walkMultipart(mediaType, params["boundary"], body)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(decodeTransfer(header.Get("Content-Transfer-Encoding"), body), maxEntrySize))
+	if err != nil {
+		return fmt.Errorf("read part: %w", err)
+	}
+
+	if disposition != "attachment" && filename == "" && len(p.body) == 0 {
+		switch mediaType {
+		case "text/plain":
+			if text := strings.TrimSpace(decodeCharset(content, params["charset"])); text != "" {
+				p.body = append(p.body, text)
+			}
+			return nil
+		case "text/html":
+			src := decodeCharset(content, params["charset"])
+			if text := render(htmlSections(mainContent(src), false)); text != "" {
+				p.body = append(p.body, text)
+			}
+			return nil
+		}
+	}
+	if disposition == "inline" && filename == "" {
+		// Inline images and the like carry no text.
+		return nil
+	}
+
+	p.attach(filename, mediaType, content)
+	return nil
+}
+
+// walkMultipart descends a multipart container. Of alternatives, the plain
+// text one is preferred.
+func (p *emailParts) walkMultipart(mediaType, boundary string, body io.Reader) error {
+	if boundary == "" {
+		return nil
+	}
+	mr := multipart.NewReader(body, boundary)
+
+	type alternative struct {
+		header textproto.MIMEHeader
+		body   []byte
+	}
+	var alternatives []alternative
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if mediaType != "multipart/alternative" {
+			if err := p.walk(part.Header, part); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(part, maxEntrySize))
+		if err != nil {
+			return err
+		}
+		alternatives = append(alternatives, alternative{header: part.Header, body: b})
+	}
+
+	if len(alternatives) == 0 {
+		return nil
+	}
+	best := alternatives[0]
+	for _, a := range alternatives {
+		if strings.HasPrefix(a.header.Get("Content-Type"), "text/plain") {
+			best = a
+			break
+		}
+	}
+	return p.walk(best.header, bytes.NewReader(best.body))
+}
+
+// attach extracts an attachment, nesting its sections one level under an
+// attachment section. Attachments that can't be extracted are listed but
+// contribute no text.
+func (p *emailParts) attach(filename, mediaType string, content []byte) {
+	name := filename
+	if name == "" {
+		name = mediaType
+	}
+	p.names = append(p.names, name)
+	if p.depth >= maxEmailDepth || len(content) == 0 {
+		return
+	}
+
+	hint := filename
+	if hint == "" {
+		hint = mediaType
+	}
+	extracted, err := extract(bytes.NewReader(content), int64(len(content)), hint, p.depth+1)
+	if err != nil || strings.TrimSpace(extracted.Content) == "" {
+		return
+	}
+
+	p.attachments = append(p.attachments, Section{Kind: SectionAttachment, Title: "Attachment: " + name, Level: 1})
+	for _, s := range extracted.Sections {
+		if s.Title != "" {
+			s.Level = min(max(s.Level, 1)+1, 6)
+		}
+		if s.Kind == SectionPage && s.Title == "" && s.Page > 0 && strings.TrimSpace(s.Content) != "" {
+			s.Title = fmt.Sprintf("%s, page %d", name, s.Page)
+			s.Level = 2
+		}
+		// Page numbers refer to the attachment, not the message.
+		s.Page = 0
+		p.attachments = append(p.attachments, s)
+	}
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// decodeCharset decodes text in the charsets mail commonly uses; anything
+// else is decoded as UTF-8 when valid.
+func decodeCharset(b []byte, charset string) string {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "iso-8859-1", "latin1", "iso-8859-15", "windows-1252", "cp1252":
+		return decodeWindows1252(b)
+	}
+	return decodeText(b)
+}
+
+// decodeHeader decodes RFC 2047 encoded words.
+func decodeHeader(v string) string {
+	if decoded, err := wordDecoder.DecodeHeader(v); err == nil {
+		v = decoded
+	}
+	return collapseSpace(v)
+}
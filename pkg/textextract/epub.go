@@ -0,0 +1,138 @@
+package textextract
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// extractEPUB extracts the book's XHTML documents in reading (spine) order.
+// Each document's headings start sections; text before a document's first
+// heading becomes a numbered chapter section.
+func extractEPUB(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	zr, err := zip.NewReader(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("open EPUB: %w", err)
+	}
+	files := zipFiles(zr)
+
+	opfPath, err := epubPackagePath(files)
+	if err != nil {
+		return nil, err
+	}
+	opfFile, ok := files[opfPath]
+	if !ok {
+		return nil, fmt.Errorf("open EPUB: missing package document %s", opfPath)
+	}
+	content, err := readZipFile(opfFile)
+	if err != nil {
+		return nil, fmt.Errorf("read package document: %w", err)
+	}
+
+	var opf struct {
+		Metadata struct {
+			Title    []string `xml:"title"`
+			Creator  []string `xml:"creator"`
+			Language []string `xml:"language"`
+		} `xml:"metadata"`
+		Manifest struct {
+			Items []struct {
+				ID        string `xml:"id,attr"`
+				Href      string `xml:"href,attr"`
+				MediaType string `xml:"media-type,attr"`
+			} `xml:"item"`
+		} `xml:"manifest"`
+		Spine struct {
+			Items []struct {
+				IDRef  string `xml:"idref,attr"`
+				Linear string `xml:"linear,attr"`
+			} `xml:"itemref"`
+		} `xml:"spine"`
+	}
+	if err := xml.Unmarshal(content, &opf); err != nil {
+		return nil, fmt.Errorf("parse package document: %w", err)
+	}
+
+	metadata := map[string]string{}
+	if len(opf.Metadata.Title) > 0 {
+		metadata["title"] = strings.TrimSpace(opf.Metadata.Title[0])
+	}
+	if len(opf.Metadata.Creator) > 0 {
+		metadata["author"] = strings.TrimSpace(strings.Join(opf.Metadata.Creator, ", "))
+	}
+	if len(opf.Metadata.Language) > 0 {
+		metadata["language"] = strings.TrimSpace(opf.Metadata.Language[0])
+	}
+
+	hrefs := make(map[string]string, len(opf.Manifest.Items))
+	for _, item := range opf.Manifest.Items {
+		if !strings.Contains(item.MediaType, "html") {
+			continue
+		}
+		href, err := url.PathUnescape(item.Href)
+		if err != nil {
+			href = item.Href
+		}
+		hrefs[item.ID] = path.Join(path.Dir(opfPath), href)
+	}
+
+	var sections []Section
+	chapters := 0
+	for _, ref := range opf.Spine.Items {
+		f, ok := files[hrefs[ref.IDRef]]
+		if !ok || ref.Linear == "no" {
+			continue
+		}
+		doc, err := readZipFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		chapter := htmlSections(mainContent(decodeText(doc)), false)
+		if len(chapter) == 0 {
+			continue
+		}
+		chapters++
+		if chapter[0].Title == "" {
+			chapter[0].Kind = SectionChapter
+			chapter[0].Title = "Chapter " + strconv.Itoa(chapters)
+			chapter[0].Level = 1
+		}
+		sections = append(sections, chapter...)
+	}
+
+	metadata["chapters"] = strconv.Itoa(chapters)
+	return structured(sections, metadata), nil
+}
+
+// epubPackagePath finds the package (OPF) document through the container.
+func epubPackagePath(files map[string]*zip.File) (string, error) {
+	f, ok := files["META-INF/container.xml"]
+	if !ok {
+		return "", fmt.Errorf("open EPUB: missing container")
+	}
+	content, err := readZipFile(f)
+	if err != nil {
+		return "", fmt.Errorf("read container: %w", err)
+	}
+
+	var container struct {
+		Rootfiles []struct {
+			FullPath  string `xml:"full-path,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(content, &container); err != nil {
+		return "", fmt.Errorf("parse container: %w", err)
+	}
+	for _, r := range container.Rootfiles {
+		if r.MediaType == "" || r.MediaType == "application/oebps-package+xml" {
+			return r.FullPath, nil
+		}
+	}
+	return "", fmt.Errorf("open EPUB: no package document")
+}
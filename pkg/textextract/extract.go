@@ -3,136 +3,278 @@ package textextract
 import (
 	"archive/zip"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
-	"path/filepath"
 	"strings"
-
-	"github.com/ledongthuc/pdf"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
+// ExtractedText is a document's text. Content is the whole text, with
+// section titles rendered as Markdown headings and pages separated by form
+// feeds; Sections is the same text split along the document's structure.
+// Laid-out formats (DOCX, PDF) also list their Blocks. Images have no text
+// until they are OCRed.
 type ExtractedText struct {
 	Content  string
 	Pages    int
 	Metadata map[string]string
+	Sections []Section
+	Blocks   []Block
+}
+
+// Section kinds.
+const (
+	SectionText       = "text"
+	SectionHeading    = "heading"
+	SectionPage       = "page"
+	SectionSlide      = "slide"
+	SectionSheet      = "sheet"
+	SectionTable      = "table"
+	SectionChapter    = "chapter"
+	SectionRecord     = "record"
+	SectionMessage    = "message"
+	SectionAttachment = "attachment"
+)
+
+// Section is one structural part of a document: the text under a heading,
+// a page, a slide, a sheet, a record, an email body or attachment.
+type Section struct {
+	Kind    string `json:"kind"`
+	Title   string `json:"title,omitempty"`
+	Level   int    `json:"level,omitempty"`
+	Page    int    `json:"page,omitempty"`
+	Content string `json:"content"`
 }
 
+// maxEntrySize caps how much of a single archive entry is decompressed.
+const maxEntrySize = 64 << 20
+
+// Extract extracts the text of a file. fileType is a format name, MIME type,
+// extension or file name; the format is detected from the content when the
+// hint is missing or wrong.
 func Extract(data io.ReaderAt, size int64, fileType string) (*ExtractedText, error) {
-	switch strings.ToLower(fileType) {
-	case ".pdf", "pdf", "application/pdf":
-		return extractPDF(data, size)
-	case ".docx", "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
-		return extractDOCX(data, size)
-	case ".txt", "txt", "text/plain":
-		return extractTXT(data, size)
+	return extract(data, size, fileType, 0)
+}
+
+// extract dispatches on the detected format; depth counts nesting through
+// email attachments.
+func extract(data io.ReaderAt, size int64, fileType string, depth int) (*ExtractedText, error) {
+	format := Detect(data, size, fileType)
+	var (
+		result *ExtractedText
+		err    error
+	)
+	switch format {
+	case FormatPDF:
+		result, err = extractPDF(data, size)
+	case FormatDOCX:
+		result, err = extractDOCX(data, size)
+	case FormatXLSX:
+		result, err = extractXLSX(data, size)
+	case FormatPPTX:
+		result, err = extractPPTX(data, size)
+	case FormatEPUB:
+		result, err = extractEPUB(data, size)
+	case FormatRTF:
+		result, err = extractRTF(data, size)
+	case FormatHTML:
+		result, err = extractHTML(data, size)
+	case FormatMarkdown:
+		result, err = extractMarkdown(data, size)
+	case FormatCSV:
+		result, err = extractDelimited(data, size, ',')
+	case FormatTSV:
+		result, err = extractDelimited(data, size, '\t')
+	case FormatJSON:
+		result, err = extractJSON(data, size)
+	case FormatJSONL:
+		result, err = extractJSONL(data, size)
+	case FormatEmail:
+		result, err = extractEmail(data, size, depth)
+	case FormatText:
+		result, err = extractTXT(data, size)
+	case FormatPNG, FormatJPEG, FormatTIFF:
+		result, err = extractImage(data, size)
 	default:
 		return nil, fmt.Errorf("unsupported file type: %s", fileType)
 	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Metadata == nil {
+		result.Metadata = map[string]string{}
+	}
+	result.Metadata["type"] = format
+	return result, nil
 }
 
+// SupportedTypes returns the extensions Extract handles.
 func SupportedTypes() []string {
-	return []string{".pdf", ".docx", ".txt"}
+	return []string{
+		".pdf", ".docx", ".xlsx", ".pptx", ".epub", ".rtf", ".html", ".htm", ".md",
+		".csv", ".tsv", ".json", ".jsonl", ".eml", ".txt", ".png", ".jpg", ".jpeg", ".tif", ".tiff",
+	}
 }
 
-func extractPDF(data io.ReaderAt, size int64) (*ExtractedText, error) {
-	reader, err := pdf.NewReader(data, size)
+func extractTXT(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
 	if err != nil {
-		return nil, fmt.Errorf("open PDF: %w", err)
+		return nil, fmt.Errorf("read TXT: %w", err)
 	}
+	return single(strings.TrimSpace(decodeText(buf)), nil), nil
+}
 
-	var buf strings.Builder
-	numPages := reader.NumPage()
+// single wraps unstructured text as one section.
+func single(text string, metadata map[string]string) *ExtractedText {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return &ExtractedText{
+		Content:  text,
+		Pages:    1,
+		Metadata: metadata,
+		Sections: []Section{{Kind: SectionText, Content: text}},
+	}
+}
+
+// structured builds the result for sectioned text.
+func structured(sections []Section, metadata map[string]string) *ExtractedText {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return &ExtractedText{
+		Content:  render(sections),
+		Pages:    1,
+		Metadata: metadata,
+		Sections: sections,
+	}
+}
 
-	for i := 1; i <= numPages; i++ {
-		page := reader.Page(i)
-		if page.V.IsNull() {
+// render joins sections into one text, titles as Markdown headings so the
+// markdown chunking strategy can recover the section paths.
+func render(sections []Section) string {
+	var buf strings.Builder
+	for _, s := range sections {
+		content := strings.TrimSpace(s.Content)
+		if s.Title == "" && content == "" {
 			continue
 		}
-		text, err := page.GetPlainText(nil)
-		if err != nil {
-			continue
+		if buf.Len() > 0 {
+			buf.WriteString("\n\n")
+		}
+		if s.Title != "" {
+			buf.WriteString(strings.Repeat("#", min(max(s.Level, 1), 6)))
+			buf.WriteByte(' ')
+			buf.WriteString(s.Title)
+			if content != "" {
+				buf.WriteString("\n\n")
+			}
 		}
-		buf.WriteString(text)
-		buf.WriteString("\n")
+		buf.WriteString(content)
 	}
+	return buf.String()
+}
 
-	return &ExtractedText{
-		Content: buf.String(),
-		Pages:   numPages,
-		Metadata: map[string]string{
-			"type": "pdf",
-		},
-	}, nil
+// renderPages joins page sections with form feeds, keeping empty pages so
+// page numbers stay aligned.
+func renderPages(sections []Section) string {
+	var buf strings.Builder
+	for i, s := range sections {
+		if i > 0 {
+			buf.WriteString("\n\f")
+		}
+		if s.Title != "" {
+			buf.WriteString("# " + s.Title + "\n\n")
+		}
+		buf.WriteString(s.Content)
+	}
+	return buf.String()
 }
 
-func extractDOCX(data io.ReaderAt, size int64) (*ExtractedText, error) {
-	reader, err := zip.NewReader(data, size)
-	if err != nil {
-		return nil, fmt.Errorf("open DOCX: %w", err)
+// readAll reads the whole file.
+func readAll(data io.ReaderAt, size int64) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := data.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
 	}
+	return buf[:n], nil
+}
 
-	var buf strings.Builder
-	for _, f := range reader.File {
-		if filepath.Base(f.Name) == "document.xml" {
-			rc, err := f.Open()
-			if err != nil {
-				return nil, fmt.Errorf("open document.xml: %w", err)
-			}
-			defer rc.Close()
+// readZipFile reads an archive entry, up to maxEntrySize.
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
 
-			content, err := io.ReadAll(rc)
-			if err != nil {
-				return nil, fmt.Errorf("read document.xml: %w", err)
-			}
+	content, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxEntrySize {
+		return nil, fmt.Errorf("%s exceeds %d bytes", f.Name, maxEntrySize)
+	}
+	return content, nil
+}
 
-			// Simple XML text extraction — strip tags
-			text := stripXMLTags(string(content))
-			buf.WriteString(text)
-			break
-		}
+// zipFiles indexes an archive's entries by name.
+func zipFiles(zr *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
 	}
+	return files
+}
 
-	return &ExtractedText{
-		Content: buf.String(),
-		Pages:   1,
-		Metadata: map[string]string{
-			"type": "docx",
-		},
-	}, nil
+// decodeText decodes text as UTF-8, UTF-16 with a byte order mark, or,
+// when it isn't valid UTF-8, Windows-1252.
+func decodeText(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		return string(b[3:])
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return decodeUTF16(b[2:], binary.LittleEndian)
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeUTF16(b[2:], binary.BigEndian)
+	case utf8.Valid(b):
+		return string(b)
+	}
+	return decodeWindows1252(b)
 }
 
-func extractTXT(data io.ReaderAt, size int64) (*ExtractedText, error) {
-	buf := make([]byte, size)
-	_, err := data.ReadAt(buf, 0)
-	if err != nil && err != io.EOF {
-		return nil, fmt.Errorf("read TXT: %w", err)
+func decodeUTF16(b []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = order.Uint16(b[2*i:])
 	}
+	return string(utf16.Decode(units))
+}
 
-	return &ExtractedText{
-		Content: string(bytes.TrimSpace(buf)),
-		Pages:   1,
-		Metadata: map[string]string{
-			"type": "txt",
-		},
-	}, nil
-}
-
-func stripXMLTags(s string) string {
-	var result strings.Builder
-	inTag := false
-	for _, r := range s {
+// cp1252 maps the Windows-1252 bytes 0x80-0x9F that differ from Latin-1.
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+func decodeWindows1252(b []byte) string {
+	var buf strings.Builder
+	buf.Grow(len(b))
+	for _, c := range b {
 		switch {
-		case r == '<':
-			inTag = true
-		case r == '>':
-			inTag = false
-			result.WriteRune(' ')
-		case !inTag:
-			result.WriteRune(r)
+		case c >= 0x80 && c < 0xA0:
+			buf.WriteRune(cp1252[c-0x80])
+		default:
+			buf.WriteRune(rune(c))
 		}
 	}
-	// Collapse whitespace
-	text := result.String()
-	parts := strings.Fields(text)
-	return strings.Join(parts, " ")
+	return buf.String()
+}
+
+// collapseSpace replaces runs of whitespace with single spaces.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
 }
@@ -0,0 +1,541 @@
+package textextract
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// htmlBlockTags end the current paragraph when opened or closed.
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true,
+	"footer": true, "main": true, "ul": true, "ol": true, "li": true,
+	"blockquote": true, "br": true, "hr": true, "dl": true, "dt": true,
+	"dd": true, "figure": true, "figcaption": true, "body": true, "tr": true,
+	"td": true, "th": true, "caption": true, "address": true, "details": true,
+	"summary": true,
+}
+
+// htmlSkipTags have content that is never text.
+var htmlSkipTags = map[string]bool{
+	"script": true, "style": true, "head": true, "noscript": true,
+	"template": true, "svg": true, "iframe": true, "canvas": true,
+	"object": true, "button": true, "select": true,
+}
+
+// boilerplateTags hold site chrome rather than content.
+var boilerplateTags = map[string]bool{
+	"nav": true, "aside": true, "footer": true, "form": true, "header": true,
+	"menu": true, "dialog": true,
+}
+
+// boilerplateWords in a class or id mark site chrome.
+var boilerplateWords = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "sidebar": true,
+	"footer": true, "breadcrumb": true, "breadcrumbs": true, "cookie": true,
+	"cookies": true, "banner": true, "advert": true, "advertisement": true,
+	"ad": true, "ads": true, "share": true, "social": true, "related": true,
+	"comments": true, "subscribe": true, "newsletter": true, "popup": true,
+	"modal": true, "skip": true, "toolbar": true,
+}
+
+// extractHTML extracts the main content of an HTML page: the first <main>
+// or <article> when there is one, minus navigation, sidebars, footers,
+// scripts and elements whose class or id marks them as chrome. Headings
+// start sections; tables become Markdown pipe tables.
+func extractHTML(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("read HTML: %w", err)
+	}
+	src := decodeText(buf)
+
+	metadata := map[string]string{}
+	if title := htmlTitle(src); title != "" {
+		metadata["title"] = title
+	}
+	sections := htmlSections(mainContent(src), true)
+	if _, ok := metadata["title"]; !ok {
+		for _, s := range sections {
+			if s.Level == 1 {
+				metadata["title"] = s.Title
+				break
+			}
+		}
+	}
+	return structured(sections, metadata), nil
+}
+
+// HTMLPage is what a crawler reads from an HTML page. URLs are returned as
+// written; resolve them against Base, or the page's URL when Base is empty.
+type HTMLPage struct {
+	Title     string
+	Base      string   // href of <base>
+	Canonical string   // href of <link rel="canonical">
+	Links     []string // hrefs of <a> elements not marked rel="nofollow"
+	// NoIndex and NoFollow come from <meta name="robots">.
+	NoIndex  bool
+	NoFollow bool
+	// Text is the main content as extraction keeps it, without site
+	// chrome; it changes only when the content does.
+	Text string
+}
+
+// ParseHTMLPage reads a page's title, links and robots directives, and its
+// main content.
+func ParseHTMLPage(data []byte) HTMLPage {
+	src := decodeText(data)
+	page := HTMLPage{Title: htmlTitle(src)}
+
+	pos := 0
+	for {
+		tag, ok := scanTag(src, pos)
+		if !ok {
+			break
+		}
+		pos = tag.end
+		if tag.closing {
+			continue
+		}
+		switch tag.name {
+		case "script", "style", "template":
+			if !tag.selfClosing {
+				_, pos = skipTag(src, tag.end, tag.name)
+			}
+		case "base":
+			if page.Base == "" {
+				page.Base = strings.TrimSpace(tag.attr("href"))
+			}
+		case "link":
+			if page.Canonical == "" && hasToken(tag.attr("rel"), "canonical") {
+				page.Canonical = strings.TrimSpace(tag.attr("href"))
+			}
+		case "meta":
+			if strings.EqualFold(tag.attr("name"), "robots") {
+				content := tag.attr("content")
+				page.NoIndex = page.NoIndex || hasToken(content, "noindex") || hasToken(content, "none")
+				page.NoFollow = page.NoFollow || hasToken(content, "nofollow") || hasToken(content, "none")
+			}
+		case "a":
+			href := strings.TrimSpace(tag.attr("href"))
+			if href != "" && !hasToken(tag.attr("rel"), "nofollow") {
+				page.Links = append(page.Links, href)
+			}
+		}
+	}
+
+	var text strings.Builder
+	for _, section := range htmlSections(mainContent(src), true) {
+		text.WriteString(section.Title + "\n" + section.Content + "\n\n")
+	}
+	page.Text = text.String()
+	return page
+}
+
+// hasToken reports whether a comma- or space-separated attribute value
+// contains token, ignoring case.
+func hasToken(value, token string) bool {
+	for _, t := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlSections splits HTML into sections at headings. With dropChrome,
+// boilerplate elements are skipped.
+func htmlSections(src string, dropChrome bool) []Section {
+	var (
+		sections   []Section
+		current    = Section{Kind: SectionText}
+		paragraphs []string
+		text       strings.Builder
+	)
+	flushText := func() {
+		if p := collapseSpace(text.String()); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+		text.Reset()
+	}
+	flushSection := func() {
+		flushText()
+		current.Content = strings.Join(paragraphs, "\n\n")
+		if current.Title != "" || current.Content != "" {
+			sections = append(sections, current)
+		}
+		paragraphs = nil
+	}
+
+	pos := 0
+	for pos < len(src) {
+		tag, ok := scanTag(src, pos)
+		if !ok {
+			text.WriteString(html.UnescapeString(src[pos:]))
+			break
+		}
+		text.WriteString(html.UnescapeString(src[pos:tag.start]))
+		pos = tag.end
+
+		switch {
+		case tag.closing:
+			if htmlBlockTags[tag.name] {
+				flushText()
+			}
+
+		case htmlSkipTags[tag.name], dropChrome && isChrome(src, tag):
+			if !tag.selfClosing {
+				_, pos = skipTag(src, tag.end, tag.name)
+			}
+
+		case htmlHeading(tag.name) > 0:
+			inner, after := skipTag(src, tag.end, tag.name)
+			pos = after
+			title := collapseSpace(html.UnescapeString(stripTags(src[tag.end:inner])))
+			if title == "" {
+				continue
+			}
+			flushSection()
+			current = Section{Kind: SectionHeading, Title: title, Level: htmlHeading(tag.name)}
+
+		case tag.name == "table":
+			flushText()
+			inner, after := skipTag(src, tag.end, "table")
+			if table := htmlTable(src[tag.end:inner]); table != "" {
+				paragraphs = append(paragraphs, table)
+			}
+			pos = after
+
+		case tag.name == "pre":
+			flushText()
+			inner, after := skipTag(src, tag.end, "pre")
+			if pre := strings.TrimSpace(html.UnescapeString(stripTags(src[tag.end:inner]))); pre != "" {
+				paragraphs = append(paragraphs, pre)
+			}
+			pos = after
+
+		case tag.name == "img":
+			if alt := collapseSpace(tag.attr("alt")); alt != "" {
+				text.WriteString(" " + alt + " ")
+			}
+
+		case tag.name == "li":
+			flushText()
+			text.WriteString("- ")
+
+		case htmlBlockTags[tag.name]:
+			flushText()
+		}
+	}
+	flushSection()
+	return sections
+}
+
+// mainContent narrows a page to its <main>, role="main" element or first
+// <article>, falling back to <body> and then the whole document.
+func mainContent(src string) string {
+	var article, body string
+	pos := 0
+	for {
+		tag, ok := scanTag(src, pos)
+		if !ok {
+			break
+		}
+		pos = tag.end
+		if tag.closing || tag.selfClosing {
+			continue
+		}
+		switch {
+		case tag.name == "main", tag.attr("role") == "main":
+			inner, _ := skipTag(src, tag.end, tag.name)
+			return src[tag.end:inner]
+		case tag.name == "article" && article == "":
+			inner, _ := skipTag(src, tag.end, tag.name)
+			article = src[tag.end:inner]
+		case tag.name == "body" && body == "":
+			body = src[tag.end:]
+		case htmlSkipTags[tag.name]:
+			_, pos = skipTag(src, tag.end, tag.name)
+		}
+	}
+	switch {
+	case article != "":
+		return article
+	case body != "":
+		return body
+	}
+	return src
+}
+
+// htmlTitle returns the page's <title>.
+func htmlTitle(src string) string {
+	pos := 0
+	for {
+		tag, ok := scanTag(src, pos)
+		if !ok {
+			return ""
+		}
+		pos = tag.end
+		if tag.name == "title" && !tag.closing {
+			inner, _ := skipTag(src, tag.end, "title")
+			return collapseSpace(html.UnescapeString(src[tag.end:inner]))
+		}
+		if tag.name == "body" {
+			return ""
+		}
+	}
+}
+
+// isChrome reports whether an element is site chrome. A <header> holding
+// the page's <h1> is content.
+func isChrome(src string, tag scannedTag) bool {
+	if tag.name == "header" && !tag.selfClosing {
+		inner, _ := skipTag(src, tag.end, "header")
+		return !strings.Contains(strings.ToLower(src[tag.end:inner]), "<h1")
+	}
+	if boilerplateTags[tag.name] {
+		return true
+	}
+	switch tag.name {
+	case "body", "main", "article", "html":
+		return false
+	}
+	if _, hidden := tag.attrs["hidden"]; hidden || tag.attr("aria-hidden") == "true" {
+		return true
+	}
+	switch tag.attr("role") {
+	case "navigation", "banner", "contentinfo", "complementary", "search", "dialog":
+		return true
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(tag.attr("class")+" "+tag.attr("id")), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if boilerplateWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+type scannedTag struct {
+	name        string
+	attrs       map[string]string
+	closing     bool
+	selfClosing bool
+	start       int // offset of '<'
+	end         int // offset after '>'
+}
+
+func (t scannedTag) attr(name string) string {
+	return t.attrs[name]
+}
+
+// scanTag finds the next tag at or after pos, skipping comments, doctypes
+// and processing instructions.
+func scanTag(src string, pos int) (scannedTag, bool) {
+	for {
+		i := strings.IndexByte(src[pos:], '<')
+		if i < 0 {
+			return scannedTag{}, false
+		}
+		start := pos + i
+		if strings.HasPrefix(src[start:], "<!--") {
+			j := strings.Index(src[start:], "-->")
+			if j < 0 {
+				return scannedTag{}, false
+			}
+			pos = start + j + 3
+			continue
+		}
+		end := tagEnd(src, start+1)
+		if end < 0 {
+			return scannedTag{}, false
+		}
+		body := strings.TrimSpace(src[start+1 : end-1])
+		if body == "" || body[0] == '!' || body[0] == '?' {
+			pos = end
+			continue
+		}
+
+		tag := scannedTag{start: start, end: end}
+		if body[0] == '/' {
+			tag.closing = true
+			body = body[1:]
+		}
+		if strings.HasSuffix(body, "/") {
+			tag.selfClosing = true
+			body = body[:len(body)-1]
+		}
+		name := body
+		if k := strings.IndexAny(body, " \t\r\n/"); k >= 0 {
+			name = body[:k]
+		}
+		// Namespaced XHTML tags (epub:switch) keep their local name.
+		if k := strings.IndexByte(name, ':'); k >= 0 {
+			name = name[k+1:]
+		}
+		tag.name = strings.ToLower(name)
+		if !tag.closing {
+			tag.attrs = parseAttrs(body[len(name):])
+		}
+		return tag, true
+	}
+}
+
+// tagEnd returns the offset after the '>' closing a tag, ignoring '>'
+// inside quoted attribute values.
+func tagEnd(src string, pos int) int {
+	var quote byte
+	for i := pos; i < len(src); i++ {
+		switch c := src[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// parseAttrs parses name="value" pairs; names are lower-cased.
+func parseAttrs(s string) map[string]string {
+	attrs := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t\r\n/")
+		if s == "" {
+			return attrs
+		}
+		k := strings.IndexAny(s, " \t\r\n=/")
+		if k < 0 {
+			attrs[strings.ToLower(s)] = ""
+			return attrs
+		}
+		name := strings.ToLower(s[:k])
+		s = strings.TrimLeft(s[k:], " \t\r\n")
+		if !strings.HasPrefix(s, "=") {
+			attrs[name] = ""
+			continue
+		}
+		s = strings.TrimLeft(s[1:], " \t\r\n")
+		var value string
+		switch {
+		case s == "":
+		case s[0] == '"' || s[0] == '\'':
+			if j := strings.IndexByte(s[1:], s[0]); j >= 0 {
+				value, s = s[1:j+1], s[j+2:]
+			} else {
+				value, s = s[1:], ""
+			}
+		default:
+			j := strings.IndexAny(s, " \t\r\n")
+			if j < 0 {
+				j = len(s)
+			}
+			value, s = s[:j], s[j:]
+		}
+		attrs[name] = html.UnescapeString(value)
+	}
+}
+
+// skipTag returns the offsets of the closing tag matching name, honouring
+// nesting, or len(src) if it never closes.
+func skipTag(src string, pos int, name string) (inner, after int) {
+	depth := 1
+	for {
+		tag, ok := scanTag(src, pos)
+		if !ok {
+			return len(src), len(src)
+		}
+		if tag.name == name && !tag.selfClosing {
+			if tag.closing {
+				depth--
+				if depth == 0 {
+					return tag.start, tag.end
+				}
+			} else {
+				depth++
+			}
+		}
+		pos = tag.end
+	}
+}
+
+func htmlHeading(name string) int {
+	if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+		return int(name[1] - '0')
+	}
+	return 0
+}
+
+// htmlTable renders a table as a Markdown pipe table.
+func htmlTable(src string) string {
+	var rows [][]string
+	var cells []string
+	var cell strings.Builder
+	inCell := false
+	endCell := func() {
+		if inCell {
+			cells = append(cells, collapseSpace(html.UnescapeString(cell.String())))
+			cell.Reset()
+			inCell = false
+		}
+	}
+	endRow := func() {
+		endCell()
+		if strings.TrimSpace(strings.Join(cells, "")) != "" {
+			rows = append(rows, cells)
+		}
+		cells = nil
+	}
+
+	pos := 0
+	for pos < len(src) {
+		tag, ok := scanTag(src, pos)
+		if !ok {
+			break
+		}
+		if inCell {
+			cell.WriteString(src[pos:tag.start])
+		}
+		pos = tag.end
+		switch {
+		case htmlSkipTags[tag.name] && !tag.closing && !tag.selfClosing:
+			_, pos = skipTag(src, tag.end, tag.name)
+		case tag.name == "tr":
+			endRow()
+		case tag.name == "td" || tag.name == "th":
+			endCell()
+			if !tag.closing {
+				inCell = true
+			}
+		case tag.name == "br" || tag.name == "p":
+			if inCell {
+				cell.WriteByte(' ')
+			}
+		}
+	}
+	endRow()
+	return tableMarkdown(rows)
+}
+
+// stripTags removes tags, leaving a space in place of each.
+func stripTags(s string) string {
+	var buf strings.Builder
+	pos := 0
+	for pos < len(s) {
+		tag, ok := scanTag(s, pos)
+		if !ok {
+			buf.WriteString(s[pos:])
+			break
+		}
+		buf.WriteString(s[pos:tag.start])
+		buf.WriteByte(' ')
+		pos = tag.end
+	}
+	return buf.String()
+}
@@ -0,0 +1,30 @@
+package textextract
+
+import (
+	"image"
+	_ "image/jpeg" // register decoders for image.DecodeConfig
+	_ "image/png"
+	"io"
+	"strconv"
+)
+
+// IsImage reports whether format is an image format, whose text can only
+// be read by OCR.
+func IsImage(format string) bool {
+	switch format {
+	case FormatPNG, FormatJPEG, FormatTIFF:
+		return true
+	}
+	return false
+}
+
+// extractImage returns no text, only the image's dimensions where they can
+// be read; recognizing the text is left to OCR.
+func extractImage(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	metadata := map[string]string{}
+	if cfg, _, err := image.DecodeConfig(io.NewSectionReader(data, 0, size)); err == nil {
+		metadata["width"] = strconv.Itoa(cfg.Width)
+		metadata["height"] = strconv.Itoa(cfg.Height)
+	}
+	return &ExtractedText{Pages: 1, Metadata: metadata}, nil
+}
@@ -0,0 +1,153 @@
+package textextract
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// recordTitleKeys name the fields that title a record, in order of
+// preference.
+var recordTitleKeys = []string{"title", "name", "subject", "id", "key"}
+
+// extractJSON extracts a JSON document as "path: value" lines. An array's
+// elements and an object's top-level keys each become a section.
+func extractJSON(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("read JSON: %w", err)
+	}
+	v, err := decodeJSON([]byte(decodeText(buf)))
+	if err != nil {
+		return nil, fmt.Errorf("parse JSON: %w", err)
+	}
+
+	var sections []Section
+	switch v := v.(type) {
+	case []any:
+		for i, elem := range v {
+			sections = append(sections, recordSection(i+1, elem))
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			sections = append(sections, Section{
+				Kind:    SectionRecord,
+				Title:   key,
+				Level:   1,
+				Content: strings.Join(flattenJSON("", v[key], nil), "\n"),
+			})
+		}
+	default:
+		return single(jsonScalar(v), nil), nil
+	}
+	return structured(sections, map[string]string{"records": strconv.Itoa(len(sections))}), nil
+}
+
+// extractJSONL extracts JSON Lines, one record section per line.
+func extractJSONL(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("read JSON Lines: %w", err)
+	}
+
+	var sections []Section
+	for i, line := range strings.Split(decodeText(buf), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		v, err := decodeJSON([]byte(line))
+		if err != nil {
+			return nil, fmt.Errorf("parse JSON Lines line %d: %w", i+1, err)
+		}
+		sections = append(sections, recordSection(len(sections)+1, v))
+	}
+	return structured(sections, map[string]string{"records": strconv.Itoa(len(sections))}), nil
+}
+
+func decodeJSON(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// recordSection titles a record by its first title-like field, or by its
+// position.
+func recordSection(n int, v any) Section {
+	title := "Record " + strconv.Itoa(n)
+	if obj, ok := v.(map[string]any); ok {
+		for _, key := range recordTitleKeys {
+			if s := jsonScalar(obj[key]); s != "" {
+				title += ": " + collapseSpace(s)
+				break
+			}
+		}
+	}
+	return Section{
+		Kind:    SectionRecord,
+		Title:   title,
+		Level:   1,
+		Content: strings.Join(flattenJSON("", v, nil), "\n"),
+	}
+}
+
+// flattenJSON writes every scalar in v as a "path: value" line.
+func flattenJSON(path string, v any, lines []string) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			lines = flattenJSON(joinPath(path, key), v[key], lines)
+		}
+	case []any:
+		for i, elem := range v {
+			lines = flattenJSON(path+"["+strconv.Itoa(i)+"]", elem, lines)
+		}
+	default:
+		s := jsonScalar(v)
+		if s == "" {
+			return lines
+		}
+		if path == "" {
+			return append(lines, s)
+		}
+		lines = append(lines, path+": "+s)
+	}
+	return lines
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func jsonScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,120 @@
+package textextract
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// extractMarkdown keeps the Markdown, minus any front matter, and splits it
+// into sections at ATX headings outside code fences.
+func extractMarkdown(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("read Markdown: %w", err)
+	}
+	text := strings.TrimSpace(decodeText(buf))
+	body, metadata := frontMatter(text)
+
+	var (
+		sections []Section
+		current  = Section{Kind: SectionText}
+		lines    []string
+		fence    string
+	)
+	flush := func() {
+		current.Content = strings.TrimSpace(strings.Join(lines, "\n"))
+		if current.Title != "" || current.Content != "" {
+			sections = append(sections, current)
+		}
+		lines = lines[:0]
+	}
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			lines = append(lines, line)
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			lines = append(lines, line)
+			continue
+		}
+		if level, title, ok := atxHeading(line); ok {
+			flush()
+			current = Section{Kind: SectionHeading, Title: title, Level: level}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	if title, ok := metadata["title"]; !ok || title == "" {
+		for _, s := range sections {
+			if s.Level == 1 {
+				metadata["title"] = s.Title
+				break
+			}
+		}
+	}
+
+	return &ExtractedText{
+		Content:  body,
+		Pages:    1,
+		Metadata: metadata,
+		Sections: sections,
+	}, nil
+}
+
+// atxHeading parses a "# Title" line.
+func atxHeading(line string) (int, string, bool) {
+	if strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+		return 0, "", false
+	}
+	line = strings.TrimSpace(line)
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t') {
+		return 0, "", false
+	}
+	title := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+	if title == "" {
+		return 0, "", false
+	}
+	return level, title, true
+}
+
+// frontMatter strips a leading YAML front matter block, returning its
+// simple "key: value" pairs as metadata.
+func frontMatter(text string) (string, map[string]string) {
+	metadata := map[string]string{}
+	if !strings.HasPrefix(text, "---\n") {
+		return text, metadata
+	}
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return text, metadata
+	}
+	for _, line := range strings.Split(text[4:4+end], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+		if key = strings.ToLower(strings.TrimSpace(key)); key != "" && value != "" {
+			metadata[key] = value
+		}
+	}
+	rest := text[4+end+4:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[i+1:]
+	} else {
+		rest = ""
+	}
+	return strings.TrimSpace(rest), metadata
+}
@@ -0,0 +1,492 @@
+package textextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const drawingNS = "http://schemas.openxmlformats.org/drawingml/2006/main"
+
+// relationship is an entry of an OPC .rels part.
+type relationship struct {
+	Type   string
+	Target string // resolved to an archive path
+}
+
+// readRels reads the relationships of part, keyed by ID. A missing .rels
+// part has no relationships.
+func readRels(files map[string]*zip.File, part string) (map[string]relationship, error) {
+	dir, name := path.Split(part)
+	f, ok := files[dir+"_rels/"+name+".rels"]
+	if !ok {
+		return map[string]relationship{}, nil
+	}
+	content, err := readZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc struct {
+		Relationships []struct {
+			ID         string `xml:"Id,attr"`
+			Type       string `xml:"Type,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s relationships: %w", part, err)
+	}
+	rels := make(map[string]relationship, len(doc.Relationships))
+	for _, r := range doc.Relationships {
+		if r.TargetMode == "External" {
+			continue
+		}
+		target := strings.TrimPrefix(r.Target, "/")
+		if !strings.HasPrefix(r.Target, "/") {
+			target = path.Join(dir, r.Target)
+		}
+		rels[r.ID] = relationship{Type: r.Type, Target: target}
+	}
+	return rels, nil
+}
+
+// relID returns an element's r:id attribute.
+func relID(attrs []xml.Attr) string {
+	for _, a := range attrs {
+		if a.Name.Local == "id" && strings.Contains(a.Name.Space, "relationships") {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func attrValue(attrs []xml.Attr, name string) string {
+	for _, a := range attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// extractXLSX extracts each worksheet as table sections named after the
+// sheet, in workbook order. Cells hold their cached values; formulas and
+// number formats are not evaluated.
+func extractXLSX(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	zr, err := zip.NewReader(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("open XLSX: %w", err)
+	}
+	files := zipFiles(zr)
+
+	workbook, ok := files["xl/workbook.xml"]
+	if !ok {
+		return nil, fmt.Errorf("open XLSX: missing workbook")
+	}
+	content, err := readZipFile(workbook)
+	if err != nil {
+		return nil, fmt.Errorf("read workbook: %w", err)
+	}
+	rels, err := readRels(files, workbook.Name)
+	if err != nil {
+		return nil, fmt.Errorf("read workbook: %w", err)
+	}
+	shared, err := sharedStrings(files, rels)
+	if err != nil {
+		return nil, fmt.Errorf("read shared strings: %w", err)
+	}
+
+	var sections []Section
+	sheets := 0
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse workbook: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "sheet" {
+			continue
+		}
+		rel, ok := rels[relID(start.Attr)]
+		f, found := files[rel.Target]
+		if !ok || !found {
+			continue
+		}
+		rows, err := sheetRows(f, shared)
+		if err != nil {
+			return nil, fmt.Errorf("read sheet %s: %w", attrValue(start.Attr, "name"), err)
+		}
+		sheets++
+		name := attrValue(start.Attr, "name")
+		for _, s := range tableSections(rows, name) {
+			s.Kind = SectionSheet
+			sections = append(sections, s)
+		}
+	}
+
+	return structured(sections, map[string]string{"sheets": strconv.Itoa(sheets)}), nil
+}
+
+// sharedStrings reads the workbook's shared string table.
+func sharedStrings(files map[string]*zip.File, rels map[string]relationship) ([]string, error) {
+	var f *zip.File
+	for _, rel := range rels {
+		if strings.HasSuffix(rel.Type, "/sharedStrings") {
+			f = files[rel.Target]
+		}
+	}
+	if f == nil {
+		return nil, nil
+	}
+	content, err := readZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		strs     []string
+		cur      strings.Builder
+		inText   bool
+		phonetic int
+	)
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return strs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				cur.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				phonetic++
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				strs = append(strs, cur.String())
+			case "t":
+				inText = false
+			case "rPh":
+				phonetic--
+			}
+		case xml.CharData:
+			if inText && phonetic == 0 {
+				cur.Write(t)
+			}
+		}
+	}
+}
+
+// sheetRows reads a worksheet's cells into rows, keeping cells in their
+// columns.
+func sheetRows(f *zip.File, shared []string) ([][]string, error) {
+	content, err := readZipFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		rows     [][]string
+		row      []string
+		col      int
+		cellType string
+		value    strings.Builder
+		inValue  bool
+	)
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = nil
+				col = 0
+			case "c":
+				cellType = attrValue(t.Attr, "t")
+				if ref := attrValue(t.Attr, "r"); ref != "" {
+					col = columnIndex(ref)
+				}
+				value.Reset()
+			case "v", "t":
+				inValue = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "row":
+				rows = append(rows, row)
+			case "c":
+				for len(row) < col {
+					row = append(row, "")
+				}
+				row = append(row, cellValue(cellType, value.String(), shared))
+				col++
+			case "v", "t":
+				inValue = false
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(t)
+			}
+		}
+	}
+}
+
+func cellValue(cellType, raw string, shared []string) string {
+	switch cellType {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return shared[i]
+	case "b":
+		if strings.TrimSpace(raw) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return raw
+}
+
+// columnIndex converts the letters of a cell reference ("AB12") to a
+// 0-based column.
+func columnIndex(ref string) int {
+	col := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+	}
+	return max(col-1, 0)
+}
+
+// extractPPTX extracts one page per slide, in presentation order: the
+// slide's title, its text and tables, and its speaker notes.
+func extractPPTX(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	zr, err := zip.NewReader(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("open PPTX: %w", err)
+	}
+	files := zipFiles(zr)
+
+	presentation, ok := files["ppt/presentation.xml"]
+	if !ok {
+		return nil, fmt.Errorf("open PPTX: missing presentation")
+	}
+	content, err := readZipFile(presentation)
+	if err != nil {
+		return nil, fmt.Errorf("read presentation: %w", err)
+	}
+	rels, err := readRels(files, presentation.Name)
+	if err != nil {
+		return nil, fmt.Errorf("read presentation: %w", err)
+	}
+
+	var sections []Section
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse presentation: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "sldId" {
+			continue
+		}
+		rel, ok := rels[relID(start.Attr)]
+		f, found := files[rel.Target]
+		if !ok || !found {
+			continue
+		}
+		n := len(sections) + 1
+		section, err := slideSection(files, f, n)
+		if err != nil {
+			return nil, fmt.Errorf("read slide %d: %w", n, err)
+		}
+		sections = append(sections, section)
+	}
+
+	// Slides are pages, so chunks report their slide number.
+	return &ExtractedText{
+		Content:  renderPages(sections),
+		Pages:    len(sections),
+		Metadata: map[string]string{"slides": strconv.Itoa(len(sections))},
+		Sections: sections,
+	}, nil
+}
+
+func slideSection(files map[string]*zip.File, f *zip.File, n int) (Section, error) {
+	content, err := readZipFile(f)
+	if err != nil {
+		return Section{}, err
+	}
+	shapes, err := slideShapes(content)
+	if err != nil {
+		return Section{}, err
+	}
+
+	section := Section{Kind: SectionSlide, Level: 1, Page: n}
+	var body []string
+	for _, s := range shapes {
+		text := strings.Join(s.paragraphs, "\n")
+		switch {
+		case text == "":
+		case (s.placeholder == "title" || s.placeholder == "ctrTitle") && section.Title == "":
+			section.Title = collapseSpace(text)
+		case s.placeholder == "sldNum" || s.placeholder == "dt" || s.placeholder == "ftr":
+		default:
+			body = append(body, text)
+		}
+	}
+	if section.Title == "" {
+		section.Title = "Slide " + strconv.Itoa(n)
+	}
+
+	rels, err := readRels(files, f.Name)
+	if err != nil {
+		return Section{}, err
+	}
+	for _, rel := range rels {
+		notesFile, ok := files[rel.Target]
+		if !strings.HasSuffix(rel.Type, "/notesSlide") || !ok {
+			continue
+		}
+		notesContent, err := readZipFile(notesFile)
+		if err != nil {
+			return Section{}, err
+		}
+		notes, err := slideShapes(notesContent)
+		if err != nil {
+			return Section{}, err
+		}
+		for _, s := range notes {
+			if text := strings.Join(s.paragraphs, "\n"); s.placeholder == "body" && text != "" {
+				body = append(body, "Notes: "+text)
+			}
+		}
+	}
+
+	section.Content = strings.Join(body, "\n\n")
+	return section, nil
+}
+
+// slideShape is a shape or graphic frame of a slide: its placeholder type,
+// if any, and its paragraphs, with tables as Markdown pipe tables.
+type slideShape struct {
+	placeholder string
+	paragraphs  []string
+}
+
+func slideShapes(content []byte) ([]slideShape, error) {
+	var (
+		shapes []slideShape
+		shape  = -1 // index of the open shape
+		para   strings.Builder
+		inText bool
+		rows   [][]string
+		cells  []string
+		cell   []string
+		inCell bool
+	)
+	dec := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return shapes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "sp", "graphicFrame":
+				shapes = append(shapes, slideShape{})
+				shape = len(shapes) - 1
+			case "ph":
+				if shape >= 0 {
+					// Untyped placeholders are body text.
+					shapes[shape].placeholder = cmp.Or(attrValue(t.Attr, "type"), "body")
+				}
+			case "p":
+				if t.Name.Space == drawingNS {
+					para.Reset()
+				}
+			case "t":
+				inText = t.Name.Space == drawingNS
+			case "br":
+				para.WriteByte(' ')
+			case "tbl":
+				rows = nil
+			case "tr":
+				cells = nil
+			case "tc":
+				cell = nil
+				inCell = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "sp", "graphicFrame":
+				shape = -1
+			case "p":
+				text := collapseSpace(para.String())
+				if t.Name.Space != drawingNS || text == "" || shape < 0 {
+					continue
+				}
+				if inCell {
+					cell = append(cell, text)
+				} else {
+					shapes[shape].paragraphs = append(shapes[shape].paragraphs, text)
+				}
+			case "t":
+				inText = false
+			case "tc":
+				cells = append(cells, strings.Join(cell, " "))
+				inCell = false
+			case "tr":
+				if strings.TrimSpace(strings.Join(cells, "")) != "" {
+					rows = append(rows, cells)
+				}
+			case "tbl":
+				if table := tableMarkdown(rows); shape >= 0 && table != "" {
+					shapes[shape].paragraphs = append(shapes[shape].paragraphs, table)
+				}
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+}
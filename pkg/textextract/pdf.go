@@ -0,0 +1,387 @@
+package textextract
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	// listMarker matches a bullet or an enumerator ("1.", "a)", "iv.")
+	// opening a list item.
+	listMarker = regexp.MustCompile(`^(?:[•◦▪▫‣⁃∙·●○■□–\-*]|\(?(?:\d{1,3}|[a-zA-Z]|[ivxlcdm]{1,5})[.)])\s+`)
+	// pageNumber matches running page numbers ("12", "Page 3 of 10").
+	pageNumber = regexp.MustCompile(`(?i)^(?:page\s*)?\d{1,3}(?:\s*(?:of|/)\s*\d{1,4})?$`)
+)
+
+// pdfLine is a line of text laid out on a page.
+type pdfLine struct {
+	text  string
+	cells []string // the text split at wide gaps, for table detection
+	size  float64  // dominant font size
+	x, y  float64  // left edge and baseline
+}
+
+// extractPDF lays out each page's glyphs into blocks. Headings are lines
+// set larger than the body text or matching the document outline; lines
+// opening with a bullet or enumerator are list items; runs of lines split
+// into aligned columns by wide gaps are tables. Pages whose glyphs can't
+// be read fall back to their plain text.
+func extractPDF(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	reader, err := pdf.NewReader(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("open PDF: %w", err)
+	}
+
+	numPages := reader.NumPage()
+	pages := make([][]pdfLine, numPages)
+	for i := range pages {
+		pages[i] = pageLines(reader.Page(i + 1))
+	}
+
+	body := bodyFontSize(pages)
+	layout := pdfLayout{
+		body:    body,
+		levels:  headingLevels(pages, body),
+		outline: outlineLevels(reader),
+	}
+	var blocks []Block
+	for i, lines := range pages {
+		if i > 0 {
+			blocks = append(blocks, Block{Type: BlockPageBreak, Page: i + 1})
+		}
+		blocks = append(blocks, layout.blocks(lines, i+1)...)
+	}
+
+	result := FromBlocks(blocks, pdfInfo(reader))
+	result.Pages = numPages
+	return result, nil
+}
+
+// pageLines reads a page's glyphs into lines, in content-stream order. A
+// page whose content can't be interpreted is read as plain text instead.
+func pageLines(page pdf.Page) (lines []pdfLine) {
+	if page.V.IsNull() {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			lines = plainLines(page)
+		}
+	}()
+
+	var (
+		glyphs []pdf.Text
+		lineY  float64
+	)
+	flush := func() {
+		if line, ok := layoutLine(glyphs); ok {
+			lines = append(lines, line)
+		}
+		glyphs = nil
+	}
+	for _, g := range page.Content().Text {
+		if len(glyphs) > 0 && math.Abs(g.Y-lineY) > math.Max(g.FontSize, 1)*0.5 {
+			flush()
+		}
+		if len(glyphs) == 0 {
+			lineY = g.Y
+		}
+		glyphs = append(glyphs, g)
+	}
+	flush()
+
+	if len(lines) == 0 {
+		return plainLines(page)
+	}
+	return lines
+}
+
+// layoutLine joins a line's glyphs left to right, inserting spaces at word
+// gaps and splitting cells at wide ones.
+func layoutLine(glyphs []pdf.Text) (pdfLine, bool) {
+	if len(glyphs) == 0 {
+		return pdfLine{}, false
+	}
+	sort.SliceStable(glyphs, func(i, j int) bool { return glyphs[i].X < glyphs[j].X })
+
+	sizes := map[float64]int{}
+	var (
+		text, cell strings.Builder
+		cells      []string
+		end        = glyphs[0].X
+	)
+	for i, g := range glyphs {
+		size := math.Max(g.FontSize, 1)
+		sizes[math.Round(size*2)/2] += utf8.RuneCountInString(g.S)
+		if i > 0 {
+			gap := g.X - end
+			switch {
+			case gap > size*1.5:
+				cells = append(cells, cell.String())
+				cell.Reset()
+				text.WriteByte(' ')
+			case gap > size*0.15 && !strings.HasSuffix(text.String(), " ") && g.S != " ":
+				text.WriteByte(' ')
+				cell.WriteByte(' ')
+			}
+		}
+		text.WriteString(g.S)
+		cell.WriteString(g.S)
+		end = math.Max(end, g.X+g.W)
+	}
+	cells = append(cells, cell.String())
+
+	line := pdfLine{text: collapseSpace(text.String()), x: glyphs[0].X, y: glyphs[0].Y}
+	if line.text == "" {
+		return pdfLine{}, false
+	}
+	for _, c := range cells {
+		if c = collapseSpace(c); c != "" {
+			line.cells = append(line.cells, c)
+		}
+	}
+	best := 0
+	for size, n := range sizes {
+		if n > best || (n == best && size > line.size) {
+			line.size, best = size, n
+		}
+	}
+	return line, true
+}
+
+// plainLines reads a page as plain text lines, without positions.
+func plainLines(page pdf.Page) []pdfLine {
+	text, err := page.GetPlainText(nil)
+	if err != nil {
+		return nil
+	}
+	var lines []pdfLine
+	for _, l := range strings.Split(text, "\n") {
+		if l = collapseSpace(l); l != "" {
+			lines = append(lines, pdfLine{text: l, cells: []string{l}})
+		}
+	}
+	return lines
+}
+
+// bodyFontSize is the font size most text is set in.
+func bodyFontSize(pages [][]pdfLine) float64 {
+	counts := map[float64]int{}
+	for _, lines := range pages {
+		for _, l := range lines {
+			counts[l.size] += len(l.text)
+		}
+	}
+	body, best := 0.0, 0
+	for size, n := range counts {
+		if n > best || (n == best && size < body) {
+			body, best = size, n
+		}
+	}
+	return body
+}
+
+// headingLevels ranks the font sizes larger than the body text: the
+// largest is level 1.
+func headingLevels(pages [][]pdfLine, body float64) map[float64]int {
+	seen := map[float64]bool{}
+	for _, lines := range pages {
+		for _, l := range lines {
+			if isHeadingSize(l.size, body) && len(l.text) <= 200 {
+				seen[l.size] = true
+			}
+		}
+	}
+	sizes := make([]float64, 0, len(seen))
+	for size := range seen {
+		sizes = append(sizes, size)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+	levels := make(map[float64]int, len(sizes))
+	for i, size := range sizes {
+		levels[size] = min(i+1, 6)
+	}
+	return levels
+}
+
+func isHeadingSize(size, body float64) bool {
+	return body > 0 && size >= body*1.15
+}
+
+// outlineLevels maps the document outline's titles to their depth.
+func outlineLevels(reader *pdf.Reader) (levels map[string]int) {
+	levels = map[string]int{}
+	defer func() {
+		if recover() != nil {
+			levels = map[string]int{}
+		}
+	}()
+	var walk func(o pdf.Outline, depth int)
+	walk = func(o pdf.Outline, depth int) {
+		if title := normalizeTitle(o.Title); title != "" && depth > 0 {
+			if _, ok := levels[title]; !ok {
+				levels[title] = min(depth, 6)
+			}
+		}
+		for _, child := range o.Child {
+			walk(child, depth+1)
+		}
+	}
+	walk(reader.Outline(), 0)
+	return levels
+}
+
+func normalizeTitle(s string) string {
+	return strings.ToLower(collapseSpace(s))
+}
+
+// pdfInfo reads the document information dictionary.
+func pdfInfo(reader *pdf.Reader) (metadata map[string]string) {
+	metadata = map[string]string{}
+	defer func() {
+		if recover() != nil {
+			metadata = map[string]string{}
+		}
+	}()
+	info := reader.Trailer().Key("Info")
+	for key, name := range map[string]string{"Title": "title", "Author": "author", "Subject": "subject"} {
+		if v := strings.TrimSpace(info.Key(key).Text()); v != "" {
+			metadata[name] = v
+		}
+	}
+	return metadata
+}
+
+// pdfLayout types a page's lines into blocks.
+type pdfLayout struct {
+	body    float64
+	levels  map[float64]int
+	outline map[string]int
+}
+
+func (l pdfLayout) headingLevel(line pdfLine) int {
+	if level, ok := l.outline[normalizeTitle(line.text)]; ok {
+		return level
+	}
+	if len(line.text) <= 200 && isHeadingSize(line.size, l.body) {
+		return l.levels[line.size]
+	}
+	return 0
+}
+
+func (l pdfLayout) blocks(lines []pdfLine, page int) []Block {
+	var blocks []Block
+	margin := math.Inf(1)
+	for _, line := range lines {
+		margin = math.Min(margin, line.x)
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case pageNumber.MatchString(line.text):
+			i++
+
+		case l.headingLevel(line) > 0:
+			level := l.headingLevel(line)
+			text := line.text
+			j := i + 1
+			// A heading may wrap onto lines set the same way.
+			for j < len(lines) && lines[j].size == line.size && l.headingLevel(lines[j]) == level &&
+				l.follows(lines[j-1], lines[j]) {
+				text += " " + lines[j].text
+				j++
+			}
+			blocks = append(blocks, Block{Type: BlockHeading, Level: level, Text: text, Page: page})
+			i = j
+
+		case len(line.cells) > 1 && i+1 < len(lines) && len(lines[i+1].cells) > 1:
+			rows := [][]string{line.cells}
+			j := i + 1
+			for j < len(lines) && len(lines[j].cells) > 1 && l.follows(lines[j-1], lines[j]) {
+				rows = append(rows, lines[j].cells)
+				j++
+			}
+			if len(rows) < 2 {
+				blocks = append(blocks, Block{Type: BlockParagraph, Text: line.text, Page: page})
+				i++
+				continue
+			}
+			blocks = append(blocks, Block{Type: BlockTable, Rows: rows, Page: page})
+			i = j
+
+		case listMarker.MatchString(line.text):
+			text := listMarker.ReplaceAllString(line.text, "")
+			if marker := listMarker.FindString(line.text); !isBullet(marker) {
+				// Keep enumerators; they carry meaning ("Step 2").
+				text = strings.TrimSpace(marker) + " " + text
+			}
+			j := i + 1
+			for j < len(lines) && l.continues(lines[j-1], lines[j]) && !listMarker.MatchString(lines[j].text) &&
+				lines[j].x > line.x {
+				text = joinLines(text, lines[j].text)
+				j++
+			}
+			level := 1
+			if l.body > 0 && !math.IsInf(margin, 1) {
+				level = 1 + int((line.x-margin)/(l.body*1.5))
+			}
+			blocks = append(blocks, Block{Type: BlockListItem, Level: min(max(level, 1), 6), Text: text, Page: page})
+			i = j
+
+		default:
+			text := line.text
+			j := i + 1
+			for j < len(lines) && l.continues(lines[j-1], lines[j]) && !listMarker.MatchString(lines[j].text) &&
+				!(len(lines[j].cells) > 1 && j+1 < len(lines) && len(lines[j+1].cells) > 1) {
+				text = joinLines(text, lines[j].text)
+				j++
+			}
+			blocks = append(blocks, Block{Type: BlockParagraph, Text: text, Page: page})
+			i = j
+		}
+	}
+	return blocks
+}
+
+// follows reports whether next is the line right below prev.
+func (l pdfLayout) follows(prev, next pdfLine) bool {
+	if prev.size == 0 || next.size == 0 {
+		// Plain-text fallback lines have no positions.
+		return true
+	}
+	gap := prev.y - next.y
+	return gap > 0 && gap <= math.Max(prev.size, next.size)*1.8
+}
+
+// continues reports whether next continues prev's paragraph: directly
+// below it, set the same way, and not a heading or page number.
+func (l pdfLayout) continues(prev, next pdfLine) bool {
+	if !l.follows(prev, next) || l.headingLevel(next) > 0 || pageNumber.MatchString(next.text) {
+		return false
+	}
+	return math.Abs(prev.size-next.size) < 1
+}
+
+func isBullet(marker string) bool {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(marker))
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '('
+}
+
+// joinLines joins wrapped lines, rejoining words hyphenated at the break.
+func joinLines(a, b string) string {
+	if strings.HasSuffix(a, "-") && !strings.HasSuffix(a, " -") {
+		if r, _ := utf8.DecodeRuneInString(b); unicode.IsLower(r) {
+			return a[:len(a)-1] + b
+		}
+	}
+	return a + " " + b
+}
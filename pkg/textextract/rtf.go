@@ -0,0 +1,213 @@
+package textextract
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// rtfSkipDestinations are groups whose content is never text.
+var rtfSkipDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "listtable": true,
+	"listoverridetable": true, "revtbl": true, "rsidtbl": true, "generator": true,
+	"pict": true, "object": true, "themedata": true, "colorschememapping": true,
+	"datastore": true, "latentstyles": true, "xmlnstbl": true, "fldinst": true,
+	"header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true,
+	"footnote": true, "filetbl": true, "pgdsctbl": true, "bkmkstart": true,
+	"bkmkend": true, "mmathPr": true,
+}
+
+// rtfInfoFields are the document properties read from the info group.
+var rtfInfoFields = map[string]string{
+	"title": "title", "author": "author", "subject": "subject", "keywords": "keywords",
+}
+
+// rtfSymbols are control words that stand for a character.
+var rtfSymbols = map[string]string{
+	"tab": "\t", "emdash": "—", "endash": "–", "bullet": "•", "lquote": "‘",
+	"rquote": "’", "ldblquote": "“", "rdblquote": "”", "emspace": " ", "enspace": " ",
+}
+
+// rtfState is the formatting state a group inherits and restores.
+type rtfState struct {
+	skip bool   // content is dropped
+	dest string // info field being read, if any
+	uc   int    // fallback characters after a \u character
+}
+
+// extractRTF extracts RTF text paragraph by paragraph. Paragraphs with an
+// outline level are headings and start sections; table cells are joined
+// with " | ".
+func extractRTF(data io.ReaderAt, size int64) (*ExtractedText, error) {
+	buf, err := readAll(data, size)
+	if err != nil {
+		return nil, fmt.Errorf("read RTF: %w", err)
+	}
+	src := string(buf)
+
+	var (
+		sections   []Section
+		current    = Section{Kind: SectionText}
+		paragraphs []string
+		para       strings.Builder
+		outline    = -1 // outline level of the current paragraph
+		state      = rtfState{uc: 1}
+		stack      []rtfState
+		fallback   int // \u fallback characters still to skip
+		metadata   = map[string]string{}
+		field      strings.Builder
+	)
+	emit := func(s string) {
+		switch {
+		case fallback > 0:
+			fallback--
+		case state.dest != "":
+			field.WriteString(s)
+		case !state.skip:
+			para.WriteString(s)
+		}
+	}
+	endParagraph := func() {
+		// Table rows end with a cell mark.
+		text := strings.TrimSuffix(collapseSpace(para.String()), " |")
+		para.Reset()
+		if text == "" {
+			return
+		}
+		if outline >= 0 {
+			current.Content = strings.Join(paragraphs, "\n\n")
+			if current.Title != "" || current.Content != "" {
+				sections = append(sections, current)
+			}
+			current = Section{Kind: SectionHeading, Title: text, Level: min(outline+1, 6)}
+			paragraphs = nil
+			return
+		}
+		paragraphs = append(paragraphs, text)
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch c {
+		case '{':
+			stack = append(stack, state)
+			if strings.HasPrefix(src[i+1:], "\\*") {
+				state.skip = true
+			}
+		case '}':
+			if state.dest != "" && (len(stack) == 0 || stack[len(stack)-1].dest != state.dest) {
+				if v := strings.TrimSpace(field.String()); v != "" {
+					metadata[state.dest] = v
+				}
+				field.Reset()
+			}
+			if len(stack) > 0 {
+				state = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+		case '\r', '\n':
+		case '\\':
+			if i+1 >= len(src) {
+				break
+			}
+			next := src[i+1]
+			switch {
+			case next == '\'' && i+3 < len(src):
+				if b, err := strconv.ParseUint(src[i+2:i+4], 16, 8); err == nil {
+					emit(decodeWindows1252([]byte{byte(b)}))
+				}
+				i += 3
+			case next == '{' || next == '}' || next == '\\':
+				emit(string(next))
+				i++
+			case next == '~':
+				emit(" ")
+				i++
+			case next == '_':
+				emit("-")
+				i++
+			case next == '\r' || next == '\n':
+				if !state.skip {
+					endParagraph()
+				}
+				i++
+			case isASCIILetter(next):
+				j := i + 1
+				for j < len(src) && isASCIILetter(src[j]) {
+					j++
+				}
+				word := src[i+1 : j]
+				k := j
+				if k < len(src) && src[k] == '-' {
+					k++
+				}
+				for k < len(src) && src[k] >= '0' && src[k] <= '9' {
+					k++
+				}
+				param, hasParam := 0, k > j
+				if hasParam {
+					param, _ = strconv.Atoi(src[j:k])
+				}
+				if k < len(src) && src[k] == ' ' {
+					k++
+				}
+				i = k - 1
+
+				switch {
+				case rtfSkipDestinations[word]:
+					state.skip = true
+				case word == "info":
+					state.skip = true
+				case rtfInfoFields[word] != "" && len(stack) > 1 && stack[len(stack)-1].skip:
+					state.dest = rtfInfoFields[word]
+				case word == "u" && hasParam:
+					if param < 0 {
+						param += 65536
+					}
+					emit(string(rune(param)))
+					fallback = state.uc
+				case word == "uc" && hasParam:
+					state.uc = param
+				case word == "par" || word == "sect" || word == "page" || word == "row":
+					if !state.skip {
+						endParagraph()
+					}
+				case word == "line":
+					emit("\n")
+				case word == "cell" || word == "nestcell":
+					emit(" | ")
+				case word == "pard":
+					outline = -1
+				case word == "outlinelevel" && hasParam:
+					outline = param
+				case rtfSymbols[word] != "":
+					emit(rtfSymbols[word])
+				}
+			default:
+				i++
+			}
+		default:
+			// RTF is 7-bit, but some writers embed UTF-8 directly.
+			r, n := utf8.DecodeRuneInString(src[i:])
+			if r == utf8.RuneError {
+				r, n = rune(c), 1
+			}
+			emit(string(r))
+			i += n - 1
+		}
+	}
+	endParagraph()
+	current.Content = strings.Join(paragraphs, "\n\n")
+	if current.Title != "" || current.Content != "" {
+		sections = append(sections, current)
+	}
+
+	return structured(sections, metadata), nil
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}